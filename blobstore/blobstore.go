@@ -0,0 +1,48 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// BlobStore holds files clients upload directly with signed urls, e.g. post
+// option images and avatars. Keys are slash separated paths like
+// post-options/<uuid>.png.
+type BlobStore interface {
+	// Bucket names the store, clients send it back with the keys they upload to
+	Bucket() string
+	// SignedPutURL returns a url the client can PUT the object to until
+	// expires. The upload must be sent with the same Content-Type.
+	SignedPutURL(ctx context.Context, key string, contentType string, expires time.Time) (string, error)
+	// SignedGetURL returns a url anyone can GET the object from until expires.
+	SignedGetURL(ctx context.Context, key string, expires time.Time) (string, error)
+	// Stat returns ErrNotExist if nothing has been uploaded to the key.
+	Stat(ctx context.Context, key string) (*Attrs, error)
+	// Delete returns ErrNotExist if nothing has been uploaded to the key.
+	Delete(ctx context.Context, key string) error
+	// Copy overwrites anything at dstKey, and returns ErrNotExist if nothing
+	// has been uploaded to srcKey.
+	Copy(ctx context.Context, srcKey string, dstKey string) error
+	// Open returns ErrNotExist if nothing has been uploaded to the key. The
+	// caller has to close the reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put writes the object from the server side, overwriting anything at the
+	// key.
+	Put(ctx context.Context, key string, contentType string, r io.Reader) error
+	// List returns every object with a key starting with prefix, ordered by
+	// key.
+	List(ctx context.Context, prefix string) ([]Attrs, error)
+}
+
+type Attrs struct {
+	Key         string
+	Size        int64
+	ContentType string
+	CreatedAt   time.Time
+}
+
+var ErrNotExist = errors.New("object does not exist")
+
+var ErrInvalidKey = errors.New("object key must be a relative path without ..")
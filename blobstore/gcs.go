@@ -0,0 +1,142 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// NewGCS returns a BlobStore backed by a Google Cloud Storage bucket. Signing
+// urls needs credentials for a service account, which are picked up from the
+// environment by the storage client.
+func NewGCS(client *storage.Client, bucketName string) BlobStore {
+	return &gcs{
+		bucket:     client.Bucket(bucketName),
+		bucketName: bucketName,
+	}
+}
+
+type gcs struct {
+	bucket     *storage.BucketHandle
+	bucketName string
+}
+
+func (g *gcs) Bucket() string {
+	return g.bucketName
+}
+
+func (g *gcs) SignedPutURL(
+	ctx context.Context, key string, contentType string, expires time.Time,
+) (string, error) {
+	url, err := g.bucket.SignedURL(key, &storage.SignedURLOptions{
+		Method:      "PUT",
+		Expires:     expires,
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing url: %w", err)
+	}
+	return url, nil
+}
+
+func (g *gcs) SignedGetURL(
+	ctx context.Context, key string, expires time.Time,
+) (string, error) {
+	url, err := g.bucket.SignedURL(key, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: expires,
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing url: %w", err)
+	}
+	return url, nil
+}
+
+func (g *gcs) Stat(ctx context.Context, key string) (*Attrs, error) {
+	attrs, err := g.bucket.Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("getting object attrs: %w", err)
+	}
+	return &Attrs{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		CreatedAt:   attrs.Created,
+	}, nil
+}
+
+func (g *gcs) Delete(ctx context.Context, key string) error {
+	if err := g.bucket.Object(key).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("deleting object: %w", err)
+	}
+	return nil
+}
+
+func (g *gcs) Copy(ctx context.Context, srcKey string, dstKey string) error {
+	src := g.bucket.Object(srcKey)
+	if _, err := g.bucket.Object(dstKey).CopierFrom(src).Run(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("copying object: %w", err)
+	}
+	return nil
+}
+
+func (g *gcs) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := g.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("opening object: %w", err)
+	}
+	return r, nil
+}
+
+func (g *gcs) Put(
+	ctx context.Context, key string, contentType string, r io.Reader,
+) error {
+	w := g.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("writing object: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing object writer: %w", err)
+	}
+	return nil
+}
+
+func (g *gcs) List(ctx context.Context, prefix string) ([]Attrs, error) {
+	res := []Attrs{}
+	it := g.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing objects: %w", err)
+		}
+		res = append(res, Attrs{
+			Key:         attrs.Name,
+			Size:        attrs.Size,
+			ContentType: attrs.ContentType,
+			CreatedAt:   attrs.Created,
+		})
+	}
+	return res, nil
+}
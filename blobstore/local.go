@@ -0,0 +1,339 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalPathPrefix is where Local.Handler expects to be mounted.
+const LocalPathPrefix = "/blobs/"
+
+// Uploads to Local.Handler larger than this are rejected. Big enough for any
+// file the services accept.
+const maxLocalUploadSize = 256 << 20
+
+// NewLocal returns a BlobStore that keeps objects under dir, for running
+// without GCP credentials. Signed urls point at Local.Handler, which has to
+// be served at LocalPathPrefix of baseURL. Urls are signed with a random key,
+// so they stop working after a restart.
+func NewLocal(dir string, bucketName string, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating dir: %w", err)
+	}
+	signingKey := make([]byte, 32)
+	if _, err := rand.Read(signingKey); err != nil {
+		return nil, fmt.Errorf("generating signing key: %w", err)
+	}
+	return &Local{
+		dir:           dir,
+		bucketName:    bucketName,
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		signingKey:    signingKey,
+		maxUploadSize: maxLocalUploadSize,
+	}, nil
+}
+
+type Local struct {
+	dir           string
+	bucketName    string
+	baseURL       string
+	signingKey    []byte
+	maxUploadSize int64
+}
+
+func (l *Local) Bucket() string {
+	return l.bucketName
+}
+
+func (l *Local) SignedPutURL(
+	ctx context.Context, key string, contentType string, expires time.Time,
+) (string, error) {
+	return l.signedURL(http.MethodPut, key, contentType, expires)
+}
+
+func (l *Local) SignedGetURL(
+	ctx context.Context, key string, expires time.Time,
+) (string, error) {
+	return l.signedURL(http.MethodGet, key, "", expires)
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*Attrs, error) {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("stating file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, ErrNotExist
+	}
+	return &Attrs{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		CreatedAt:   info.ModTime(),
+	}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(filePath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("removing file: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Copy(ctx context.Context, srcKey string, dstKey string) error {
+	srcPath, err := l.filePath(srcKey)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer src.Close()
+	return l.write(dstKey, src)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	return file, nil
+}
+
+// Put ignores the content type, which is worked out from the key's extension
+// when the object is served.
+func (l *Local) Put(
+	ctx context.Context, key string, contentType string, r io.Reader,
+) error {
+	return l.write(key, r)
+}
+
+// List skips the temp files of uploads that are still being written.
+func (l *Local) List(ctx context.Context, prefix string) ([]Attrs, error) {
+	res := []Attrs{}
+	dir := path.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		dir = strings.TrimSuffix(prefix, "/")
+	}
+	if !filepath.IsLocal(filepath.FromSlash(dir)) {
+		return nil, ErrInvalidKey
+	}
+	root := filepath.Join(l.dir, filepath.FromSlash(dir))
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		res = append(res, Attrs{
+			Key:         key,
+			Size:        info.Size(),
+			ContentType: mime.TypeByExtension(path.Ext(key)),
+			CreatedAt:   info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking dir: %w", err)
+	}
+	return res, nil
+}
+
+// Handler serves the urls signed by the store: PUT to upload and GET to
+// download. It has to be mounted at LocalPathPrefix.
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, LocalPathPrefix)
+		contentType := ""
+		if r.Method == http.MethodPut {
+			contentType = r.Header.Get("Content-Type")
+		}
+		if !l.validSignature(r.Method, key, contentType, r.URL.Query()) {
+			http.Error(w, "signature is invalid or has expired", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			body := http.MaxBytesReader(w, r.Body, l.maxUploadSize)
+			if err := l.write(key, body); err != nil {
+				if errors.Is(err, ErrInvalidKey) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				maxBytesErr := &http.MaxBytesError{}
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, "upload is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				log.Printf("writing blob %s: %v", key, err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			filePath, err := l.filePath(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			file, err := os.Open(filePath)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer file.Close()
+			info, err := file.Stat()
+			if err != nil || info.IsDir() {
+				http.NotFound(w, r)
+				return
+			}
+			// Served from the API's origin, so uploads like SVGs mustn't be
+			// able to run script
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+		}
+	})
+}
+
+func (l *Local) signedURL(
+	method string, key string, contentType string, expires time.Time,
+) (string, error) {
+	if _, err := l.filePath(key); err != nil {
+		return "", err
+	}
+	expiresAt := strconv.FormatInt(expires.Unix(), 10)
+	queryParams := url.Values{}
+	queryParams.Set("expires", expiresAt)
+	queryParams.Set("signature", l.signature(method, key, contentType, expiresAt))
+	return fmt.Sprintf(
+		"%s%s%s?%s", l.baseURL, LocalPathPrefix, key, queryParams.Encode(),
+	), nil
+}
+
+func (l *Local) signature(
+	method string, key string, contentType string, expiresAt string,
+) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(strings.Join([]string{method, key, contentType, expiresAt}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (l *Local) validSignature(
+	method string, key string, contentType string, query url.Values,
+) bool {
+	expiresAt := query.Get("expires")
+	expires, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	expected := l.signature(method, key, contentType, expiresAt)
+	return hmac.Equal([]byte(expected), []byte(query.Get("signature")))
+}
+
+// filePath maps the key to a path under the store's dir, rejecting keys that
+// would escape it.
+func (l *Local) filePath(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(name) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.dir, name), nil
+}
+
+// write saves to a temp file first so a failed upload doesn't leave a
+// partial object behind.
+func (l *Local) write(key string, r io.Reader) error {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("creating dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(t.TempDir(), "test-bucket", "http://blobs.test/")
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	return l
+}
+
+// do sends the request to the store's handler the way a client with the url
+// would.
+func do(l *Local, method string, signedURL string, contentType string, body string) *http.Response {
+	req := httptest.NewRequest(method, signedURL, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestLocalSignedURLs(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	expires := time.Now().Add(time.Minute)
+
+	putURL, err := l.SignedPutURL(ctx, "avatars/a.png", "image/png", expires)
+	if err != nil {
+		t.Fatalf("signing put url: %v", err)
+	}
+	if !strings.HasPrefix(putURL, "http://blobs.test/blobs/avatars/a.png?") {
+		t.Errorf("got put url %s", putURL)
+	}
+	if res := do(l, http.MethodPut, putURL, "image/png", "png data"); res.StatusCode != http.StatusOK {
+		t.Fatalf("got put status %v", res.StatusCode)
+	}
+
+	attrs, err := l.Stat(ctx, "avatars/a.png")
+	if err != nil {
+		t.Fatalf("stating: %v", err)
+	}
+	if attrs.Size != int64(len("png data")) || attrs.ContentType != "image/png" {
+		t.Errorf("got attrs %+v", attrs)
+	}
+
+	getURL, err := l.SignedGetURL(ctx, "avatars/a.png", expires)
+	if err != nil {
+		t.Fatalf("signing get url: %v", err)
+	}
+	res := do(l, http.MethodGet, getURL, "", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got get status %v", res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "png data" {
+		t.Errorf("got body %q", body)
+	}
+	if res.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("served without nosniff")
+	}
+}
+
+func TestLocalRejectsInvalidSignatures(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	if err := l.Put(ctx, "avatars/a.png", "image/png", strings.NewReader("png data")); err != nil {
+		t.Fatalf("putting: %v", err)
+	}
+	expires := time.Now().Add(time.Minute)
+	getURL, err := l.SignedGetURL(ctx, "avatars/a.png", expires)
+	if err != nil {
+		t.Fatalf("signing get url: %v", err)
+	}
+	putURL, err := l.SignedPutURL(ctx, "avatars/a.png", "image/png", expires)
+	if err != nil {
+		t.Fatalf("signing put url: %v", err)
+	}
+	expiredURL, err := l.SignedGetURL(ctx, "avatars/a.png", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("signing expired url: %v", err)
+	}
+
+	tamper := func(signedURL string, change func(u *url.URL, query url.Values)) string {
+		u, _ := url.Parse(signedURL)
+		query := u.Query()
+		change(u, query)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	otherStore := newTestLocal(t)
+	otherStoreURL, err := otherStore.SignedGetURL(ctx, "avatars/a.png", expires)
+	if err != nil {
+		t.Fatalf("signing url: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+	}{
+		{"expired", http.MethodGet, expiredURL, ""},
+		{"later expiry", http.MethodGet, tamper(getURL, func(u *url.URL, query url.Values) {
+			query.Set("expires", "99999999999")
+		}), ""},
+		{"other key", http.MethodGet, tamper(getURL, func(u *url.URL, query url.Values) {
+			u.Path = "/blobs/avatars/b.png"
+		}), ""},
+		{"changed signature", http.MethodGet, tamper(getURL, func(u *url.URL, query url.Values) {
+			query.Set("signature", strings.ToUpper(query.Get("signature")))
+		}), ""},
+		{"no signature", http.MethodGet, tamper(getURL, func(u *url.URL, query url.Values) {
+			query.Del("signature")
+		}), ""},
+		{"signed by another store", http.MethodGet, otherStoreURL, ""},
+		{"put with a get url", http.MethodPut, getURL, "image/png"},
+		{"get with a put url", http.MethodGet, putURL, ""},
+		{"put with another content type", http.MethodPut, putURL, "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := do(l, tt.method, tt.url, tt.contentType, "<script>alert(1)</script>")
+			if res.StatusCode != http.StatusForbidden {
+				t.Errorf("got status %v, want %v", res.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+
+	r, err := l.Open(ctx, "avatars/a.png")
+	if err != nil {
+		t.Fatalf("opening: %v", err)
+	}
+	defer r.Close()
+	if body, _ := io.ReadAll(r); string(body) != "png data" {
+		t.Errorf("object was overwritten with %q", body)
+	}
+}
+
+func TestLocalRejectsKeysOutsideDir(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	for _, key := range []string{"", "../a.png", "avatars/../../a.png", "/etc/passwd"} {
+		if _, err := l.SignedPutURL(ctx, key, "image/png", time.Now().Add(time.Minute)); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("signing put url for %q: got %v, want ErrInvalidKey", key, err)
+		}
+		if err := l.Put(ctx, key, "image/png", strings.NewReader("data")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("putting %q: got %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("stating %q: got %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("opening %q: got %v, want ErrInvalidKey", key, err)
+		}
+		if err := l.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("deleting %q: got %v, want ErrInvalidKey", key, err)
+		}
+	}
+	if _, err := l.List(ctx, "../"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("listing ../: got %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	for _, key := range []string{
+		"post-options/b.png",
+		"post-options/a.png",
+		"post-option-renditions/a/full.jpg",
+		"avatars/a.png",
+	} {
+		if err := l.Put(ctx, key, "", strings.NewReader("data")); err != nil {
+			t.Fatalf("putting %s: %v", key, err)
+		}
+	}
+	// An upload still being written
+	if err := os.WriteFile(
+		filepath.Join(l.dir, "post-options", ".upload-123"), []byte("data"), 0o644,
+	); err != nil {
+		t.Fatalf("writing temp file: %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"post-options/", []string{"post-options/a.png", "post-options/b.png"}},
+		{"post-option", []string{
+			"post-option-renditions/a/full.jpg", "post-options/a.png", "post-options/b.png",
+		}},
+		{"post-options/a", []string{"post-options/a.png"}},
+		{"post-option-renditions/a/", []string{"post-option-renditions/a/full.jpg"}},
+		{"missing/", []string{}},
+		{"", []string{
+			"avatars/a.png", "post-option-renditions/a/full.jpg",
+			"post-options/a.png", "post-options/b.png",
+		}},
+	}
+	for _, tt := range tests {
+		objects, err := l.List(ctx, tt.prefix)
+		if err != nil {
+			t.Fatalf("listing %q: %v", tt.prefix, err)
+		}
+		got := []string{}
+		for _, o := range objects {
+			got = append(got, o.Key)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("listing %q: got %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestLocalLimitsUploadSize(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	l.maxUploadSize = 8
+
+	putURL, err := l.SignedPutURL(ctx, "avatars/a.png", "image/png", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("signing put url: %v", err)
+	}
+	res := do(l, http.MethodPut, putURL, "image/png", "more than eight bytes")
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %v, want %v", res.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	if _, err = l.Stat(ctx, "avatars/a.png"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("got %v, want the upload to not be saved", err)
+	}
+	if res = do(l, http.MethodPut, putURL, "image/png", "8 bytes!"); res.StatusCode != http.StatusOK {
+		t.Errorf("got status %v for an upload at the limit", res.StatusCode)
+	}
+}
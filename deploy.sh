@@ -19,7 +19,7 @@ fi
 
 gcloud run deploy three-tier-app-api \
     --image australia-southeast1-docker.pkg.dev/trusty-charmer-415303/quorum/quorum-api:$new_tag \
-    --update-secrets=JWT_SECRET=JWT_SECRET:latest,MJ_API_KEY=MJ_API_KEY:latest,MJ_SECRET_KEY=MJ_SECRET_KEY:latest \
+    --update-secrets=JWT_SIGNING_KEY=JWT_SIGNING_KEY:latest,MJ_API_KEY=MJ_API_KEY:latest,MJ_SECRET_KEY=MJ_SECRET_KEY:latest \
     --set-env-vars $env_vars_string \
     --region australia-southeast1
 
@@ -2,53 +2,153 @@ package graph
 
 import (
 	"context"
+	"log"
+	"net"
 	"net/http"
+	"quorum-api/keyring"
+	srvcustomer "quorum-api/services/customer"
 	"strings"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 type JWTClaims struct {
 	IsVerified bool `json:"is_verified"`
+	// Session the token was issued for. Tokens without a session can't be
+	// revoked, so they're not accepted.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type authCtxKey struct{}
 
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+type authInfo struct {
+	CustomerID uuid.UUID
+	SessionID  uuid.UUID
+}
+
+type clientCtxKey struct{}
+
+// ClientInfo describes where a request came from, recorded against sessions.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+func AuthMiddleware(keys *keyring.KeyRing, customers srvcustomer.SRVCustomer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientCtxKey{}, clientInfoFromRequest(r))
 			tokenString, _ := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
-			token, err := jwt.ParseWithClaims(
-				tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-					return []byte(jwtSecret), nil
-				})
-
-			// Allow unauthenticated users in
-			if err != nil {
-				next.ServeHTTP(w, r)
-				return
-			}
-			if claims, ok := token.Claims.(*JWTClaims); ok && claims.IsVerified {
-				userID, err := uuid.Parse(claims.Subject)
-				if err == nil {
-					ctx := context.WithValue(r.Context(), authCtxKey{}, userID)
-					r = r.WithContext(ctx)
-				}
-			}
+			r = r.WithContext(withVerifiedCustomer(ctx, keys, customers, tokenString))
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// WebsocketInitFunc authenticates subscriptions. Browsers can't set headers on
+// websocket connections, so the token is sent in the connection_init payload
+// instead.
+func WebsocketInitFunc(keys *keyring.KeyRing, customers srvcustomer.SRVCustomer) transport.WebsocketInitFunc {
+	return func(
+		ctx context.Context, initPayload transport.InitPayload,
+	) (context.Context, *transport.InitPayload, error) {
+		tokenString, _ := strings.CutPrefix(initPayload.Authorization(), "Bearer ")
+		return withVerifiedCustomer(ctx, keys, customers, tokenString), &initPayload, nil
+	}
+}
+
+// withVerifiedCustomer adds the customer to the context if the token is valid,
+// verified and its session hasn't been revoked. Unauthenticated customers are
+// allowed through.
+func withVerifiedCustomer(
+	ctx context.Context,
+	keys *keyring.KeyRing,
+	customers srvcustomer.SRVCustomer,
+	tokenString string,
+) context.Context {
+	if tokenString == "" {
+		return ctx
+	}
+	token, err := keys.Parse(tokenString, &JWTClaims{})
+	if err != nil {
+		return ctx
+	}
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !claims.IsVerified {
+		return ctx
+	}
+	customerID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return ctx
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return ctx
+	}
+
+	client := GetClientInfo(ctx)
+	if err = customers.TouchSession(ctx, srvcustomer.TouchSessionRequest{
+		ID:         sessionID,
+		CustomerID: customerID,
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+	}); err != nil {
+		if err != srvcustomer.ErrSessionRevoked {
+			log.Printf("touching session %s: %v", sessionID, err)
+		}
+		return ctx
+	}
+
+	return context.WithValue(ctx, authCtxKey{}, authInfo{
+		CustomerID: customerID,
+		SessionID:  sessionID,
+	})
+}
+
+func clientInfoFromRequest(r *http.Request) ClientInfo {
+	// Cloud run appends the client it saw to X-Forwarded-For, anything
+	// before that was sent by the client and can't be trusted
+	ipAddress := ""
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		forwardedFor := values[len(values)-1]
+		ipAddress = strings.TrimSpace(forwardedFor[strings.LastIndex(forwardedFor, ",")+1:])
+	}
+	if ipAddress == "" {
+		ipAddress, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	return ClientInfo{
+		IPAddress: ipAddress,
+		UserAgent: r.UserAgent(),
+	}
+}
+
 func GetVerifiedCustomer(ctx context.Context) uuid.NullUUID {
-	raw, ok := ctx.Value(authCtxKey{}).(uuid.UUID)
+	raw, ok := ctx.Value(authCtxKey{}).(authInfo)
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{
+		Valid: true,
+		UUID:  raw.CustomerID,
+	}
+}
+
+// GetSessionID returns the session of the verified customer's token.
+func GetSessionID(ctx context.Context) uuid.NullUUID {
+	raw, ok := ctx.Value(authCtxKey{}).(authInfo)
 	if !ok {
 		return uuid.NullUUID{}
 	}
 	return uuid.NullUUID{
 		Valid: true,
-		UUID:  raw,
+		UUID:  raw.SessionID,
 	}
 }
+
+func GetClientInfo(ctx context.Context) ClientInfo {
+	client, _ := ctx.Value(clientCtxKey{}).(ClientInfo)
+	return client
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInfoFromRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		forwardedFor []string
+		ipAddress    string
+	}{
+		{
+			name:      "not forwarded",
+			ipAddress: "192.0.2.1",
+		},
+		{
+			name:         "forwarded",
+			forwardedFor: []string{"203.0.113.7"},
+			ipAddress:    "203.0.113.7",
+		},
+		{
+			name:         "spoofed by the client",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7"},
+			ipAddress:    "203.0.113.7",
+		},
+		{
+			name:         "spoofed in another header",
+			forwardedFor: []string{"198.51.100.1", "198.51.100.2,203.0.113.7"},
+			ipAddress:    "203.0.113.7",
+		},
+		{
+			name:         "empty",
+			forwardedFor: []string{""},
+			ipAddress:    "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/graphql", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("User-Agent", "test")
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			info := clientInfoFromRequest(r)
+			if info.IPAddress != tt.ipAddress {
+				t.Errorf("got %q, want %q", info.IPAddress, tt.ipAddress)
+			}
+			if info.UserAgent != "test" {
+				t.Errorf("got user agent %q", info.UserAgent)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package graph
 import (
 	"context"
 	"net/http"
+	srvcomment "quorum-api/services/comment"
 	srvcustomer "quorum-api/services/customer"
 	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/google/uuid"
 	"github.com/vikstrous/dataloadgen"
 )
@@ -22,6 +25,26 @@ type Loaders struct {
 	PostLoader       *dataloadgen.Loader[uuid.UUID, *srvpost.Post]
 	PostOptionLoader *dataloadgen.Loader[uuid.UUID, *srvpost.Option]
 	PostVoteLoader   *dataloadgen.Loader[uuid.UUID, *srvpost.Vote]
+	// Keyed by post ID
+	PostResultsLoader *dataloadgen.Loader[uuid.UUID, *srvpost.Results]
+	// Keyed by post ID
+	PostFeedbackLoader *dataloadgen.Loader[uuid.UUID, *srvpost.Feedback]
+	// Keyed by post ID
+	PostCriteriaLoader *dataloadgen.Loader[uuid.UUID, []*srvpost.Criterion]
+	// Keyed by post ID
+	PostCriterionResultsLoader *dataloadgen.Loader[uuid.UUID, []*srvpost.CriterionResult]
+	CommentLoader              *dataloadgen.Loader[uuid.UUID, *srvcomment.Comment]
+	// Top level comments on a post (not about a specific option), keyed by post ID
+	PostCommentsLoader *dataloadgen.Loader[uuid.UUID, []*srvcomment.Comment]
+	// Top level comments on an option, keyed by option ID
+	PostOptionCommentsLoader *dataloadgen.Loader[uuid.UUID, []*srvcomment.Comment]
+	// Keyed by parent comment ID
+	CommentRepliesLoader *dataloadgen.Loader[uuid.UUID, []*srvcomment.Comment]
+	WorkspaceLoader      *dataloadgen.Loader[uuid.UUID, *srvworkspace.Workspace]
+	// Keyed by workspace ID
+	WorkspaceMembersLoader *dataloadgen.Loader[uuid.UUID, []*srvworkspace.Member]
+	// The workspaces a customer is a member of, keyed by customer ID
+	CustomerMembershipsLoader *dataloadgen.Loader[uuid.UUID, []*srvworkspace.Member]
 }
 
 type getters struct {
@@ -43,6 +66,39 @@ func NewLoaders(services Services) *Loaders {
 		PostVoteLoader: dataloadgen.NewLoader(
 			getters.getPostVotes, dataloadgen.WithWait(time.Millisecond),
 		),
+		PostResultsLoader: dataloadgen.NewLoader(
+			getters.getPostResults, dataloadgen.WithWait(time.Millisecond),
+		),
+		PostFeedbackLoader: dataloadgen.NewLoader(
+			getters.getPostFeedback, dataloadgen.WithWait(time.Millisecond),
+		),
+		PostCriteriaLoader: dataloadgen.NewLoader(
+			getters.getPostCriteria, dataloadgen.WithWait(time.Millisecond),
+		),
+		PostCriterionResultsLoader: dataloadgen.NewLoader(
+			getters.getPostCriterionResults, dataloadgen.WithWait(time.Millisecond),
+		),
+		CommentLoader: dataloadgen.NewLoader(
+			getters.getComments, dataloadgen.WithWait(time.Millisecond),
+		),
+		PostCommentsLoader: dataloadgen.NewLoader(
+			getters.getPostComments, dataloadgen.WithWait(time.Millisecond),
+		),
+		PostOptionCommentsLoader: dataloadgen.NewLoader(
+			getters.getPostOptionComments, dataloadgen.WithWait(time.Millisecond),
+		),
+		CommentRepliesLoader: dataloadgen.NewLoader(
+			getters.getCommentReplies, dataloadgen.WithWait(time.Millisecond),
+		),
+		WorkspaceLoader: dataloadgen.NewLoader(
+			getters.getWorkspaces, dataloadgen.WithWait(time.Millisecond),
+		),
+		WorkspaceMembersLoader: dataloadgen.NewLoader(
+			getters.getWorkspaceMembers, dataloadgen.WithWait(time.Millisecond),
+		),
+		CustomerMembershipsLoader: dataloadgen.NewLoader(
+			getters.getCustomerMemberships, dataloadgen.WithWait(time.Millisecond),
+		),
 	}
 }
 
@@ -55,6 +111,16 @@ func LoadersMiddleware(services Services, next http.Handler) http.Handler {
 	})
 }
 
+// LoadersResponseMiddleware gives each response its own loaders. Subscription
+// events are resolved with the websocket connection's context, so would
+// otherwise be served stale data cached when the connection was opened.
+func LoadersResponseMiddleware(services Services) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		loader := NewLoaders(services)
+		return next(context.WithValue(ctx, loadersCtxKey{}, loader))
+	}
+}
+
 func (g *getters) getCustomers(
 	ctx context.Context, ids []uuid.UUID,
 ) ([]*srvcustomer.Customer, []error) {
@@ -142,3 +208,250 @@ func (g *getters) getPosts(
 	}
 	return result, nil
 }
+
+func (g *getters) getPostResults(
+	ctx context.Context, postIDs []uuid.UUID,
+) ([]*srvpost.Results, []error) {
+	results, err := g.services.Post.GetResultsByFilter(
+		ctx, srvpost.GetResultsByFilterRequest{
+			PostIDs: postIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	rMap := map[uuid.UUID]*srvpost.Results{}
+	for _, r := range results {
+		rMap[r.PostID] = &r
+	}
+	result := []*srvpost.Results{}
+	for _, id := range postIDs {
+		result = append(result, rMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getPostFeedback(
+	ctx context.Context, postIDs []uuid.UUID,
+) ([]*srvpost.Feedback, []error) {
+	feedback, err := g.services.Post.GetFeedbackByFilter(
+		ctx, srvpost.GetFeedbackByFilterRequest{
+			PostIDs: postIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	fMap := map[uuid.UUID]*srvpost.Feedback{}
+	for _, f := range feedback {
+		fMap[f.PostID] = &f
+	}
+	result := []*srvpost.Feedback{}
+	for _, id := range postIDs {
+		result = append(result, fMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getPostCriteria(
+	ctx context.Context, postIDs []uuid.UUID,
+) ([][]*srvpost.Criterion, []error) {
+	criteria, err := g.services.Post.GetCriteriaByFilter(
+		ctx, srvpost.GetCriteriaByFilterRequest{
+			PostIDs: postIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	cMap := map[uuid.UUID][]*srvpost.Criterion{}
+	for _, c := range criteria {
+		cMap[c.PostID] = append(cMap[c.PostID], &c)
+	}
+	result := [][]*srvpost.Criterion{}
+	for _, id := range postIDs {
+		result = append(result, cMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getPostCriterionResults(
+	ctx context.Context, postIDs []uuid.UUID,
+) ([][]*srvpost.CriterionResult, []error) {
+	results, err := g.services.Post.GetCriterionResultsByFilter(
+		ctx, srvpost.GetCriterionResultsByFilterRequest{
+			PostIDs: postIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	rMap := map[uuid.UUID][]*srvpost.CriterionResult{}
+	for _, r := range results {
+		rMap[r.PostID] = append(rMap[r.PostID], &r)
+	}
+	result := [][]*srvpost.CriterionResult{}
+	for _, id := range postIDs {
+		result = append(result, rMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getComments(
+	ctx context.Context, ids []uuid.UUID,
+) ([]*srvcomment.Comment, []error) {
+	comments, err := g.services.Comment.GetCommentsByFilter(
+		ctx, srvcomment.GetCommentsByFilterRequest{
+			IDs: ids,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	cMap := map[uuid.UUID]*srvcomment.Comment{}
+	for _, c := range comments {
+		cMap[c.ID] = &c
+	}
+	result := []*srvcomment.Comment{}
+	for _, id := range ids {
+		result = append(result, cMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getPostComments(
+	ctx context.Context, postIDs []uuid.UUID,
+) ([][]*srvcomment.Comment, []error) {
+	comments, err := g.services.Comment.GetCommentsByFilter(
+		ctx, srvcomment.GetCommentsByFilterRequest{
+			PostIDs:      postIDs,
+			TopLevelOnly: true,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	cMap := map[uuid.UUID][]*srvcomment.Comment{}
+	for _, c := range comments {
+		if c.OptionID != nil {
+			continue
+		}
+		cMap[c.PostID] = append(cMap[c.PostID], &c)
+	}
+	result := [][]*srvcomment.Comment{}
+	for _, id := range postIDs {
+		result = append(result, cMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getPostOptionComments(
+	ctx context.Context, optionIDs []uuid.UUID,
+) ([][]*srvcomment.Comment, []error) {
+	comments, err := g.services.Comment.GetCommentsByFilter(
+		ctx, srvcomment.GetCommentsByFilterRequest{
+			OptionIDs:    optionIDs,
+			TopLevelOnly: true,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	cMap := map[uuid.UUID][]*srvcomment.Comment{}
+	for _, c := range comments {
+		cMap[*c.OptionID] = append(cMap[*c.OptionID], &c)
+	}
+	result := [][]*srvcomment.Comment{}
+	for _, id := range optionIDs {
+		result = append(result, cMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getCommentReplies(
+	ctx context.Context, parentIDs []uuid.UUID,
+) ([][]*srvcomment.Comment, []error) {
+	comments, err := g.services.Comment.GetCommentsByFilter(
+		ctx, srvcomment.GetCommentsByFilterRequest{
+			ParentIDs: parentIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	cMap := map[uuid.UUID][]*srvcomment.Comment{}
+	for _, c := range comments {
+		cMap[*c.ParentID] = append(cMap[*c.ParentID], &c)
+	}
+	result := [][]*srvcomment.Comment{}
+	for _, id := range parentIDs {
+		result = append(result, cMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getWorkspaces(
+	ctx context.Context, ids []uuid.UUID,
+) ([]*srvworkspace.Workspace, []error) {
+	workspaces, err := g.services.Workspace.GetWorkspacesByFilter(
+		ctx, srvworkspace.GetWorkspacesByFilterRequest{
+			IDs: ids,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	wMap := map[uuid.UUID]*srvworkspace.Workspace{}
+	for _, w := range workspaces {
+		wMap[w.ID] = &w
+	}
+	result := []*srvworkspace.Workspace{}
+	for _, id := range ids {
+		result = append(result, wMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getWorkspaceMembers(
+	ctx context.Context, workspaceIDs []uuid.UUID,
+) ([][]*srvworkspace.Member, []error) {
+	members, err := g.services.Workspace.GetMembersByFilter(
+		ctx, srvworkspace.GetMembersByFilterRequest{
+			WorkspaceIDs: workspaceIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	mMap := map[uuid.UUID][]*srvworkspace.Member{}
+	for _, m := range members {
+		mMap[m.WorkspaceID] = append(mMap[m.WorkspaceID], &m)
+	}
+	result := [][]*srvworkspace.Member{}
+	for _, id := range workspaceIDs {
+		result = append(result, mMap[id])
+	}
+	return result, nil
+}
+
+func (g *getters) getCustomerMemberships(
+	ctx context.Context, customerIDs []uuid.UUID,
+) ([][]*srvworkspace.Member, []error) {
+	members, err := g.services.Workspace.GetMembersByFilter(
+		ctx, srvworkspace.GetMembersByFilterRequest{
+			CustomerIDs: customerIDs,
+		},
+	)
+	if err != nil {
+		return nil, []error{err}
+	}
+	mMap := map[uuid.UUID][]*srvworkspace.Member{}
+	for _, m := range members {
+		mMap[m.CustomerID] = append(mMap[m.CustomerID], &m)
+	}
+	result := [][]*srvworkspace.Member{}
+	for _, id := range customerIDs {
+		result = append(result, mMap[id])
+	}
+	return result, nil
+}
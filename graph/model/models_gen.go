@@ -5,20 +5,70 @@ package model
 import (
 	"fmt"
 	"io"
+	srvcomment "quorum-api/services/comment"
 	srvcustomer "quorum-api/services/customer"
 	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
 	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AcceptWorkspaceInvitationError interface {
+	IsAcceptWorkspaceInvitationError()
+}
+
+type AddCommentError interface {
+	IsAddCommentError()
+}
+
+type AnswerMatchupError interface {
+	IsAnswerMatchupError()
+}
+
 type BaseError interface {
 	IsBaseError()
 	GetMessage() string
 	GetPath() []string
 }
 
+type ChangeVoteError interface {
+	IsChangeVoteError()
+}
+
+type ChangeWorkspaceMemberRoleError interface {
+	IsChangeWorkspaceMemberRoleError()
+}
+
+type ConfirmEmailChangeError interface {
+	IsConfirmEmailChangeError()
+}
+
+type CreatePostInviteLinkError interface {
+	IsCreatePostInviteLinkError()
+}
+
+type CreateWorkspaceError interface {
+	IsCreateWorkspaceError()
+}
+
+type DeleteAccountError interface {
+	IsDeleteAccountError()
+}
+
+type DeleteCommentError interface {
+	IsDeleteCommentError()
+}
+
+type EditCommentError interface {
+	IsEditCommentError()
+}
+
+type GenerateSignedAvatarURLError interface {
+	IsGenerateSignedAvatarURLError()
+}
+
 type GenerateSignedPostOptionURLError interface {
 	IsGenerateSignedPostOptionURLError()
 }
@@ -27,14 +77,74 @@ type GetLoginLinkError interface {
 	IsGetLoginLinkError()
 }
 
+type InviteToWorkspaceError interface {
+	IsInviteToWorkspaceError()
+}
+
+type LogoutAllSessionsError interface {
+	IsLogoutAllSessionsError()
+}
+
+type LogoutError interface {
+	IsLogoutError()
+}
+
+type NextMatchupError interface {
+	IsNextMatchupError()
+}
+
+type RateOptionsError interface {
+	IsRateOptionsError()
+}
+
+type RefreshSessionError interface {
+	IsRefreshSessionError()
+}
+
+type RemoveWorkspaceMemberError interface {
+	IsRemoveWorkspaceMemberError()
+}
+
+type RequestEmailChangeError interface {
+	IsRequestEmailChangeError()
+}
+
+type RetractVoteError interface {
+	IsRetractVoteError()
+}
+
+type RevokePostInviteError interface {
+	IsRevokePostInviteError()
+}
+
+type RevokeWorkspaceInvitationError interface {
+	IsRevokeWorkspaceInvitationError()
+}
+
+type SendPostInvitesError interface {
+	IsSendPostInvitesError()
+}
+
 type SignUpError interface {
 	IsSignUpError()
 }
 
+type SubmitBallotError interface {
+	IsSubmitBallotError()
+}
+
 type SubmitVoteError interface {
 	IsSubmitVoteError()
 }
 
+type UpdateProfileError interface {
+	IsUpdateProfileError()
+}
+
+type UpdateWorkspaceError interface {
+	IsUpdateWorkspaceError()
+}
+
 type UpsertPostError interface {
 	IsUpsertPostError()
 }
@@ -43,6 +153,80 @@ type VerifyCustomerTokenError interface {
 	IsVerifyCustomerTokenError()
 }
 
+type AcceptWorkspaceInvitationInput struct {
+	Token string `json:"token"`
+}
+
+type AcceptWorkspaceInvitationPayload struct {
+	Workspace *srvworkspace.Workspace          `json:"workspace,omitempty"`
+	Errors    []AcceptWorkspaceInvitationError `json:"errors"`
+}
+
+type AddCommentInput struct {
+	PostID   uuid.UUID  `json:"postId"`
+	OptionID *uuid.UUID `json:"optionId,omitempty"`
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+	Body     string     `json:"body"`
+}
+
+type AddCommentPayload struct {
+	Comment *srvcomment.Comment `json:"comment,omitempty"`
+	Errors  []AddCommentError   `json:"errors"`
+}
+
+type AnswerMatchupInput struct {
+	MatchupID      uuid.UUID `json:"matchupId"`
+	WinnerOptionID uuid.UUID `json:"winnerOptionId"`
+	InviteToken    *string   `json:"inviteToken,omitempty"`
+}
+
+type AnswerMatchupPayload struct {
+	Post   *srvpost.Post        `json:"post,omitempty"`
+	Errors []AnswerMatchupError `json:"errors"`
+}
+
+type AvatarFileNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (AvatarFileNotFoundError) IsBaseError()            {}
+func (this AvatarFileNotFoundError) GetMessage() string { return this.Message }
+func (this AvatarFileNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (AvatarFileNotFoundError) IsUpdateProfileError() {}
+
+type ChangeVoteInput struct {
+	OptionID uuid.UUID                 `json:"optionId"`
+	Reason   *string                   `json:"reason,omitempty"`
+	Criteria []*CriterionResponseInput `json:"criteria,omitempty"`
+}
+
+type ChangeVotePayload struct {
+	Post   *srvpost.Post     `json:"post,omitempty"`
+	Errors []ChangeVoteError `json:"errors"`
+}
+
+type ChangeWorkspaceMemberRoleInput struct {
+	WorkspaceID uuid.UUID     `json:"workspaceId"`
+	CustomerID  uuid.UUID     `json:"customerId"`
+	Role        WorkspaceRole `json:"role"`
+}
+
+type ChangeWorkspaceMemberRolePayload struct {
+	Workspace *srvworkspace.Workspace          `json:"workspace,omitempty"`
+	Errors    []ChangeWorkspaceMemberRoleError `json:"errors"`
+}
+
 type ClosesAtNotAfterOpensAtError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
@@ -63,14 +247,14 @@ func (this ClosesAtNotAfterOpensAtError) GetPath() []string {
 
 func (ClosesAtNotAfterOpensAtError) IsUpsertPostError() {}
 
-type CustomerNotFoundError struct {
+type CommentNotFoundError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (CustomerNotFoundError) IsBaseError()            {}
-func (this CustomerNotFoundError) GetMessage() string { return this.Message }
-func (this CustomerNotFoundError) GetPath() []string {
+func (CommentNotFoundError) IsBaseError()            {}
+func (this CommentNotFoundError) GetMessage() string { return this.Message }
+func (this CommentNotFoundError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -81,16 +265,20 @@ func (this CustomerNotFoundError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (CustomerNotFoundError) IsGetLoginLinkError() {}
+func (CommentNotFoundError) IsAddCommentError() {}
 
-type ErrPostNotOwned struct {
+func (CommentNotFoundError) IsEditCommentError() {}
+
+func (CommentNotFoundError) IsDeleteCommentError() {}
+
+type CommentNotOwnedError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (ErrPostNotOwned) IsBaseError()            {}
-func (this ErrPostNotOwned) GetMessage() string { return this.Message }
-func (this ErrPostNotOwned) GetPath() []string {
+func (CommentNotOwnedError) IsBaseError()            {}
+func (this CommentNotOwnedError) GetMessage() string { return this.Message }
+func (this CommentNotOwnedError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -101,39 +289,48 @@ func (this ErrPostNotOwned) GetPath() []string {
 	return interfaceSlice
 }
 
-func (ErrPostNotOwned) IsUpsertPostError() {}
+func (CommentNotOwnedError) IsEditCommentError() {}
 
-type GenerateSignedPostOptionUrInput struct {
-	// Generates a url to upload the file too based off this filename.
-	// The name is ignored, but the extension is not.
-	FileName    string `json:"fileName"`
-	ContentType string `json:"contentType"`
+func (CommentNotOwnedError) IsDeleteCommentError() {}
+
+type ConfirmEmailChangeInput struct {
+	Token string `json:"token"`
 }
 
-type GenerateSignedPostOptionURLPayload struct {
-	BucketName string                             `json:"bucketName"`
-	FileKey    string                             `json:"fileKey"`
-	URL        string                             `json:"url"`
-	Errors     []GenerateSignedPostOptionURLError `json:"errors"`
+type ConfirmEmailChangePayload struct {
+	Customer *srvcustomer.Customer     `json:"customer,omitempty"`
+	Errors   []ConfirmEmailChangeError `json:"errors"`
 }
 
-type GetLoginLinkInput struct {
-	Email    string `json:"email"`
-	ReturnTo string `json:"returnTo"`
+type CreatePostInviteLinkInput struct {
+	PostID    uuid.UUID  `json:"postId"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   *int       `json:"maxUses,omitempty"`
 }
 
-type GetLoginLinkPayload struct {
-	Errors []GetLoginLinkError `json:"errors"`
+type CreatePostInviteLinkPayload struct {
+	Invite *srvpost.Invite             `json:"invite,omitempty"`
+	URL    *string                     `json:"url,omitempty"`
+	Errors []CreatePostInviteLinkError `json:"errors"`
 }
 
-type InvalidEmailError struct {
+type CreateWorkspaceInput struct {
+	Name string `json:"name"`
+}
+
+type CreateWorkspacePayload struct {
+	Workspace *srvworkspace.Workspace `json:"workspace,omitempty"`
+	Errors    []CreateWorkspaceError  `json:"errors"`
+}
+
+type CriterionNotFoundError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (InvalidEmailError) IsBaseError()            {}
-func (this InvalidEmailError) GetMessage() string { return this.Message }
-func (this InvalidEmailError) GetPath() []string {
+func (CriterionNotFoundError) IsBaseError()            {}
+func (this CriterionNotFoundError) GetMessage() string { return this.Message }
+func (this CriterionNotFoundError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -144,18 +341,25 @@ func (this InvalidEmailError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (InvalidEmailError) IsSignUpError() {}
+func (CriterionNotFoundError) IsSubmitVoteError() {}
 
-func (InvalidEmailError) IsGetLoginLinkError() {}
+func (CriterionNotFoundError) IsChangeVoteError() {}
 
-type InvalidReturnToError struct {
+func (CriterionNotFoundError) IsSubmitBallotError() {}
+
+type CriterionResponseInput struct {
+	CriterionID uuid.UUID `json:"criterionId"`
+	Score       *int      `json:"score,omitempty"`
+}
+
+type CustomerNotFoundError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (InvalidReturnToError) IsBaseError()            {}
-func (this InvalidReturnToError) GetMessage() string { return this.Message }
-func (this InvalidReturnToError) GetPath() []string {
+func (CustomerNotFoundError) IsBaseError()            {}
+func (this CustomerNotFoundError) GetMessage() string { return this.Message }
+func (this CustomerNotFoundError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -166,18 +370,39 @@ func (this InvalidReturnToError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (InvalidReturnToError) IsSignUpError() {}
+func (CustomerNotFoundError) IsGetLoginLinkError() {}
 
-func (InvalidReturnToError) IsGetLoginLinkError() {}
+type DeleteAccountPayload struct {
+	Errors []DeleteAccountError `json:"errors"`
+}
 
-type LinkExpiredError struct {
+type DeleteCommentInput struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type DeleteCommentPayload struct {
+	Comment *srvcomment.Comment  `json:"comment,omitempty"`
+	Errors  []DeleteCommentError `json:"errors"`
+}
+
+type EditCommentInput struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+type EditCommentPayload struct {
+	Comment *srvcomment.Comment `json:"comment,omitempty"`
+	Errors  []EditCommentError  `json:"errors"`
+}
+
+type EmailTakenError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (LinkExpiredError) IsBaseError()            {}
-func (this LinkExpiredError) GetMessage() string { return this.Message }
-func (this LinkExpiredError) GetPath() []string {
+func (EmailTakenError) IsBaseError()            {}
+func (this EmailTakenError) GetMessage() string { return this.Message }
+func (this EmailTakenError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -188,19 +413,18 @@ func (this LinkExpiredError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (LinkExpiredError) IsVerifyCustomerTokenError() {}
+func (EmailTakenError) IsRequestEmailChangeError() {}
 
-type Mutation struct {
-}
+func (EmailTakenError) IsConfirmEmailChangeError() {}
 
-type OpensAtAlreadyPassedError struct {
+type ErrPostNotOwned struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (OpensAtAlreadyPassedError) IsBaseError()            {}
-func (this OpensAtAlreadyPassedError) GetMessage() string { return this.Message }
-func (this OpensAtAlreadyPassedError) GetPath() []string {
+func (ErrPostNotOwned) IsBaseError()            {}
+func (this ErrPostNotOwned) GetMessage() string { return this.Message }
+func (this ErrPostNotOwned) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -211,16 +435,20 @@ func (this OpensAtAlreadyPassedError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (OpensAtAlreadyPassedError) IsUpsertPostError() {}
+func (ErrPostNotOwned) IsUpsertPostError() {}
 
-type OptionNotFoundError struct {
+func (ErrPostNotOwned) IsCreatePostInviteLinkError() {}
+
+func (ErrPostNotOwned) IsSendPostInvitesError() {}
+
+type FeedbackNotAcceptedError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (OptionNotFoundError) IsBaseError()            {}
-func (this OptionNotFoundError) GetMessage() string { return this.Message }
-func (this OptionNotFoundError) GetPath() []string {
+func (FeedbackNotAcceptedError) IsBaseError()            {}
+func (this FeedbackNotAcceptedError) GetMessage() string { return this.Message }
+func (this FeedbackNotAcceptedError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -231,41 +459,56 @@ func (this OptionNotFoundError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (OptionNotFoundError) IsSubmitVoteError() {}
+func (FeedbackNotAcceptedError) IsRateOptionsError() {}
 
-type Query struct {
+func (FeedbackNotAcceptedError) IsNextMatchupError() {}
+
+func (FeedbackNotAcceptedError) IsAnswerMatchupError() {}
+
+type GenerateSignedAvatarURLInput struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
 }
 
-type SignUpInput struct {
-	FirstName  string `json:"firstName"`
-	LastName   string `json:"lastName"`
-	Email      string `json:"email"`
-	Profession string `json:"profession"`
-	ReturnTo   string `json:"returnTo"`
+type GenerateSignedAvatarURLPayload struct {
+	FileKey *string                        `json:"fileKey,omitempty"`
+	URL     *string                        `json:"url,omitempty"`
+	Errors  []GenerateSignedAvatarURLError `json:"errors"`
 }
 
-type SignUpPayload struct {
-	Errors []SignUpError `json:"errors"`
+type GenerateSignedPostOptionUrInput struct {
+	// Generates a url to upload the file too based off this filename.
+	// The name is ignored, but the extension is not. One of .jpeg, .jpg, .png,
+	// .gif, .webp, .svg, .pdf, .mp4, .webm or .mov, and contentType has to
+	// match it.
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
 }
 
-type SubmitVoteInput struct {
-	OptionID uuid.UUID `json:"optionId"`
-	Reason   *string   `json:"reason,omitempty"`
+type GenerateSignedPostOptionURLPayload struct {
+	BucketName string                             `json:"bucketName"`
+	FileKey    string                             `json:"fileKey"`
+	URL        string                             `json:"url"`
+	Errors     []GenerateSignedPostOptionURLError `json:"errors"`
 }
 
-type SubmitVotePayload struct {
-	Post   *srvpost.Post     `json:"post,omitempty"`
-	Errors []SubmitVoteError `json:"errors"`
+type GetLoginLinkInput struct {
+	Email    string `json:"email"`
+	ReturnTo string `json:"returnTo"`
 }
 
-type TooFewOptionsError struct {
+type GetLoginLinkPayload struct {
+	Errors []GetLoginLinkError `json:"errors"`
+}
+
+type InsufficientWorkspaceRoleError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (TooFewOptionsError) IsBaseError()            {}
-func (this TooFewOptionsError) GetMessage() string { return this.Message }
-func (this TooFewOptionsError) GetPath() []string {
+func (InsufficientWorkspaceRoleError) IsBaseError()            {}
+func (this InsufficientWorkspaceRoleError) GetMessage() string { return this.Message }
+func (this InsufficientWorkspaceRoleError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -276,16 +519,24 @@ func (this TooFewOptionsError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (TooFewOptionsError) IsUpsertPostError() {}
+func (InsufficientWorkspaceRoleError) IsUpdateWorkspaceError() {}
 
-type TooManyOptionsError struct {
+func (InsufficientWorkspaceRoleError) IsInviteToWorkspaceError() {}
+
+func (InsufficientWorkspaceRoleError) IsRevokeWorkspaceInvitationError() {}
+
+func (InsufficientWorkspaceRoleError) IsChangeWorkspaceMemberRoleError() {}
+
+func (InsufficientWorkspaceRoleError) IsRemoveWorkspaceMemberError() {}
+
+type InvalidBallotError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (TooManyOptionsError) IsBaseError()            {}
-func (this TooManyOptionsError) GetMessage() string { return this.Message }
-func (this TooManyOptionsError) GetPath() []string {
+func (InvalidBallotError) IsBaseError()            {}
+func (this InvalidBallotError) GetMessage() string { return this.Message }
+func (this InvalidBallotError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -296,20 +547,38 @@ func (this TooManyOptionsError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (TooManyOptionsError) IsUpsertPostError() {}
+func (InvalidBallotError) IsSubmitBallotError() {}
 
-type UnauthenticatedError struct {
+type InvalidCommentBodyError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (UnauthenticatedError) IsSubmitVoteError() {}
+func (InvalidCommentBodyError) IsBaseError()            {}
+func (this InvalidCommentBodyError) GetMessage() string { return this.Message }
+func (this InvalidCommentBodyError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
 
-func (UnauthenticatedError) IsUpsertPostError() {}
+func (InvalidCommentBodyError) IsAddCommentError() {}
 
-func (UnauthenticatedError) IsBaseError()            {}
-func (this UnauthenticatedError) GetMessage() string { return this.Message }
-func (this UnauthenticatedError) GetPath() []string {
+func (InvalidCommentBodyError) IsEditCommentError() {}
+
+type InvalidCriterionError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidCriterionError) IsBaseError()            {}
+func (this InvalidCriterionError) GetMessage() string { return this.Message }
+func (this InvalidCriterionError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -320,18 +589,16 @@ func (this UnauthenticatedError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (UnauthenticatedError) IsGenerateSignedPostOptionURLError() {}
+func (InvalidCriterionError) IsUpsertPostError() {}
 
-type UnsupportedFileTypeError struct {
+type InvalidCriterionResponseError struct {
 	Message string   `json:"message"`
 	Path    []string `json:"path,omitempty"`
 }
 
-func (UnsupportedFileTypeError) IsUpsertPostError() {}
-
-func (UnsupportedFileTypeError) IsBaseError()            {}
-func (this UnsupportedFileTypeError) GetMessage() string { return this.Message }
-func (this UnsupportedFileTypeError) GetPath() []string {
+func (InvalidCriterionResponseError) IsBaseError()            {}
+func (this InvalidCriterionResponseError) GetMessage() string { return this.Message }
+func (this InvalidCriterionResponseError) GetPath() []string {
 	if this.Path == nil {
 		return nil
 	}
@@ -342,20 +609,888 @@ func (this UnsupportedFileTypeError) GetPath() []string {
 	return interfaceSlice
 }
 
-func (UnsupportedFileTypeError) IsGenerateSignedPostOptionURLError() {}
+func (InvalidCriterionResponseError) IsSubmitVoteError() {}
 
-type UpsertPostInput struct {
-	ID          uuid.UUID                `json:"id"`
-	DesignPhase *DesignPhase             `json:"designPhase,omitempty"`
-	Context     *string                  `json:"context,omitempty"`
-	Category    *PostCategory            `json:"category,omitempty"`
-	OpensAt     *time.Time               `json:"opensAt,omitempty"`
-	ClosesAt    *time.Time               `json:"closesAt,omitempty"`
-	Options     []*UpsertPostOptionInput `json:"options"`
-}
+func (InvalidCriterionResponseError) IsChangeVoteError() {}
 
-type UpsertPostOptionInput struct {
-	ID         uuid.UUID `json:"id"`
+func (InvalidCriterionResponseError) IsSubmitBallotError() {}
+
+type InvalidEmailError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidEmailError) IsBaseError()            {}
+func (this InvalidEmailError) GetMessage() string { return this.Message }
+func (this InvalidEmailError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidEmailError) IsSignUpError() {}
+
+func (InvalidEmailError) IsGetLoginLinkError() {}
+
+func (InvalidEmailError) IsRequestEmailChangeError() {}
+
+func (InvalidEmailError) IsInviteToWorkspaceError() {}
+
+func (InvalidEmailError) IsSendPostInvitesError() {}
+
+type InvalidMaxSelectionsError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidMaxSelectionsError) IsBaseError()            {}
+func (this InvalidMaxSelectionsError) GetMessage() string { return this.Message }
+func (this InvalidMaxSelectionsError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidMaxSelectionsError) IsUpsertPostError() {}
+
+type InvalidPostInviteError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidPostInviteError) IsBaseError()            {}
+func (this InvalidPostInviteError) GetMessage() string { return this.Message }
+func (this InvalidPostInviteError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidPostInviteError) IsCreatePostInviteLinkError() {}
+
+func (InvalidPostInviteError) IsSendPostInvitesError() {}
+
+type InvalidProfileError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidProfileError) IsBaseError()            {}
+func (this InvalidProfileError) GetMessage() string { return this.Message }
+func (this InvalidProfileError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidProfileError) IsUpdateProfileError() {}
+
+type InvalidRefreshTokenError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidRefreshTokenError) IsBaseError()            {}
+func (this InvalidRefreshTokenError) GetMessage() string { return this.Message }
+func (this InvalidRefreshTokenError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidRefreshTokenError) IsRefreshSessionError() {}
+
+type InvalidReturnToError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidReturnToError) IsBaseError()            {}
+func (this InvalidReturnToError) GetMessage() string { return this.Message }
+func (this InvalidReturnToError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidReturnToError) IsSignUpError() {}
+
+func (InvalidReturnToError) IsGetLoginLinkError() {}
+
+func (InvalidReturnToError) IsRequestEmailChangeError() {}
+
+type InvalidScoreError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidScoreError) IsBaseError()            {}
+func (this InvalidScoreError) GetMessage() string { return this.Message }
+func (this InvalidScoreError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidScoreError) IsRateOptionsError() {}
+
+type InvalidWorkspaceNameError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvalidWorkspaceNameError) IsBaseError()            {}
+func (this InvalidWorkspaceNameError) GetMessage() string { return this.Message }
+func (this InvalidWorkspaceNameError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvalidWorkspaceNameError) IsCreateWorkspaceError() {}
+
+func (InvalidWorkspaceNameError) IsUpdateWorkspaceError() {}
+
+type InvitationEmailMismatchError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InvitationEmailMismatchError) IsBaseError()            {}
+func (this InvitationEmailMismatchError) GetMessage() string { return this.Message }
+func (this InvitationEmailMismatchError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (InvitationEmailMismatchError) IsAcceptWorkspaceInvitationError() {}
+
+type InviteRequiredError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (InviteRequiredError) IsSubmitVoteError() {}
+
+func (InviteRequiredError) IsSubmitBallotError() {}
+
+func (InviteRequiredError) IsRateOptionsError() {}
+
+func (InviteRequiredError) IsAnswerMatchupError() {}
+
+func (InviteRequiredError) IsBaseError()            {}
+func (this InviteRequiredError) GetMessage() string { return this.Message }
+func (this InviteRequiredError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type InviteToWorkspaceInput struct {
+	WorkspaceID uuid.UUID      `json:"workspaceId"`
+	Email       string         `json:"email"`
+	Role        *WorkspaceRole `json:"role,omitempty"`
+}
+
+type InviteToWorkspacePayload struct {
+	Workspace *srvworkspace.Workspace  `json:"workspace,omitempty"`
+	Errors    []InviteToWorkspaceError `json:"errors"`
+}
+
+type LastWorkspaceOwnerError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (LastWorkspaceOwnerError) IsBaseError()            {}
+func (this LastWorkspaceOwnerError) GetMessage() string { return this.Message }
+func (this LastWorkspaceOwnerError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (LastWorkspaceOwnerError) IsChangeWorkspaceMemberRoleError() {}
+
+func (LastWorkspaceOwnerError) IsRemoveWorkspaceMemberError() {}
+
+type LinkAlreadyUsedError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (LinkAlreadyUsedError) IsBaseError()            {}
+func (this LinkAlreadyUsedError) GetMessage() string { return this.Message }
+func (this LinkAlreadyUsedError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (LinkAlreadyUsedError) IsVerifyCustomerTokenError() {}
+
+func (LinkAlreadyUsedError) IsConfirmEmailChangeError() {}
+
+func (LinkAlreadyUsedError) IsSubmitVoteError() {}
+
+func (LinkAlreadyUsedError) IsSubmitBallotError() {}
+
+func (LinkAlreadyUsedError) IsRateOptionsError() {}
+
+func (LinkAlreadyUsedError) IsAnswerMatchupError() {}
+
+func (LinkAlreadyUsedError) IsAcceptWorkspaceInvitationError() {}
+
+type LinkExpiredError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (LinkExpiredError) IsBaseError()            {}
+func (this LinkExpiredError) GetMessage() string { return this.Message }
+func (this LinkExpiredError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (LinkExpiredError) IsVerifyCustomerTokenError() {}
+
+func (LinkExpiredError) IsConfirmEmailChangeError() {}
+
+func (LinkExpiredError) IsSubmitVoteError() {}
+
+func (LinkExpiredError) IsSubmitBallotError() {}
+
+func (LinkExpiredError) IsRateOptionsError() {}
+
+func (LinkExpiredError) IsAnswerMatchupError() {}
+
+func (LinkExpiredError) IsRevokeWorkspaceInvitationError() {}
+
+func (LinkExpiredError) IsAcceptWorkspaceInvitationError() {}
+
+type LogoutAllSessionsPayload struct {
+	Errors []LogoutAllSessionsError `json:"errors"`
+}
+
+type LogoutPayload struct {
+	Errors []LogoutError `json:"errors"`
+}
+
+type MatchupAnsweredError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (MatchupAnsweredError) IsBaseError()            {}
+func (this MatchupAnsweredError) GetMessage() string { return this.Message }
+func (this MatchupAnsweredError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (MatchupAnsweredError) IsAnswerMatchupError() {}
+
+type MatchupNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (MatchupNotFoundError) IsBaseError()            {}
+func (this MatchupNotFoundError) GetMessage() string { return this.Message }
+func (this MatchupNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (MatchupNotFoundError) IsAnswerMatchupError() {}
+
+type Mutation struct {
+}
+
+type NextMatchupInput struct {
+	PostID uuid.UUID `json:"postId"`
+}
+
+type NextMatchupPayload struct {
+	Matchup *srvpost.Matchup   `json:"matchup,omitempty"`
+	Errors  []NextMatchupError `json:"errors"`
+}
+
+type OpensAtAlreadyPassedError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (OpensAtAlreadyPassedError) IsBaseError()            {}
+func (this OpensAtAlreadyPassedError) GetMessage() string { return this.Message }
+func (this OpensAtAlreadyPassedError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (OpensAtAlreadyPassedError) IsUpsertPostError() {}
+
+type OptionFileTooLargeError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (OptionFileTooLargeError) IsBaseError()            {}
+func (this OptionFileTooLargeError) GetMessage() string { return this.Message }
+func (this OptionFileTooLargeError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (OptionFileTooLargeError) IsUpsertPostError() {}
+
+type OptionNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (OptionNotFoundError) IsBaseError()            {}
+func (this OptionNotFoundError) GetMessage() string { return this.Message }
+func (this OptionNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (OptionNotFoundError) IsSubmitVoteError() {}
+
+func (OptionNotFoundError) IsChangeVoteError() {}
+
+func (OptionNotFoundError) IsSubmitBallotError() {}
+
+func (OptionNotFoundError) IsRateOptionsError() {}
+
+func (OptionNotFoundError) IsAnswerMatchupError() {}
+
+func (OptionNotFoundError) IsAddCommentError() {}
+
+type OptionRatingInput struct {
+	OptionID uuid.UUID `json:"optionId"`
+	Score    int       `json:"score"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	StartCursor *string `json:"startCursor,omitempty"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type PostEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *srvpost.Post `json:"node"`
+}
+
+type PostFilter struct {
+	Categories   []PostCategory `json:"categories,omitempty"`
+	DesignPhases []DesignPhase  `json:"designPhases,omitempty"`
+	Statuses     []PostStatus   `json:"statuses,omitempty"`
+	AuthorIds    []uuid.UUID    `json:"authorIds,omitempty"`
+	WorkspaceIds []uuid.UUID    `json:"workspaceIds,omitempty"`
+}
+
+type PostInviteNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (PostInviteNotFoundError) IsBaseError()            {}
+func (this PostInviteNotFoundError) GetMessage() string { return this.Message }
+func (this PostInviteNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (PostInviteNotFoundError) IsRevokePostInviteError() {}
+
+type PostNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (PostNotFoundError) IsBaseError()            {}
+func (this PostNotFoundError) GetMessage() string { return this.Message }
+func (this PostNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (PostNotFoundError) IsRetractVoteError() {}
+
+func (PostNotFoundError) IsSubmitBallotError() {}
+
+func (PostNotFoundError) IsRateOptionsError() {}
+
+func (PostNotFoundError) IsNextMatchupError() {}
+
+func (PostNotFoundError) IsAddCommentError() {}
+
+func (PostNotFoundError) IsCreatePostInviteLinkError() {}
+
+func (PostNotFoundError) IsSendPostInvitesError() {}
+
+type PostNotLiveError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (PostNotLiveError) IsBaseError()            {}
+func (this PostNotLiveError) GetMessage() string { return this.Message }
+func (this PostNotLiveError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (PostNotLiveError) IsSubmitVoteError() {}
+
+func (PostNotLiveError) IsChangeVoteError() {}
+
+func (PostNotLiveError) IsRetractVoteError() {}
+
+func (PostNotLiveError) IsSubmitBallotError() {}
+
+func (PostNotLiveError) IsRateOptionsError() {}
+
+func (PostNotLiveError) IsNextMatchupError() {}
+
+func (PostNotLiveError) IsAnswerMatchupError() {}
+
+type Query struct {
+}
+
+type RateOptionsInput struct {
+	PostID      uuid.UUID            `json:"postId"`
+	Ratings     []*OptionRatingInput `json:"ratings"`
+	InviteToken *string              `json:"inviteToken,omitempty"`
+}
+
+type RateOptionsPayload struct {
+	Post   *srvpost.Post      `json:"post,omitempty"`
+	Errors []RateOptionsError `json:"errors"`
+}
+
+type RefreshSessionInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshSessionPayload struct {
+	Token        *string               `json:"token,omitempty"`
+	RefreshToken *string               `json:"refreshToken,omitempty"`
+	Errors       []RefreshSessionError `json:"errors"`
+}
+
+type RemoveWorkspaceMemberInput struct {
+	WorkspaceID uuid.UUID `json:"workspaceId"`
+	CustomerID  uuid.UUID `json:"customerId"`
+}
+
+type RemoveWorkspaceMemberPayload struct {
+	Workspace *srvworkspace.Workspace      `json:"workspace,omitempty"`
+	Errors    []RemoveWorkspaceMemberError `json:"errors"`
+}
+
+type RequestEmailChangeInput struct {
+	NewEmail string `json:"newEmail"`
+	ReturnTo string `json:"returnTo"`
+}
+
+type RequestEmailChangePayload struct {
+	Errors []RequestEmailChangeError `json:"errors"`
+}
+
+type RetractVoteInput struct {
+	PostID uuid.UUID `json:"postId"`
+}
+
+type RetractVotePayload struct {
+	Post   *srvpost.Post      `json:"post,omitempty"`
+	Errors []RetractVoteError `json:"errors"`
+}
+
+type RevokePostInviteInput struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type RevokePostInvitePayload struct {
+	Post   *srvpost.Post           `json:"post,omitempty"`
+	Errors []RevokePostInviteError `json:"errors"`
+}
+
+type RevokeWorkspaceInvitationInput struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type RevokeWorkspaceInvitationPayload struct {
+	Workspace *srvworkspace.Workspace          `json:"workspace,omitempty"`
+	Errors    []RevokeWorkspaceInvitationError `json:"errors"`
+}
+
+type SendPostInvitesInput struct {
+	PostID    uuid.UUID  `json:"postId"`
+	Emails    []string   `json:"emails"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type SendPostInvitesPayload struct {
+	Invites []*srvpost.Invite      `json:"invites,omitempty"`
+	Errors  []SendPostInvitesError `json:"errors"`
+}
+
+type SignUpInput struct {
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	Email      string `json:"email"`
+	Profession string `json:"profession"`
+	ReturnTo   string `json:"returnTo"`
+}
+
+type SignUpPayload struct {
+	Errors []SignUpError `json:"errors"`
+}
+
+type SubmitBallotInput struct {
+	PostID      uuid.UUID                 `json:"postId"`
+	OptionIds   []uuid.UUID               `json:"optionIds"`
+	Reason      *string                   `json:"reason,omitempty"`
+	Criteria    []*CriterionResponseInput `json:"criteria,omitempty"`
+	InviteToken *string                   `json:"inviteToken,omitempty"`
+}
+
+type SubmitBallotPayload struct {
+	Post   *srvpost.Post       `json:"post,omitempty"`
+	Errors []SubmitBallotError `json:"errors"`
+}
+
+type SubmitVoteInput struct {
+	OptionID    uuid.UUID                 `json:"optionId"`
+	Reason      *string                   `json:"reason,omitempty"`
+	Criteria    []*CriterionResponseInput `json:"criteria,omitempty"`
+	InviteToken *string                   `json:"inviteToken,omitempty"`
+}
+
+type SubmitVotePayload struct {
+	Post   *srvpost.Post     `json:"post,omitempty"`
+	Errors []SubmitVoteError `json:"errors"`
+}
+
+type Subscription struct {
+}
+
+type TooFewOptionsError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (TooFewOptionsError) IsBaseError()            {}
+func (this TooFewOptionsError) GetMessage() string { return this.Message }
+func (this TooFewOptionsError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (TooFewOptionsError) IsUpsertPostError() {}
+
+type TooManyOptionsError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (TooManyOptionsError) IsBaseError()            {}
+func (this TooManyOptionsError) GetMessage() string { return this.Message }
+func (this TooManyOptionsError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (TooManyOptionsError) IsUpsertPostError() {}
+
+type UnauthenticatedError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (UnauthenticatedError) IsLogoutError() {}
+
+func (UnauthenticatedError) IsLogoutAllSessionsError() {}
+
+func (UnauthenticatedError) IsUpdateProfileError() {}
+
+func (UnauthenticatedError) IsRequestEmailChangeError() {}
+
+func (UnauthenticatedError) IsDeleteAccountError() {}
+
+func (UnauthenticatedError) IsSubmitVoteError() {}
+
+func (UnauthenticatedError) IsChangeVoteError() {}
+
+func (UnauthenticatedError) IsRetractVoteError() {}
+
+func (UnauthenticatedError) IsSubmitBallotError() {}
+
+func (UnauthenticatedError) IsRateOptionsError() {}
+
+func (UnauthenticatedError) IsNextMatchupError() {}
+
+func (UnauthenticatedError) IsAnswerMatchupError() {}
+
+func (UnauthenticatedError) IsUpsertPostError() {}
+
+func (UnauthenticatedError) IsBaseError()            {}
+func (this UnauthenticatedError) GetMessage() string { return this.Message }
+func (this UnauthenticatedError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (UnauthenticatedError) IsGenerateSignedPostOptionURLError() {}
+
+func (UnauthenticatedError) IsGenerateSignedAvatarURLError() {}
+
+func (UnauthenticatedError) IsAddCommentError() {}
+
+func (UnauthenticatedError) IsEditCommentError() {}
+
+func (UnauthenticatedError) IsDeleteCommentError() {}
+
+func (UnauthenticatedError) IsCreateWorkspaceError() {}
+
+func (UnauthenticatedError) IsUpdateWorkspaceError() {}
+
+func (UnauthenticatedError) IsInviteToWorkspaceError() {}
+
+func (UnauthenticatedError) IsRevokeWorkspaceInvitationError() {}
+
+func (UnauthenticatedError) IsAcceptWorkspaceInvitationError() {}
+
+func (UnauthenticatedError) IsChangeWorkspaceMemberRoleError() {}
+
+func (UnauthenticatedError) IsRemoveWorkspaceMemberError() {}
+
+func (UnauthenticatedError) IsCreatePostInviteLinkError() {}
+
+func (UnauthenticatedError) IsSendPostInvitesError() {}
+
+func (UnauthenticatedError) IsRevokePostInviteError() {}
+
+type UnsupportedFileTypeError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (UnsupportedFileTypeError) IsUpsertPostError() {}
+
+func (UnsupportedFileTypeError) IsBaseError()            {}
+func (this UnsupportedFileTypeError) GetMessage() string { return this.Message }
+func (this UnsupportedFileTypeError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (UnsupportedFileTypeError) IsGenerateSignedPostOptionURLError() {}
+
+func (UnsupportedFileTypeError) IsGenerateSignedAvatarURLError() {}
+
+type UpdateProfileInput struct {
+	FirstName     *string `json:"firstName,omitempty"`
+	LastName      *string `json:"lastName,omitempty"`
+	Profession    *string `json:"profession,omitempty"`
+	DisplayName   *string `json:"displayName,omitempty"`
+	Bio           *string `json:"bio,omitempty"`
+	PortfolioURL  *string `json:"portfolioUrl,omitempty"`
+	AvatarFileKey *string `json:"avatarFileKey,omitempty"`
+}
+
+type UpdateProfilePayload struct {
+	Customer *srvcustomer.Customer `json:"customer,omitempty"`
+	Errors   []UpdateProfileError  `json:"errors"`
+}
+
+type UpdateWorkspaceInput struct {
+	ID   uuid.UUID `json:"id"`
+	Name *string   `json:"name,omitempty"`
+}
+
+type UpdateWorkspacePayload struct {
+	Workspace *srvworkspace.Workspace `json:"workspace,omitempty"`
+	Errors    []UpdateWorkspaceError  `json:"errors"`
+}
+
+type UpsertPostCriterionInput struct {
+	ID       uuid.UUID     `json:"id"`
+	Name     string        `json:"name"`
+	Kind     CriterionKind `json:"kind"`
+	Position int           `json:"position"`
+}
+
+type UpsertPostInput struct {
+	ID                uuid.UUID                   `json:"id"`
+	DesignPhase       *DesignPhase                `json:"designPhase,omitempty"`
+	Context           *string                     `json:"context,omitempty"`
+	Category          *PostCategory               `json:"category,omitempty"`
+	OpensAt           *time.Time                  `json:"opensAt,omitempty"`
+	ClosesAt          *time.Time                  `json:"closesAt,omitempty"`
+	ResultsVisibility *ResultsVisibility          `json:"resultsVisibility,omitempty"`
+	AllowVoteChanges  *bool                       `json:"allowVoteChanges,omitempty"`
+	Visibility        *PostVisibility             `json:"visibility,omitempty"`
+	WorkspaceID       *uuid.UUID                  `json:"workspaceId,omitempty"`
+	Restricted        *bool                       `json:"restricted,omitempty"`
+	VotingMode        *VotingMode                 `json:"votingMode,omitempty"`
+	MaxSelections     *int                        `json:"maxSelections,omitempty"`
+	FeedbackMode      *FeedbackMode               `json:"feedbackMode,omitempty"`
+	Criteria          []*UpsertPostCriterionInput `json:"criteria,omitempty"`
+	Options           []*UpsertPostOptionInput    `json:"options"`
+}
+
+type UpsertPostOptionInput struct {
+	ID         uuid.UUID `json:"id"`
 	Position   int       `json:"position"`
 	BucketName string    `json:"bucketName"`
 	FileKey    string    `json:"fileKey"`
@@ -370,10 +1505,172 @@ type VerifyCustomerTokenInput struct {
 	Token string `json:"token"`
 }
 
-type VerifyCustomerTokenPayload struct {
-	Customer *srvcustomer.Customer      `json:"customer,omitempty"`
-	NewToken *string                    `json:"newToken,omitempty"`
-	Errors   []VerifyCustomerTokenError `json:"errors"`
+type VerifyCustomerTokenPayload struct {
+	Customer     *srvcustomer.Customer      `json:"customer,omitempty"`
+	NewToken     *string                    `json:"newToken,omitempty"`
+	RefreshToken *string                    `json:"refreshToken,omitempty"`
+	Errors       []VerifyCustomerTokenError `json:"errors"`
+}
+
+type VoteAlreadyCastError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (VoteAlreadyCastError) IsBaseError()            {}
+func (this VoteAlreadyCastError) GetMessage() string { return this.Message }
+func (this VoteAlreadyCastError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (VoteAlreadyCastError) IsSubmitVoteError() {}
+
+func (VoteAlreadyCastError) IsChangeVoteError() {}
+
+func (VoteAlreadyCastError) IsRetractVoteError() {}
+
+func (VoteAlreadyCastError) IsSubmitBallotError() {}
+
+func (VoteAlreadyCastError) IsRateOptionsError() {}
+
+type VoteNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (VoteNotFoundError) IsBaseError()            {}
+func (this VoteNotFoundError) GetMessage() string { return this.Message }
+func (this VoteNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (VoteNotFoundError) IsChangeVoteError() {}
+
+func (VoteNotFoundError) IsRetractVoteError() {}
+
+type WorkspaceMemberNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (WorkspaceMemberNotFoundError) IsBaseError()            {}
+func (this WorkspaceMemberNotFoundError) GetMessage() string { return this.Message }
+func (this WorkspaceMemberNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (WorkspaceMemberNotFoundError) IsChangeWorkspaceMemberRoleError() {}
+
+func (WorkspaceMemberNotFoundError) IsRemoveWorkspaceMemberError() {}
+
+type WorkspaceNotFoundError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (WorkspaceNotFoundError) IsUpsertPostError() {}
+
+func (WorkspaceNotFoundError) IsBaseError()            {}
+func (this WorkspaceNotFoundError) GetMessage() string { return this.Message }
+func (this WorkspaceNotFoundError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+func (WorkspaceNotFoundError) IsUpdateWorkspaceError() {}
+
+func (WorkspaceNotFoundError) IsInviteToWorkspaceError() {}
+
+func (WorkspaceNotFoundError) IsChangeWorkspaceMemberRoleError() {}
+
+func (WorkspaceNotFoundError) IsRemoveWorkspaceMemberError() {}
+
+type WorkspaceRequiredError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (WorkspaceRequiredError) IsUpsertPostError() {}
+
+func (WorkspaceRequiredError) IsBaseError()            {}
+func (this WorkspaceRequiredError) GetMessage() string { return this.Message }
+func (this WorkspaceRequiredError) GetPath() []string {
+	if this.Path == nil {
+		return nil
+	}
+	interfaceSlice := make([]string, 0, len(this.Path))
+	for _, concrete := range this.Path {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type CriterionKind string
+
+const (
+	CriterionKindRating CriterionKind = "RATING"
+	CriterionKindTag    CriterionKind = "TAG"
+)
+
+var AllCriterionKind = []CriterionKind{
+	CriterionKindRating,
+	CriterionKindTag,
+}
+
+func (e CriterionKind) IsValid() bool {
+	switch e {
+	case CriterionKindRating, CriterionKindTag:
+		return true
+	}
+	return false
+}
+
+func (e CriterionKind) String() string {
+	return string(e)
+}
+
+func (e *CriterionKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CriterionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CriterionKind", str)
+	}
+	return nil
+}
+
+func (e CriterionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
 type DesignPhase string
@@ -419,6 +1716,137 @@ func (e DesignPhase) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+type FeedbackMode string
+
+const (
+	FeedbackModeNone     FeedbackMode = "NONE"
+	FeedbackModeRating   FeedbackMode = "RATING"
+	FeedbackModePairwise FeedbackMode = "PAIRWISE"
+)
+
+var AllFeedbackMode = []FeedbackMode{
+	FeedbackModeNone,
+	FeedbackModeRating,
+	FeedbackModePairwise,
+}
+
+func (e FeedbackMode) IsValid() bool {
+	switch e {
+	case FeedbackModeNone, FeedbackModeRating, FeedbackModePairwise:
+		return true
+	}
+	return false
+}
+
+func (e FeedbackMode) String() string {
+	return string(e)
+}
+
+func (e *FeedbackMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FeedbackMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FeedbackMode", str)
+	}
+	return nil
+}
+
+func (e FeedbackMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ImageSize string
+
+const (
+	ImageSizeThumb  ImageSize = "THUMB"
+	ImageSizeMedium ImageSize = "MEDIUM"
+	ImageSizeFull   ImageSize = "FULL"
+)
+
+var AllImageSize = []ImageSize{
+	ImageSizeThumb,
+	ImageSizeMedium,
+	ImageSizeFull,
+}
+
+func (e ImageSize) IsValid() bool {
+	switch e {
+	case ImageSizeThumb, ImageSizeMedium, ImageSizeFull:
+		return true
+	}
+	return false
+}
+
+func (e ImageSize) String() string {
+	return string(e)
+}
+
+func (e *ImageSize) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImageSize(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImageSize", str)
+	}
+	return nil
+}
+
+func (e ImageSize) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MediaType string
+
+const (
+	MediaTypeImage    MediaType = "IMAGE"
+	MediaTypeVector   MediaType = "VECTOR"
+	MediaTypeDocument MediaType = "DOCUMENT"
+	MediaTypeVideo    MediaType = "VIDEO"
+)
+
+var AllMediaType = []MediaType{
+	MediaTypeImage,
+	MediaTypeVector,
+	MediaTypeDocument,
+	MediaTypeVideo,
+}
+
+func (e MediaType) IsValid() bool {
+	switch e {
+	case MediaTypeImage, MediaTypeVector, MediaTypeDocument, MediaTypeVideo:
+		return true
+	}
+	return false
+}
+
+func (e MediaType) String() string {
+	return string(e)
+}
+
+func (e *MediaType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MediaType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MediaType", str)
+	}
+	return nil
+}
+
+func (e MediaType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type PostCategory string
 
 const (
@@ -470,6 +1898,49 @@ func (e PostCategory) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+type PostOrder string
+
+const (
+	PostOrderOpensAtDesc   PostOrder = "OPENS_AT_DESC"
+	PostOrderCreatedAtDesc PostOrder = "CREATED_AT_DESC"
+	PostOrderClosesAtAsc   PostOrder = "CLOSES_AT_ASC"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderOpensAtDesc,
+	PostOrderCreatedAtDesc,
+	PostOrderClosesAtAsc,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderOpensAtDesc, PostOrderCreatedAtDesc, PostOrderClosesAtAsc:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type PostStatus string
 
 const (
@@ -512,3 +1983,216 @@ func (e *PostStatus) UnmarshalGQL(v interface{}) error {
 func (e PostStatus) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type PostVisibility string
+
+const (
+	PostVisibilityPublic    PostVisibility = "PUBLIC"
+	PostVisibilityWorkspace PostVisibility = "WORKSPACE"
+	PostVisibilityUnlisted  PostVisibility = "UNLISTED"
+)
+
+var AllPostVisibility = []PostVisibility{
+	PostVisibilityPublic,
+	PostVisibilityWorkspace,
+	PostVisibilityUnlisted,
+}
+
+func (e PostVisibility) IsValid() bool {
+	switch e {
+	case PostVisibilityPublic, PostVisibilityWorkspace, PostVisibilityUnlisted:
+		return true
+	}
+	return false
+}
+
+func (e PostVisibility) String() string {
+	return string(e)
+}
+
+func (e *PostVisibility) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostVisibility(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostVisibility", str)
+	}
+	return nil
+}
+
+func (e PostVisibility) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ProcessingStatus string
+
+const (
+	ProcessingStatusPending   ProcessingStatus = "PENDING"
+	ProcessingStatusProcessed ProcessingStatus = "PROCESSED"
+	ProcessingStatusFailed    ProcessingStatus = "FAILED"
+)
+
+var AllProcessingStatus = []ProcessingStatus{
+	ProcessingStatusPending,
+	ProcessingStatusProcessed,
+	ProcessingStatusFailed,
+}
+
+func (e ProcessingStatus) IsValid() bool {
+	switch e {
+	case ProcessingStatusPending, ProcessingStatusProcessed, ProcessingStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ProcessingStatus) String() string {
+	return string(e)
+}
+
+func (e *ProcessingStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ProcessingStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ProcessingStatus", str)
+	}
+	return nil
+}
+
+func (e ProcessingStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ResultsVisibility string
+
+const (
+	ResultsVisibilityAnonymous  ResultsVisibility = "ANONYMOUS"
+	ResultsVisibilityAttributed ResultsVisibility = "ATTRIBUTED"
+)
+
+var AllResultsVisibility = []ResultsVisibility{
+	ResultsVisibilityAnonymous,
+	ResultsVisibilityAttributed,
+}
+
+func (e ResultsVisibility) IsValid() bool {
+	switch e {
+	case ResultsVisibilityAnonymous, ResultsVisibilityAttributed:
+		return true
+	}
+	return false
+}
+
+func (e ResultsVisibility) String() string {
+	return string(e)
+}
+
+func (e *ResultsVisibility) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ResultsVisibility(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ResultsVisibility", str)
+	}
+	return nil
+}
+
+func (e ResultsVisibility) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type VotingMode string
+
+const (
+	VotingModeSingle      VotingMode = "SINGLE"
+	VotingModeMultiSelect VotingMode = "MULTI_SELECT"
+	VotingModeRanked      VotingMode = "RANKED"
+)
+
+var AllVotingMode = []VotingMode{
+	VotingModeSingle,
+	VotingModeMultiSelect,
+	VotingModeRanked,
+}
+
+func (e VotingMode) IsValid() bool {
+	switch e {
+	case VotingModeSingle, VotingModeMultiSelect, VotingModeRanked:
+		return true
+	}
+	return false
+}
+
+func (e VotingMode) String() string {
+	return string(e)
+}
+
+func (e *VotingMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VotingMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VotingMode", str)
+	}
+	return nil
+}
+
+func (e VotingMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleOwner  WorkspaceRole = "OWNER"
+	WorkspaceRoleAdmin  WorkspaceRole = "ADMIN"
+	WorkspaceRoleMember WorkspaceRole = "MEMBER"
+)
+
+var AllWorkspaceRole = []WorkspaceRole{
+	WorkspaceRoleOwner,
+	WorkspaceRoleAdmin,
+	WorkspaceRoleMember,
+}
+
+func (e WorkspaceRole) IsValid() bool {
+	switch e {
+	case WorkspaceRoleOwner, WorkspaceRoleAdmin, WorkspaceRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e WorkspaceRole) String() string {
+	return string(e)
+}
+
+func (e *WorkspaceRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WorkspaceRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WorkspaceRole", str)
+	}
+	return nil
+}
+
+func (e WorkspaceRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,200 @@
+package graph
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"quorum-api/oidc"
+	srvcustomer "quorum-api/services/customer"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Binds the sign in to the browser that started it, so a callback url can't
+// be used to sign someone else in
+const oidcStateCookie = "oidc_state"
+
+// OIDCLoginHandler sends the customer to the provider to sign in, from
+// GET /auth/oidc/{provider}/login?returnTo=/some/path.
+func OIDCLoginHandler(
+	providers map[string]*oidc.Provider,
+	customers srvcustomer.SRVCustomer,
+	secureCookies bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[r.PathValue("provider")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		returnTo := r.URL.Query().Get("returnTo")
+		if returnTo == "" {
+			returnTo = "/"
+		}
+		if !strings.HasPrefix(returnTo, "/") || strings.Contains(returnTo, ".") {
+			http.Error(w, "returnTo must be a relative url", http.StatusBadRequest)
+			return
+		}
+
+		codeVerifier := oauth2.GenerateVerifier()
+		nonce, err := newNonce()
+		if err != nil {
+			log.Printf("generating nonce: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		state, err := customers.CreateOIDCLoginState(
+			r.Context(), srvcustomer.CreateOIDCLoginStateRequest{
+				Provider:     provider.Name,
+				CodeVerifier: codeVerifier,
+				Nonce:        nonce,
+				ReturnTo:     returnTo,
+			},
+		)
+		if err != nil {
+			log.Printf("creating oidc login state: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/auth/oidc/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   secureCookies,
+			// Lax so the cookie is sent on the provider's redirect back
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, provider.AuthCodeURL(state, codeVerifier, nonce), http.StatusFound)
+	})
+}
+
+// OIDCCallbackHandler is where the provider redirects back to, at
+// GET /auth/oidc/{provider}/callback. The customer is sent on to the
+// frontend's /verify page with a login token, the same as a login link, which
+// exchanges it for a session with verifyCustomerToken. Failures are sent to
+// /login?error= with one of cancelled, expired, email_not_verified or failed.
+func OIDCCallbackHandler(
+	providers map[string]*oidc.Provider,
+	customers srvcustomer.SRVCustomer,
+	frontendURL string,
+	secureCookies bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[r.PathValue("provider")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		fail := func(reason string) {
+			http.Redirect(w, r, fmt.Sprintf("%s/login?error=%s", frontendURL, reason), http.StatusFound)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Path:     "/auth/oidc/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   secureCookies,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		query := r.URL.Query()
+		if query.Get("error") != "" {
+			fail("cancelled")
+			return
+		}
+
+		state := query.Get("state")
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || state == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			fail("expired")
+			return
+		}
+
+		loginState, err := customers.ConsumeOIDCLoginState(
+			r.Context(), srvcustomer.ConsumeOIDCLoginStateRequest{
+				Provider: provider.Name,
+				State:    state,
+			},
+		)
+		if err != nil {
+			if errors.Is(err, srvcustomer.ErrOIDCLoginStateInvalid) {
+				fail("expired")
+				return
+			}
+			log.Printf("consuming oidc login state: %v", err)
+			fail("failed")
+			return
+		}
+
+		claims, err := provider.Exchange(
+			r.Context(), query.Get("code"), loginState.CodeVerifier, loginState.Nonce,
+		)
+		if err != nil {
+			log.Printf("oidc %s: %v", provider.Name, err)
+			fail("failed")
+			return
+		}
+
+		request := srvcustomer.SignInWithIdentityRequest{
+			Provider:      provider.Name,
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: bool(claims.EmailVerified),
+		}
+		if claims.GivenName != "" {
+			request.FirstName = &claims.GivenName
+		}
+		if claims.FamilyName != "" {
+			request.LastName = &claims.FamilyName
+		}
+		customerID, err := customers.SignInWithIdentity(r.Context(), request)
+		switch {
+		case err == nil:
+			// continue
+		case errors.Is(err, srvcustomer.ErrIdentityEmailNotVerified),
+			errors.Is(err, srvcustomer.ErrInvalidEmail):
+			fail("email_not_verified")
+			return
+		default:
+			log.Printf("signing in with identity: %v", err)
+			fail("failed")
+			return
+		}
+
+		token, err := customers.CreateLoginToken(
+			r.Context(), nil, srvcustomer.CreateLoginTokenRequest{
+				CustomerID: customerID,
+			},
+		)
+		if err != nil {
+			log.Printf("creating login token for %s: %v", customerID, err)
+			fail("failed")
+			return
+		}
+		queryParams := url.Values{}
+		queryParams.Add("returnTo", loginState.ReturnTo)
+		queryParams.Add("token", token)
+		http.Redirect(w, r, fmt.Sprintf("%s/verify?%s", frontendURL, queryParams.Encode()), http.StatusFound)
+	})
+}
+
+func newNonce() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,349 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"quorum-api/database"
+	"quorum-api/oidc"
+	"quorum-api/oidc/oidctest"
+	srvcustomer "quorum-api/services/customer"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const oidcTestFrontendURL = "http://app.test"
+
+// fakeCustomerService keeps sign in state, customers and their linked
+// identities in memory, following the same rules as the real service.
+type fakeCustomerService struct {
+	srvcustomer.SRVCustomer
+	mu          sync.Mutex
+	loginStates map[string]fakeLoginState
+	// Verified customers, keyed by email
+	customers map[string]uuid.UUID
+	// Keyed by provider and subject
+	identities  map[string]uuid.UUID
+	loginTokens map[string]uuid.UUID
+}
+
+type fakeLoginState struct {
+	provider string
+	state    srvcustomer.OIDCLoginState
+}
+
+func newFakeCustomerService() *fakeCustomerService {
+	return &fakeCustomerService{
+		loginStates: map[string]fakeLoginState{},
+		customers:   map[string]uuid.UUID{},
+		identities:  map[string]uuid.UUID{},
+		loginTokens: map[string]uuid.UUID{},
+	}
+}
+
+func (s *fakeCustomerService) CreateOIDCLoginState(
+	ctx context.Context, request srvcustomer.CreateOIDCLoginStateRequest,
+) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := uuid.NewString()
+	s.loginStates[state] = fakeLoginState{
+		provider: request.Provider,
+		state: srvcustomer.OIDCLoginState{
+			CodeVerifier: request.CodeVerifier,
+			Nonce:        request.Nonce,
+			ReturnTo:     request.ReturnTo,
+		},
+	}
+	return state, nil
+}
+
+func (s *fakeCustomerService) ConsumeOIDCLoginState(
+	ctx context.Context, request srvcustomer.ConsumeOIDCLoginStateRequest,
+) (*srvcustomer.OIDCLoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loginState, ok := s.loginStates[request.State]
+	delete(s.loginStates, request.State)
+	if !ok || loginState.provider != request.Provider {
+		return nil, srvcustomer.ErrOIDCLoginStateInvalid
+	}
+	return &loginState.state, nil
+}
+
+func (s *fakeCustomerService) SignInWithIdentity(
+	ctx context.Context, request srvcustomer.SignInWithIdentityRequest,
+) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s:%s", request.Provider, request.Subject)
+	if customerID, ok := s.identities[key]; ok {
+		return customerID, nil
+	}
+	if !request.EmailVerified {
+		return uuid.Nil, srvcustomer.ErrIdentityEmailNotVerified
+	}
+	customerID, ok := s.customers[request.Email]
+	if !ok {
+		customerID = uuid.New()
+		s.customers[request.Email] = customerID
+	}
+	s.identities[key] = customerID
+	return customerID, nil
+}
+
+func (s *fakeCustomerService) CreateLoginToken(
+	ctx context.Context, tx database.Q, request srvcustomer.CreateLoginTokenRequest,
+) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := uuid.NewString()
+	s.loginTokens[token] = request.CustomerID
+	return token, nil
+}
+
+type oidcTest struct {
+	t         *testing.T
+	issuer    *oidctest.Issuer
+	provider  *oidc.Provider
+	customers *fakeCustomerService
+	mux       *http.ServeMux
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+	issuer, err := oidctest.NewIssuer("quorum", "secret")
+	if err != nil {
+		t.Fatalf("starting issuer: %v", err)
+	}
+	t.Cleanup(issuer.Close)
+
+	provider, err := oidc.New(context.Background(), oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "http://api.test/auth/oidc/test/callback",
+	})
+	if err != nil {
+		t.Fatalf("creating provider: %v", err)
+	}
+
+	customers := newFakeCustomerService()
+	providers := map[string]*oidc.Provider{"test": provider}
+	mux := http.NewServeMux()
+	mux.Handle("GET /auth/oidc/{provider}/login", OIDCLoginHandler(providers, customers, false))
+	mux.Handle("GET /auth/oidc/{provider}/callback", OIDCCallbackHandler(
+		providers, customers, oidcTestFrontendURL, false,
+	))
+
+	return &oidcTest{
+		t:         t,
+		issuer:    issuer,
+		provider:  provider,
+		customers: customers,
+		mux:       mux,
+	}
+}
+
+// login starts a sign in, returning the state cookie and where the customer
+// was sent to sign in.
+func (ot *oidcTest) login() (*http.Cookie, string) {
+	ot.t.Helper()
+	rec := httptest.NewRecorder()
+	ot.mux.ServeHTTP(rec, httptest.NewRequest(
+		http.MethodGet, "/auth/oidc/test/login?returnTo=/post/123", nil,
+	))
+	if rec.Code != http.StatusFound {
+		ot.t.Fatalf("got login status %v", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie {
+		ot.t.Fatalf("got cookies %v", cookies)
+	}
+	return cookies[0], rec.Header().Get("Location")
+}
+
+// callback is the provider redirecting back, returning where the customer
+// ends up.
+func (ot *oidcTest) callback(query url.Values, cookie *http.Cookie) *url.URL {
+	ot.t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	ot.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		ot.t.Fatalf("got callback status %v", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		ot.t.Fatalf("parsing redirect: %v", err)
+	}
+	return location
+}
+
+// signIn goes through the whole flow as the identity.
+func (ot *oidcTest) signIn(identity oidctest.Identity) *url.URL {
+	ot.t.Helper()
+	cookie, authCodeURL := ot.login()
+	code, err := ot.issuer.Authorize(authCodeURL, identity)
+	if err != nil {
+		ot.t.Fatalf("authorizing: %v", err)
+	}
+	return ot.callback(url.Values{
+		"code":  {code},
+		"state": {cookie.Value},
+	}, cookie)
+}
+
+func assertLoginError(t *testing.T, location *url.URL, reason string) {
+	t.Helper()
+	if location.Path != "/login" || location.Query().Get("error") != reason {
+		t.Errorf("got redirect to %s, want /login?error=%s", location, reason)
+	}
+}
+
+func TestOIDCLinksExistingCustomerByVerifiedEmail(t *testing.T) {
+	ot := newOIDCTest(t)
+	existingID := uuid.New()
+	ot.customers.customers["voter@example.com"] = existingID
+
+	location := ot.signIn(oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: true,
+	})
+
+	if location.Path != "/verify" || location.Query().Get("returnTo") != "/post/123" {
+		t.Fatalf("got redirect to %s", location)
+	}
+	token := location.Query().Get("token")
+	if got := ot.customers.loginTokens[token]; got != existingID {
+		t.Errorf("got login token for %s, want existing customer %s", got, existingID)
+	}
+	if got := ot.customers.identities["test:sub-1"]; got != existingID {
+		t.Errorf("got identity linked to %s, want %s", got, existingID)
+	}
+}
+
+func TestOIDCDoesNotLinkUnverifiedEmail(t *testing.T) {
+	ot := newOIDCTest(t)
+	ot.customers.customers["voter@example.com"] = uuid.New()
+
+	location := ot.signIn(oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: false,
+	})
+
+	assertLoginError(t, location, "email_not_verified")
+	if len(ot.customers.identities) != 0 {
+		t.Errorf("got identities %v, want none linked", ot.customers.identities)
+	}
+	if len(ot.customers.loginTokens) != 0 {
+		t.Error("login token was created")
+	}
+}
+
+func TestOIDCChecksStateCookie(t *testing.T) {
+	ot := newOIDCTest(t)
+	cookie, authCodeURL := ot.login()
+	code, err := ot.issuer.Authorize(authCodeURL, oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+	query := url.Values{
+		"code":  {code},
+		"state": {cookie.Value},
+	}
+
+	// A callback url opened in a browser that didn't start the sign in
+	assertLoginError(t, ot.callback(query, nil), "expired")
+	otherCookie := *cookie
+	otherCookie.Value = uuid.NewString()
+	assertLoginError(t, ot.callback(query, &otherCookie), "expired")
+	if len(ot.customers.loginTokens) != 0 {
+		t.Error("login token was created")
+	}
+
+	// The state is still usable by the browser that started it, but only once
+	location := ot.callback(query, cookie)
+	if location.Path != "/verify" {
+		t.Fatalf("got redirect to %s", location)
+	}
+	assertLoginError(t, ot.callback(query, cookie), "expired")
+}
+
+func TestOIDCChecksCodeVerifier(t *testing.T) {
+	ot := newOIDCTest(t)
+	cookie, authCodeURL := ot.login()
+	code, err := ot.issuer.Authorize(authCodeURL, oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	// As if the code was intercepted and redeemed with another sign in's
+	// verifier
+	ot.customers.mu.Lock()
+	loginState := ot.customers.loginStates[cookie.Value]
+	loginState.state.CodeVerifier = "not-the-verifier-the-challenge-was-made-from"
+	ot.customers.loginStates[cookie.Value] = loginState
+	ot.customers.mu.Unlock()
+
+	location := ot.callback(url.Values{
+		"code":  {code},
+		"state": {cookie.Value},
+	}, cookie)
+
+	assertLoginError(t, location, "failed")
+	if !slices.Contains(ot.issuer.Rejected(), "code_verifier mismatch") {
+		t.Errorf("got rejections %q, want a code_verifier mismatch", ot.issuer.Rejected())
+	}
+	if len(ot.customers.identities) != 0 {
+		t.Errorf("got identities %v, want none linked", ot.customers.identities)
+	}
+}
+
+func TestOIDCChecksNonce(t *testing.T) {
+	ot := newOIDCTest(t)
+	otherNonce := "from-another-sign-in"
+
+	location := ot.signIn(oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: true,
+		Nonce:         &otherNonce,
+	})
+
+	assertLoginError(t, location, "failed")
+	if len(ot.customers.identities) != 0 {
+		t.Errorf("got identities %v, want none linked", ot.customers.identities)
+	}
+}
+
+func TestOIDCCancelled(t *testing.T) {
+	ot := newOIDCTest(t)
+	cookie, _ := ot.login()
+
+	location := ot.callback(url.Values{
+		"error": {"access_denied"},
+		"state": {cookie.Value},
+	}, cookie)
+
+	assertLoginError(t, location, "cancelled")
+}
@@ -3,18 +3,233 @@
 package graph
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"quorum-api/database"
+	"quorum-api/graph/model"
+	"quorum-api/keyring"
+	srvcomment "quorum-api/services/comment"
 	srvcommunications "quorum-api/services/communications"
 	srvcustomer "quorum-api/services/customer"
 	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/google/uuid"
 )
 
 type Resolver struct {
-	JWTSecret string
-	Services  Services
+	Keys     *keyring.KeyRing
+	Services Services
 }
 
 type Services struct {
 	Customer       srvcustomer.SRVCustomer
 	Post           srvpost.SRVPost
 	Communications srvcommunications.SRVCommunications
+	Comment        srvcomment.SRVComment
+	Workspace      srvworkspace.SRVWorkspace
+}
+
+// enqueueLoginLink emails the customer a single use link that logs them in and
+// sends them back to returnTo. Earlier links sent to the customer stop
+// working. Pass a tx to enqueue it in the same tx as another change, or nil.
+func (r *Resolver) enqueueLoginLink(
+	ctx context.Context,
+	tx database.Q,
+	customerID uuid.UUID,
+	email string,
+	firstName *string,
+	returnTo string,
+) error {
+	token, err := r.Services.Customer.CreateLoginToken(
+		ctx, tx, srvcustomer.CreateLoginTokenRequest{
+			CustomerID: customerID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("creating login token: %w", err)
+	}
+	queryParams := url.Values{}
+	queryParams.Add("returnTo", returnTo)
+	queryParams.Add("token", token)
+	confirmationLink := fmt.Sprintf("%s/verify?%s", os.Getenv("FRONTEND_URL"), queryParams.Encode())
+	if err = r.Services.Communications.EnqueueEmail(ctx, tx, srvcommunications.EnqueueEmailRequest{
+		ToEmail:  email,
+		Template: srvcommunications.TemplateLoginLink,
+		Subject:  "Log in to Quorum",
+		Variables: map[string]interface{}{
+			"first_name":        firstName,
+			"confirmation_link": confirmationLink,
+		},
+	}); err != nil {
+		return fmt.Errorf("enqueueing email: %w", err)
+	}
+	return nil
+}
+
+// How long access tokens last before they need to be refreshed with the
+// session's refresh token
+const accessTokenTTL = 15 * time.Minute
+
+func (r *Resolver) newAccessToken(customerID uuid.UUID, sessionID uuid.UUID) (string, error) {
+	tokenString, err := r.Keys.Sign(JWTClaims{
+		IsVerified: true,
+		SessionID:  sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Subject:   customerID.String(),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// postConnection fetches a page of posts matching request, first must be
+// between 1 and 100.
+func (r *Resolver) postConnection(
+	ctx context.Context,
+	first int,
+	after *string,
+	request srvpost.GetPostsByFilterRequest,
+) (*model.PostConnection, error) {
+	if first < 1 || first > 100 {
+		return nil, fmt.Errorf("first must be between 1 and 100")
+	}
+
+	request.Limit = first + 1
+	if after != nil {
+		cursor, err := srvpost.DecodePostCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		request.After = cursor
+	}
+
+	posts, err := r.Services.Post.GetPostsByFilter(ctx, request)
+	if err != nil {
+		if errors.Is(err, srvpost.ErrInvalidCursor) {
+			return nil, err
+		}
+		panic(fmt.Errorf("getting posts: %w", err))
+	}
+
+	hasNextPage := len(posts) > first
+	if hasNextPage {
+		posts = posts[:first]
+	}
+
+	edges := []*model.PostEdge{}
+	for i := range posts {
+		post := posts[i]
+		edges = append(edges, &model.PostEdge{
+			Cursor: post.Cursor(request.OrderBy).Encode(),
+			Node:   &post,
+		})
+	}
+
+	pageInfo := &model.PageInfo{
+		HasNextPage: hasNextPage,
+	}
+	if len(edges) > 0 {
+		pageInfo.StartCursor = &edges[0].Cursor
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.PostConnection{
+		Edges:    edges,
+		PageInfo: pageInfo,
+	}, nil
+}
+
+// postViewer is the logged in customer and the workspaces they're a member
+// of, which decide the posts they can see and vote on.
+func (r *Resolver) postViewer(ctx context.Context) srvpost.PostViewer {
+	viewer := srvpost.PostViewer{
+		CustomerID: GetVerifiedCustomer(ctx),
+	}
+	if !viewer.CustomerID.Valid {
+		return viewer
+	}
+	memberships, err := GetLoaders(ctx).CustomerMembershipsLoader.Load(ctx, viewer.CustomerID.UUID)
+	if err != nil {
+		panic(fmt.Errorf("loading memberships: %w", err))
+	}
+	for _, m := range memberships {
+		viewer.WorkspaceIDs = append(viewer.WorkspaceIDs, m.WorkspaceID)
+	}
+	return viewer
+}
+
+// workspaceMembership is the logged in customer's membership of the
+// workspace, or nil if they aren't a member.
+func (r *Resolver) workspaceMembership(
+	ctx context.Context, workspaceID uuid.UUID,
+) *srvworkspace.Member {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return nil
+	}
+	memberships, err := GetLoaders(ctx).CustomerMembershipsLoader.Load(ctx, verifiedCustomer.UUID)
+	if err != nil {
+		panic(fmt.Errorf("loading memberships: %w", err))
+	}
+	for _, m := range memberships {
+		if m.WorkspaceID == workspaceID {
+			return m
+		}
+	}
+	return nil
+}
+
+// loadWorkspace reloads the workspace after a mutation changed it, or its
+// members, returning nil if the logged in customer is no longer a member.
+func (r *Resolver) loadWorkspace(
+	ctx context.Context, workspaceID uuid.UUID,
+) *srvworkspace.Workspace {
+	loaders := GetLoaders(ctx)
+	loaders.WorkspaceLoader.Clear(workspaceID)
+	loaders.WorkspaceMembersLoader.Clear(workspaceID)
+	if verifiedCustomer := GetVerifiedCustomer(ctx); verifiedCustomer.Valid {
+		loaders.CustomerMembershipsLoader.Clear(verifiedCustomer.UUID)
+	}
+	if r.workspaceMembership(ctx, workspaceID) == nil {
+		return nil
+	}
+	workspace, err := loaders.WorkspaceLoader.Load(ctx, workspaceID)
+	if err != nil {
+		panic(fmt.Errorf("loading workspace: %w", err))
+	}
+	return workspace
+}
+
+// newPostInviteLink returns the link to the post with the invite's token for
+// voters to open. Only works on invites just returned by CreateInvites.
+func newPostInviteLink(invite srvpost.Invite) (string, error) {
+	if invite.Token == "" {
+		return "", fmt.Errorf("invite %s has no token", invite.ID)
+	}
+	queryParams := url.Values{}
+	queryParams.Add("invite", invite.Token)
+	return fmt.Sprintf("%s/posts/%s?%s", os.Getenv("FRONTEND_URL"), invite.PostID, queryParams.Encode()), nil
+}
+
+func criterionResponses(input []*model.CriterionResponseInput) []srvpost.CriterionResponse {
+	responses := []srvpost.CriterionResponse{}
+	for _, c := range input {
+		responses = append(responses, srvpost.CriterionResponse{
+			CriterionID: c.CriterionID,
+			Score:       c.Score,
+		})
+	}
+	return responses
 }
@@ -8,19 +8,93 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/url"
 	"os"
+	"quorum-api/database"
 	"quorum-api/graph/model"
+	srvcomment "quorum-api/services/comment"
 	srvcommunications "quorum-api/services/communications"
 	srvcustomer "quorum-api/services/customer"
 	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
 	"strings"
 	"time"
 
-	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// Post is the resolver for the post field.
+func (r *commentResolver) Post(ctx context.Context, obj *srvcomment.Comment) (*srvpost.Post, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+	return post, nil
+}
+
+// Option is the resolver for the option field.
+func (r *commentResolver) Option(ctx context.Context, obj *srvcomment.Comment) (*srvpost.Option, error) {
+	if obj.OptionID == nil {
+		return nil, nil
+	}
+	option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, *obj.OptionID)
+	if err != nil {
+		panic(fmt.Errorf("loading option: %w", err))
+	}
+	return option, nil
+}
+
+// Parent is the resolver for the parent field.
+func (r *commentResolver) Parent(ctx context.Context, obj *srvcomment.Comment) (*srvcomment.Comment, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	parent, err := GetLoaders(ctx).CommentLoader.Load(ctx, *obj.ParentID)
+	if err != nil {
+		panic(fmt.Errorf("loading parent comment: %w", err))
+	}
+	return parent, nil
+}
+
+// Replies is the resolver for the replies field.
+func (r *commentResolver) Replies(ctx context.Context, obj *srvcomment.Comment) ([]*srvcomment.Comment, error) {
+	replies, err := GetLoaders(ctx).CommentRepliesLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading replies: %w", err))
+	}
+	if replies == nil {
+		return []*srvcomment.Comment{}, nil
+	}
+	return replies, nil
+}
+
+// Author is the resolver for the author field.
+func (r *commentResolver) Author(ctx context.Context, obj *srvcomment.Comment) (*srvcustomer.Customer, error) {
+	if obj.DeletedAt != nil {
+		return nil, nil
+	}
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.AuthorID)
+	if err != nil {
+		panic(fmt.Errorf("loading author: %w", err))
+	}
+	return customer, nil
+}
+
+// IsPostAuthor is the resolver for the isPostAuthor field.
+func (r *commentResolver) IsPostAuthor(ctx context.Context, obj *srvcomment.Comment) (bool, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+	return post != nil && post.AuthorID == obj.AuthorID, nil
+}
+
+// IsDeleted is the resolver for the isDeleted field.
+func (r *commentResolver) IsDeleted(ctx context.Context, obj *srvcomment.Comment) (bool, error) {
+	return obj.DeletedAt != nil, nil
+}
+
 // SignUp is the resolver for the signUp field.
 func (r *mutationResolver) SignUp(ctx context.Context, input model.SignUpInput) (*model.SignUpPayload, error) {
 	if strings.Contains(input.ReturnTo, ".") {
@@ -33,12 +107,15 @@ func (r *mutationResolver) SignUp(ctx context.Context, input model.SignUpInput)
 			},
 		}, nil
 	}
-	customerID, err := r.Services.Customer.CreateUnverifiedCustomer(ctx,
+	_, err := r.Services.Customer.CreateUnverifiedCustomer(ctx,
 		srvcustomer.CreateUnverifiedCustomerRequest{
 			Email:      input.Email,
 			FirstName:  &input.FirstName,
 			LastName:   &input.LastName,
 			Profession: &input.Profession,
+			OnCreate: func(ctx context.Context, tx database.Q, customerID uuid.UUID) error {
+				return r.enqueueLoginLink(ctx, tx, customerID, input.Email, &input.FirstName, input.ReturnTo)
+			},
 		},
 	)
 	switch err {
@@ -56,7 +133,11 @@ func (r *mutationResolver) SignUp(ctx context.Context, input model.SignUpInput)
 		if len(customers) != 1 {
 			panic("expected exactly 1 customer")
 		}
-		customerID = customers[0].ID
+		if err = r.enqueueLoginLink(
+			ctx, nil, customers[0].ID, input.Email, &input.FirstName, input.ReturnTo,
+		); err != nil {
+			panic(err)
+		}
 	case srvcustomer.ErrInvalidEmail:
 		return &model.SignUpPayload{
 			Errors: []model.SignUpError{
@@ -69,35 +150,9 @@ func (r *mutationResolver) SignUp(ctx context.Context, input model.SignUpInput)
 	default:
 		panic(err)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
-		IsVerified: false,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Subject:   customerID.String(),
-		},
-	})
-	tokenString, err := token.SignedString([]byte(r.JWTSecret))
-	if err != nil {
-		panic(err)
-	}
-	queryParams := url.Values{}
-	queryParams.Add("returnTo", input.ReturnTo)
-	queryParams.Add("token", tokenString)
-	confirmationLink := fmt.Sprintf("%s/verify?%s", os.Getenv("FRONTEND_URL"), queryParams.Encode())
-	if err = r.Services.Communications.SendEmail(srvcommunications.SendEmailRequest{
-		ToEmail:    input.Email,
-		TemplateID: 5834186,
-		Subject:    "Verify your Quorum Account",
-		Variables: map[string]interface{}{
-			"first_name":        input.FirstName,
-			"confirmation_link": confirmationLink,
-		},
-	}); err != nil {
-		panic(err)
-	}
-	return &model.SignUpPayload{}, nil
+	return &model.SignUpPayload{
+		Errors: []model.SignUpError{},
+	}, nil
 }
 
 // GetLoginLink is the resolver for the getLoginLink field.
@@ -132,197 +187,282 @@ func (r *mutationResolver) GetLoginLink(ctx context.Context, input model.GetLogi
 	}
 
 	customer := customers[0]
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
-		IsVerified: false,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Subject:   customer.ID.String(),
-		},
-	})
-	tokenString, err := token.SignedString([]byte(r.JWTSecret))
-	if err != nil {
-		panic(err)
-	}
-	queryParams := url.Values{}
-	queryParams.Add("returnTo", input.ReturnTo)
-	queryParams.Add("token", tokenString)
-	confirmationLink := fmt.Sprintf("%s/verify?%s", os.Getenv("FRONTEND_URL"), queryParams.Encode())
-	if err = r.Services.Communications.SendEmail(srvcommunications.SendEmailRequest{
-		ToEmail:    customer.Email,
-		TemplateID: 5834186,
-		Subject:    "Verify your Quorum Account",
-		Variables: map[string]interface{}{
-			"first_name":        customer.FirstName,
-			"confirmation_link": confirmationLink,
-		},
-	}); err != nil {
+	if err = r.enqueueLoginLink(
+		ctx, nil, customer.ID, customer.Email, customer.FirstName, input.ReturnTo,
+	); err != nil {
 		panic(err)
 	}
-	return &model.GetLoginLinkPayload{}, nil
+	return &model.GetLoginLinkPayload{
+		Errors: []model.GetLoginLinkError{},
+	}, nil
 }
 
 // VerifyCustomerToken is the resolver for the verifyCustomerToken field.
 func (r *mutationResolver) VerifyCustomerToken(ctx context.Context, input model.VerifyCustomerTokenInput) (*model.VerifyCustomerTokenPayload, error) {
-	token, err := jwt.ParseWithClaims(
-		input.Token, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(r.JWTSecret), nil
-		})
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return &model.VerifyCustomerTokenPayload{
-				Errors: []model.VerifyCustomerTokenError{
-					&model.LinkExpiredError{
-						Message: "Link has expired, either call sign up (new customers), or generate a new login link (existing customers)",
-					},
+	customerID, err := r.Services.Customer.VerifyLoginToken(ctx, input.Token)
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvcustomer.ErrLoginTokenUsed):
+		return &model.VerifyCustomerTokenPayload{
+			Errors: []model.VerifyCustomerTokenError{
+				&model.LinkAlreadyUsedError{
+					Message: "Link has already been used, generate a new login link",
 				},
-			}, nil
-		}
-		panic(fmt.Errorf("parsing token: %v", err))
-	} else if claims, ok := token.Claims.(*JWTClaims); ok {
-		if claims.IsVerified {
-			panic(fmt.Errorf("expected token to not be verified"))
-		}
-		customerID, err := uuid.Parse(claims.Subject)
-		if err != nil {
-			panic(fmt.Errorf("expected customerID to be a uuid"))
-		}
-		if err = r.Services.Customer.VerifyCustomer(ctx, customerID); err != nil {
-			panic(fmt.Errorf("verifying customer: %v", err))
-		}
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
-			IsVerified: true,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().AddDate(1, 0, 0)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				NotBefore: jwt.NewNumericDate(time.Now()),
-				Subject:   customerID.String(),
 			},
-		})
-		tokenString, err := token.SignedString([]byte(r.JWTSecret))
-		if err != nil {
-			panic(err)
-		}
-		customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, customerID)
-		if err != nil {
-			panic(err)
-		}
+		}, nil
+	case errors.Is(err, srvcustomer.ErrLoginTokenRevoked):
 		return &model.VerifyCustomerTokenPayload{
-			NewToken: &tokenString,
-			Customer: customer,
+			Errors: []model.VerifyCustomerTokenError{
+				&model.LinkExpiredError{
+					Message: "A newer link has been sent, use the link in the latest email",
+				},
+			},
 		}, nil
-	}
-	panic(fmt.Errorf("unknown claims type, cannot proceed"))
-}
-
-// UpsertPost is the resolver for the upsertPost field.
-func (r *mutationResolver) UpsertPost(ctx context.Context, input model.UpsertPostInput) (*model.UpsertPostPayload, error) {
-	verifiedCustomer := GetVerifiedCustomer(ctx)
-	if !verifiedCustomer.Valid {
-		return &model.UpsertPostPayload{
-			Errors: []model.UpsertPostError{
-				model.UnauthenticatedError{
-					Message: "Author of post unknown - try logging again",
+	case errors.Is(err, srvcustomer.ErrLoginTokenExpired),
+		errors.Is(err, srvcustomer.ErrLoginTokenInvalid):
+		return &model.VerifyCustomerTokenPayload{
+			Errors: []model.VerifyCustomerTokenError{
+				&model.LinkExpiredError{
+					Message: "Link has expired, either call sign up (new customers), or generate a new login link (existing customers)",
 				},
 			},
 		}, nil
+	default:
+		panic(fmt.Errorf("verifying login token: %w", err))
 	}
 
-	options := []*srvpost.UpsertPostOptionRequest{}
-	for _, o := range input.Options {
-		options = append(options, &srvpost.UpsertPostOptionRequest{
-			Position:   o.Position,
-			ID:         o.ID,
-			BucketName: o.BucketName,
-			FileKey:    o.FileKey,
-		})
+	client := GetClientInfo(ctx)
+	session, err := r.Services.Customer.CreateSession(
+		ctx, srvcustomer.CreateSessionRequest{
+			CustomerID: customerID,
+			IPAddress:  client.IPAddress,
+			UserAgent:  client.UserAgent,
+		},
+	)
+	if err != nil {
+		panic(fmt.Errorf("creating session: %w", err))
+	}
+	tokenString, err := r.newAccessToken(customerID, session.SessionID)
+	if err != nil {
+		panic(err)
+	}
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, customerID)
+	if err != nil {
+		panic(err)
 	}
+	return &model.VerifyCustomerTokenPayload{
+		NewToken:     &tokenString,
+		RefreshToken: &session.RefreshToken,
+		Customer:     customer,
+		Errors:       []model.VerifyCustomerTokenError{},
+	}, nil
+}
 
-	err := r.Services.Post.UpsertPost(ctx, srvpost.UpsertPostRequest{
-		ID:          input.ID,
-		Options:     options,
-		DesignPhase: (*srvpost.DesignPhase)(input.DesignPhase),
-		Category:    (*srvpost.PostCategory)(input.Category),
-		OpensAt:     input.OpensAt,
-		ClosesAt:    input.ClosesAt,
-		AuthorID:    verifiedCustomer.UUID,
-		Context:     input.Context,
-	})
-	if errors.Is(err, srvpost.ErrOpensAtAlreadyPassed) {
-		return &model.UpsertPostPayload{
-			Errors: []model.UpsertPostError{
-				model.OpensAtAlreadyPassedError{
-					Message: err.Error(),
+// RefreshSession is the resolver for the refreshSession field.
+func (r *mutationResolver) RefreshSession(ctx context.Context, input model.RefreshSessionInput) (*model.RefreshSessionPayload, error) {
+	client := GetClientInfo(ctx)
+	session, err := r.Services.Customer.RefreshSession(
+		ctx, srvcustomer.RefreshSessionRequest{
+			RefreshToken: input.RefreshToken,
+			IPAddress:    client.IPAddress,
+			UserAgent:    client.UserAgent,
+		},
+	)
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvcustomer.ErrRefreshTokenInvalid):
+		return &model.RefreshSessionPayload{
+			Errors: []model.RefreshSessionError{
+				&model.InvalidRefreshTokenError{
+					Message: "Session has ended, generate a new login link",
+					Path:    []string{"input", "refreshToken"},
 				},
 			},
 		}, nil
-	}
-	if errors.Is(err, srvpost.ErrPostNotOwned) {
-		return &model.UpsertPostPayload{
-			Errors: []model.UpsertPostError{
-				model.ErrPostNotOwned{
-					Message: err.Error(),
+	case errors.Is(err, srvcustomer.ErrRefreshTokenReused):
+		return &model.RefreshSessionPayload{
+			Errors: []model.RefreshSessionError{
+				&model.InvalidRefreshTokenError{
+					Message: "Refresh token was already used so the session has been ended, generate a new login link",
+					Path:    []string{"input", "refreshToken"},
 				},
 			},
 		}, nil
+	default:
+		panic(fmt.Errorf("refreshing session: %w", err))
 	}
-	if errors.Is(err, srvpost.ErrTooFewOptions) {
-		return &model.UpsertPostPayload{
-			Errors: []model.UpsertPostError{
-				model.TooFewOptionsError{
-					Message: err.Error(),
+
+	token, err := r.newAccessToken(session.CustomerID, session.SessionID)
+	if err != nil {
+		panic(err)
+	}
+	return &model.RefreshSessionPayload{
+		Token:        &token,
+		RefreshToken: &session.RefreshToken,
+		Errors:       []model.RefreshSessionError{},
+	}, nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (*model.LogoutPayload, error) {
+	sessionID := GetSessionID(ctx)
+	if !sessionID.Valid {
+		return &model.LogoutPayload{
+			Errors: []model.LogoutError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
 				},
 			},
 		}, nil
 	}
-	if errors.Is(err, srvpost.ErrTooManyOptions) {
-		return &model.UpsertPostPayload{
-			Errors: []model.UpsertPostError{
-				model.TooManyOptionsError{
-					Message: err.Error(),
+	if err := r.Services.Customer.RevokeSessions(
+		ctx, srvcustomer.RevokeSessionsRequest{
+			IDs: []uuid.UUID{sessionID.UUID},
+		},
+	); err != nil {
+		panic(fmt.Errorf("revoking session: %w", err))
+	}
+	return &model.LogoutPayload{
+		Errors: []model.LogoutError{},
+	}, nil
+}
+
+// LogoutAllSessions is the resolver for the logoutAllSessions field.
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (*model.LogoutAllSessionsPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.LogoutAllSessionsPayload{
+			Errors: []model.LogoutAllSessionsError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
 				},
 			},
 		}, nil
 	}
-	if err != nil {
-		panic(fmt.Errorf("creating post: %w", err))
+	if err := r.Services.Customer.RevokeSessions(
+		ctx, srvcustomer.RevokeSessionsRequest{
+			CustomerIDs: []uuid.UUID{verifiedCustomer.UUID},
+		},
+	); err != nil {
+		panic(fmt.Errorf("revoking sessions: %w", err))
 	}
+	if err := r.Services.Customer.RevokeLoginTokens(ctx, verifiedCustomer.UUID); err != nil {
+		panic(fmt.Errorf("revoking login tokens: %w", err))
+	}
+	return &model.LogoutAllSessionsPayload{
+		Errors: []model.LogoutAllSessionsError{},
+	}, nil
+}
 
-	post, err := GetLoaders(ctx).PostLoader.Load(ctx, input.ID)
+// UpdateProfile is the resolver for the updateProfile field.
+func (r *mutationResolver) UpdateProfile(ctx context.Context, input model.UpdateProfileInput) (*model.UpdateProfilePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.UpdateProfilePayload{
+			Errors: []model.UpdateProfileError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+	if err := r.Services.Customer.UpdateCustomer(ctx, srvcustomer.UpdateCustomerRequest{
+		ID:            verifiedCustomer.UUID,
+		FirstName:     input.FirstName,
+		LastName:      input.LastName,
+		Profession:    input.Profession,
+		DisplayName:   input.DisplayName,
+		Bio:           input.Bio,
+		PortfolioURL:  input.PortfolioURL,
+		AvatarFileKey: input.AvatarFileKey,
+	}); err != nil {
+		switch {
+		case errors.Is(err, srvcustomer.ErrInvalidDisplayName):
+			return &model.UpdateProfilePayload{
+				Errors: []model.UpdateProfileError{
+					&model.InvalidProfileError{
+						Message: err.Error(),
+						Path:    []string{"input", "displayName"},
+					},
+				},
+			}, nil
+		case errors.Is(err, srvcustomer.ErrInvalidBio):
+			return &model.UpdateProfilePayload{
+				Errors: []model.UpdateProfileError{
+					&model.InvalidProfileError{
+						Message: err.Error(),
+						Path:    []string{"input", "bio"},
+					},
+				},
+			}, nil
+		case errors.Is(err, srvcustomer.ErrInvalidPortfolioURL):
+			return &model.UpdateProfilePayload{
+				Errors: []model.UpdateProfileError{
+					&model.InvalidProfileError{
+						Message: err.Error(),
+						Path:    []string{"input", "portfolioUrl"},
+					},
+				},
+			}, nil
+		case errors.Is(err, srvcustomer.ErrAvatarTooLarge),
+			errors.Is(err, srvcustomer.ErrUnsupportedAvatarType):
+			return &model.UpdateProfilePayload{
+				Errors: []model.UpdateProfileError{
+					&model.InvalidProfileError{
+						Message: err.Error(),
+						Path:    []string{"input", "avatarFileKey"},
+					},
+				},
+			}, nil
+		case errors.Is(err, srvcustomer.ErrAvatarFileNotFound):
+			return &model.UpdateProfilePayload{
+				Errors: []model.UpdateProfileError{
+					&model.AvatarFileNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "avatarFileKey"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("updating customer: %w", err))
+	}
+	GetLoaders(ctx).CustomerLoader.Clear(verifiedCustomer.UUID)
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, verifiedCustomer.UUID)
 	if err != nil {
-		panic(fmt.Errorf("loading post: %w", err))
+		panic(err)
 	}
-
-	return &model.UpsertPostPayload{
-		Post:   post,
-		Errors: []model.UpsertPostError{},
+	return &model.UpdateProfilePayload{
+		Customer: customer,
+		Errors:   []model.UpdateProfileError{},
 	}, nil
 }
 
-// GenerateSignedPostOptionURL is the resolver for the generateSignedPostOptionUrl field.
-func (r *mutationResolver) GenerateSignedPostOptionURL(ctx context.Context, input model.GenerateSignedPostOptionUrInput) (*model.GenerateSignedPostOptionURLPayload, error) {
+// GenerateSignedAvatarURL is the resolver for the generateSignedAvatarUrl field.
+func (r *mutationResolver) GenerateSignedAvatarURL(ctx context.Context, input model.GenerateSignedAvatarURLInput) (*model.GenerateSignedAvatarURLPayload, error) {
 	verifiedCustomer := GetVerifiedCustomer(ctx)
 	if !verifiedCustomer.Valid {
-		return &model.GenerateSignedPostOptionURLPayload{
-			Errors: []model.GenerateSignedPostOptionURLError{
+		return &model.GenerateSignedAvatarURLPayload{
+			Errors: []model.GenerateSignedAvatarURLError{
 				model.UnauthenticatedError{
-					Message: "Author of post unknown - try logging again",
+					Message: "Not logged in",
 				},
 			},
 		}, nil
 	}
 
-	resp, err := r.Services.Post.GenerateSignedPostOptionURL(
-		ctx, srvpost.GenerateSignedPostOptionURLRequest{
+	resp, err := r.Services.Customer.GenerateSignedAvatarURL(
+		ctx, srvcustomer.GenerateSignedAvatarURLRequest{
+			CustomerID:  verifiedCustomer.UUID,
 			FileName:    input.FileName,
 			ContentType: input.ContentType,
 		},
 	)
 	if err != nil {
-		if errors.Is(err, srvpost.ErrUnsupportedFileType) {
-			return &model.GenerateSignedPostOptionURLPayload{
-				Errors: []model.GenerateSignedPostOptionURLError{
+		if errors.Is(err, srvcustomer.ErrUnsupportedAvatarType) {
+			return &model.GenerateSignedAvatarURLPayload{
+				Errors: []model.GenerateSignedAvatarURLError{
 					model.UnsupportedFileTypeError{
 						Message: err.Error(),
 					},
@@ -332,103 +472,2338 @@ func (r *mutationResolver) GenerateSignedPostOptionURL(ctx context.Context, inpu
 		panic(err)
 	}
 
-	return &model.GenerateSignedPostOptionURLPayload{
-		FileKey:    resp.FileKey,
-		BucketName: resp.BucketName,
-		URL:        resp.URL,
+	return &model.GenerateSignedAvatarURLPayload{
+		FileKey: &resp.FileKey,
+		URL:     &resp.URL,
+		Errors:  []model.GenerateSignedAvatarURLError{},
 	}, nil
 }
 
-// SubmitVote is the resolver for the submitVote field.
-func (r *mutationResolver) SubmitVote(ctx context.Context, input model.SubmitVoteInput) (*model.SubmitVotePayload, error) {
+// RequestEmailChange is the resolver for the requestEmailChange field.
+func (r *mutationResolver) RequestEmailChange(ctx context.Context, input model.RequestEmailChangeInput) (*model.RequestEmailChangePayload, error) {
 	verifiedCustomer := GetVerifiedCustomer(ctx)
 	if !verifiedCustomer.Valid {
-		return &model.SubmitVotePayload{
-			Errors: []model.SubmitVoteError{
+		return &model.RequestEmailChangePayload{
+			Errors: []model.RequestEmailChangeError{
 				model.UnauthenticatedError{
-					Message: "Sign up or login to vote on a post",
+					Message: "Not logged in",
 				},
 			},
 		}, nil
 	}
-
-	resp, err := r.Services.Post.SubmitVote(ctx, srvpost.SubmitVoteRequest{
-		CustomerID: verifiedCustomer.UUID,
-		OptionID:   input.OptionID,
-		Reason:     input.Reason,
-	})
-	if err != nil {
-		if errors.Is(err, srvpost.ErrOptionNotFound) {
-			return &model.SubmitVotePayload{
-				Errors: []model.SubmitVoteError{
-					model.OptionNotFoundError{
-						Message: err.Error(),
-						Path:    []string{"input", "optionId"},
-					},
+	if strings.Contains(input.ReturnTo, ".") {
+		return &model.RequestEmailChangePayload{
+			Errors: []model.RequestEmailChangeError{
+				&model.InvalidReturnToError{
+					Message: "Return to should not contain url scheme or host",
+					Path:    []string{"input", "returnTo"},
 				},
-			}, nil
-		}
+			},
+		}, nil
 	}
-
-	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, verifiedCustomer.UUID)
 	if err != nil {
-		panic(fmt.Errorf("loading post: %w", err))
+		panic(err)
 	}
 
-	return &model.SubmitVotePayload{
-		Post: post,
-	}, nil
-}
-
-// DesignPhase is the resolver for the designPhase field.
-func (r *postResolver) DesignPhase(ctx context.Context, obj *srvpost.Post) (*model.DesignPhase, error) {
-	return (*model.DesignPhase)(obj.DesignPhase), nil
+	err = r.Services.Customer.RequestEmailChange(ctx, srvcustomer.RequestEmailChangeRequest{
+		CustomerID: verifiedCustomer.UUID,
+		NewEmail:   input.NewEmail,
+		OnCreate: func(ctx context.Context, tx database.Q, token string) error {
+			queryParams := url.Values{}
+			queryParams.Add("returnTo", input.ReturnTo)
+			queryParams.Add("token", token)
+			return r.Services.Communications.EnqueueEmail(ctx, tx, srvcommunications.EnqueueEmailRequest{
+				ToEmail:  input.NewEmail,
+				Template: srvcommunications.TemplateEmailChange,
+				Subject:  "Confirm your new Quorum email",
+				Variables: map[string]interface{}{
+					"first_name":        customer.FirstName,
+					"confirmation_link": fmt.Sprintf("%s/confirm-email?%s", os.Getenv("FRONTEND_URL"), queryParams.Encode()),
+				},
+			})
+		},
+	})
+	switch {
+	case err == nil:
+		return &model.RequestEmailChangePayload{
+			Errors: []model.RequestEmailChangeError{},
+		}, nil
+	case errors.Is(err, srvcustomer.ErrInvalidEmail):
+		return &model.RequestEmailChangePayload{
+			Errors: []model.RequestEmailChangeError{
+				&model.InvalidEmailError{
+					Message: "Invalid format for email.",
+					Path:    []string{"input", "newEmail"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvcustomer.ErrEmailTaken):
+		return &model.RequestEmailChangePayload{
+			Errors: []model.RequestEmailChangeError{
+				&model.EmailTakenError{
+					Message: "Another account already uses that email",
+					Path:    []string{"input", "newEmail"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("requesting email change: %w", err))
+	}
+}
+
+// ConfirmEmailChange is the resolver for the confirmEmailChange field.
+func (r *mutationResolver) ConfirmEmailChange(ctx context.Context, input model.ConfirmEmailChangeInput) (*model.ConfirmEmailChangePayload, error) {
+	customerID, err := r.Services.Customer.ConfirmEmailChange(ctx, input.Token)
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvcustomer.ErrEmailChangeTokenUsed):
+		return &model.ConfirmEmailChangePayload{
+			Errors: []model.ConfirmEmailChangeError{
+				&model.LinkAlreadyUsedError{
+					Message: "Email has already been changed using this link",
+				},
+			},
+		}, nil
+	case errors.Is(err, srvcustomer.ErrEmailChangeTokenRevoked):
+		return &model.ConfirmEmailChangePayload{
+			Errors: []model.ConfirmEmailChangeError{
+				&model.LinkExpiredError{
+					Message: "A newer email change has been requested, use the link in the latest email",
+				},
+			},
+		}, nil
+	case errors.Is(err, srvcustomer.ErrEmailChangeTokenExpired),
+		errors.Is(err, srvcustomer.ErrEmailChangeTokenInvalid):
+		return &model.ConfirmEmailChangePayload{
+			Errors: []model.ConfirmEmailChangeError{
+				&model.LinkExpiredError{
+					Message: "Link has expired, request the email change again",
+				},
+			},
+		}, nil
+	case errors.Is(err, srvcustomer.ErrEmailTaken):
+		return &model.ConfirmEmailChangePayload{
+			Errors: []model.ConfirmEmailChangeError{
+				&model.EmailTakenError{
+					Message: "Another account started using that email since the change was requested",
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("confirming email change: %w", err))
+	}
+
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, customerID)
+	if err != nil {
+		panic(err)
+	}
+	return &model.ConfirmEmailChangePayload{
+		Customer: customer,
+		Errors:   []model.ConfirmEmailChangeError{},
+	}, nil
+}
+
+// DeleteAccount is the resolver for the deleteAccount field.
+func (r *mutationResolver) DeleteAccount(ctx context.Context) (*model.DeleteAccountPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.DeleteAccountPayload{
+			Errors: []model.DeleteAccountError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+	if err := r.Services.Customer.DeleteCustomer(ctx, srvcustomer.DeleteCustomerRequest{
+		ID: verifiedCustomer.UUID,
+		OnDelete: func(ctx context.Context, tx database.Q, customerID uuid.UUID) error {
+			if err := r.Services.Post.RemoveCustomer(ctx, tx, srvpost.RemoveCustomerRequest{
+				CustomerID: customerID,
+			}); err != nil {
+				return fmt.Errorf("removing customer from posts: %w", err)
+			}
+			if err := r.Services.Comment.DeleteCommentsByAuthor(ctx, tx, srvcomment.DeleteCommentsByAuthorRequest{
+				AuthorID: customerID,
+			}); err != nil {
+				return fmt.Errorf("deleting comments: %w", err)
+			}
+			if err := r.Services.Workspace.RemoveCustomer(ctx, tx, srvworkspace.RemoveCustomerRequest{
+				CustomerID: customerID,
+			}); err != nil {
+				return fmt.Errorf("removing customer from workspaces: %w", err)
+			}
+			return nil
+		},
+	}); err != nil {
+		panic(fmt.Errorf("deleting customer: %w", err))
+	}
+	return &model.DeleteAccountPayload{
+		Errors: []model.DeleteAccountError{},
+	}, nil
+}
+
+// UpsertPost is the resolver for the upsertPost field.
+func (r *mutationResolver) UpsertPost(ctx context.Context, input model.UpsertPostInput) (*model.UpsertPostPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.UnauthenticatedError{
+					Message: "Author of post unknown - try logging again",
+				},
+			},
+		}, nil
+	}
+
+	if input.WorkspaceID != nil && r.workspaceMembership(ctx, *input.WorkspaceID) == nil {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				&model.WorkspaceNotFoundError{
+					Message: srvworkspace.ErrWorkspaceNotFound.Error(),
+					Path:    []string{"input", "workspaceId"},
+				},
+			},
+		}, nil
+	}
+
+	options := []*srvpost.UpsertPostOptionRequest{}
+	for _, o := range input.Options {
+		options = append(options, &srvpost.UpsertPostOptionRequest{
+			Position:   o.Position,
+			ID:         o.ID,
+			BucketName: o.BucketName,
+			FileKey:    o.FileKey,
+		})
+	}
+
+	var criteria []*srvpost.UpsertPostCriterionRequest
+	if input.Criteria != nil {
+		criteria = []*srvpost.UpsertPostCriterionRequest{}
+		for _, c := range input.Criteria {
+			criteria = append(criteria, &srvpost.UpsertPostCriterionRequest{
+				ID:       c.ID,
+				Name:     c.Name,
+				Kind:     srvpost.CriterionKind(c.Kind),
+				Position: c.Position,
+			})
+		}
+	}
+
+	err := r.Services.Post.UpsertPost(ctx, srvpost.UpsertPostRequest{
+		ID:                input.ID,
+		Options:           options,
+		DesignPhase:       (*srvpost.DesignPhase)(input.DesignPhase),
+		Category:          (*srvpost.PostCategory)(input.Category),
+		OpensAt:           input.OpensAt,
+		ClosesAt:          input.ClosesAt,
+		ResultsVisibility: (*srvpost.ResultsVisibility)(input.ResultsVisibility),
+		AllowVoteChanges:  input.AllowVoteChanges,
+		Visibility:        (*srvpost.PostVisibility)(input.Visibility),
+		WorkspaceID:       input.WorkspaceID,
+		Restricted:        input.Restricted,
+		VotingMode:        (*srvpost.VotingMode)(input.VotingMode),
+		MaxSelections:     input.MaxSelections,
+		FeedbackMode:      (*srvpost.FeedbackMode)(input.FeedbackMode),
+		Criteria:          criteria,
+		AuthorID:          verifiedCustomer.UUID,
+		Context:           input.Context,
+	})
+	if errors.Is(err, srvpost.ErrOpensAtAlreadyPassed) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.OpensAtAlreadyPassedError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrPostNotOwned) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.ErrPostNotOwned{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrTooFewOptions) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.TooFewOptionsError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrTooManyOptions) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.TooManyOptionsError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrWorkspaceRequired) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				&model.WorkspaceRequiredError{
+					Message: err.Error(),
+					Path:    []string{"input", "workspaceId"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrInvalidMaxSelections) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				&model.InvalidMaxSelectionsError{
+					Message: err.Error(),
+					Path:    []string{"input", "maxSelections"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrUnsupportedFileType) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				model.UnsupportedFileTypeError{
+					Message: err.Error(),
+					Path:    []string{"input", "options"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrOptionFileTooLarge) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				&model.OptionFileTooLargeError{
+					Message: err.Error(),
+					Path:    []string{"input", "options"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvpost.ErrTooManyCriteria) || errors.Is(err, srvpost.ErrInvalidCriterion) {
+		return &model.UpsertPostPayload{
+			Errors: []model.UpsertPostError{
+				&model.InvalidCriterionError{
+					Message: err.Error(),
+					Path:    []string{"input", "criteria"},
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		panic(fmt.Errorf("creating post: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, input.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.UpsertPostPayload{
+		Post:   post,
+		Errors: []model.UpsertPostError{},
+	}, nil
+}
+
+// GenerateSignedPostOptionURL is the resolver for the generateSignedPostOptionUrl field.
+func (r *mutationResolver) GenerateSignedPostOptionURL(ctx context.Context, input model.GenerateSignedPostOptionUrInput) (*model.GenerateSignedPostOptionURLPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.GenerateSignedPostOptionURLPayload{
+			Errors: []model.GenerateSignedPostOptionURLError{
+				model.UnauthenticatedError{
+					Message: "Author of post unknown - try logging again",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.GenerateSignedPostOptionURL(
+		ctx, srvpost.GenerateSignedPostOptionURLRequest{
+			FileName:    input.FileName,
+			ContentType: input.ContentType,
+		},
+	)
+	if err != nil {
+		if errors.Is(err, srvpost.ErrUnsupportedFileType) {
+			return &model.GenerateSignedPostOptionURLPayload{
+				Errors: []model.GenerateSignedPostOptionURLError{
+					model.UnsupportedFileTypeError{
+						Message: err.Error(),
+					},
+				},
+			}, nil
+		}
+		panic(err)
+	}
+
+	return &model.GenerateSignedPostOptionURLPayload{
+		FileKey:    resp.FileKey,
+		BucketName: resp.BucketName,
+		URL:        resp.URL,
+	}, nil
+}
+
+// SubmitVote is the resolver for the submitVote field.
+func (r *mutationResolver) SubmitVote(ctx context.Context, input model.SubmitVoteInput) (*model.SubmitVotePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.SubmitVotePayload{
+			Errors: []model.SubmitVoteError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.SubmitVote(ctx, srvpost.SubmitVoteRequest{
+		CustomerID:   verifiedCustomer.UUID,
+		OptionID:     input.OptionID,
+		Reason:       input.Reason,
+		WorkspaceIDs: r.postViewer(ctx).WorkspaceIDs,
+		InviteToken:  input.InviteToken,
+		Criteria:     criterionResponses(input.Criteria),
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrOptionNotFound) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					model.OptionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteAlreadyCast) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					model.VoteAlreadyCastError{
+						Message: "You have already voted on this post, call changeVote to change it",
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteRequired) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					&model.InviteRequiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteNotFound) || errors.Is(err, srvpost.ErrInviteExpired) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					&model.LinkExpiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteUsedUp) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					&model.LinkAlreadyUsedError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrCriterionNotFound) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					&model.CriterionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInvalidCriterionResponse) {
+			return &model.SubmitVotePayload{
+				Errors: []model.SubmitVoteError{
+					&model.InvalidCriterionResponseError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("submitting vote: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.SubmitVotePayload{
+		Post:   post,
+		Errors: []model.SubmitVoteError{},
+	}, nil
+}
+
+// ChangeVote is the resolver for the changeVote field.
+func (r *mutationResolver) ChangeVote(ctx context.Context, input model.ChangeVoteInput) (*model.ChangeVotePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.ChangeVotePayload{
+			Errors: []model.ChangeVoteError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.ChangeVote(ctx, srvpost.ChangeVoteRequest{
+		CustomerID:   verifiedCustomer.UUID,
+		OptionID:     input.OptionID,
+		Reason:       input.Reason,
+		WorkspaceIDs: r.postViewer(ctx).WorkspaceIDs,
+		Criteria:     criterionResponses(input.Criteria),
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrOptionNotFound) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					model.OptionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteNotFound) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					model.VoteNotFoundError{
+						Message: "You haven't voted on this post yet, call submitVote instead",
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteAlreadyCast) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					model.VoteAlreadyCastError{
+						Message: "The author of this post does not allow votes to be changed",
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrCriterionNotFound) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					&model.CriterionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInvalidCriterionResponse) {
+			return &model.ChangeVotePayload{
+				Errors: []model.ChangeVoteError{
+					&model.InvalidCriterionResponseError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("changing vote: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.ChangeVotePayload{
+		Post:   post,
+		Errors: []model.ChangeVoteError{},
+	}, nil
+}
+
+// RetractVote is the resolver for the retractVote field.
+func (r *mutationResolver) RetractVote(ctx context.Context, input model.RetractVoteInput) (*model.RetractVotePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.RetractVotePayload{
+			Errors: []model.RetractVoteError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.RetractVote(ctx, srvpost.RetractVoteRequest{
+		CustomerID: verifiedCustomer.UUID,
+		PostID:     input.PostID,
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrPostNotFound) {
+			return &model.RetractVotePayload{
+				Errors: []model.RetractVoteError{
+					model.PostNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.RetractVotePayload{
+				Errors: []model.RetractVoteError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteNotFound) {
+			return &model.RetractVotePayload{
+				Errors: []model.RetractVoteError{
+					model.VoteNotFoundError{
+						Message: err.Error(),
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteAlreadyCast) {
+			return &model.RetractVotePayload{
+				Errors: []model.RetractVoteError{
+					model.VoteAlreadyCastError{
+						Message: "The author of this post does not allow votes to be retracted",
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("retracting vote: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.RetractVotePayload{
+		Post:   post,
+		Errors: []model.RetractVoteError{},
+	}, nil
+}
+
+// SubmitBallot is the resolver for the submitBallot field.
+func (r *mutationResolver) SubmitBallot(ctx context.Context, input model.SubmitBallotInput) (*model.SubmitBallotPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.SubmitBallotPayload{
+			Errors: []model.SubmitBallotError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.SubmitBallot(ctx, srvpost.SubmitBallotRequest{
+		PostID:       input.PostID,
+		OptionIDs:    input.OptionIds,
+		CustomerID:   verifiedCustomer.UUID,
+		Reason:       input.Reason,
+		WorkspaceIDs: r.postViewer(ctx).WorkspaceIDs,
+		InviteToken:  input.InviteToken,
+		Criteria:     criterionResponses(input.Criteria),
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrPostNotFound) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					model.PostNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrOptionNotFound) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					model.OptionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionIds"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInvalidBallot) || errors.Is(err, srvpost.ErrTooManySelections) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.InvalidBallotError{
+						Message: err.Error(),
+						Path:    []string{"input", "optionIds"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteAlreadyCast) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					model.VoteAlreadyCastError{
+						Message: "The author of this post does not allow votes to be changed",
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteRequired) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.InviteRequiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteNotFound) || errors.Is(err, srvpost.ErrInviteExpired) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.LinkExpiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteUsedUp) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.LinkAlreadyUsedError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrCriterionNotFound) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.CriterionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInvalidCriterionResponse) {
+			return &model.SubmitBallotPayload{
+				Errors: []model.SubmitBallotError{
+					&model.InvalidCriterionResponseError{
+						Message: err.Error(),
+						Path:    []string{"input", "criteria"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("submitting ballot: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.SubmitBallotPayload{
+		Post:   post,
+		Errors: []model.SubmitBallotError{},
+	}, nil
+}
+
+// RateOptions is the resolver for the rateOptions field.
+func (r *mutationResolver) RateOptions(ctx context.Context, input model.RateOptionsInput) (*model.RateOptionsPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.RateOptionsPayload{
+			Errors: []model.RateOptionsError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	ratings := []srvpost.OptionRating{}
+	for _, rating := range input.Ratings {
+		ratings = append(ratings, srvpost.OptionRating{
+			OptionID: rating.OptionID,
+			Score:    rating.Score,
+		})
+	}
+
+	resp, err := r.Services.Post.RateOptions(ctx, srvpost.RateOptionsRequest{
+		PostID:       input.PostID,
+		CustomerID:   verifiedCustomer.UUID,
+		Ratings:      ratings,
+		WorkspaceIDs: r.postViewer(ctx).WorkspaceIDs,
+		InviteToken:  input.InviteToken,
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrPostNotFound) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					model.PostNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrFeedbackNotAccepted) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					&model.FeedbackNotAcceptedError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrOptionNotFound) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					model.OptionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "ratings"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInvalidScore) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					&model.InvalidScoreError{
+						Message: err.Error(),
+						Path:    []string{"input", "ratings"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrVoteAlreadyCast) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					model.VoteAlreadyCastError{
+						Message: "The author of this post does not allow ratings to be changed",
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteRequired) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					&model.InviteRequiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteNotFound) || errors.Is(err, srvpost.ErrInviteExpired) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					&model.LinkExpiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteUsedUp) {
+			return &model.RateOptionsPayload{
+				Errors: []model.RateOptionsError{
+					&model.LinkAlreadyUsedError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("rating options: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.RateOptionsPayload{
+		Post:   post,
+		Errors: []model.RateOptionsError{},
+	}, nil
+}
+
+// NextMatchup is the resolver for the nextMatchup field.
+func (r *mutationResolver) NextMatchup(ctx context.Context, input model.NextMatchupInput) (*model.NextMatchupPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.NextMatchupPayload{
+			Errors: []model.NextMatchupError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	matchup, err := r.Services.Post.GetNextMatchup(ctx, srvpost.GetNextMatchupRequest{
+		PostID:       input.PostID,
+		CustomerID:   verifiedCustomer.UUID,
+		WorkspaceIDs: r.postViewer(ctx).WorkspaceIDs,
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrPostNotFound) {
+			return &model.NextMatchupPayload{
+				Errors: []model.NextMatchupError{
+					model.PostNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.NextMatchupPayload{
+				Errors: []model.NextMatchupError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrFeedbackNotAccepted) {
+			return &model.NextMatchupPayload{
+				Errors: []model.NextMatchupError{
+					&model.FeedbackNotAcceptedError{
+						Message: err.Error(),
+						Path:    []string{"input", "postId"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("getting next matchup: %w", err))
+	}
+
+	return &model.NextMatchupPayload{
+		Matchup: matchup,
+		Errors:  []model.NextMatchupError{},
+	}, nil
+}
+
+// AnswerMatchup is the resolver for the answerMatchup field.
+func (r *mutationResolver) AnswerMatchup(ctx context.Context, input model.AnswerMatchupInput) (*model.AnswerMatchupPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.AnswerMatchupPayload{
+			Errors: []model.AnswerMatchupError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to vote on a post",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.AnswerMatchup(ctx, srvpost.AnswerMatchupRequest{
+		MatchupID:      input.MatchupID,
+		CustomerID:     verifiedCustomer.UUID,
+		WinnerOptionID: input.WinnerOptionID,
+		WorkspaceIDs:   r.postViewer(ctx).WorkspaceIDs,
+		InviteToken:    input.InviteToken,
+	})
+	if err != nil {
+		if errors.Is(err, srvpost.ErrMatchupNotFound) || errors.Is(err, srvpost.ErrPostNotFound) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.MatchupNotFoundError{
+						Message: srvpost.ErrMatchupNotFound.Error(),
+						Path:    []string{"input", "matchupId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrPostNotLive) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					model.PostNotLiveError{
+						Message: err.Error(),
+						Path:    []string{"input", "matchupId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrFeedbackNotAccepted) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.FeedbackNotAcceptedError{
+						Message: err.Error(),
+						Path:    []string{"input", "matchupId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrOptionNotFound) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					model.OptionNotFoundError{
+						Message: err.Error(),
+						Path:    []string{"input", "winnerOptionId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrMatchupAnswered) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.MatchupAnsweredError{
+						Message: err.Error(),
+						Path:    []string{"input", "matchupId"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteRequired) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.InviteRequiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteNotFound) || errors.Is(err, srvpost.ErrInviteExpired) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.LinkExpiredError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		if errors.Is(err, srvpost.ErrInviteUsedUp) {
+			return &model.AnswerMatchupPayload{
+				Errors: []model.AnswerMatchupError{
+					&model.LinkAlreadyUsedError{
+						Message: err.Error(),
+						Path:    []string{"input", "inviteToken"},
+					},
+				},
+			}, nil
+		}
+		panic(fmt.Errorf("answering matchup: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+
+	return &model.AnswerMatchupPayload{
+		Post:   post,
+		Errors: []model.AnswerMatchupError{},
+	}, nil
+}
+
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, input model.AddCommentInput) (*model.AddCommentPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.AddCommentPayload{
+			Errors: []model.AddCommentError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to comment on a post",
+				},
+			},
+		}, nil
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, input.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+	if post == nil || !post.VisibleTo(r.postViewer(ctx), time.Now()) {
+		return &model.AddCommentPayload{
+			Errors: []model.AddCommentError{
+				model.PostNotFoundError{
+					Message: srvpost.ErrPostNotFound.Error(),
+					Path:    []string{"input", "postId"},
+				},
+			},
+		}, nil
+	}
+
+	if input.OptionID != nil {
+		option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, *input.OptionID)
+		if err != nil {
+			panic(fmt.Errorf("loading option: %w", err))
+		}
+		if option == nil || option.PostID != post.ID {
+			return &model.AddCommentPayload{
+				Errors: []model.AddCommentError{
+					model.OptionNotFoundError{
+						Message: srvpost.ErrOptionNotFound.Error(),
+						Path:    []string{"input", "optionId"},
+					},
+				},
+			}, nil
+		}
+	}
+
+	resp, err := r.Services.Comment.AddComment(ctx, srvcomment.AddCommentRequest{
+		PostID:   input.PostID,
+		OptionID: input.OptionID,
+		ParentID: input.ParentID,
+		AuthorID: verifiedCustomer.UUID,
+		Body:     input.Body,
+	})
+	if errors.Is(err, srvcomment.ErrCommentNotFound) {
+		return &model.AddCommentPayload{
+			Errors: []model.AddCommentError{
+				model.CommentNotFoundError{
+					Message: "Parent comment not found in this thread",
+					Path:    []string{"input", "parentId"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvcomment.ErrInvalidBody) {
+		return &model.AddCommentPayload{
+			Errors: []model.AddCommentError{
+				model.InvalidCommentBodyError{
+					Message: err.Error(),
+					Path:    []string{"input", "body"},
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		panic(fmt.Errorf("adding comment: %w", err))
+	}
+
+	comment, err := GetLoaders(ctx).CommentLoader.Load(ctx, resp.CommentID)
+	if err != nil {
+		panic(fmt.Errorf("loading comment: %w", err))
+	}
+
+	return &model.AddCommentPayload{
+		Comment: comment,
+		Errors:  []model.AddCommentError{},
+	}, nil
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, input model.EditCommentInput) (*model.EditCommentPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.EditCommentPayload{
+			Errors: []model.EditCommentError{
+				model.UnauthenticatedError{
+					Message: "Author of comment unknown - try logging again",
+				},
+			},
+		}, nil
+	}
+
+	err := r.Services.Comment.EditComment(ctx, srvcomment.EditCommentRequest{
+		ID:       input.ID,
+		AuthorID: verifiedCustomer.UUID,
+		Body:     input.Body,
+	})
+	if errors.Is(err, srvcomment.ErrCommentNotFound) {
+		return &model.EditCommentPayload{
+			Errors: []model.EditCommentError{
+				model.CommentNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvcomment.ErrCommentNotOwned) {
+		return &model.EditCommentPayload{
+			Errors: []model.EditCommentError{
+				model.CommentNotOwnedError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvcomment.ErrInvalidBody) {
+		return &model.EditCommentPayload{
+			Errors: []model.EditCommentError{
+				model.InvalidCommentBodyError{
+					Message: err.Error(),
+					Path:    []string{"input", "body"},
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		panic(fmt.Errorf("editing comment: %w", err))
+	}
+
+	comment, err := GetLoaders(ctx).CommentLoader.Load(ctx, input.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading comment: %w", err))
+	}
+
+	return &model.EditCommentPayload{
+		Comment: comment,
+		Errors:  []model.EditCommentError{},
+	}, nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, input model.DeleteCommentInput) (*model.DeleteCommentPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.DeleteCommentPayload{
+			Errors: []model.DeleteCommentError{
+				model.UnauthenticatedError{
+					Message: "Author of comment unknown - try logging again",
+				},
+			},
+		}, nil
+	}
+
+	err := r.Services.Comment.DeleteComment(ctx, srvcomment.DeleteCommentRequest{
+		ID:       input.ID,
+		AuthorID: verifiedCustomer.UUID,
+	})
+	if errors.Is(err, srvcomment.ErrCommentNotFound) {
+		return &model.DeleteCommentPayload{
+			Errors: []model.DeleteCommentError{
+				model.CommentNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	}
+	if errors.Is(err, srvcomment.ErrCommentNotOwned) {
+		return &model.DeleteCommentPayload{
+			Errors: []model.DeleteCommentError{
+				model.CommentNotOwnedError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		panic(fmt.Errorf("deleting comment: %w", err))
+	}
+
+	comment, err := GetLoaders(ctx).CommentLoader.Load(ctx, input.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading comment: %w", err))
+	}
+
+	return &model.DeleteCommentPayload{
+		Comment: comment,
+		Errors:  []model.DeleteCommentError{},
+	}, nil
+}
+
+// CreateWorkspace is the resolver for the createWorkspace field.
+func (r *mutationResolver) CreateWorkspace(ctx context.Context, input model.CreateWorkspaceInput) (*model.CreateWorkspacePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.CreateWorkspacePayload{
+			Errors: []model.CreateWorkspaceError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login to create a workspace",
+				},
+			},
+		}, nil
+	}
+
+	workspaceID, err := r.Services.Workspace.CreateWorkspace(ctx, srvworkspace.CreateWorkspaceRequest{
+		Name:    input.Name,
+		OwnerID: verifiedCustomer.UUID,
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrInvalidName):
+		return &model.CreateWorkspacePayload{
+			Errors: []model.CreateWorkspaceError{
+				&model.InvalidWorkspaceNameError{
+					Message: err.Error(),
+					Path:    []string{"input", "name"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("creating workspace: %w", err))
+	}
+
+	return &model.CreateWorkspacePayload{
+		Workspace: r.loadWorkspace(ctx, workspaceID),
+		Errors:    []model.CreateWorkspaceError{},
+	}, nil
+}
+
+// UpdateWorkspace is the resolver for the updateWorkspace field.
+func (r *mutationResolver) UpdateWorkspace(ctx context.Context, input model.UpdateWorkspaceInput) (*model.UpdateWorkspacePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.UpdateWorkspacePayload{
+			Errors: []model.UpdateWorkspaceError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	err := r.Services.Workspace.UpdateWorkspace(ctx, srvworkspace.UpdateWorkspaceRequest{
+		ID:      input.ID,
+		ActorID: verifiedCustomer.UUID,
+		Name:    input.Name,
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrWorkspaceNotFound):
+		return &model.UpdateWorkspacePayload{
+			Errors: []model.UpdateWorkspaceError{
+				&model.WorkspaceNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInsufficientRole):
+		return &model.UpdateWorkspacePayload{
+			Errors: []model.UpdateWorkspaceError{
+				&model.InsufficientWorkspaceRoleError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInvalidName):
+		return &model.UpdateWorkspacePayload{
+			Errors: []model.UpdateWorkspaceError{
+				&model.InvalidWorkspaceNameError{
+					Message: err.Error(),
+					Path:    []string{"input", "name"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("updating workspace: %w", err))
+	}
+
+	return &model.UpdateWorkspacePayload{
+		Workspace: r.loadWorkspace(ctx, input.ID),
+		Errors:    []model.UpdateWorkspaceError{},
+	}, nil
+}
+
+// InviteToWorkspace is the resolver for the inviteToWorkspace field.
+func (r *mutationResolver) InviteToWorkspace(ctx context.Context, input model.InviteToWorkspaceInput) (*model.InviteToWorkspacePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.InviteToWorkspacePayload{
+			Errors: []model.InviteToWorkspaceError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	role := srvworkspace.RoleMember
+	if input.Role != nil {
+		role = srvworkspace.Role(*input.Role)
+	}
+	err := r.Services.Workspace.InviteMember(ctx, srvworkspace.InviteMemberRequest{
+		WorkspaceID: input.WorkspaceID,
+		ActorID:     verifiedCustomer.UUID,
+		Email:       input.Email,
+		Role:        role,
+		OnCreate: func(ctx context.Context, tx database.Q, token string) error {
+			workspace, err := GetLoaders(ctx).WorkspaceLoader.Load(ctx, input.WorkspaceID)
+			if err != nil {
+				return fmt.Errorf("loading workspace: %w", err)
+			}
+			inviter, err := GetLoaders(ctx).CustomerLoader.Load(ctx, verifiedCustomer.UUID)
+			if err != nil {
+				return fmt.Errorf("loading inviter: %w", err)
+			}
+			inviterName, err := r.Profile().DisplayName(ctx, inviter)
+			if err != nil {
+				return fmt.Errorf("getting inviter name: %w", err)
+			}
+			queryParams := url.Values{}
+			queryParams.Add("token", token)
+			return r.Services.Communications.EnqueueEmail(ctx, tx, srvcommunications.EnqueueEmailRequest{
+				ToEmail:  input.Email,
+				Template: srvcommunications.TemplateWorkspaceInvitation,
+				Subject:  fmt.Sprintf("Join %s on Quorum", workspace.Name),
+				Variables: map[string]interface{}{
+					"inviter_name":    inviterName,
+					"workspace_name":  workspace.Name,
+					"invitation_link": fmt.Sprintf("%s/workspaces/accept?%s", os.Getenv("FRONTEND_URL"), queryParams.Encode()),
+				},
+			})
+		},
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrWorkspaceNotFound):
+		return &model.InviteToWorkspacePayload{
+			Errors: []model.InviteToWorkspaceError{
+				&model.WorkspaceNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "workspaceId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInsufficientRole):
+		return &model.InviteToWorkspacePayload{
+			Errors: []model.InviteToWorkspaceError{
+				&model.InsufficientWorkspaceRoleError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInvalidEmail):
+		return &model.InviteToWorkspacePayload{
+			Errors: []model.InviteToWorkspaceError{
+				&model.InvalidEmailError{
+					Message: "Invalid format for email.",
+					Path:    []string{"input", "email"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("inviting member: %w", err))
+	}
+
+	return &model.InviteToWorkspacePayload{
+		Workspace: r.loadWorkspace(ctx, input.WorkspaceID),
+		Errors:    []model.InviteToWorkspaceError{},
+	}, nil
+}
+
+// RevokeWorkspaceInvitation is the resolver for the revokeWorkspaceInvitation field.
+func (r *mutationResolver) RevokeWorkspaceInvitation(ctx context.Context, input model.RevokeWorkspaceInvitationInput) (*model.RevokeWorkspaceInvitationPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.RevokeWorkspaceInvitationPayload{
+			Errors: []model.RevokeWorkspaceInvitationError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	invitations, err := r.Services.Workspace.GetInvitationsByFilter(
+		ctx, srvworkspace.GetInvitationsByFilterRequest{
+			IDs: []uuid.UUID{input.ID},
+		},
+	)
+	if err != nil {
+		panic(fmt.Errorf("getting invitation: %w", err))
+	}
+	if len(invitations) == 0 {
+		err = srvworkspace.ErrInvitationInvalid
+	} else {
+		err = r.Services.Workspace.RevokeInvitation(ctx, srvworkspace.RevokeInvitationRequest{
+			ID:      input.ID,
+			ActorID: verifiedCustomer.UUID,
+		})
+	}
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrInvitationInvalid):
+		return &model.RevokeWorkspaceInvitationPayload{
+			Errors: []model.RevokeWorkspaceInvitationError{
+				&model.LinkExpiredError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInsufficientRole):
+		return &model.RevokeWorkspaceInvitationPayload{
+			Errors: []model.RevokeWorkspaceInvitationError{
+				&model.InsufficientWorkspaceRoleError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("revoking invitation: %w", err))
+	}
+
+	return &model.RevokeWorkspaceInvitationPayload{
+		Workspace: r.loadWorkspace(ctx, invitations[0].WorkspaceID),
+		Errors:    []model.RevokeWorkspaceInvitationError{},
+	}, nil
+}
+
+// AcceptWorkspaceInvitation is the resolver for the acceptWorkspaceInvitation field.
+func (r *mutationResolver) AcceptWorkspaceInvitation(ctx context.Context, input model.AcceptWorkspaceInvitationInput) (*model.AcceptWorkspaceInvitationPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.AcceptWorkspaceInvitationPayload{
+			Errors: []model.AcceptWorkspaceInvitationError{
+				model.UnauthenticatedError{
+					Message: "Sign up or login with the email the invitation was sent to",
+				},
+			},
+		}, nil
+	}
+
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, verifiedCustomer.UUID)
+	if err != nil {
+		panic(fmt.Errorf("loading customer: %w", err))
+	}
+	workspaceID, err := r.Services.Workspace.AcceptInvitation(ctx, srvworkspace.AcceptInvitationRequest{
+		Token:      input.Token,
+		CustomerID: verifiedCustomer.UUID,
+		Email:      customer.Email,
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrInvitationInvalid),
+		errors.Is(err, srvworkspace.ErrInvitationExpired):
+		return &model.AcceptWorkspaceInvitationPayload{
+			Errors: []model.AcceptWorkspaceInvitationError{
+				&model.LinkExpiredError{
+					Message: "This invitation has expired or was revoked, ask for a new one",
+					Path:    []string{"input", "token"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInvitationUsed):
+		return &model.AcceptWorkspaceInvitationPayload{
+			Errors: []model.AcceptWorkspaceInvitationError{
+				&model.LinkAlreadyUsedError{
+					Message: err.Error(),
+					Path:    []string{"input", "token"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInvitationEmailMismatch):
+		return &model.AcceptWorkspaceInvitationPayload{
+			Errors: []model.AcceptWorkspaceInvitationError{
+				&model.InvitationEmailMismatchError{
+					Message: err.Error(),
+					Path:    []string{"input", "token"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("accepting invitation: %w", err))
+	}
+
+	return &model.AcceptWorkspaceInvitationPayload{
+		Workspace: r.loadWorkspace(ctx, workspaceID),
+		Errors:    []model.AcceptWorkspaceInvitationError{},
+	}, nil
+}
+
+// ChangeWorkspaceMemberRole is the resolver for the changeWorkspaceMemberRole field.
+func (r *mutationResolver) ChangeWorkspaceMemberRole(ctx context.Context, input model.ChangeWorkspaceMemberRoleInput) (*model.ChangeWorkspaceMemberRolePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.ChangeWorkspaceMemberRolePayload{
+			Errors: []model.ChangeWorkspaceMemberRoleError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	err := r.Services.Workspace.ChangeMemberRole(ctx, srvworkspace.ChangeMemberRoleRequest{
+		WorkspaceID: input.WorkspaceID,
+		ActorID:     verifiedCustomer.UUID,
+		CustomerID:  input.CustomerID,
+		Role:        srvworkspace.Role(input.Role),
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrWorkspaceNotFound):
+		return &model.ChangeWorkspaceMemberRolePayload{
+			Errors: []model.ChangeWorkspaceMemberRoleError{
+				&model.WorkspaceNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "workspaceId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrMemberNotFound):
+		return &model.ChangeWorkspaceMemberRolePayload{
+			Errors: []model.ChangeWorkspaceMemberRoleError{
+				&model.WorkspaceMemberNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "customerId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInsufficientRole):
+		return &model.ChangeWorkspaceMemberRolePayload{
+			Errors: []model.ChangeWorkspaceMemberRoleError{
+				&model.InsufficientWorkspaceRoleError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrLastOwner):
+		return &model.ChangeWorkspaceMemberRolePayload{
+			Errors: []model.ChangeWorkspaceMemberRoleError{
+				&model.LastWorkspaceOwnerError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("changing member role: %w", err))
+	}
+
+	return &model.ChangeWorkspaceMemberRolePayload{
+		Workspace: r.loadWorkspace(ctx, input.WorkspaceID),
+		Errors:    []model.ChangeWorkspaceMemberRoleError{},
+	}, nil
+}
+
+// RemoveWorkspaceMember is the resolver for the removeWorkspaceMember field.
+func (r *mutationResolver) RemoveWorkspaceMember(ctx context.Context, input model.RemoveWorkspaceMemberInput) (*model.RemoveWorkspaceMemberPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.RemoveWorkspaceMemberPayload{
+			Errors: []model.RemoveWorkspaceMemberError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	err := r.Services.Workspace.RemoveMember(ctx, srvworkspace.RemoveMemberRequest{
+		WorkspaceID: input.WorkspaceID,
+		ActorID:     verifiedCustomer.UUID,
+		CustomerID:  input.CustomerID,
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvworkspace.ErrWorkspaceNotFound):
+		return &model.RemoveWorkspaceMemberPayload{
+			Errors: []model.RemoveWorkspaceMemberError{
+				&model.WorkspaceNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "workspaceId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrMemberNotFound):
+		return &model.RemoveWorkspaceMemberPayload{
+			Errors: []model.RemoveWorkspaceMemberError{
+				&model.WorkspaceMemberNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "customerId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrInsufficientRole):
+		return &model.RemoveWorkspaceMemberPayload{
+			Errors: []model.RemoveWorkspaceMemberError{
+				&model.InsufficientWorkspaceRoleError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	case errors.Is(err, srvworkspace.ErrLastOwner):
+		return &model.RemoveWorkspaceMemberPayload{
+			Errors: []model.RemoveWorkspaceMemberError{
+				&model.LastWorkspaceOwnerError{
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("removing member: %w", err))
+	}
+
+	return &model.RemoveWorkspaceMemberPayload{
+		Workspace: r.loadWorkspace(ctx, input.WorkspaceID),
+		Errors:    []model.RemoveWorkspaceMemberError{},
+	}, nil
+}
+
+// CreatePostInviteLink is the resolver for the createPostInviteLink field.
+func (r *mutationResolver) CreatePostInviteLink(ctx context.Context, input model.CreatePostInviteLinkInput) (*model.CreatePostInviteLinkPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.CreatePostInviteLinkPayload{
+			Errors: []model.CreatePostInviteLinkError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	invites, err := r.Services.Post.CreateInvites(ctx, srvpost.CreateInvitesRequest{
+		PostID:    input.PostID,
+		AuthorID:  verifiedCustomer.UUID,
+		MaxUses:   input.MaxUses,
+		ExpiresAt: input.ExpiresAt,
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvpost.ErrPostNotFound):
+		return &model.CreatePostInviteLinkPayload{
+			Errors: []model.CreatePostInviteLinkError{
+				model.PostNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "postId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrPostNotOwned):
+		return &model.CreatePostInviteLinkPayload{
+			Errors: []model.CreatePostInviteLinkError{
+				model.ErrPostNotOwned{
+					Message: err.Error(),
+					Path:    []string{"input", "postId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrInvalidInviteExpiry):
+		return &model.CreatePostInviteLinkPayload{
+			Errors: []model.CreatePostInviteLinkError{
+				&model.InvalidPostInviteError{
+					Message: err.Error(),
+					Path:    []string{"input", "expiresAt"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrInvalidInviteMaxUses):
+		return &model.CreatePostInviteLinkPayload{
+			Errors: []model.CreatePostInviteLinkError{
+				&model.InvalidPostInviteError{
+					Message: err.Error(),
+					Path:    []string{"input", "maxUses"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("creating invite: %w", err))
+	}
+
+	link, err := newPostInviteLink(invites[0])
+	if err != nil {
+		panic(fmt.Errorf("creating invite link: %w", err))
+	}
+	return &model.CreatePostInviteLinkPayload{
+		Invite: &invites[0],
+		URL:    &link,
+		Errors: []model.CreatePostInviteLinkError{},
+	}, nil
+}
+
+// SendPostInvites is the resolver for the sendPostInvites field.
+func (r *mutationResolver) SendPostInvites(ctx context.Context, input model.SendPostInvitesInput) (*model.SendPostInvitesPayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+	if len(input.Emails) == 0 {
+		return &model.SendPostInvitesPayload{
+			Invites: []*srvpost.Invite{},
+			Errors:  []model.SendPostInvitesError{},
+		}, nil
+	}
+
+	invites, err := r.Services.Post.CreateInvites(ctx, srvpost.CreateInvitesRequest{
+		PostID:    input.PostID,
+		AuthorID:  verifiedCustomer.UUID,
+		Emails:    input.Emails,
+		ExpiresAt: input.ExpiresAt,
+		OnCreate: func(ctx context.Context, tx database.Q, invites []srvpost.Invite) error {
+			author, err := GetLoaders(ctx).CustomerLoader.Load(ctx, verifiedCustomer.UUID)
+			if err != nil {
+				return fmt.Errorf("loading author: %w", err)
+			}
+			authorName, err := r.Profile().DisplayName(ctx, author)
+			if err != nil {
+				return fmt.Errorf("getting author name: %w", err)
+			}
+			for _, invite := range invites {
+				link, err := newPostInviteLink(invite)
+				if err != nil {
+					return fmt.Errorf("creating invite link: %w", err)
+				}
+				if err = r.Services.Communications.EnqueueEmail(ctx, tx, srvcommunications.EnqueueEmailRequest{
+					ToEmail:  *invite.Email,
+					Template: srvcommunications.TemplatePostInvite,
+					Subject:  fmt.Sprintf("%s would like your vote on Quorum", authorName),
+					Variables: map[string]interface{}{
+						"author_name":     authorName,
+						"invitation_link": link,
+						"expires_at":      invite.ExpiresAt.Format("2 January 2006"),
+					},
+				}); err != nil {
+					return fmt.Errorf("enqueueing email: %w", err)
+				}
+			}
+			return nil
+		},
+	})
+	switch {
+	case err == nil:
+		// continue
+	case errors.Is(err, srvpost.ErrPostNotFound):
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				model.PostNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "postId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrPostNotOwned):
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				model.ErrPostNotOwned{
+					Message: err.Error(),
+					Path:    []string{"input", "postId"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrInvalidInviteExpiry):
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				&model.InvalidPostInviteError{
+					Message: err.Error(),
+					Path:    []string{"input", "expiresAt"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrTooManyInviteEmails):
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				&model.InvalidPostInviteError{
+					Message: err.Error(),
+					Path:    []string{"input", "emails"},
+				},
+			},
+		}, nil
+	case errors.Is(err, srvpost.ErrInvalidInviteEmail):
+		return &model.SendPostInvitesPayload{
+			Errors: []model.SendPostInvitesError{
+				&model.InvalidEmailError{
+					Message: err.Error(),
+					Path:    []string{"input", "emails"},
+				},
+			},
+		}, nil
+	default:
+		panic(fmt.Errorf("creating invites: %w", err))
+	}
+
+	res := []*srvpost.Invite{}
+	for i := range invites {
+		res = append(res, &invites[i])
+	}
+	return &model.SendPostInvitesPayload{
+		Invites: res,
+		Errors:  []model.SendPostInvitesError{},
+	}, nil
+}
+
+// RevokePostInvite is the resolver for the revokePostInvite field.
+func (r *mutationResolver) RevokePostInvite(ctx context.Context, input model.RevokePostInviteInput) (*model.RevokePostInvitePayload, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid {
+		return &model.RevokePostInvitePayload{
+			Errors: []model.RevokePostInviteError{
+				model.UnauthenticatedError{
+					Message: "Not logged in",
+				},
+			},
+		}, nil
+	}
+
+	resp, err := r.Services.Post.RevokeInvite(ctx, srvpost.RevokeInviteRequest{
+		ID:       input.ID,
+		AuthorID: verifiedCustomer.UUID,
+	})
+	if errors.Is(err, srvpost.ErrInviteNotFound) {
+		return &model.RevokePostInvitePayload{
+			Errors: []model.RevokePostInviteError{
+				&model.PostInviteNotFoundError{
+					Message: err.Error(),
+					Path:    []string{"input", "id"},
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		panic(fmt.Errorf("revoking invite: %w", err))
+	}
+
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, resp.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+	return &model.RevokePostInvitePayload{
+		Post:   post,
+		Errors: []model.RevokePostInviteError{},
+	}, nil
+}
+
+// DesignPhase is the resolver for the designPhase field.
+func (r *postResolver) DesignPhase(ctx context.Context, obj *srvpost.Post) (*model.DesignPhase, error) {
+	return (*model.DesignPhase)(obj.DesignPhase), nil
+}
+
+// Category is the resolver for the category field.
+func (r *postResolver) Category(ctx context.Context, obj *srvpost.Post) (*model.PostCategory, error) {
+	return (*model.PostCategory)(obj.Category), nil
+}
+
+// Author is the resolver for the author field.
+func (r *postResolver) Author(ctx context.Context, obj *srvpost.Post) (*srvcustomer.Customer, error) {
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.AuthorID)
+	if err != nil {
+		panic(fmt.Errorf("loading author: %w", err))
+	}
+	return customer, nil
+}
+
+// Options is the resolver for the options field.
+func (r *postResolver) Options(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Option, error) {
+	options, err := GetLoaders(ctx).PostOptionLoader.LoadAll(ctx, obj.OptionIDs)
+	if err != nil {
+		panic(fmt.Errorf("loading options: %w", err))
+	}
+	return options, nil
+}
+
+// Votes is the resolver for the votes field.
+func (r *postResolver) Votes(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Vote, error) {
+	votes, err := GetLoaders(ctx).PostVoteLoader.LoadAll(ctx, obj.VoteIDs)
+	if err != nil {
+		panic(fmt.Errorf("loading votes: %w", err))
+	}
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if obj.ResultsVisibleTo(verifiedCustomer, time.Now()) {
+		return votes, nil
+	}
+	ownVotes := []*srvpost.Vote{}
+	for _, v := range votes {
+		if v != nil && verifiedCustomer.Valid && v.CustomerID == verifiedCustomer {
+			ownVotes = append(ownVotes, v)
+		}
+	}
+	return ownVotes, nil
+}
+
+// ResultsVisibility is the resolver for the resultsVisibility field.
+func (r *postResolver) ResultsVisibility(ctx context.Context, obj *srvpost.Post) (model.ResultsVisibility, error) {
+	return model.ResultsVisibility(obj.ResultsVisibility), nil
+}
+
+// Visibility is the resolver for the visibility field.
+func (r *postResolver) Visibility(ctx context.Context, obj *srvpost.Post) (model.PostVisibility, error) {
+	return model.PostVisibility(obj.Visibility), nil
+}
+
+// Workspace is the resolver for the workspace field.
+func (r *postResolver) Workspace(ctx context.Context, obj *srvpost.Post) (*srvworkspace.Workspace, error) {
+	if obj.WorkspaceID == nil || r.workspaceMembership(ctx, *obj.WorkspaceID) == nil {
+		return nil, nil
+	}
+	workspace, err := GetLoaders(ctx).WorkspaceLoader.Load(ctx, *obj.WorkspaceID)
+	if err != nil {
+		panic(fmt.Errorf("loading workspace: %w", err))
+	}
+	return workspace, nil
+}
+
+// Invites is the resolver for the invites field.
+func (r *postResolver) Invites(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Invite, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid || verifiedCustomer.UUID != obj.AuthorID {
+		return nil, nil
+	}
+	invites, err := r.Services.Post.GetInvitesByFilter(ctx, srvpost.GetInvitesByFilterRequest{
+		PostIDs: []uuid.UUID{obj.ID},
+	})
+	if err != nil {
+		panic(fmt.Errorf("getting invites: %w", err))
+	}
+	res := []*srvpost.Invite{}
+	for i := range invites {
+		res = append(res, &invites[i])
+	}
+	return res, nil
+}
+
+// VotingMode is the resolver for the votingMode field.
+func (r *postResolver) VotingMode(ctx context.Context, obj *srvpost.Post) (model.VotingMode, error) {
+	return model.VotingMode(obj.VotingMode), nil
+}
+
+// TotalVotes is the resolver for the totalVotes field.
+func (r *postResolver) TotalVotes(ctx context.Context, obj *srvpost.Post) (*int, error) {
+	if !obj.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	results, err := GetLoaders(ctx).PostResultsLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading results: %w", err))
+	}
+	return &results.TotalVotes, nil
+}
+
+// WinningOption is the resolver for the winningOption field.
+func (r *postResolver) WinningOption(ctx context.Context, obj *srvpost.Post) (*srvpost.Option, error) {
+	if !obj.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	results, err := GetLoaders(ctx).PostResultsLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading results: %w", err))
+	}
+	if results.WinningOptionID == nil {
+		return nil, nil
+	}
+	option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, *results.WinningOptionID)
+	if err != nil {
+		panic(fmt.Errorf("loading option: %w", err))
+	}
+	return option, nil
+}
+
+// Rounds is the resolver for the rounds field.
+func (r *postResolver) Rounds(ctx context.Context, obj *srvpost.Post) ([]*srvpost.RankedRound, error) {
+	if obj.VotingMode != srvpost.VotingModeRanked ||
+		!obj.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	results, err := GetLoaders(ctx).PostResultsLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading results: %w", err))
+	}
+	rounds := []*srvpost.RankedRound{}
+	for i := range results.Rounds {
+		rounds = append(rounds, &results.Rounds[i])
+	}
+	return rounds, nil
+}
+
+// FeedbackMode is the resolver for the feedbackMode field.
+func (r *postResolver) FeedbackMode(ctx context.Context, obj *srvpost.Post) (model.FeedbackMode, error) {
+	return model.FeedbackMode(obj.FeedbackMode), nil
+}
+
+// Criteria is the resolver for the criteria field.
+func (r *postResolver) Criteria(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Criterion, error) {
+	criteria, err := GetLoaders(ctx).PostCriteriaLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading criteria: %w", err))
+	}
+	if criteria == nil {
+		return []*srvpost.Criterion{}, nil
+	}
+	return criteria, nil
+}
+
+// Status is the resolver for the status field.
+func (r *postResolver) Status(ctx context.Context, obj *srvpost.Post) (model.PostStatus, error) {
+	if obj == nil {
+		return model.PostStatusDraft, nil
+	}
+	return model.PostStatus(obj.Status(time.Now())), nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *srvpost.Post) ([]*srvcomment.Comment, error) {
+	comments, err := GetLoaders(ctx).PostCommentsLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading comments: %w", err))
+	}
+	if comments == nil {
+		return []*srvcomment.Comment{}, nil
+	}
+	return comments, nil
+}
+
+// Kind is the resolver for the kind field.
+func (r *postCriterionResolver) Kind(ctx context.Context, obj *srvpost.Criterion) (model.CriterionKind, error) {
+	return model.CriterionKind(obj.Kind), nil
+}
+
+// OptionA is the resolver for the optionA field.
+func (r *postMatchupResolver) OptionA(ctx context.Context, obj *srvpost.Matchup) (*srvpost.Option, error) {
+	option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, obj.OptionAID)
+	if err != nil {
+		panic(fmt.Errorf("loading option: %w", err))
+	}
+	return option, nil
+}
+
+// OptionB is the resolver for the optionB field.
+func (r *postMatchupResolver) OptionB(ctx context.Context, obj *srvpost.Matchup) (*srvpost.Option, error) {
+	option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, obj.OptionBID)
+	if err != nil {
+		panic(fmt.Errorf("loading option: %w", err))
+	}
+	return option, nil
 }
 
-// Category is the resolver for the category field.
-func (r *postResolver) Category(ctx context.Context, obj *srvpost.Post) (*model.PostCategory, error) {
-	return (*model.PostCategory)(obj.Category), nil
+// MediaType is the resolver for the mediaType field.
+func (r *postOptionResolver) MediaType(ctx context.Context, obj *srvpost.Option) (model.MediaType, error) {
+	return model.MediaType(obj.MediaType), nil
 }
 
-// Author is the resolver for the author field.
-func (r *postResolver) Author(ctx context.Context, obj *srvpost.Post) (*srvcustomer.Customer, error) {
-	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.AuthorID)
+// URL is the resolver for the url field.
+func (r *postOptionResolver) URL(ctx context.Context, obj *srvpost.Option, size model.ImageSize) (*string, error) {
+	url, ok := obj.URLs[srvpost.ImageSize(size)]
+	if !ok {
+		return nil, nil
+	}
+	return &url, nil
+}
+
+// ProcessingStatus is the resolver for the processingStatus field.
+func (r *postOptionResolver) ProcessingStatus(ctx context.Context, obj *srvpost.Option) (model.ProcessingStatus, error) {
+	return model.ProcessingStatus(obj.ProcessingStatus), nil
+}
+
+// VoteCount is the resolver for the voteCount field.
+func (r *postOptionResolver) VoteCount(ctx context.Context, obj *srvpost.Option) (*int, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
 	if err != nil {
-		panic(fmt.Errorf("loading author: %w", err))
+		panic(fmt.Errorf("loading post: %w", err))
 	}
-	return customer, nil
+	if !post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	results, err := GetLoaders(ctx).PostResultsLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading results: %w", err))
+	}
+	voteCount := 0
+	if optionResult := results.Option(obj.ID); optionResult != nil {
+		voteCount = optionResult.VoteCount
+	}
+	return &voteCount, nil
 }
 
-// Options is the resolver for the options field.
-func (r *postResolver) Options(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Option, error) {
-	options, err := GetLoaders(ctx).PostOptionLoader.LoadAll(ctx, obj.OptionIDs)
+// VotePercentage is the resolver for the votePercentage field.
+func (r *postOptionResolver) VotePercentage(ctx context.Context, obj *srvpost.Option) (*float64, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
 	if err != nil {
-		panic(fmt.Errorf("loading options: %w", err))
+		panic(fmt.Errorf("loading post: %w", err))
 	}
-	return options, nil
+	if !post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	results, err := GetLoaders(ctx).PostResultsLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading results: %w", err))
+	}
+	votePercentage := 0.0
+	if optionResult := results.Option(obj.ID); optionResult != nil {
+		votePercentage = optionResult.VotePercentage
+	}
+	return &votePercentage, nil
 }
 
-// Votes is the resolver for the votes field.
-func (r *postResolver) Votes(ctx context.Context, obj *srvpost.Post) ([]*srvpost.Vote, error) {
-	votes, err := GetLoaders(ctx).PostVoteLoader.LoadAll(ctx, obj.VoteIDs)
+// Feedback is the resolver for the feedback field.
+func (r *postOptionResolver) Feedback(ctx context.Context, obj *srvpost.Option) (*srvpost.OptionFeedback, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
 	if err != nil {
-		panic(fmt.Errorf("loading votes: %w", err))
+		panic(fmt.Errorf("loading post: %w", err))
 	}
-	return votes, nil
+	if post.FeedbackMode == srvpost.FeedbackModeNone ||
+		!post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	feedback, err := GetLoaders(ctx).PostFeedbackLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading feedback: %w", err))
+	}
+	if feedback == nil {
+		return nil, nil
+	}
+	return feedback.Option(obj.ID), nil
 }
 
-// Status is the resolver for the status field.
-func (r *postResolver) Status(ctx context.Context, obj *srvpost.Post) (model.PostStatus, error) {
-	if obj == nil || obj.OpensAt == nil || obj.ClosesAt == nil {
-		return model.PostStatusDraft, nil
+// Criteria is the resolver for the criteria field.
+func (r *postOptionResolver) Criteria(ctx context.Context, obj *srvpost.Option) ([]*srvpost.CriterionResult, error) {
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
 	}
-	if obj.OpensAt.After(time.Now()) {
-		return model.PostStatusDraft, nil
+	if !post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	criteria, err := GetLoaders(ctx).PostCriteriaLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading criteria: %w", err))
+	}
+	results, err := GetLoaders(ctx).PostCriterionResultsLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading criterion results: %w", err))
+	}
+
+	optionResults := []*srvpost.CriterionResult{}
+	for _, c := range criteria {
+		var optionResult *srvpost.CriterionResult
+		for _, r := range results {
+			if r.CriterionID == c.ID && r.OptionID == obj.ID {
+				optionResult = r
+				break
+			}
+		}
+		if optionResult == nil {
+			optionResult = &srvpost.CriterionResult{
+				PostID:      obj.PostID,
+				CriterionID: c.ID,
+				OptionID:    obj.ID,
+			}
+			if c.Kind == srvpost.CriterionKindTag {
+				tagPercentage := 0.0
+				optionResult.TagPercentage = &tagPercentage
+			}
+		}
+		optionResults = append(optionResults, optionResult)
+	}
+	return optionResults, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postOptionResolver) Comments(ctx context.Context, obj *srvpost.Option) ([]*srvcomment.Comment, error) {
+	comments, err := GetLoaders(ctx).PostOptionCommentsLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading comments: %w", err))
+	}
+	if comments == nil {
+		return []*srvcomment.Comment{}, nil
+	}
+	return comments, nil
+}
+
+// Criterion is the resolver for the criterion field.
+func (r *postOptionCriterionResultResolver) Criterion(ctx context.Context, obj *srvpost.CriterionResult) (*srvpost.Criterion, error) {
+	criteria, err := GetLoaders(ctx).PostCriteriaLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading criteria: %w", err))
+	}
+	for _, c := range criteria {
+		if c.ID == obj.CriterionID {
+			return c, nil
+		}
+	}
+	panic(fmt.Errorf("criterion %v not found", obj.CriterionID))
+}
+
+// Option is the resolver for the option field.
+func (r *postOptionRoundResultResolver) Option(ctx context.Context, obj *srvpost.OptionResult) (*srvpost.Option, error) {
+	option, err := GetLoaders(ctx).PostOptionLoader.Load(ctx, obj.OptionID)
+	if err != nil {
+		panic(fmt.Errorf("loading option: %w", err))
 	}
-	if obj.ClosesAt.After(time.Now()) {
-		return model.PostStatusLive, nil
+	return option, nil
+}
+
+// EliminatedOptions is the resolver for the eliminatedOptions field.
+func (r *postResultsRoundResolver) EliminatedOptions(ctx context.Context, obj *srvpost.RankedRound) ([]*srvpost.Option, error) {
+	options, err := GetLoaders(ctx).PostOptionLoader.LoadAll(ctx, obj.EliminatedOptionIDs)
+	if err != nil {
+		panic(fmt.Errorf("loading options: %w", err))
 	}
-	return model.PostStatusClosed, nil
+	return options, nil
 }
 
 // Post is the resolver for the post field.
@@ -442,15 +2817,68 @@ func (r *postVoteResolver) Post(ctx context.Context, obj *srvpost.Vote) (*srvpos
 
 // Voter is the resolver for the voter field.
 func (r *postVoteResolver) Voter(ctx context.Context, obj *srvpost.Vote) (*srvcustomer.Customer, error) {
-	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.CustomerID)
+	post, err := GetLoaders(ctx).PostLoader.Load(ctx, obj.PostID)
+	if err != nil {
+		panic(fmt.Errorf("loading post: %w", err))
+	}
+	if !obj.CustomerID.Valid || !post.VoterVisibleTo(*obj, GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, nil
+	}
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.CustomerID.UUID)
 	if err != nil {
 		panic(fmt.Errorf("loading author: %w", err))
 	}
 	return customer, nil
 }
 
-// Customer is the resolver for the customer field.
-func (r *queryResolver) Customer(ctx context.Context) (*srvcustomer.Customer, error) {
+// Options is the resolver for the options field.
+func (r *postVoteResolver) Options(ctx context.Context, obj *srvpost.Vote) ([]*srvpost.Option, error) {
+	options, err := GetLoaders(ctx).PostOptionLoader.LoadAll(ctx, obj.OptionIDs)
+	if err != nil {
+		panic(fmt.Errorf("loading options: %w", err))
+	}
+	return options, nil
+}
+
+// DisplayName is the resolver for the displayName field.
+func (r *profileResolver) DisplayName(ctx context.Context, obj *srvcustomer.Customer) (string, error) {
+	if obj.DeletedAt != nil {
+		return "Deleted account", nil
+	}
+	if obj.DisplayName != nil {
+		return *obj.DisplayName, nil
+	}
+	name := []string{}
+	if obj.FirstName != nil {
+		name = append(name, *obj.FirstName)
+	}
+	if obj.LastName != nil {
+		name = append(name, *obj.LastName)
+	}
+	if len(name) == 0 {
+		return "Quorum member", nil
+	}
+	return strings.Join(name, " "), nil
+}
+
+// IsDeleted is the resolver for the isDeleted field.
+func (r *profileResolver) IsDeleted(ctx context.Context, obj *srvcustomer.Customer) (bool, error) {
+	return obj.DeletedAt != nil, nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *profileResolver) Posts(ctx context.Context, obj *srvcustomer.Customer, first int, after *string, orderBy model.PostOrder) (*model.PostConnection, error) {
+	viewer := r.postViewer(ctx)
+	return r.postConnection(ctx, first, after, srvpost.GetPostsByFilterRequest{
+		OrderBy:   srvpost.PostOrder(orderBy),
+		AuthorIDs: []uuid.UUID{obj.ID},
+		Statuses:  []srvpost.PostStatus{srvpost.PostStatusLive, srvpost.PostStatusClosed},
+		Viewer:    &viewer,
+	})
+}
+
+// Viewer is the resolver for the viewer field.
+func (r *queryResolver) Viewer(ctx context.Context) (*srvcustomer.Customer, error) {
 	verifiedCustomer := GetVerifiedCustomer(ctx)
 	if !verifiedCustomer.Valid {
 		return nil, nil
@@ -462,28 +2890,465 @@ func (r *queryResolver) Customer(ctx context.Context) (*srvcustomer.Customer, er
 	return customer, nil
 }
 
+// Customer is the resolver for the customer field.
+func (r *queryResolver) Customer(ctx context.Context) (*srvcustomer.Customer, error) {
+	return r.Viewer(ctx)
+}
+
+// Profile is the resolver for the profile field.
+func (r *queryResolver) Profile(ctx context.Context, id uuid.UUID) (*srvcustomer.Customer, error) {
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, id)
+	if err != nil {
+		panic(fmt.Errorf("loading customer: %w", err))
+	}
+	return customer, nil
+}
+
+// Workspace is the resolver for the workspace field.
+func (r *queryResolver) Workspace(ctx context.Context, id uuid.UUID) (*srvworkspace.Workspace, error) {
+	if r.workspaceMembership(ctx, id) == nil {
+		return nil, nil
+	}
+	workspace, err := GetLoaders(ctx).WorkspaceLoader.Load(ctx, id)
+	if err != nil {
+		panic(fmt.Errorf("loading workspace: %w", err))
+	}
+	return workspace, nil
+}
+
 // Post is the resolver for the post field.
 func (r *queryResolver) Post(ctx context.Context, id uuid.UUID) (*srvpost.Post, error) {
 	post, err := GetLoaders(ctx).PostLoader.Load(ctx, id)
 	if err != nil {
 		panic(fmt.Errorf("loading post: %w", err))
 	}
+	if post == nil || !post.VisibleTo(r.postViewer(ctx), time.Now()) {
+		return nil, nil
+	}
 	return post, nil
 }
 
+// Posts is the resolver for the posts field.
+func (r *queryResolver) Posts(ctx context.Context, first int, after *string, filter *model.PostFilter, orderBy model.PostOrder) (*model.PostConnection, error) {
+	request := srvpost.GetPostsByFilterRequest{
+		OrderBy:  srvpost.PostOrder(orderBy),
+		Statuses: []srvpost.PostStatus{srvpost.PostStatusLive, srvpost.PostStatusClosed},
+	}
+	if filter != nil {
+		request.AuthorIDs = filter.AuthorIds
+		request.WorkspaceIDs = filter.WorkspaceIds
+		for _, c := range filter.Categories {
+			request.Categories = append(request.Categories, srvpost.PostCategory(c))
+		}
+		for _, dp := range filter.DesignPhases {
+			request.DesignPhases = append(request.DesignPhases, srvpost.DesignPhase(dp))
+		}
+		if len(filter.Statuses) > 0 {
+			request.Statuses = []srvpost.PostStatus{}
+			for _, st := range filter.Statuses {
+				request.Statuses = append(request.Statuses, srvpost.PostStatus(st))
+			}
+		}
+	}
+
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if verifiedCustomer.Valid {
+		request.DraftsAuthorID = verifiedCustomer
+	} else {
+		// Nobody's drafts are visible without logging in
+		statuses := []srvpost.PostStatus{}
+		for _, st := range request.Statuses {
+			if st != srvpost.PostStatusDraft {
+				statuses = append(statuses, st)
+			}
+		}
+		if len(statuses) == 0 {
+			return &model.PostConnection{
+				Edges:    []*model.PostEdge{},
+				PageInfo: &model.PageInfo{},
+			}, nil
+		}
+		request.Statuses = statuses
+	}
+	viewer := r.postViewer(ctx)
+	request.Viewer = &viewer
+
+	return r.postConnection(ctx, first, after, request)
+}
+
+// Current is the resolver for the current field.
+func (r *sessionResolver) Current(ctx context.Context, obj *srvcustomer.Session) (bool, error) {
+	sessionID := GetSessionID(ctx)
+	return sessionID.Valid && sessionID.UUID == obj.ID, nil
+}
+
+// PostVoteAdded is the resolver for the postVoteAdded field.
+func (r *subscriptionResolver) PostVoteAdded(ctx context.Context, postID uuid.UUID) (<-chan *srvpost.Vote, error) {
+	getPost := func() (*srvpost.Post, error) {
+		posts, err := r.Services.Post.GetPostsByFilter(ctx, srvpost.GetPostsByFilterRequest{
+			IDs: []uuid.UUID{postID},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(posts) != 1 || !posts[0].VisibleTo(r.postViewer(ctx), time.Now()) {
+			return nil, srvpost.ErrPostNotFound
+		}
+		return &posts[0], nil
+	}
+
+	post, err := getPost()
+	if errors.Is(err, srvpost.ErrPostNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		panic(fmt.Errorf("getting post: %w", err))
+	}
+	if !post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+		return nil, fmt.Errorf("only the author can watch votes on a post before it closes")
+	}
+
+	events, err := r.Services.Post.SubscribeToPostEvents(ctx, srvpost.SubscribeToPostEventsRequest{
+		PostID: postID,
+	})
+	if err != nil {
+		panic(fmt.Errorf("subscribing to post events: %w", err))
+	}
+
+	votes := make(chan *srvpost.Vote)
+	go func() {
+		defer close(votes)
+		for event := range events {
+			if event.Type != srvpost.PostEventTypeVoteAdded || event.VoteID == nil {
+				continue
+			}
+			// The author can change who sees the post, or reopen it, while
+			// it's being watched
+			post, err := getPost()
+			if errors.Is(err, srvpost.ErrPostNotFound) {
+				return
+			}
+			if err != nil {
+				log.Printf("getting post %s: %v", postID, err)
+				continue
+			}
+			if !post.ResultsVisibleTo(GetVerifiedCustomer(ctx), time.Now()) {
+				return
+			}
+			res, err := r.Services.Post.GetVotesByFilter(ctx, srvpost.GetVotesByFilterRequest{
+				IDs: []uuid.UUID{*event.VoteID},
+			})
+			if err != nil {
+				log.Printf("getting vote %s: %v", *event.VoteID, err)
+				continue
+			}
+			// Vote may have been retracted since
+			if len(res) != 1 {
+				continue
+			}
+			select {
+			case votes <- &res[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return votes, nil
+}
+
+// PostStatusChanged is the resolver for the postStatusChanged field.
+func (r *subscriptionResolver) PostStatusChanged(ctx context.Context, postID uuid.UUID) (<-chan *srvpost.Post, error) {
+	getPost := func() (*srvpost.Post, error) {
+		posts, err := r.Services.Post.GetPostsByFilter(ctx, srvpost.GetPostsByFilterRequest{
+			IDs: []uuid.UUID{postID},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Checked again on each update, as the author can change who can see
+		// the post while it's being watched
+		if len(posts) != 1 || !posts[0].VisibleTo(r.postViewer(ctx), time.Now()) {
+			return nil, srvpost.ErrPostNotFound
+		}
+		return &posts[0], nil
+	}
+
+	post, err := getPost()
+	if errors.Is(err, srvpost.ErrPostNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		panic(fmt.Errorf("getting post: %w", err))
+	}
+
+	events, err := r.Services.Post.SubscribeToPostEvents(ctx, srvpost.SubscribeToPostEventsRequest{
+		PostID: postID,
+	})
+	if err != nil {
+		panic(fmt.Errorf("subscribing to post events: %w", err))
+	}
+
+	statusChanges := make(chan *srvpost.Post)
+	go func() {
+		defer close(statusChanges)
+		var lastStatus srvpost.PostStatus
+		for {
+			now := time.Now()
+			if status := post.Status(now); status != lastStatus {
+				select {
+				case statusChanges <- post:
+				case <-ctx.Done():
+					return
+				}
+				lastStatus = status
+			}
+
+			// Status changes as opensAt and closesAt pass, as well as when
+			// the author updates the post
+			var statusTimer *time.Timer
+			var statusTimerC <-chan time.Time
+			if nextChangeAt := post.NextStatusChangeAt(now); nextChangeAt != nil {
+				statusTimer = time.NewTimer(nextChangeAt.Sub(now))
+				statusTimerC = statusTimer.C
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-statusTimerC:
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.Type == srvpost.PostEventTypeUpdated {
+					updatedPost, err := getPost()
+					if errors.Is(err, srvpost.ErrPostNotFound) {
+						return
+					}
+					if err != nil {
+						log.Printf("getting post %s: %v", postID, err)
+					} else {
+						post = updatedPost
+					}
+				}
+			}
+			if statusTimer != nil {
+				statusTimer.Stop()
+			}
+		}
+	}()
+	return statusChanges, nil
+}
+
+// Sessions is the resolver for the sessions field.
+func (r *viewerResolver) Sessions(ctx context.Context, obj *srvcustomer.Customer) ([]*srvcustomer.Session, error) {
+	verifiedCustomer := GetVerifiedCustomer(ctx)
+	if !verifiedCustomer.Valid || verifiedCustomer.UUID != obj.ID {
+		return nil, nil
+	}
+	sessions, err := r.Services.Customer.GetSessionsByFilter(
+		ctx, srvcustomer.GetSessionsByFilterRequest{
+			CustomerIDs: []uuid.UUID{obj.ID},
+			ActiveOnly:  true,
+		},
+	)
+	if err != nil {
+		panic(fmt.Errorf("getting sessions: %w", err))
+	}
+	res := []*srvcustomer.Session{}
+	for i := range sessions {
+		res = append(res, &sessions[i])
+	}
+	return res, nil
+}
+
+// Profile is the resolver for the profile field.
+func (r *viewerResolver) Profile(ctx context.Context, obj *srvcustomer.Customer) (*srvcustomer.Customer, error) {
+	return obj, nil
+}
+
+// Workspaces is the resolver for the workspaces field.
+func (r *viewerResolver) Workspaces(ctx context.Context, obj *srvcustomer.Customer) ([]*srvworkspace.Workspace, error) {
+	workspaces, err := r.Services.Workspace.GetWorkspacesByFilter(
+		ctx, srvworkspace.GetWorkspacesByFilterRequest{
+			MemberIDs: []uuid.UUID{obj.ID},
+		},
+	)
+	if err != nil {
+		panic(fmt.Errorf("getting workspaces: %w", err))
+	}
+	res := []*srvworkspace.Workspace{}
+	for i := range workspaces {
+		res = append(res, &workspaces[i])
+	}
+	return res, nil
+}
+
+// ViewerRole is the resolver for the viewerRole field.
+func (r *workspaceResolver) ViewerRole(ctx context.Context, obj *srvworkspace.Workspace) (model.WorkspaceRole, error) {
+	membership := r.workspaceMembership(ctx, obj.ID)
+	if membership == nil {
+		return "", srvworkspace.ErrWorkspaceNotFound
+	}
+	return model.WorkspaceRole(membership.Role), nil
+}
+
+// Members is the resolver for the members field.
+func (r *workspaceResolver) Members(ctx context.Context, obj *srvworkspace.Workspace) ([]*srvworkspace.Member, error) {
+	if r.workspaceMembership(ctx, obj.ID) == nil {
+		return []*srvworkspace.Member{}, nil
+	}
+	members, err := GetLoaders(ctx).WorkspaceMembersLoader.Load(ctx, obj.ID)
+	if err != nil {
+		panic(fmt.Errorf("loading members: %w", err))
+	}
+	if members == nil {
+		return []*srvworkspace.Member{}, nil
+	}
+	return members, nil
+}
+
+// Invitations is the resolver for the invitations field.
+func (r *workspaceResolver) Invitations(ctx context.Context, obj *srvworkspace.Workspace) ([]*srvworkspace.Invitation, error) {
+	membership := r.workspaceMembership(ctx, obj.ID)
+	if membership == nil || !membership.Role.AtLeast(srvworkspace.RoleAdmin) {
+		return nil, nil
+	}
+	invitations, err := r.Services.Workspace.GetInvitationsByFilter(
+		ctx, srvworkspace.GetInvitationsByFilterRequest{
+			WorkspaceIDs: []uuid.UUID{obj.ID},
+			PendingOnly:  true,
+		},
+	)
+	if err != nil {
+		panic(fmt.Errorf("getting invitations: %w", err))
+	}
+	res := []*srvworkspace.Invitation{}
+	for i := range invitations {
+		res = append(res, &invitations[i])
+	}
+	return res, nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *workspaceResolver) Posts(ctx context.Context, obj *srvworkspace.Workspace, first int, after *string, orderBy model.PostOrder) (*model.PostConnection, error) {
+	viewer := r.postViewer(ctx)
+	return r.postConnection(ctx, first, after, srvpost.GetPostsByFilterRequest{
+		OrderBy:      srvpost.PostOrder(orderBy),
+		WorkspaceIDs: []uuid.UUID{obj.ID},
+		Statuses:     []srvpost.PostStatus{srvpost.PostStatusLive, srvpost.PostStatusClosed},
+		Viewer:       &viewer,
+	})
+}
+
+// Role is the resolver for the role field.
+func (r *workspaceInvitationResolver) Role(ctx context.Context, obj *srvworkspace.Invitation) (model.WorkspaceRole, error) {
+	return model.WorkspaceRole(obj.Role), nil
+}
+
+// InvitedBy is the resolver for the invitedBy field.
+func (r *workspaceInvitationResolver) InvitedBy(ctx context.Context, obj *srvworkspace.Invitation) (*srvcustomer.Customer, error) {
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.InvitedBy)
+	if err != nil {
+		panic(fmt.Errorf("loading inviter: %w", err))
+	}
+	return customer, nil
+}
+
+// Profile is the resolver for the profile field.
+func (r *workspaceMemberResolver) Profile(ctx context.Context, obj *srvworkspace.Member) (*srvcustomer.Customer, error) {
+	customer, err := GetLoaders(ctx).CustomerLoader.Load(ctx, obj.CustomerID)
+	if err != nil {
+		panic(fmt.Errorf("loading member: %w", err))
+	}
+	return customer, nil
+}
+
+// Role is the resolver for the role field.
+func (r *workspaceMemberResolver) Role(ctx context.Context, obj *srvworkspace.Member) (model.WorkspaceRole, error) {
+	return model.WorkspaceRole(obj.Role), nil
+}
+
+// JoinedAt is the resolver for the joinedAt field.
+func (r *workspaceMemberResolver) JoinedAt(ctx context.Context, obj *srvworkspace.Member) (*time.Time, error) {
+	return &obj.CreatedAt, nil
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Post returns PostResolver implementation.
 func (r *Resolver) Post() PostResolver { return &postResolver{r} }
 
+// PostCriterion returns PostCriterionResolver implementation.
+func (r *Resolver) PostCriterion() PostCriterionResolver { return &postCriterionResolver{r} }
+
+// PostMatchup returns PostMatchupResolver implementation.
+func (r *Resolver) PostMatchup() PostMatchupResolver { return &postMatchupResolver{r} }
+
+// PostOption returns PostOptionResolver implementation.
+func (r *Resolver) PostOption() PostOptionResolver { return &postOptionResolver{r} }
+
+// PostOptionCriterionResult returns PostOptionCriterionResultResolver implementation.
+func (r *Resolver) PostOptionCriterionResult() PostOptionCriterionResultResolver {
+	return &postOptionCriterionResultResolver{r}
+}
+
+// PostOptionRoundResult returns PostOptionRoundResultResolver implementation.
+func (r *Resolver) PostOptionRoundResult() PostOptionRoundResultResolver {
+	return &postOptionRoundResultResolver{r}
+}
+
+// PostResultsRound returns PostResultsRoundResolver implementation.
+func (r *Resolver) PostResultsRound() PostResultsRoundResolver { return &postResultsRoundResolver{r} }
+
 // PostVote returns PostVoteResolver implementation.
 func (r *Resolver) PostVote() PostVoteResolver { return &postVoteResolver{r} }
 
+// Profile returns ProfileResolver implementation.
+func (r *Resolver) Profile() ProfileResolver { return &profileResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Session returns SessionResolver implementation.
+func (r *Resolver) Session() SessionResolver { return &sessionResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Viewer returns ViewerResolver implementation.
+func (r *Resolver) Viewer() ViewerResolver { return &viewerResolver{r} }
+
+// Workspace returns WorkspaceResolver implementation.
+func (r *Resolver) Workspace() WorkspaceResolver { return &workspaceResolver{r} }
+
+// WorkspaceInvitation returns WorkspaceInvitationResolver implementation.
+func (r *Resolver) WorkspaceInvitation() WorkspaceInvitationResolver {
+	return &workspaceInvitationResolver{r}
+}
+
+// WorkspaceMember returns WorkspaceMemberResolver implementation.
+func (r *Resolver) WorkspaceMember() WorkspaceMemberResolver { return &workspaceMemberResolver{r} }
+
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
+type postCriterionResolver struct{ *Resolver }
+type postMatchupResolver struct{ *Resolver }
+type postOptionResolver struct{ *Resolver }
+type postOptionCriterionResultResolver struct{ *Resolver }
+type postOptionRoundResultResolver struct{ *Resolver }
+type postResultsRoundResolver struct{ *Resolver }
 type postVoteResolver struct{ *Resolver }
+type profileResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type sessionResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type viewerResolver struct{ *Resolver }
+type workspaceResolver struct{ *Resolver }
+type workspaceInvitationResolver struct{ *Resolver }
+type workspaceMemberResolver struct{ *Resolver }
@@ -0,0 +1,357 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"quorum-api/pubsub"
+	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakePostService serves posts and votes from memory, and post events through
+// the real service so they go via the pubsub it was made with.
+type fakePostService struct {
+	srvpost.SRVPost
+	mu    sync.Mutex
+	posts map[uuid.UUID]srvpost.Post
+	votes map[uuid.UUID]srvpost.Vote
+}
+
+func (s *fakePostService) GetPostsByFilter(
+	ctx context.Context, request srvpost.GetPostsByFilterRequest,
+) ([]srvpost.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []srvpost.Post{}
+	for _, id := range request.IDs {
+		if p, ok := s.posts[id]; ok {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakePostService) GetVotesByFilter(
+	ctx context.Context, request srvpost.GetVotesByFilterRequest,
+) ([]srvpost.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []srvpost.Vote{}
+	for _, id := range request.IDs {
+		if v, ok := s.votes[id]; ok {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakePostService) setPost(p srvpost.Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts[p.ID] = p
+}
+
+func (s *fakePostService) setVote(v srvpost.Vote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.votes[v.ID] = v
+}
+
+type fakeWorkspaceService struct {
+	srvworkspace.SRVWorkspace
+}
+
+func (s *fakeWorkspaceService) GetMembersByFilter(
+	ctx context.Context, request srvworkspace.GetMembersByFilterRequest,
+) ([]srvworkspace.Member, error) {
+	return nil, nil
+}
+
+type subscriptionTest struct {
+	ps       pubsub.PubSub
+	posts    *fakePostService
+	resolver *subscriptionResolver
+}
+
+func newSubscriptionTest() *subscriptionTest {
+	ps := pubsub.NewMemory()
+	posts := &fakePostService{
+		SRVPost: srvpost.New(nil, nil, ps),
+		posts:   map[uuid.UUID]srvpost.Post{},
+		votes:   map[uuid.UUID]srvpost.Vote{},
+	}
+	return &subscriptionTest{
+		ps:    ps,
+		posts: posts,
+		resolver: &subscriptionResolver{&Resolver{
+			Services: Services{
+				Post:      posts,
+				Workspace: &fakeWorkspaceService{},
+			},
+		}},
+	}
+}
+
+// ctxFor is the context of a websocket connection authenticated as the
+// customer.
+func (st *subscriptionTest) ctxFor(ctx context.Context, customerID uuid.UUID) context.Context {
+	ctx = context.WithValue(ctx, authCtxKey{}, authInfo{CustomerID: customerID})
+	return context.WithValue(ctx, loadersCtxKey{}, NewLoaders(st.resolver.Services))
+}
+
+// publish sends the event the way the post service does once a change has
+// committed.
+func (st *subscriptionTest) publish(t *testing.T, event srvpost.PostEvent) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshalling event: %v", err)
+	}
+	topic := fmt.Sprintf("post:%s", event.PostID)
+	if err = st.ps.Publish(context.Background(), topic, payload); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+}
+
+func receiveWithin[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	var zero T
+	return zero
+}
+
+func TestPostVoteAddedReceivesVotes(t *testing.T) {
+	st := newSubscriptionTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authorID := uuid.New()
+	opensAt := time.Now().Add(-time.Hour)
+	closesAt := time.Now().Add(time.Hour)
+	post := srvpost.Post{
+		ID:       uuid.New(),
+		AuthorID: authorID,
+		OpensAt:  &opensAt,
+		ClosesAt: &closesAt,
+	}
+	st.posts.setPost(post)
+
+	votes, err := st.resolver.PostVoteAdded(st.ctxFor(ctx, authorID), post.ID)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	// Retracted before it could be loaded, so it's skipped
+	retractedVoteID := uuid.New()
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeVoteAdded,
+		PostID: post.ID,
+		VoteID: &retractedVoteID,
+	})
+	// Not a vote
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeUpdated,
+		PostID: post.ID,
+	})
+	vote := srvpost.Vote{
+		ID:       uuid.New(),
+		PostID:   post.ID,
+		OptionID: uuid.New(),
+	}
+	st.posts.setVote(vote)
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeVoteAdded,
+		PostID: post.ID,
+		VoteID: &vote.ID,
+	})
+
+	if got := receiveWithin(t, votes); got.ID != vote.ID {
+		t.Errorf("got vote %s, want %s", got.ID, vote.ID)
+	}
+
+	cancel()
+	for range votes {
+	}
+}
+
+func TestPostVoteAddedHidesLiveVotesFromNonAuthors(t *testing.T) {
+	st := newSubscriptionTest()
+	opensAt := time.Now().Add(-time.Hour)
+	closesAt := time.Now().Add(time.Hour)
+	post := srvpost.Post{
+		ID:       uuid.New(),
+		AuthorID: uuid.New(),
+		OpensAt:  &opensAt,
+		ClosesAt: &closesAt,
+	}
+	st.posts.setPost(post)
+
+	ctx := st.ctxFor(context.Background(), uuid.New())
+	if _, err := st.resolver.PostVoteAdded(ctx, post.ID); err == nil {
+		t.Fatal("expected an error subscribing to someone else's live post")
+	}
+}
+
+func TestPostStatusChangedReceivesUpdates(t *testing.T) {
+	st := newSubscriptionTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authorID := uuid.New()
+	post := srvpost.Post{
+		ID:       uuid.New(),
+		AuthorID: authorID,
+	}
+	st.posts.setPost(post)
+
+	statuses, err := st.resolver.PostStatusChanged(st.ctxFor(ctx, authorID), post.ID)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	if got := receiveWithin(t, statuses); got.Status(time.Now()) != srvpost.PostStatusDraft {
+		t.Fatalf("got initial status %s, want DRAFT", got.Status(time.Now()))
+	}
+
+	// The author opens the post for voting
+	opensAt := time.Now().Add(-time.Minute)
+	closesAt := time.Now().Add(time.Hour)
+	post.OpensAt = &opensAt
+	post.ClosesAt = &closesAt
+	st.posts.setPost(post)
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeUpdated,
+		PostID: post.ID,
+	})
+
+	if got := receiveWithin(t, statuses); got.Status(time.Now()) != srvpost.PostStatusLive {
+		t.Errorf("got status %s, want LIVE", got.Status(time.Now()))
+	}
+
+	cancel()
+	for range statuses {
+	}
+}
+
+// receiveClosed waits for the subscription to end without sending anything
+// else.
+func receiveClosed[T any](t *testing.T, ch <-chan T) {
+	t.Helper()
+	select {
+	case v, ok := <-ch:
+		if ok {
+			t.Fatalf("got %v, want the channel closed", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the channel to close")
+	}
+}
+
+func TestPostVoteAddedStopsWhenResultsAreHidden(t *testing.T) {
+	st := newSubscriptionTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opensAt := time.Now().Add(-2 * time.Hour)
+	closesAt := time.Now().Add(-time.Hour)
+	post := srvpost.Post{
+		ID:       uuid.New(),
+		AuthorID: uuid.New(),
+		OpensAt:  &opensAt,
+		ClosesAt: &closesAt,
+	}
+	st.posts.setPost(post)
+
+	// Anyone can watch votes once the post has closed
+	votes, err := st.resolver.PostVoteAdded(st.ctxFor(ctx, uuid.New()), post.ID)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	// The author reopens it
+	reopenedClosesAt := time.Now().Add(time.Hour)
+	post.ClosesAt = &reopenedClosesAt
+	st.posts.setPost(post)
+
+	vote := srvpost.Vote{
+		ID:       uuid.New(),
+		PostID:   post.ID,
+		OptionID: uuid.New(),
+	}
+	st.posts.setVote(vote)
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeVoteAdded,
+		PostID: post.ID,
+		VoteID: &vote.ID,
+	})
+
+	receiveClosed(t, votes)
+}
+
+func TestPostStatusChangedHidesOtherAuthorsDrafts(t *testing.T) {
+	st := newSubscriptionTest()
+	post := srvpost.Post{
+		ID:       uuid.New(),
+		AuthorID: uuid.New(),
+	}
+	st.posts.setPost(post)
+
+	ctx := st.ctxFor(context.Background(), uuid.New())
+	if _, err := st.resolver.PostStatusChanged(ctx, post.ID); !errors.Is(err, srvpost.ErrPostNotFound) {
+		t.Fatalf("got %v, want ErrPostNotFound", err)
+	}
+	if _, err := st.resolver.PostVoteAdded(ctx, post.ID); !errors.Is(err, srvpost.ErrPostNotFound) {
+		t.Fatalf("got %v, want ErrPostNotFound", err)
+	}
+}
+
+func TestPostStatusChangedStopsWhenPostIsHidden(t *testing.T) {
+	st := newSubscriptionTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opensAt := time.Now().Add(-time.Hour)
+	closesAt := time.Now().Add(time.Hour)
+	post := srvpost.Post{
+		ID:         uuid.New(),
+		AuthorID:   uuid.New(),
+		OpensAt:    &opensAt,
+		ClosesAt:   &closesAt,
+		Visibility: srvpost.PostVisibilityPublic,
+	}
+	st.posts.setPost(post)
+
+	statuses, err := st.resolver.PostStatusChanged(st.ctxFor(ctx, uuid.New()), post.ID)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	if got := receiveWithin(t, statuses); got.Status(time.Now()) != srvpost.PostStatusLive {
+		t.Fatalf("got initial status %s, want LIVE", got.Status(time.Now()))
+	}
+
+	// The author moves it into a workspace the viewer isn't a member of
+	workspaceID := uuid.New()
+	post.Visibility = srvpost.PostVisibilityWorkspace
+	post.WorkspaceID = &workspaceID
+	st.posts.setPost(post)
+	st.publish(t, srvpost.PostEvent{
+		Type:   srvpost.PostEventTypeUpdated,
+		PostID: post.ID,
+	})
+
+	receiveClosed(t, statuses)
+}
@@ -0,0 +1,256 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// KeyRing signs JWTs with a single key, and verifies them against any key in
+// the ring. Keeping the previous signing key in the ring while rotating means
+// tokens it signed stay valid until they expire.
+type KeyRing struct {
+	signing *key
+	// Keyed by kid
+	keys map[string]*key
+}
+
+type key struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+var ErrUnknownKey = errors.New("token was not signed by a key in the ring")
+var ErrUnexpectedSigningMethod = errors.New("token signing method does not match its key")
+
+// New creates a ring that signs with signingKeyPEM, a PKCS8, SEC1 or PKCS1
+// encoded P-256 or RSA private key. verificationKeysPEM holds any number of
+// PKIX public keys that are also accepted, e.g. keys being rotated out.
+func New(signingKeyPEM []byte, verificationKeysPEM []byte) (*KeyRing, error) {
+	block, _ := pem.Decode(signingKeyPEM)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+	private, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing key: %w", err)
+	}
+	signing, err := newKey(private.Public())
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing key: %w", err)
+	}
+	signing.private = private
+
+	k := &KeyRing{
+		signing: signing,
+		keys: map[string]*key{
+			signing.id: signing,
+		},
+	}
+
+	rest := verificationKeysPEM
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing verification key: %w", err)
+		}
+		verification, err := newKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("parsing verification key: %w", err)
+		}
+		if _, ok := k.keys[verification.id]; !ok {
+			k.keys[verification.id] = verification
+		}
+	}
+
+	return k, nil
+}
+
+// NewEphemeral creates a ring with a random ES256 key, for local dev. Tokens
+// stop working when the process restarts.
+func NewEphemeral() (*KeyRing, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling key: %w", err)
+	}
+	return New(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil)
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if private, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", private)
+		}
+		return signer, nil
+	}
+	if private, err := x509.ParseECPrivateKey(der); err == nil {
+		return private, nil
+	}
+	if private, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return private, nil
+	}
+	return nil, errors.New("expected a PKCS8, SEC1 or PKCS1 private key")
+}
+
+func newKey(public crypto.PublicKey) (*key, error) {
+	k := &key{public: public}
+	switch public := public.(type) {
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", public.Curve.Params().Name)
+		}
+		k.method = jwt.SigningMethodES256
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return nil, errors.New("rsa keys must be at least 2048 bits")
+		}
+		k.method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	k.id = thumbprint(k.jwk())
+	return k, nil
+}
+
+// Sign signs the claims with the signing key, setting the kid header so the
+// verifier knows which key to use.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.id
+	signed, err := token.SignedString(k.signing.private)
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse verifies the token against the key named by its kid header, checking
+// the token was signed with that key's algorithm.
+func (k *KeyRing) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		k.keyfunc,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodES256.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+		}),
+	)
+}
+
+func (k *KeyRing) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key.public, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+func (k *key) jwk() jwk {
+	switch public := k.public.(type) {
+	case *ecdsa.PublicKey:
+		return jwk{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   encodeCoordinate(public.X),
+			Y:   encodeCoordinate(public.Y),
+		}
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}
+	}
+	return jwk{}
+}
+
+// encodeCoordinate pads P-256 coordinates to 32 bytes as required by RFC 7518.
+func encodeCoordinate(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+}
+
+// thumbprint is the RFC 7638 thumbprint of the key, used as its kid so ids
+// don't need to be configured.
+func thumbprint(j jwk) string {
+	var members string
+	switch j.Kty {
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, j.Crv, j.Kty, j.X, j.Y)
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, j.E, j.Kty, j.N)
+	}
+	hash := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// JWKSHandler serves every public key in the ring as a JWK set, for
+// /.well-known/jwks.json.
+func (k *KeyRing) JWKSHandler() http.Handler {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{
+		Keys: []jwk{},
+	}
+	// Signing key first, so it's the one clients pick when kid is missing
+	ordered := []*key{k.signing}
+	for id, key := range k.keys {
+		if id != k.signing.id {
+			ordered = append(ordered, key)
+		}
+	}
+	for _, key := range ordered {
+		j := key.jwk()
+		j.Kid = key.id
+		j.Use = "sig"
+		j.Alg = key.method.Alg()
+		set.Keys = append(set.Keys, j)
+	}
+	body, _ := json.Marshal(set)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(body)
+	})
+}
@@ -0,0 +1,265 @@
+package keyring
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func ecKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return private
+}
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return private
+}
+
+func privatePEM(t *testing.T, private interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, public interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func newRing(t *testing.T, signingKeyPEM []byte, verificationKeysPEM []byte) *KeyRing {
+	t.Helper()
+	k, err := New(signingKeyPEM, verificationKeysPEM)
+	if err != nil {
+		t.Fatalf("creating ring: %v", err)
+	}
+	return k
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "customer",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		private interface{}
+		alg     string
+	}{
+		{"ES256", ecKey(t), "ES256"},
+		{"RS256", rsaKey(t), "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newRing(t, privatePEM(t, tt.private), nil)
+			signed, err := k.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("signing: %v", err)
+			}
+
+			claims := jwt.RegisteredClaims{}
+			token, err := k.Parse(signed, &claims)
+			if err != nil {
+				t.Fatalf("parsing: %v", err)
+			}
+			if claims.Subject != "customer" {
+				t.Errorf("got subject %q", claims.Subject)
+			}
+			if token.Method.Alg() != tt.alg || token.Header["kid"] != k.signing.id {
+				t.Errorf("got alg %v and kid %v", token.Method.Alg(), token.Header["kid"])
+			}
+		})
+	}
+}
+
+func TestParseWithRotatedKey(t *testing.T) {
+	oldKey := ecKey(t)
+	oldRing := newRing(t, privatePEM(t, oldKey), nil)
+	signed, err := oldRing.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+
+	// The old key is kept for verification after switching to a new one
+	rotated := newRing(t, privatePEM(t, rsaKey(t)), publicPEM(t, &oldKey.PublicKey))
+	if _, err = rotated.Parse(signed, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("parsing with the rotated ring: %v", err)
+	}
+
+	// Then dropped once tokens it signed have expired
+	dropped := newRing(t, privatePEM(t, rsaKey(t)), nil)
+	if _, err = dropped.Parse(signed, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestParseRejectsForgedTokens(t *testing.T) {
+	ecPrivate := ecKey(t)
+	rsaPrivate := rsaKey(t)
+	k := newRing(t, privatePEM(t, rsaPrivate), publicPEM(t, &ecPrivate.PublicKey))
+	ecKID := thumbprint((&key{public: &ecPrivate.PublicKey}).jwk())
+
+	sign := func(method jwt.SigningMethod, kid string, signingKey interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, testClaims())
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatalf("signing: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		signed string
+		err    error
+	}{
+		{
+			name:   "alg none",
+			signed: sign(jwt.SigningMethodNone, k.signing.id, jwt.UnsafeAllowNoneSignatureType),
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:   "HS256 with the public key as the secret",
+			signed: sign(jwt.SigningMethodHS256, k.signing.id, publicPEM(t, &rsaPrivate.PublicKey)),
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:   "unknown kid",
+			signed: sign(jwt.SigningMethodES256, "unknown", ecKey(t)),
+			err:    ErrUnknownKey,
+		},
+		{
+			name:   "no kid",
+			signed: sign(jwt.SigningMethodRS256, "", rsaKey(t)),
+			err:    ErrUnknownKey,
+		},
+		{
+			name:   "another key's kid",
+			signed: sign(jwt.SigningMethodES256, ecKID, ecKey(t)),
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:   "RS256 with the kid of an ES256 key",
+			signed: sign(jwt.SigningMethodRS256, ecKID, rsaKey(t)),
+			err:    ErrUnexpectedSigningMethod,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Parse(tt.signed, &jwt.RegisteredClaims{})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// From RFC 7638 section 3.1
+func TestThumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString(
+		"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	)
+	if err != nil {
+		t.Fatalf("decoding modulus: %v", err)
+	}
+	k, err := newKey(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537})
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; k.id != want {
+		t.Errorf("got kid %s, want %s", k.id, want)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	ecPrivate := ecKey(t)
+	rsaPrivate := rsaKey(t)
+	k := newRing(t, privatePEM(t, ecPrivate), publicPEM(t, &rsaPrivate.PublicKey))
+
+	rec := httptest.NewRecorder()
+	k.JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got content type %q", ct)
+	}
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatalf("decoding jwks: %v", err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("got %v keys, want 2", len(set.Keys))
+	}
+
+	// The signing key comes first
+	if set.Keys[0]["kid"] != k.signing.id {
+		t.Errorf("got first kid %s, want the signing key %s", set.Keys[0]["kid"], k.signing.id)
+	}
+	wantAlgs := []string{"ES256", "RS256"}
+	required := map[string][]string{
+		"EC":  {"crv", "kty", "x", "y"},
+		"RSA": {"e", "kty", "n"},
+	}
+	for i, j := range set.Keys {
+		if j["alg"] != wantAlgs[i] || j["use"] != "sig" {
+			t.Errorf("got alg %s and use %s for key %v", j["alg"], j["use"], i)
+		}
+		// The kid is the hash of the required members, ordered
+		// lexicographically, with no whitespace
+		members := map[string]string{}
+		for _, name := range required[j["kty"]] {
+			members[name] = j[name]
+		}
+		canonical, _ := json.Marshal(members)
+		hash := sha256.Sum256(canonical)
+		if want := base64.RawURLEncoding.EncodeToString(hash[:]); j["kid"] != want {
+			t.Errorf("got kid %s for %s, want %s", j["kid"], canonical, want)
+		}
+	}
+
+	// Coordinates are padded to the curve size
+	x, _ := base64.RawURLEncoding.DecodeString(set.Keys[0]["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(set.Keys[0]["y"])
+	if len(x) != 32 || len(y) != 32 {
+		t.Errorf("got %v and %v byte coordinates, want 32", len(x), len(y))
+	}
+	if !ecPrivate.PublicKey.Equal(&ecdsa.PublicKey{
+		Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y),
+	}) {
+		t.Error("jwks key doesn't match the signing key")
+	}
+}
@@ -0,0 +1,10 @@
+begin;
+
+create type results_visibility as enum (
+    'ANONYMOUS',
+    'ATTRIBUTED'
+);
+
+alter table post add column results_visibility results_visibility not null default 'ANONYMOUS';
+
+commit;
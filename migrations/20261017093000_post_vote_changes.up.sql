@@ -0,0 +1,25 @@
+begin;
+
+alter table post add column allow_vote_changes boolean not null default true;
+
+alter table post_vote add column updated_at timestamptz not null default now();
+
+create type post_vote_action as enum (
+    'CAST',
+    'CHANGED',
+    'RETRACTED'
+);
+
+create table post_vote_event (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    customer_id uuid not null references customer(id),
+    post_option_id uuid references post_option(id),
+    action post_vote_action not null,
+    reason text,
+    created_at timestamptz not null default now()
+);
+
+create index idx_post_vote_event_post_id_customer_id on post_vote_event(post_id, customer_id);
+
+commit;
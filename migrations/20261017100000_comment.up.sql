@@ -0,0 +1,20 @@
+begin;
+
+create table comment (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    post_option_id uuid references post_option(id) on delete cascade,
+    parent_id uuid references comment(id),
+    author_id uuid not null references customer(id),
+    body text not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    edited_at timestamptz,
+    deleted_at timestamptz
+);
+
+create index idx_comment_post_id on comment(post_id);
+create index idx_comment_post_option_id on comment(post_option_id);
+create index idx_comment_parent_id on comment(parent_id);
+
+commit;
@@ -0,0 +1,33 @@
+begin;
+
+create type post_status as enum (
+    'DRAFT',
+    'LIVE',
+    'CLOSED'
+);
+
+-- Last status the lifecycle worker acted on. The current status is still
+-- worked out from opens_at and closes_at.
+alter table post add column recorded_status post_status not null default 'DRAFT';
+
+update post set recorded_status = (
+    case
+        when opens_at is null or closes_at is null or opens_at > now() then 'DRAFT'
+        when closes_at > now() then 'LIVE'
+        else 'CLOSED'
+    end
+)::post_status;
+
+create index idx_post_recorded_status on post(recorded_status) where recorded_status <> 'CLOSED';
+
+create table post_status_transition (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    from_status post_status not null,
+    to_status post_status not null,
+    created_at timestamptz not null default now()
+);
+
+create index idx_post_status_transition_post_id on post_status_transition(post_id);
+
+commit;
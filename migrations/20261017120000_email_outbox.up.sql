@@ -0,0 +1,28 @@
+begin;
+
+create type email_outbox_status as enum (
+    'PENDING',
+    'SENT',
+    'DEAD'
+);
+
+create table email_outbox (
+    id uuid primary key,
+    idempotency_key text not null,
+    to_email text not null,
+    template_id int not null,
+    subject text not null,
+    variables jsonb not null default '{}',
+    status email_outbox_status not null default 'PENDING',
+    attempts int not null default 0,
+    next_attempt_at timestamptz not null default now(),
+    last_error text,
+    sent_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create unique index idx_email_outbox_idempotency_key on email_outbox(idempotency_key);
+create index idx_email_outbox_pending on email_outbox(next_attempt_at) where status = 'PENDING';
+
+commit;
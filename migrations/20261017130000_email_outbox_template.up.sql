@@ -0,0 +1,15 @@
+begin;
+
+alter table email_outbox add column template text;
+
+-- 5834186 was the mailjet login link template, the only other template in
+-- use was the results email
+update email_outbox set template = case
+    when template_id = 5834186 then 'login_link'
+    else 'post_results'
+end;
+
+alter table email_outbox alter column template set not null;
+alter table email_outbox drop column template_id;
+
+commit;
@@ -0,0 +1,18 @@
+begin;
+
+-- Single use tokens sent in login links. customer_id can reference either
+-- customer or unverified_customer, as links are how customers get verified.
+create table login_token (
+    id uuid primary key,
+    customer_id uuid not null,
+    token_hash bytea not null,
+    expires_at timestamptz not null,
+    used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index idx_login_token_token_hash on login_token(token_hash);
+create index idx_login_token_customer_id on login_token(customer_id) where used_at is null and revoked_at is null;
+
+commit;
@@ -0,0 +1,28 @@
+begin;
+
+create table session (
+    id uuid primary key,
+    customer_id uuid not null references customer(id),
+    ip_address text,
+    user_agent text,
+    created_at timestamptz not null default now(),
+    last_seen_at timestamptz not null default now(),
+    -- Pushed back each time the session is refreshed
+    expires_at timestamptz not null,
+    revoked_at timestamptz
+);
+
+create index idx_session_customer_id on session(customer_id);
+
+-- Refresh tokens are rotated on every use. Rotated tokens are kept so that
+-- reusing one, e.g. after it was stolen, revokes the whole session.
+create table session_refresh_token (
+    token_hash bytea primary key,
+    session_id uuid not null references session(id) on delete cascade,
+    created_at timestamptz not null default now(),
+    rotated_at timestamptz
+);
+
+create index idx_session_refresh_token_session_id on session_refresh_token(session_id);
+
+commit;
@@ -0,0 +1,26 @@
+begin;
+
+-- Deleted customers are kept with their details erased, so posts and comments
+-- that reference them still resolve.
+alter table customer add column deleted_at timestamptz;
+
+-- Votes are anonymised rather than deleted when the voter deletes their account
+alter table post_vote alter column customer_id drop not null;
+alter table post_vote_event alter column customer_id drop not null;
+
+-- Single use tokens sent to the new address to confirm an email change
+create table email_change_request (
+    id uuid primary key,
+    customer_id uuid not null references customer(id),
+    new_email text not null,
+    token_hash bytea not null,
+    expires_at timestamptz not null,
+    used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index idx_email_change_request_token_hash on email_change_request(token_hash);
+create index idx_email_change_request_customer_id on email_change_request(customer_id) where used_at is null and revoked_at is null;
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+-- Public profile shown on posts, comments and votes instead of the customer's
+-- name and email
+alter table customer add column display_name text;
+alter table customer add column bio text;
+alter table customer add column portfolio_url text;
+alter table customer add column avatar_file_key text;
+
+commit;
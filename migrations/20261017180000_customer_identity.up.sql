@@ -0,0 +1,33 @@
+begin;
+
+-- Accounts at OpenID Connect providers that customers sign in with
+create table customer_identity (
+    id uuid primary key,
+    customer_id uuid not null references customer(id),
+    provider text not null,
+    -- The provider's sub claim, stable for the account unlike its email
+    subject text not null,
+    created_at timestamptz not null default now(),
+    last_used_at timestamptz not null default now()
+);
+
+create unique index idx_customer_identity_provider_subject on customer_identity(provider, subject);
+create index idx_customer_identity_customer_id on customer_identity(customer_id);
+
+-- Single use state for sign in attempts, holding the PKCE verifier and nonce
+-- until the provider redirects back
+create table oidc_login_state (
+    id uuid primary key,
+    provider text not null,
+    state_hash bytea not null,
+    code_verifier text not null,
+    nonce text not null,
+    return_to text not null,
+    expires_at timestamptz not null,
+    used_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index idx_oidc_login_state_state_hash on oidc_login_state(state_hash);
+
+commit;
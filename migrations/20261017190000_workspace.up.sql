@@ -0,0 +1,50 @@
+begin;
+
+create type workspace_role as enum ('OWNER', 'ADMIN', 'MEMBER');
+
+create table workspace (
+    id uuid primary key,
+    name text not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create table workspace_member (
+    workspace_id uuid not null references workspace(id),
+    customer_id uuid not null references customer(id),
+    role workspace_role not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (workspace_id, customer_id)
+);
+
+create index idx_workspace_member_customer_id on workspace_member(customer_id);
+
+-- Single use invitations emailed to people who may not have an account yet
+create table workspace_invitation (
+    id uuid primary key,
+    workspace_id uuid not null references workspace(id),
+    email text not null,
+    role workspace_role not null,
+    invited_by uuid not null references customer(id),
+    token_hash bytea not null,
+    expires_at timestamptz not null,
+    accepted_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index idx_workspace_invitation_token_hash on workspace_invitation(token_hash);
+create index idx_workspace_invitation_workspace_id on workspace_invitation(workspace_id) where accepted_at is null and revoked_at is null;
+
+create type post_visibility as enum ('PUBLIC', 'WORKSPACE', 'UNLISTED');
+
+alter table post add column visibility post_visibility not null default 'PUBLIC';
+alter table post add column workspace_id uuid references workspace(id);
+alter table post add constraint post_workspace_visibility check (
+    (visibility = 'WORKSPACE') = (workspace_id is not null)
+);
+
+create index idx_post_workspace_id on post(workspace_id) where workspace_id is not null;
+
+commit;
@@ -0,0 +1,37 @@
+begin;
+
+alter table post add column restricted boolean not null default false;
+
+-- Links that let voters vote on restricted posts. The link carries a random
+-- token and only its hash is stored, this row is what lets it be revoked and
+-- limits how often it's used.
+create table post_invite (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    token_hash bytea not null,
+    -- Set for invites emailed to someone, which are single use
+    email text,
+    -- Null for no limit
+    max_uses int check (max_uses > 0),
+    use_count int not null default 0,
+    expires_at timestamptz not null,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index idx_post_invite_token_hash on post_invite(token_hash);
+create index idx_post_invite_post_id on post_invite(post_id);
+
+-- Voters who've used an invite, they can vote again with it, e.g. after
+-- retracting, without using it up
+create table post_invite_redemption (
+    post_invite_id uuid not null references post_invite(id),
+    post_id uuid not null references post(id),
+    customer_id uuid not null references customer(id),
+    created_at timestamptz not null default now(),
+    primary key (post_invite_id, customer_id)
+);
+
+create index idx_post_invite_redemption_post_id_customer_id on post_invite_redemption(post_id, customer_id);
+
+commit;
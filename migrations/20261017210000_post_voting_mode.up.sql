@@ -0,0 +1,31 @@
+begin;
+
+create type voting_mode as enum (
+    'SINGLE',
+    'MULTI_SELECT',
+    'RANKED'
+);
+
+alter table post add column voting_mode voting_mode not null default 'SINGLE';
+-- Most options a MULTI_SELECT ballot can pick, null for any number
+alter table post add column max_selections int check (max_selections > 0);
+
+-- The options picked on each ballot. post_vote.post_option_id stays the
+-- ballot's first choice.
+create table post_vote_selection (
+    post_vote_id uuid not null references post_vote(id),
+    post_id uuid not null references post(id),
+    post_option_id uuid not null references post_option(id),
+    -- 1 for the first choice, only RANKED posts have more than one rank
+    rank int not null check (rank > 0),
+    primary key (post_vote_id, post_option_id),
+    unique (post_vote_id, rank)
+);
+
+create index idx_post_vote_selection_post_id on post_vote_selection(post_id);
+create index idx_post_vote_selection_post_option_id on post_vote_selection(post_option_id);
+
+insert into post_vote_selection (post_vote_id, post_id, post_option_id, rank)
+select id, post_id, post_option_id, 1 from post_vote;
+
+commit;
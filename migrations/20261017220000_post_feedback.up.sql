@@ -0,0 +1,46 @@
+begin;
+
+create type feedback_mode as enum (
+    'NONE',
+    'RATING',
+    'PAIRWISE'
+);
+
+alter table post add column feedback_mode feedback_mode not null default 'NONE';
+
+create table post_option_rating (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    post_option_id uuid not null references post_option(id),
+    -- Null once the voter has deleted their account
+    customer_id uuid references customer(id),
+    score int not null check (score between 1 and 5),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    unique (post_option_id, customer_id)
+);
+
+create index idx_post_option_rating_post_id on post_option_rating(post_id);
+create index idx_post_option_rating_customer_id on post_option_rating(customer_id);
+
+-- Pairs of options shown to a voter, who picks the one they prefer. Pairs are
+-- picked by the server so voters can't choose which options to compare.
+create table post_matchup (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    -- Null once the voter has deleted their account
+    customer_id uuid references customer(id),
+    option_a_id uuid not null references post_option(id),
+    option_b_id uuid not null references post_option(id),
+    -- Null until answered
+    winner_option_id uuid references post_option(id),
+    created_at timestamptz not null default now(),
+    answered_at timestamptz,
+    check (option_a_id <> option_b_id),
+    check (winner_option_id in (option_a_id, option_b_id))
+);
+
+create index idx_post_matchup_post_id on post_matchup(post_id);
+create index idx_post_matchup_customer_id on post_matchup(customer_id);
+
+commit;
@@ -0,0 +1,35 @@
+begin;
+
+create type criterion_kind as enum (
+    'RATING',
+    'TAG'
+);
+
+-- What the author wants voters to judge options on, e.g. readability
+create table post_criterion (
+    id uuid primary key,
+    post_id uuid not null references post(id),
+    name text not null,
+    kind criterion_kind not null,
+    position int not null check (position > 0),
+    created_at timestamptz not null default now(),
+    unique (post_id, position)
+);
+
+create index idx_post_criterion_post_id on post_criterion(post_id);
+
+-- A voter's answer to a criterion about the option they voted for. TAG
+-- criteria have no score, the row means the voter tagged it.
+create table post_vote_criterion_response (
+    post_vote_id uuid not null references post_vote(id),
+    post_id uuid not null references post(id),
+    post_criterion_id uuid not null references post_criterion(id),
+    post_option_id uuid not null references post_option(id),
+    score int check (score between 1 and 5),
+    created_at timestamptz not null default now(),
+    primary key (post_vote_id, post_criterion_id)
+);
+
+create index idx_post_vote_criterion_response_post_id on post_vote_criterion_response(post_id);
+
+commit;
@@ -0,0 +1,43 @@
+begin;
+
+create type post_option_processing_status as enum (
+    'PENDING',
+    'PROCESSED',
+    'FAILED'
+);
+
+-- Existing options are left PENDING so they're processed too
+alter table post_option
+    add column processing_status post_option_processing_status not null default 'PENDING',
+    add column processing_attempts int not null default 0,
+    add column next_processing_at timestamptz not null default now(),
+    add column processing_error text,
+    add column width int,
+    add column height int,
+    add column blurhash text;
+
+create index idx_post_option_processing_pending on post_option(next_processing_at)
+    where processing_status = 'PENDING';
+
+create type image_size as enum (
+    'THUMB',
+    'MEDIUM',
+    'FULL'
+);
+
+-- Resized copies of an option's upload, re-encoded without metadata
+create table post_option_rendition (
+    post_option_id uuid not null references post_option(id),
+    post_id uuid not null references post(id),
+    size image_size not null,
+    file_key text not null,
+    content_type text not null,
+    width int not null,
+    height int not null,
+    created_at timestamptz not null default now(),
+    primary key (post_option_id, size)
+);
+
+create index idx_post_option_rendition_post_id on post_option_rendition(post_id);
+
+commit;
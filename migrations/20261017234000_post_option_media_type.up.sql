@@ -0,0 +1,13 @@
+begin;
+
+create type media_type as enum (
+    'IMAGE',
+    'VECTOR',
+    'DOCUMENT',
+    'VIDEO'
+);
+
+-- Only images could be uploaded before
+alter table post_option add column media_type media_type not null default 'IMAGE';
+
+commit;
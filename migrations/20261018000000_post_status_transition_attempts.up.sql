@@ -0,0 +1,7 @@
+begin;
+
+-- Failed lifecycle transitions, reset once the post's transition is recorded.
+-- Posts that keep failing are tried after the rest.
+alter table post add column status_transition_attempts int not null default 0;
+
+commit;
@@ -0,0 +1,3 @@
+-- Emails claimed by an instance and being sent outside of a tx.
+-- next_attempt_at is when the claim runs out and the email is due again.
+alter type email_outbox_status add value 'SENDING' after 'PENDING';
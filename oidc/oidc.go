@@ -0,0 +1,268 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// Provider signs customers in with an OpenID Connect issuer using the
+// authorization code flow with PKCE.
+type Provider struct {
+	Name    string
+	issuer  string
+	jwksURL string
+	oauth2  oauth2.Config
+	client  *http.Client
+
+	mu sync.Mutex
+	// Keyed by kid
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type Config struct {
+	// Used in the redirect url and to tell identities from different
+	// issuers apart, e.g. google
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Claims are the ID token claims used to find or create the customer.
+type Claims struct {
+	Nonce         string        `json:"nonce"`
+	Email         string        `json:"email"`
+	EmailVerified emailVerified `json:"email_verified"`
+	GivenName     string        `json:"given_name"`
+	FamilyName    string        `json:"family_name"`
+	jwt.RegisteredClaims
+}
+
+// emailVerified accepts both true and "true", as some issuers send a string.
+type emailVerified bool
+
+func (e *emailVerified) UnmarshalJSON(data []byte) error {
+	*e = emailVerified(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+var ErrInvalidIDToken = errors.New("id token is invalid")
+var ErrNonceMismatch = errors.New("id token nonce does not match")
+
+// How often keys can be refetched when a token has an unknown kid
+const jwksRefetchInterval = time.Minute
+
+// New fetches the issuer's discovery document from
+// <issuer>/.well-known/openid-configuration.
+func New(ctx context.Context, config Config) (*Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	discovery := struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}{}
+	url := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, url, &discovery); err != nil {
+		return nil, fmt.Errorf("getting discovery document: %w", err)
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", discovery.Issuer, config.Issuer)
+	}
+
+	return &Provider{
+		Name:    config.Name,
+		issuer:  discovery.Issuer,
+		jwksURL: discovery.JWKSURI,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		},
+		client: client,
+	}, nil
+}
+
+// AuthCodeURL is where the customer is sent to sign in. codeVerifier comes
+// from oauth2.GenerateVerifier, and it and nonce need to be kept until the
+// customer is redirected back with a code.
+func (p *Provider) AuthCodeURL(state string, codeVerifier string, nonce string) string {
+	return p.oauth2.AuthCodeURL(
+		state,
+		oauth2.S256ChallengeOption(codeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
+}
+
+// Exchange swaps the code for an ID token, and returns its claims once the
+// signature, issuer, audience, expiry and nonce have been checked.
+func (p *Provider) Exchange(
+	ctx context.Context, code string, codeVerifier string, nonce string,
+) (*Claims, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token: %w", ErrInvalidIDToken)
+	}
+
+	claims := Claims{}
+	if _, err = jwt.ParseWithClaims(
+		rawIDToken,
+		&claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return p.key(ctx, token)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.oauth2.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("id token has no sub: %w", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return &claims, nil
+}
+
+// key finds the token's signing key, refetching the issuer's keys when the
+// kid is unknown so their rotations are picked up.
+func (p *Provider) key(ctx context.Context, token *jwt.Token) (crypto.PublicKey, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefetchInterval {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := getJSON(ctx, p.client, p.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("getting jwks: %w", err)
+	}
+	p.keysFetched = time.Now()
+	p.keys = map[string]crypto.PublicKey{}
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			// Skip key types we don't support rather than failing every login
+			continue
+		}
+		p.keys[j.Kid] = key
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding e: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v from %s", resp.StatusCode, url)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"quorum-api/oidc"
+	"quorum-api/oidc/oidctest"
+	"slices"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestProvider(t *testing.T) (*oidc.Provider, *oidctest.Issuer) {
+	t.Helper()
+	issuer, err := oidctest.NewIssuer("quorum", "secret")
+	if err != nil {
+		t.Fatalf("starting issuer: %v", err)
+	}
+	t.Cleanup(issuer.Close)
+
+	provider, err := oidc.New(context.Background(), oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "http://api.test/auth/oidc/test/callback",
+	})
+	if err != nil {
+		t.Fatalf("creating provider: %v", err)
+	}
+	return provider, issuer
+}
+
+func TestExchange(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	verifier := oauth2.GenerateVerifier()
+
+	authCodeURL := provider.AuthCodeURL("state", verifier, "nonce")
+	query, _ := url.Parse(authCodeURL)
+	if got := query.Query().Get("nonce"); got != "nonce" {
+		t.Errorf("got nonce %q in auth code url", got)
+	}
+	code, err := issuer.Authorize(authCodeURL, oidctest.Identity{
+		Subject:       "sub-1",
+		Email:         "voter@example.com",
+		EmailVerified: true,
+		GivenName:     "Vera",
+	})
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("exchanging: %v", err)
+	}
+	if claims.Subject != "sub-1" || claims.Email != "voter@example.com" ||
+		!bool(claims.EmailVerified) || claims.GivenName != "Vera" {
+		t.Errorf("got claims %+v", claims)
+	}
+}
+
+func TestExchangeChecksCodeVerifier(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+
+	authCodeURL := provider.AuthCodeURL("state", oauth2.GenerateVerifier(), "nonce")
+	code, err := issuer.Authorize(authCodeURL, oidctest.Identity{Subject: "sub-1"})
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	if _, err = provider.Exchange(
+		context.Background(), code, oauth2.GenerateVerifier(), "nonce",
+	); err == nil {
+		t.Fatal("expected exchange with the wrong verifier to fail")
+	}
+	if !slices.Contains(issuer.Rejected(), "code_verifier mismatch") {
+		t.Errorf("got rejections %q, want a code_verifier mismatch", issuer.Rejected())
+	}
+}
+
+func TestExchangeChecksNonce(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	verifier := oauth2.GenerateVerifier()
+
+	otherNonce := "replayed"
+	authCodeURL := provider.AuthCodeURL("state", verifier, "nonce")
+	code, err := issuer.Authorize(authCodeURL, oidctest.Identity{
+		Subject: "sub-1",
+		Nonce:   &otherNonce,
+	})
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+	if !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Fatalf("got %v, want ErrNonceMismatch", err)
+	}
+}
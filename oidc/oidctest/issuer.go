@@ -0,0 +1,226 @@
+// Package oidctest runs a local OpenID Connect issuer for tests, serving
+// discovery, a JWKS and a token endpoint that checks PKCE.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "oidctest"
+
+// Identity is who the issuer signs in as when authorizing.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	// Overrides the nonce from the authorization request when set
+	Nonce *string
+}
+
+type authorization struct {
+	identity      Identity
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+type Issuer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+	// Token requests that were rejected, with why
+	rejected []string
+}
+
+// NewIssuer starts an issuer at Server.URL. Close it once done.
+func NewIssuer(clientID string, clientSecret string) (*Issuer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	i := &Issuer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("GET /jwks", i.jwks)
+	mux.HandleFunc("POST /token", i.token)
+	i.Server = httptest.NewServer(mux)
+	return i, nil
+}
+
+// Authorize does what the issuer's sign in page would for the auth code url,
+// returning the code it would redirect back with.
+func (i *Issuer) Authorize(authCodeURL string, identity Identity) (string, error) {
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing url: %w", err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != i.ClientID {
+		return "", fmt.Errorf("unknown client_id %q", query.Get("client_id"))
+	}
+	if query.Get("response_type") != "code" {
+		return "", fmt.Errorf("unsupported response_type %q", query.Get("response_type"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", fmt.Errorf("missing S256 code_challenge")
+	}
+
+	code := randomString()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.codes[code] = authorization{
+		identity:      identity,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	return code, nil
+}
+
+// Rejected returns why each rejected token request was rejected.
+func (i *Issuer) Rejected() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]string{}, i.rejected...)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "ES256",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+		}},
+	})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		i.reject(w, "invalid_request", "parsing form")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != i.ClientID || clientSecret != i.ClientSecret {
+		i.reject(w, "invalid_client", "wrong client credentials")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		i.reject(w, "unsupported_grant_type", "wrong grant_type")
+		return
+	}
+
+	i.mu.Lock()
+	code := r.PostForm.Get("code")
+	auth, ok := i.codes[code]
+	// Codes are single use
+	delete(i.codes, code)
+	i.mu.Unlock()
+	if !ok {
+		i.reject(w, "invalid_grant", "unknown code")
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != auth.redirectURI {
+		i.reject(w, "invalid_grant", "redirect_uri mismatch")
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		i.reject(w, "invalid_grant", "code_verifier mismatch")
+		return
+	}
+
+	nonce := auth.nonce
+	if auth.identity.Nonce != nil {
+		nonce = *auth.identity.Nonce
+	}
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":            i.URL,
+		"aud":            i.ClientID,
+		"sub":            auth.identity.Subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          auth.identity.Email,
+		"email_verified": auth.identity.EmailVerified,
+		"given_name":     auth.identity.GivenName,
+		"family_name":    auth.identity.FamilyName,
+	})
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(i.key)
+	if err != nil {
+		i.reject(w, "server_error", fmt.Sprintf("signing id token: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (i *Issuer) reject(w http.ResponseWriter, code string, reason string) {
+	i.mu.Lock()
+	i.rejected = append(i.rejected, reason)
+	i.mu.Unlock()
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": reason,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
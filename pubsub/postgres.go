@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// All topics share one postgres channel, and are fanned out to subscribers by
+// each instance. This saves holding a connection per topic.
+const postgresChannel = "quorum_pubsub"
+
+const postgresReconnectDelay = time.Second * 5
+
+type postgresMessage struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+}
+
+// NewPostgres returns a PubSub backed by postgres LISTEN/NOTIFY, so messages
+// are delivered to subscribers on every instance connected to the database.
+// One connection from the pool is held for listening until ctx is done.
+func NewPostgres(ctx context.Context, db *sqlx.DB) PubSub {
+	ps := &postgres{
+		db:    db,
+		local: newMemory(),
+	}
+	go ps.listen(ctx)
+	return ps
+}
+
+type postgres struct {
+	db    *sqlx.DB
+	local *memory
+}
+
+func (p *postgres) Publish(ctx context.Context, topic string, payload []byte) error {
+	message, err := json.Marshal(postgresMessage{
+		Topic:   topic,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling message: %w", err)
+	}
+	if _, err = p.db.ExecContext(
+		ctx, "select pg_notify($1, $2)", postgresChannel, string(message),
+	); err != nil {
+		return fmt.Errorf("notifying: %w", err)
+	}
+	return nil
+}
+
+func (p *postgres) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	return p.local.Subscribe(ctx, topic)
+}
+
+func (p *postgres) listen(ctx context.Context) {
+	for {
+		err := p.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("pubsub: listening on %q: %v, reconnecting", postgresChannel, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(postgresReconnectDelay):
+		}
+	}
+}
+
+func (p *postgres) listenOnce(ctx context.Context) error {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+		pgxConn := stdlibConn.Conn()
+		if _, err := pgxConn.Exec(ctx, "listen "+postgresChannel); err != nil {
+			return fmt.Errorf("listening: %w", err)
+		}
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				// Don't hand the connection back to the pool while it is
+				// still listening
+				return fmt.Errorf("waiting for notification: %w: %w", err, driver.ErrBadConn)
+			}
+			message := postgresMessage{}
+			if err = json.Unmarshal([]byte(notification.Payload), &message); err != nil {
+				log.Printf("pubsub: unmarshalling notification: %v", err)
+				continue
+			}
+			p.local.publish(message.Topic, message.Payload)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// PubSub fans out messages published to a topic to every subscriber of that
+// topic. Delivery is best effort: slow subscribers miss messages rather than
+// blocking publishers.
+type PubSub interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe returns a channel of payloads published to the topic. The
+	// channel is closed once ctx is done.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
+
+const subscriberBufferSize = 16
+
+// NewMemory returns a PubSub that only delivers messages within the current
+// process. Useful for tests and local development.
+func NewMemory() PubSub {
+	return newMemory()
+}
+
+func newMemory() *memory {
+	return &memory{
+		subscribers: map[string]map[chan []byte]struct{}{},
+	}
+}
+
+type memory struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan []byte]struct{}
+}
+
+func (m *memory) Publish(ctx context.Context, topic string, payload []byte) error {
+	m.publish(topic, payload)
+	return nil
+}
+
+func (m *memory) publish(topic string, payload []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+			// subscriber isn't keeping up, drop the message
+		}
+	}
+}
+
+func (m *memory) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, subscriberBufferSize)
+
+	m.mu.Lock()
+	if m.subscribers[topic] == nil {
+		m.subscribers[topic] = map[chan []byte]struct{}{}
+	}
+	m.subscribers[topic][ch] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[topic], ch)
+		if len(m.subscribers[topic]) == 0 {
+			delete(m.subscribers, topic)
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan []byte) string {
+	t.Helper()
+	select {
+	case payload, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return string(payload)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	return ""
+}
+
+func assertNothingReceived(t *testing.T, ch <-chan []byte) {
+	t.Helper()
+	select {
+	case payload := <-ch:
+		t.Fatalf("got unexpected message %q", payload)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestMemoryFansOutToSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ps := NewMemory()
+
+	first, err := ps.Subscribe(ctx, "post:1")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	second, err := ps.Subscribe(ctx, "post:1")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	other, err := ps.Subscribe(ctx, "post:2")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	if err = ps.Publish(ctx, "post:1", []byte("hello")); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+
+	if got := receive(t, first); got != "hello" {
+		t.Errorf("first subscriber got %q", got)
+	}
+	if got := receive(t, second); got != "hello" {
+		t.Errorf("second subscriber got %q", got)
+	}
+	assertNothingReceived(t, other)
+}
+
+func TestMemoryUnsubscribesWhenCtxDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ps := newMemory()
+
+	subCtx, unsubscribe := context.WithCancel(ctx)
+	leaving, err := ps.Subscribe(subCtx, "post:1")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	staying, err := ps.Subscribe(ctx, "post:1")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	unsubscribe()
+	select {
+	case _, ok := <-leaving:
+		if ok {
+			t.Fatal("got message after unsubscribing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel wasn't closed after unsubscribing")
+	}
+
+	if err = ps.Publish(ctx, "post:1", []byte("still here")); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+	if got := receive(t, staying); got != "still here" {
+		t.Errorf("remaining subscriber got %q", got)
+	}
+
+	cancel()
+	select {
+	case <-staying:
+	case <-time.After(time.Second):
+		t.Fatal("channel wasn't closed after unsubscribing")
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if len(ps.subscribers) != 0 {
+		t.Errorf("got %v topics left after everyone unsubscribed", len(ps.subscribers))
+	}
+}
+
+func TestMemoryDropsMessagesForSlowSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ps := NewMemory()
+
+	ch, err := ps.Subscribe(ctx, "post:1")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	for i := 0; i < subscriberBufferSize+5; i++ {
+		if err = ps.Publish(ctx, "post:1", []byte("message")); err != nil {
+			t.Fatalf("publishing: %v", err)
+		}
+	}
+	if len(ch) != subscriberBufferSize {
+		t.Errorf("got %v buffered messages, want %v", len(ch), subscriberBufferSize)
+	}
+}
@@ -2,17 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"quorum-api/blobstore"
 	"quorum-api/database"
 	"quorum-api/graph"
+	"quorum-api/keyring"
+	"quorum-api/oidc"
+	"quorum-api/pubsub"
+	srvcomment "quorum-api/services/comment"
 	srvcommunications "quorum-api/services/communications"
 	srvcustomer "quorum-api/services/customer"
 	srvpost "quorum-api/services/post"
+	srvworkspace "quorum-api/services/workspace"
+	"quorum-api/worker"
+	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 
 	"cloud.google.com/go/storage"
@@ -20,6 +36,8 @@ import (
 
 const defaultPort = "8080"
 
+const bucketName = "quorum-vote"
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -37,10 +55,7 @@ func main() {
 		}
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("expected \"JWT_SECRET\" environment variable")
-	}
+	keys := newKeyRing()
 
 	dbConnString, err := database.GetConnectionStringFromEnv()
 	if err != nil {
@@ -53,47 +68,265 @@ func main() {
 	}
 
 	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		log.Fatalf("creating google storage client: %v", err)
-	}
+	blobs := newBlobStore(ctx)
 
-	mjApiKey := os.Getenv("MJ_API_KEY")
-	if mjApiKey == "" {
-		log.Fatalf("expected env var \"MJ_API_KEY\" to be set")
-	}
+	emailProvider := newEmailProvider()
 
-	mjApiSecret := os.Getenv("MJ_SECRET_KEY")
-	if mjApiSecret == "" {
-		log.Fatalf("expected env var \"MJ_SECRET_KEY\" to be set")
-	}
+	ps := pubsub.NewPostgres(ctx, db)
 
 	services := graph.Services{
-		Customer:       srvcustomer.New(db),
-		Post:           srvpost.New(db, client.Bucket("quorum-vote"), "quorum-vote"),
-		Communications: srvcommunications.New(mjApiKey, mjApiSecret),
+		Customer:       srvcustomer.New(db, blobs),
+		Post:           srvpost.New(db, blobs, ps),
+		Communications: srvcommunications.New(db, emailProvider),
+		Comment:        srvcomment.New(db),
+		Workspace:      srvworkspace.New(db),
 	}
 
-	var srv http.Handler = handler.NewDefaultServer(
+	gqlSrv := handler.New(
 		graph.NewExecutableSchema(
 			graph.Config{Resolvers: &graph.Resolver{
-				JWTSecret: jwtSecret,
-				Services:  services,
+				Keys:     keys,
+				Services: services,
 			}},
 		),
 	)
+	gqlSrv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              graph.WebsocketInitFunc(keys, services.Customer),
+		Upgrader: websocket.Upgrader{
+			// Matches Access-Control-Allow-Origin below
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	})
+	gqlSrv.AddTransport(transport.Options{})
+	gqlSrv.AddTransport(transport.GET{})
+	gqlSrv.AddTransport(transport.POST{})
+	gqlSrv.AddTransport(transport.MultipartForm{})
+	gqlSrv.SetQueryCache(lru.New(1000))
+	gqlSrv.Use(extension.Introspection{})
+	gqlSrv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
+	gqlSrv.AroundResponses(graph.LoadersResponseMiddleware(services))
+
+	lifecycle := worker.Lifecycle{
+		Post:           services.Post,
+		Customer:       services.Customer,
+		Communications: services.Communications,
+		FrontendURL:    os.Getenv("FRONTEND_URL"),
+	}
+	go worker.Every(ctx, "lifecycle", 30*time.Second, lifecycle.Run)
+
+	outbox := worker.Outbox{
+		Communications: services.Communications,
+	}
+	go worker.Every(ctx, "outbox", 10*time.Second, outbox.Run)
 
+	media := worker.Media{
+		Post: services.Post,
+	}
+	go worker.Every(ctx, "media", 10*time.Second, media.Run)
+
+	// Only logs what it would delete until deleting is turned on explicitly
+	orphans := worker.Orphans{
+		Post:   services.Post,
+		DryRun: os.Getenv("ORPHAN_SWEEP_DRY_RUN") != "false",
+	}
+	go worker.Every(ctx, "orphans", time.Hour, orphans.Run)
+
+	var srv http.Handler = gqlSrv
 	srv = AddAccessControlHeaders(srv)
 	srv = graph.LoadersMiddleware(services, srv)
-	srv = graph.AuthMiddleware(jwtSecret)(srv)
+	srv = graph.AuthMiddleware(keys, services.Customer)(srv)
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	http.Handle("/query", srv)
+	http.Handle("/.well-known/jwks.json", AddAccessControlHeaders(keys.JWKSHandler()))
+	if local, ok := blobs.(*blobstore.Local); ok {
+		http.Handle(blobstore.LocalPathPrefix, AddAccessControlHeaders(local.Handler()))
+	}
+
+	if providers := newOIDCProviders(ctx); len(providers) > 0 {
+		secureCookies := os.Getenv("GO_ENV") != "local"
+		http.Handle("GET /auth/oidc/{provider}/login", graph.OIDCLoginHandler(
+			providers, services.Customer, secureCookies,
+		))
+		http.Handle("GET /auth/oidc/{provider}/callback", graph.OIDCCallbackHandler(
+			providers, services.Customer, os.Getenv("FRONTEND_URL"), secureCookies,
+		))
+	}
+
+	if adminSecret := os.Getenv("ADMIN_SECRET"); adminSecret != "" {
+		http.Handle("/internal/email-outbox", EmailOutboxStatusHandler(
+			services.Communications, adminSecret,
+		))
+		http.Handle("GET /internal/orphaned-files", OrphanedFilesHandler(
+			services.Post, adminSecret,
+		))
+	} else {
+		log.Printf("\"ADMIN_SECRET\" not set, internal endpoints are disabled")
+	}
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
 
+// newKeyRing loads the JWT signing key from JWT_SIGNING_KEY, and any PEM
+// public keys in JWT_VERIFICATION_KEYS that should still be accepted, e.g.
+// the previous signing key while rotating. Locally a random key is used if
+// JWT_SIGNING_KEY isn't set.
+func newKeyRing() *keyring.KeyRing {
+	signingKey := os.Getenv("JWT_SIGNING_KEY")
+	if signingKey == "" {
+		if os.Getenv("GO_ENV") != "local" {
+			log.Fatal("expected \"JWT_SIGNING_KEY\" environment variable")
+		}
+		log.Printf("\"JWT_SIGNING_KEY\" not set, using a random key, tokens won't survive a restart")
+		keys, err := keyring.NewEphemeral()
+		if err != nil {
+			log.Fatalf("creating ephemeral key ring: %v", err)
+		}
+		return keys
+	}
+
+	keys, err := keyring.New(
+		[]byte(signingKey), []byte(os.Getenv("JWT_VERIFICATION_KEYS")),
+	)
+	if err != nil {
+		log.Fatalf("creating key ring: %v", err)
+	}
+	return keys
+}
+
+// newOIDCProviders loads the OpenID Connect providers named in the comma
+// separated OIDC_PROVIDERS, e.g. google. Each needs OIDC_<NAME>_ISSUER,
+// OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET, and API_URL is used to
+// build their redirect urls.
+func newOIDCProviders(ctx context.Context) map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+	names := os.Getenv("OIDC_PROVIDERS")
+	if names == "" {
+		return providers
+	}
+
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		log.Fatalf("expected env var \"API_URL\" to be set")
+	}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/auth/oidc/%s/callback", apiURL, name),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			log.Fatalf("expected env vars \"%sISSUER\" and \"%sCLIENT_ID\" to be set", prefix, prefix)
+		}
+		provider, err := oidc.New(ctx, config)
+		if err != nil {
+			log.Fatalf("creating oidc provider %s: %v", name, err)
+		}
+		providers[name] = provider
+	}
+	return providers
+}
+
+// newBlobStore picks where uploads are kept from BLOB_STORE, one of gcs or
+// local. Defaults to local when running locally, which keeps files under
+// BLOB_DIR (blobs by default) and serves them from API_URL, and gcs otherwise.
+func newBlobStore(ctx context.Context) blobstore.BlobStore {
+	store := os.Getenv("BLOB_STORE")
+	if store == "" {
+		store = "gcs"
+		if os.Getenv("GO_ENV") == "local" {
+			store = "local"
+		}
+	}
+
+	switch store {
+	case "gcs":
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			log.Fatalf("creating google storage client: %v", err)
+		}
+		return blobstore.NewGCS(client, bucketName)
+	case "local":
+		apiURL := os.Getenv("API_URL")
+		if apiURL == "" {
+			log.Fatalf("expected env var \"API_URL\" to be set")
+		}
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "blobs"
+		}
+		log.Printf("keeping uploads in %s", dir)
+		local, err := blobstore.NewLocal(dir, bucketName, apiURL)
+		if err != nil {
+			log.Fatalf("creating local blob store: %v", err)
+		}
+		return local
+	default:
+		log.Fatalf("unknown \"BLOB_STORE\" %q", store)
+		return nil
+	}
+}
+
+// newEmailProvider picks the email provider from EMAIL_PROVIDER, one of
+// mailjet, smtp or file. Defaults to file locally and mailjet otherwise.
+func newEmailProvider() srvcommunications.Provider {
+	provider := os.Getenv("EMAIL_PROVIDER")
+	if provider == "" {
+		provider = "mailjet"
+		if os.Getenv("GO_ENV") == "local" {
+			provider = "file"
+		}
+	}
+
+	switch provider {
+	case "mailjet":
+		mjApiKey := os.Getenv("MJ_API_KEY")
+		if mjApiKey == "" {
+			log.Fatalf("expected env var \"MJ_API_KEY\" to be set")
+		}
+
+		mjApiSecret := os.Getenv("MJ_SECRET_KEY")
+		if mjApiSecret == "" {
+			log.Fatalf("expected env var \"MJ_SECRET_KEY\" to be set")
+		}
+		return srvcommunications.NewMailjetProvider(mjApiKey, mjApiSecret)
+	case "smtp":
+		config := srvcommunications.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+		if config.Host == "" {
+			log.Fatalf("expected env var \"SMTP_HOST\" to be set")
+		}
+		if config.Port == "" {
+			config.Port = "587"
+		}
+		return srvcommunications.NewSMTPProvider(config)
+	case "file":
+		path := os.Getenv("EMAIL_FILE_PATH")
+		if path == "" {
+			path = "emails.mbox"
+		}
+		log.Printf("writing emails to %s", path)
+		return srvcommunications.NewFileProvider(path)
+	default:
+		log.Fatalf("unknown \"EMAIL_PROVIDER\" %q", provider)
+		return nil
+	}
+}
+
 func AddAccessControlHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -101,3 +334,96 @@ func AddAccessControlHeaders(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// EmailOutboxStatusHandler lists outbox emails that haven't been sent, so
+// stuck and dead lettered emails can be inspected. Filter with
+// ?status=PENDING,DEAD (the default).
+func EmailOutboxStatusHandler(
+	communications srvcommunications.SRVCommunications, adminSecret string,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminSecret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		statuses := []srvcommunications.OutboxStatus{
+			srvcommunications.OutboxStatusPending,
+			srvcommunications.OutboxStatusDead,
+		}
+		if raw := r.URL.Query().Get("status"); raw != "" {
+			statuses = nil
+			for _, raw := range strings.Split(raw, ",") {
+				status := srvcommunications.OutboxStatus(raw)
+				switch status {
+				case srvcommunications.OutboxStatusPending,
+					srvcommunications.OutboxStatusSent,
+					srvcommunications.OutboxStatusDead:
+					statuses = append(statuses, status)
+				default:
+					http.Error(w, fmt.Sprintf("unknown status %q", raw), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		emails, err := communications.GetOutboxEmailsByFilter(
+			r.Context(), srvcommunications.GetOutboxEmailsByFilterRequest{
+				Statuses: statuses,
+				Limit:    500,
+			},
+		)
+		if err != nil {
+			log.Printf("getting outbox emails: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(emails); err != nil {
+			log.Printf("encoding outbox emails: %v", err)
+		}
+	})
+}
+
+// OrphanedFilesHandler dry runs the orphaned file sweep, listing the option
+// files that would be deleted. ?gracePeriod=72h overrides how old they have to
+// be, defaulting to a day.
+func OrphanedFilesHandler(
+	post srvpost.SRVPost, adminSecret string,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminSecret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		gracePeriod := 24 * time.Hour
+		if raw := r.URL.Query().Get("gracePeriod"); raw != "" {
+			var err error
+			if gracePeriod, err = time.ParseDuration(raw); err != nil {
+				http.Error(w, fmt.Sprintf("invalid grace period %q", raw), http.StatusBadRequest)
+				return
+			}
+		}
+
+		res, err := post.SweepOrphanedFiles(
+			r.Context(), srvpost.SweepOrphanedFilesRequest{
+				GracePeriod: gracePeriod,
+				DryRun:      true,
+			},
+		)
+		if err != nil {
+			log.Printf("sweeping orphaned files: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(res); err != nil {
+			log.Printf("encoding orphaned files: %v", err)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package srvcomment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quorum-api/database"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SRVComment interface {
+	GetCommentsByFilter(ctx context.Context, request GetCommentsByFilterRequest) ([]Comment, error)
+	AddComment(ctx context.Context, request AddCommentRequest) (*AddCommentResponse, error)
+	EditComment(ctx context.Context, request EditCommentRequest) error
+	DeleteComment(ctx context.Context, request DeleteCommentRequest) error
+	// DeleteCommentsByAuthor deletes every comment by the author and erases
+	// their bodies, in the tx the author's account is deleted in. Replies to
+	// the comments are kept.
+	DeleteCommentsByAuthor(ctx context.Context, tx database.Q, request DeleteCommentsByAuthorRequest) error
+}
+
+type GetCommentsByFilterRequest struct {
+	IDs       []uuid.UUID
+	PostIDs   []uuid.UUID
+	OptionIDs []uuid.UUID
+	ParentIDs []uuid.UUID
+	// Only return comments that aren't replies
+	TopLevelOnly bool
+}
+
+type Comment struct {
+	ID     uuid.UUID
+	PostID uuid.UUID
+	// Set when the comment is about a specific option rather than the post
+	OptionID *uuid.UUID
+	// Set when the comment is a reply
+	ParentID *uuid.UUID
+	AuthorID uuid.UUID
+	// Nil when the comment has been deleted
+	Body      *string
+	CreatedAt time.Time
+	EditedAt  *time.Time
+	DeletedAt *time.Time
+}
+
+type AddCommentRequest struct {
+	PostID   uuid.UUID
+	OptionID *uuid.UUID
+	ParentID *uuid.UUID
+	AuthorID uuid.UUID
+	Body     string
+}
+
+type AddCommentResponse struct {
+	CommentID uuid.UUID
+}
+
+type EditCommentRequest struct {
+	ID       uuid.UUID
+	AuthorID uuid.UUID
+	Body     string
+}
+
+type DeleteCommentRequest struct {
+	ID       uuid.UUID
+	AuthorID uuid.UUID
+}
+
+type DeleteCommentsByAuthorRequest struct {
+	AuthorID uuid.UUID
+}
+
+const maxBodyLength = 2000
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+var ErrCommentNotOwned = errors.New("comment was written by another customer")
+
+var ErrInvalidBody = fmt.Errorf("comment must be between 1 and %v characters", maxBodyLength)
+
+func New(db *sqlx.DB) SRVComment {
+	return &srv{
+		db: db,
+	}
+}
+
+type srv struct {
+	db *sqlx.DB
+}
+
+func (s *srv) GetCommentsByFilter(
+	ctx context.Context, request GetCommentsByFilterRequest,
+) ([]Comment, error) {
+	comments, err := getCommentsByFilter(
+		ctx, s.db, getCommentsByFilterParams{
+			IDs:           request.IDs,
+			PostIDs:       request.PostIDs,
+			PostOptionIDs: request.OptionIDs,
+			ParentIDs:     request.ParentIDs,
+			TopLevelOnly:  request.TopLevelOnly,
+		}, DBLockUnspecified,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting comments: %w", err)
+	}
+
+	res := []Comment{}
+	for _, c := range comments {
+		comment := Comment{
+			ID:        c.ID,
+			PostID:    c.PostID,
+			OptionID:  c.PostOptionID,
+			ParentID:  c.ParentID,
+			AuthorID:  c.AuthorID,
+			CreatedAt: c.CreatedAt,
+			EditedAt:  c.EditedAt,
+			DeletedAt: c.DeletedAt,
+		}
+		if c.DeletedAt == nil {
+			body := c.Body
+			comment.Body = &body
+		}
+		res = append(res, comment)
+	}
+
+	return res, nil
+}
+
+func validateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || len([]rune(body)) > maxBodyLength {
+		return "", ErrInvalidBody
+	}
+	return body, nil
+}
+
+func (s *srv) AddComment(
+	ctx context.Context, request AddCommentRequest,
+) (*AddCommentResponse, error) {
+	body, err := validateBody(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.ParentID != nil {
+		parents, err := getCommentsByFilter(
+			ctx, s.db, getCommentsByFilterParams{
+				IDs: []uuid.UUID{*request.ParentID},
+			}, DBLockUnspecified,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getting parent comment: %w", err)
+		}
+		if len(parents) != 1 {
+			return nil, ErrCommentNotFound
+		}
+		// Replies belong to the same thread as their parent
+		parent := parents[0]
+		if parent.PostID != request.PostID ||
+			!sameOptionID(parent.PostOptionID, request.OptionID) {
+			return nil, ErrCommentNotFound
+		}
+	}
+
+	commentID := uuid.New()
+	if err = insertComment(ctx, s.db, insertCommentParams{
+		ID:           commentID,
+		PostID:       request.PostID,
+		PostOptionID: request.OptionID,
+		ParentID:     request.ParentID,
+		AuthorID:     request.AuthorID,
+		Body:         body,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting comment: %w", err)
+	}
+
+	return &AddCommentResponse{
+		CommentID: commentID,
+	}, nil
+}
+
+func sameOptionID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// getOwnedComment locks the comment for update and checks it can be changed
+// by the author.
+func getOwnedComment(
+	ctx context.Context, tx *sqlx.Tx, id uuid.UUID, authorID uuid.UUID,
+) (*comment, error) {
+	comments, err := getCommentsByFilter(
+		ctx, tx, getCommentsByFilterParams{
+			IDs: []uuid.UUID{id},
+		}, DBLockForUpdate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting comment: %w", err)
+	}
+	if len(comments) != 1 || comments[0].DeletedAt != nil {
+		return nil, ErrCommentNotFound
+	}
+	if comments[0].AuthorID != authorID {
+		return nil, ErrCommentNotOwned
+	}
+	return &comments[0], nil
+}
+
+func (s *srv) EditComment(ctx context.Context, request EditCommentRequest) error {
+	body, err := validateBody(request.Body)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = getOwnedComment(ctx, tx, request.ID, request.AuthorID); err != nil {
+		return err
+	}
+
+	if err = updateCommentBody(ctx, tx, request.ID, body); err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) DeleteComment(ctx context.Context, request DeleteCommentRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = getOwnedComment(ctx, tx, request.ID, request.AuthorID); err != nil {
+		return err
+	}
+
+	// Soft delete so replies keep their place in the thread
+	if err = softDeleteComment(ctx, tx, request.ID); err != nil {
+		return fmt.Errorf("deleting comment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) DeleteCommentsByAuthor(
+	ctx context.Context, tx database.Q, request DeleteCommentsByAuthorRequest,
+) error {
+	if err := eraseCommentsByAuthor(ctx, tx, request.AuthorID); err != nil {
+		return fmt.Errorf("erasing comments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package srvcomment
+
+import (
+	"context"
+	"fmt"
+	"quorum-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DBLock string
+
+const (
+	DBLockUnspecified DBLock = ""
+	DBLockForUpdate   DBLock = "for update"
+)
+
+type getCommentsByFilterParams struct {
+	IDs           database.UUIDSlice
+	PostIDs       database.UUIDSlice
+	PostOptionIDs database.UUIDSlice
+	ParentIDs     database.UUIDSlice
+	TopLevelOnly  bool
+}
+
+type comment struct {
+	ID           uuid.UUID  `db:"id"`
+	PostID       uuid.UUID  `db:"post_id"`
+	PostOptionID *uuid.UUID `db:"post_option_id"`
+	ParentID     *uuid.UUID `db:"parent_id"`
+	AuthorID     uuid.UUID  `db:"author_id"`
+	Body         string     `db:"body"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
+	EditedAt     *time.Time `db:"edited_at"`
+	DeletedAt    *time.Time `db:"deleted_at"`
+}
+
+func getCommentsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getCommentsByFilterParams,
+	dbLock DBLock,
+) ([]comment, error) {
+	comments := []comment{}
+	query := `
+		select
+			id,
+			post_id,
+			post_option_id,
+			parent_id,
+			author_id,
+			body,
+			created_at,
+			updated_at,
+			edited_at,
+			deleted_at
+		from comment
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+	if len(params.PostOptionIDs) > 0 {
+		args = append(args, params.PostOptionIDs)
+		query = fmt.Sprintf("%s and post_option_id = any($%v)", query, len(args))
+	}
+	if len(params.ParentIDs) > 0 {
+		args = append(args, params.ParentIDs)
+		query = fmt.Sprintf("%s and parent_id = any($%v)", query, len(args))
+	}
+	if params.TopLevelOnly {
+		query = fmt.Sprintf("%s and parent_id is null", query)
+	}
+
+	query = fmt.Sprintf(`%s
+		order by created_at, id
+	%s`, query, dbLock)
+
+	if err := db.SelectContext(ctx, &comments, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+type insertCommentParams struct {
+	ID           uuid.UUID  `db:"id"`
+	PostID       uuid.UUID  `db:"post_id"`
+	PostOptionID *uuid.UUID `db:"post_option_id"`
+	ParentID     *uuid.UUID `db:"parent_id"`
+	AuthorID     uuid.UUID  `db:"author_id"`
+	Body         string     `db:"body"`
+}
+
+func insertComment(
+	ctx context.Context,
+	db database.Q,
+	params insertCommentParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into comment (
+			id,
+			post_id,
+			post_option_id,
+			parent_id,
+			author_id,
+			body
+		) values (
+			:id,
+			:post_id,
+			:post_option_id,
+			:parent_id,
+			:author_id,
+			:body
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting comment: %w", err)
+	}
+	return nil
+}
+
+func updateCommentBody(
+	ctx context.Context,
+	db database.Q,
+	id uuid.UUID,
+	body string,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update comment set
+			body = $2,
+			edited_at = now(),
+			updated_at = now()
+		where id = $1
+	`, id, body); err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+	return nil
+}
+
+func softDeleteComment(
+	ctx context.Context,
+	db database.Q,
+	id uuid.UUID,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update comment set
+			deleted_at = now(),
+			updated_at = now()
+		where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("soft deleting comment: %w", err)
+	}
+	return nil
+}
+
+func eraseCommentsByAuthor(
+	ctx context.Context,
+	db database.Q,
+	authorID uuid.UUID,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update comment set
+			body = '',
+			deleted_at = coalesce(deleted_at, now()),
+			updated_at = now()
+		where author_id = $1
+	`, authorID); err != nil {
+		return fmt.Errorf("erasing comments: %w", err)
+	}
+	return nil
+}
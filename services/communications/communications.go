@@ -1,65 +1,291 @@
 package communications
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"quorum-api/database"
+	"time"
 
-	"github.com/davecgh/go-spew/spew"
-	mailjet "github.com/mailjet/mailjet-apiv3-go/v4"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type SRVCommunications interface {
-	SendEmail(request SendEmailRequest) error
+	SendEmail(ctx context.Context, request SendEmailRequest) error
+	// EnqueueEmail writes the email to the outbox to be delivered by
+	// DeliverPendingEmails. Pass the tx of the change that caused the
+	// email so it's only sent if that change commits, or nil to write it
+	// on its own.
+	EnqueueEmail(ctx context.Context, tx database.Q, request EnqueueEmailRequest) error
+	DeliverPendingEmails(ctx context.Context, request DeliverPendingEmailsRequest) (DeliverPendingEmailsResponse, error)
+	GetOutboxEmailsByFilter(ctx context.Context, request GetOutboxEmailsByFilterRequest) ([]OutboxEmail, error)
 }
 
 type SendEmailRequest struct {
-	ToEmail    string
-	TemplateID int
-	Subject    string
-	Variables  map[string]interface{}
+	ToEmail   string
+	Template  Template
+	Subject   string
+	Variables map[string]interface{}
+	// Passed to the provider so sends can be traced back to the outbox
+	CustomID string
 }
 
+type EnqueueEmailRequest struct {
+	// Emails with a key that's already in the outbox are ignored, defaults
+	// to a random key
+	IdempotencyKey string
+	ToEmail        string
+	Template       Template
+	Subject        string
+	Variables      map[string]interface{}
+}
+
+type DeliverPendingEmailsRequest struct {
+	Limit int
+}
+
+type DeliverPendingEmailsResponse struct {
+	Sent   int
+	Failed int
+}
+
+type GetOutboxEmailsByFilterRequest struct {
+	IDs      []uuid.UUID
+	Statuses []OutboxStatus
+	Limit    int
+}
+
+type OutboxEmail struct {
+	ID             uuid.UUID
+	IdempotencyKey string
+	ToEmail        string
+	Template       Template
+	Subject        string
+	Status         OutboxStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      *string
+	SentAt         *time.Time
+	CreatedAt      time.Time
+}
+
+const (
+	maxOutboxAttempts = 8
+	outboxBaseBackoff = 30 * time.Second
+	outboxMaxBackoff  = time.Hour
+	outboxSendTimeout = 30 * time.Second
+)
+
+var ErrUnknownTemplate = errors.New("no email template with that name")
+
 type srvCommunications struct {
-	mjClient *mailjet.Client
+	db       *sqlx.DB
+	provider Provider
 }
 
-func New(mjApiKeyPublic string, mjApiKeyPrivate string) SRVCommunications {
-	mjClient := mailjet.NewMailjetClient(
-		mjApiKeyPublic, mjApiKeyPrivate,
-	)
+func New(db *sqlx.DB, provider Provider) SRVCommunications {
 	return &srvCommunications{
-		mjClient: mjClient,
+		db:       db,
+		provider: provider,
 	}
 }
 
-func (s *srvCommunications) SendEmail(request SendEmailRequest) error {
-	if os.Getenv("GO_ENV") == "local" {
-		fmt.Printf("Email subject %q", request.Subject)
-		spew.Dump(request.Variables)
-		return nil
-	}
-	messagesInfo := []mailjet.InfoMessagesV31{
-		{
-			From: &mailjet.RecipientV31{
-				Email: "noreply@quorumvote.com",
-				Name:  "Verify your Quorum Account",
-			},
-			To: &mailjet.RecipientsV31{
-				mailjet.RecipientV31{
-					Email: request.ToEmail,
-				},
-			},
-			TemplateID:       request.TemplateID,
-			TemplateLanguage: true,
-			Subject:          request.Subject,
-			Variables:        request.Variables,
-		},
-	}
-	messages := mailjet.MessagesV31{Info: messagesInfo}
-	res, err := s.mjClient.SendMailV31(&messages)
+func (s *srvCommunications) SendEmail(ctx context.Context, request SendEmailRequest) error {
+	html, err := renderTemplate(request.Template, request.Variables)
 	if err != nil {
+		return fmt.Errorf("rendering template: %w", err)
+	}
+	if err = s.provider.Send(ctx, Message{
+		From:     defaultFrom,
+		To:       request.ToEmail,
+		Subject:  request.Subject,
+		HTML:     html,
+		CustomID: request.CustomID,
+	}); err != nil {
 		return fmt.Errorf("sending email: %w", err)
 	}
-	fmt.Printf("email result: %v", res)
 	return nil
 }
+
+func (s *srvCommunications) EnqueueEmail(
+	ctx context.Context, tx database.Q, request EnqueueEmailRequest,
+) error {
+	if tx == nil {
+		tx = s.db
+	}
+
+	if templates.Lookup(fmt.Sprintf("%s.html", request.Template)) == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownTemplate, request.Template)
+	}
+
+	variables, err := json.Marshal(request.Variables)
+	if err != nil {
+		return fmt.Errorf("marshalling variables: %w", err)
+	}
+
+	id := uuid.New()
+	idempotencyKey := request.IdempotencyKey
+	if idempotencyKey == "" {
+		idempotencyKey = id.String()
+	}
+
+	if err := insertOutboxEmail(ctx, tx, insertOutboxEmailParams{
+		ID:             id,
+		IdempotencyKey: idempotencyKey,
+		ToEmail:        request.ToEmail,
+		Template:       request.Template,
+		Subject:        request.Subject,
+		Variables:      variables,
+	}); err != nil {
+		return fmt.Errorf("inserting outbox email: %w", err)
+	}
+	return nil
+}
+
+func (s *srvCommunications) DeliverPendingEmails(
+	ctx context.Context, request DeliverPendingEmailsRequest,
+) (DeliverPendingEmailsResponse, error) {
+	res := DeliverPendingEmailsResponse{}
+
+	// Long enough to send the whole batch, after which emails still SENDING
+	// are assumed lost with their instance and claimed again
+	lease := outboxSendTimeout * time.Duration(request.Limit+1)
+	emails, err := s.claimOutboxEmails(ctx, request.Limit, lease)
+	if err != nil {
+		return res, fmt.Errorf("claiming outbox emails: %w", err)
+	}
+
+	for _, email := range emails {
+		params := updateOutboxEmailParams{
+			ID:            email.ID,
+			Status:        OutboxStatusSent,
+			Attempts:      email.Attempts,
+			NextAttemptAt: email.NextAttemptAt,
+		}
+
+		variables := map[string]interface{}{}
+		err := json.Unmarshal(email.Variables, &variables)
+		if err == nil {
+			sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+			err = s.SendEmail(sendCtx, SendEmailRequest{
+				ToEmail:   email.ToEmail,
+				Template:  email.Template,
+				Subject:   email.Subject,
+				Variables: variables,
+				CustomID:  email.IdempotencyKey,
+			})
+			cancel()
+		}
+
+		if err != nil {
+			res.Failed++
+			lastError := err.Error()
+			params.LastError = &lastError
+			params.Status = OutboxStatusPending
+			params.NextAttemptAt = time.Now().Add(outboxBackoff(params.Attempts))
+			if params.Attempts >= maxOutboxAttempts {
+				params.Status = OutboxStatusDead
+			}
+		} else {
+			res.Sent++
+			sentAt := time.Now()
+			params.SentAt = &sentAt
+		}
+
+		// Recorded on its own so a later failure can't put a sent email
+		// back to PENDING
+		if err := updateOutboxEmail(ctx, s.db, params); err != nil {
+			return res, fmt.Errorf("updating outbox email: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// claimOutboxEmails marks due emails as SENDING until the lease is up, so
+// other instances skip them while they're sent outside of any tx.
+func (s *srvCommunications) claimOutboxEmails(
+	ctx context.Context, limit int, lease time.Duration,
+) ([]outboxEmail, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	emails, err := getOutboxEmailsByFilter(ctx, tx, getOutboxEmailsByFilterParams{
+		Statuses: []string{string(OutboxStatusPending), string(OutboxStatusSending)},
+		Due:      true,
+		Limit:    limit,
+	}, DBLockForUpdateSkipLocked)
+	if err != nil {
+		return nil, fmt.Errorf("getting outbox emails: %w", err)
+	}
+
+	for i := range emails {
+		emails[i].Status = OutboxStatusSending
+		emails[i].Attempts++
+		emails[i].NextAttemptAt = time.Now().Add(lease)
+		if err = updateOutboxEmail(ctx, tx, updateOutboxEmailParams{
+			ID:            emails[i].ID,
+			Status:        emails[i].Status,
+			Attempts:      emails[i].Attempts,
+			NextAttemptAt: emails[i].NextAttemptAt,
+			LastError:     emails[i].LastError,
+		}); err != nil {
+			return nil, fmt.Errorf("updating outbox email: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return emails, nil
+}
+
+// outboxBackoff is how long to wait before retrying an email that has
+// failed attempts times, doubling each time.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
+
+func (s *srvCommunications) GetOutboxEmailsByFilter(
+	ctx context.Context, request GetOutboxEmailsByFilterRequest,
+) ([]OutboxEmail, error) {
+	params := getOutboxEmailsByFilterParams{
+		IDs:   request.IDs,
+		Limit: request.Limit,
+	}
+	for _, status := range request.Statuses {
+		params.Statuses = append(params.Statuses, string(status))
+	}
+
+	rows, err := getOutboxEmailsByFilter(ctx, s.db, params, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting outbox emails: %w", err)
+	}
+
+	res := []OutboxEmail{}
+	for _, row := range rows {
+		res = append(res, OutboxEmail{
+			ID:             row.ID,
+			IdempotencyKey: row.IdempotencyKey,
+			ToEmail:        row.ToEmail,
+			Template:       row.Template,
+			Subject:        row.Subject,
+			Status:         row.Status,
+			Attempts:       row.Attempts,
+			NextAttemptAt:  row.NextAttemptAt,
+			LastError:      row.LastError,
+			SentAt:         row.SentAt,
+			CreatedAt:      row.CreatedAt,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,172 @@
+package communications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"quorum-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DBLock string
+
+const (
+	DBLockUnspecified         DBLock = ""
+	DBLockForUpdate           DBLock = "for update"
+	DBLockForUpdateSkipLocked DBLock = "for update skip locked"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	// Claimed by an instance that's sending it, until next_attempt_at
+	OutboxStatusSending OutboxStatus = "SENDING"
+	OutboxStatusSent    OutboxStatus = "SENT"
+	// Gave up after too many failed attempts
+	OutboxStatusDead OutboxStatus = "DEAD"
+)
+
+type insertOutboxEmailParams struct {
+	ID             uuid.UUID       `db:"id"`
+	IdempotencyKey string          `db:"idempotency_key"`
+	ToEmail        string          `db:"to_email"`
+	Template       Template        `db:"template"`
+	Subject        string          `db:"subject"`
+	Variables      json.RawMessage `db:"variables"`
+}
+
+func insertOutboxEmail(
+	ctx context.Context,
+	db database.Q,
+	params insertOutboxEmailParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into email_outbox (
+			id,
+			idempotency_key,
+			to_email,
+			template,
+			subject,
+			variables
+		) values (
+			:id,
+			:idempotency_key,
+			:to_email,
+			:template,
+			:subject,
+			:variables
+		) on conflict (idempotency_key) do nothing
+	`, params); err != nil {
+		return fmt.Errorf("inserting email_outbox: %w", err)
+	}
+	return nil
+}
+
+type getOutboxEmailsByFilterParams struct {
+	IDs      database.UUIDSlice
+	Statuses []string
+	// Only return emails due to be attempted
+	Due   bool
+	Limit int
+}
+
+type outboxEmail struct {
+	ID             uuid.UUID       `db:"id"`
+	IdempotencyKey string          `db:"idempotency_key"`
+	ToEmail        string          `db:"to_email"`
+	Template       Template        `db:"template"`
+	Subject        string          `db:"subject"`
+	Variables      json.RawMessage `db:"variables"`
+	Status         OutboxStatus    `db:"status"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	LastError      *string         `db:"last_error"`
+	SentAt         *time.Time      `db:"sent_at"`
+	CreatedAt      time.Time       `db:"created_at"`
+}
+
+func getOutboxEmailsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getOutboxEmailsByFilterParams,
+	dbLock DBLock,
+) ([]outboxEmail, error) {
+	emails := []outboxEmail{}
+	query := `
+		select
+			id,
+			idempotency_key,
+			to_email,
+			template,
+			subject,
+			variables,
+			status,
+			attempts,
+			next_attempt_at,
+			last_error,
+			sent_at,
+			created_at
+		from email_outbox
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.Statuses) > 0 {
+		args = append(args, params.Statuses)
+		query = fmt.Sprintf("%s and status = any($%v::email_outbox_status[])", query, len(args))
+	}
+	if params.Due {
+		query = fmt.Sprintf("%s and next_attempt_at <= now()", query)
+	}
+
+	query = fmt.Sprintf("%s order by next_attempt_at, id", query)
+
+	if params.Limit > 0 {
+		args = append(args, params.Limit)
+		query = fmt.Sprintf("%s limit $%v", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &emails, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting email_outbox: %w", err)
+	}
+
+	return emails, nil
+}
+
+type updateOutboxEmailParams struct {
+	ID            uuid.UUID    `db:"id"`
+	Status        OutboxStatus `db:"status"`
+	Attempts      int          `db:"attempts"`
+	NextAttemptAt time.Time    `db:"next_attempt_at"`
+	LastError     *string      `db:"last_error"`
+	SentAt        *time.Time   `db:"sent_at"`
+}
+
+func updateOutboxEmail(
+	ctx context.Context,
+	db database.Q,
+	params updateOutboxEmailParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		update email_outbox set
+			status = :status,
+			attempts = :attempts,
+			next_attempt_at = :next_attempt_at,
+			last_error = :last_error,
+			sent_at = :sent_at,
+			updated_at = now()
+		where id = :id
+	`, params); err != nil {
+		return fmt.Errorf("updating email_outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package communications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileProvider struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileProvider appends emails to an mbox file instead of sending them, so
+// they can be read with any mail client during local dev.
+func NewFileProvider(path string) Provider {
+	return &fileProvider{
+		path: path,
+	}
+}
+
+func (p *fileProvider) Send(ctx context.Context, message Message) error {
+	msg, err := formatMessage(message)
+	if err != nil {
+		return fmt.Errorf("formatting message: %w", err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", message.From.Address, time.Now().UTC().Format(time.ANSIC))
+	for _, line := range bytes.Split(bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			b.WriteString(">")
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening mbox: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("writing mbox: %w", err)
+	}
+	return nil
+}
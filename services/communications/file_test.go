@@ -0,0 +1,106 @@
+package communications
+
+import (
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readMbox splits an mboxrd file into its envelope lines and messages,
+// unquoting body lines that start with "From ".
+func readMbox(t *testing.T, path string) ([]string, []*mail.Message) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading mbox: %v", err)
+	}
+
+	envelopes := []string{}
+	entries := [][]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if strings.HasPrefix(line, "From ") {
+			envelopes = append(envelopes, line)
+			entries = append(entries, []string{})
+			continue
+		}
+		if len(entries) == 0 {
+			t.Fatalf("mbox doesn't start with a From line: %q", line)
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			line = line[1:]
+		}
+		entries[len(entries)-1] = append(entries[len(entries)-1], line)
+	}
+
+	messages := []*mail.Message{}
+	for i, entry := range entries {
+		msg, err := mail.ReadMessage(strings.NewReader(strings.Join(entry, "\n")))
+		if err != nil {
+			t.Fatalf("parsing message %v: %v", i, err)
+		}
+		messages = append(messages, msg)
+	}
+	return envelopes, messages
+}
+
+func TestFileProviderSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.mbox")
+	provider := NewFileProvider(path)
+
+	messages := []Message{
+		{
+			From:     defaultFrom,
+			To:       "voter@example.com",
+			Subject:  "Voting has closed",
+			HTML:     "<p>Results are in</p>",
+			CustomID: "post-results:123",
+		},
+		{
+			From:    defaultFrom,
+			To:      "other@example.com",
+			Subject: "You've been invited",
+			// Would start a new entry if it wasn't quoted
+			HTML: "<p>Hi</p>\nFrom the team\n>From the team",
+		},
+	}
+	for _, message := range messages {
+		if err := provider.Send(context.Background(), message); err != nil {
+			t.Fatalf("sending: %v", err)
+		}
+	}
+
+	envelopes, got := readMbox(t, path)
+	if len(got) != len(messages) {
+		t.Fatalf("got %v messages, want %v", len(got), len(messages))
+	}
+	for i, message := range messages {
+		sender, date, _ := strings.Cut(strings.TrimPrefix(envelopes[i], "From "), " ")
+		if sender != message.From.Address {
+			t.Errorf("got envelope sender %q, want %q", sender, message.From.Address)
+		}
+		if _, err := time.Parse(time.ANSIC, date); err != nil {
+			t.Errorf("parsing envelope date: %v", err)
+		}
+
+		msg := got[i]
+		if to := msg.Header.Get("To"); to != message.To {
+			t.Errorf("got To %q, want %q", to, message.To)
+		}
+		if id := msg.Header.Get("X-Quorum-Custom-Id"); id != message.CustomID {
+			t.Errorf("got custom id %q, want %q", id, message.CustomID)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		if err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		if got := strings.TrimSpace(strings.ReplaceAll(string(body), "\r\n", "\n")); got != message.HTML {
+			t.Errorf("got body %q, want %q", got, message.HTML)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package communications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	mailjet "github.com/mailjet/mailjet-apiv3-go/v4"
+)
+
+type mailjetProvider struct {
+	client *mailjet.Client
+}
+
+// The client doesn't take a ctx, so requests get a fixed timeout instead
+const mailjetTimeout = 30 * time.Second
+
+func NewMailjetProvider(apiKeyPublic string, apiKeyPrivate string) Provider {
+	client := mailjet.NewMailjetClient(apiKeyPublic, apiKeyPrivate)
+	client.SetClient(&http.Client{Timeout: mailjetTimeout})
+	return &mailjetProvider{
+		client: client,
+	}
+}
+
+func (p *mailjetProvider) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	messagesInfo := []mailjet.InfoMessagesV31{
+		{
+			From: &mailjet.RecipientV31{
+				Email: message.From.Address,
+				Name:  message.From.Name,
+			},
+			To: &mailjet.RecipientsV31{
+				mailjet.RecipientV31{
+					Email: message.To,
+				},
+			},
+			Subject:  message.Subject,
+			HTMLPart: message.HTML,
+			CustomID: message.CustomID,
+		},
+	}
+	messages := mailjet.MessagesV31{Info: messagesInfo}
+	if _, err := p.client.SendMailV31(&messages); err != nil {
+		return fmt.Errorf("sending mailjet email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package communications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Provider delivers rendered emails.
+type Provider interface {
+	Send(ctx context.Context, message Message) error
+}
+
+type Message struct {
+	From    mail.Address
+	To      string
+	Subject string
+	HTML    string
+	// Identifies the message to the provider, e.g. the outbox idempotency key
+	CustomID string
+}
+
+var defaultFrom = mail.Address{
+	Name:    "Quorum",
+	Address: "noreply@quorumvote.com",
+}
+
+// formatMessage encodes the message as an RFC 5322 email, for providers that
+// deal in raw messages.
+func formatMessage(message Message) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", message.From.String())
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@quorumvote.com>\r\n", uuid.New())
+	if message.CustomID != "" {
+		fmt.Fprintf(&b, "X-Quorum-Custom-ID: %s\r\n", message.CustomID)
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(message.HTML)); err != nil {
+		return nil, fmt.Errorf("encoding body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("encoding body: %w", err)
+	}
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package communications
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	Port string
+	// Optional, auth is skipped when empty
+	Username string
+	Password string
+}
+
+type smtpProvider struct {
+	config SMTPConfig
+}
+
+func NewSMTPProvider(config SMTPConfig) Provider {
+	return &smtpProvider{
+		config: config,
+	}
+}
+
+// Send does what smtp.SendMail does, but over a connection that's closed
+// once ctx is done.
+func (p *smtpProvider) Send(ctx context.Context, message Message) error {
+	msg, err := formatMessage(message)
+	if err != nil {
+		return fmt.Errorf("formatting message: %w", err)
+	}
+
+	addr := net.JoinHostPort(p.config.Host, p.config.Port)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dialing smtp server: %w", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err = p.send(conn, message, msg); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("sending smtp email: %w", errors.Join(ctx.Err(), err))
+		}
+		return fmt.Errorf("sending smtp email: %w", err)
+	}
+	return nil
+}
+
+func (p *smtpProvider) send(conn net.Conn, message Message, msg []byte) error {
+	c, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		return fmt.Errorf("starting session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: p.config.Host}); err != nil {
+			return fmt.Errorf("starting tls: %w", err)
+		}
+	}
+
+	if p.config.Username != "" {
+		auth := smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)
+		if err = c.Auth(auth); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err = c.Mail(message.From.Address); err != nil {
+		return fmt.Errorf("setting sender: %w", err)
+	}
+	if err = c.Rcpt(message.To); err != nil {
+		return fmt.Errorf("setting recipient: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("starting data: %w", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("finishing data: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,188 @@
+package communications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts a single session and records what it's sent. It
+// doesn't offer STARTTLS or AUTH.
+type fakeSMTPServer struct {
+	listener net.Listener
+	from     string
+	rcpt     []string
+	data     []byte
+	done     chan error
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &fakeSMTPServer{
+		listener: listener,
+		done:     make(chan error, 1),
+	}
+	go func() {
+		s.done <- s.serve()
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPConfig{Host: host, Port: port}
+}
+
+func (s *fakeSMTPServer) serve() error {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	if err = tp.PrintfLine("220 fake ESMTP"); err != nil {
+		return err
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			err = tp.PrintfLine("250 fake")
+		case "MAIL":
+			s.from = arg
+			err = tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, arg)
+			err = tp.PrintfLine("250 ok")
+		case "DATA":
+			if err = tp.PrintfLine("354 go ahead"); err != nil {
+				return err
+			}
+			if s.data, err = io.ReadAll(tp.DotReader()); err != nil {
+				return err
+			}
+			err = tp.PrintfLine("250 queued")
+		case "QUIT":
+			return tp.PrintfLine("221 bye")
+		default:
+			err = tp.PrintfLine("502 unknown command")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestSMTPProviderSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	provider := NewSMTPProvider(server.config())
+
+	message := Message{
+		From:     mail.Address{Name: "Quorum", Address: "noreply@quorumvote.com"},
+		To:       "voter@example.com",
+		Subject:  "Voting has closed – see the results",
+		HTML:     `<p style="color: red">Results are in</p>`,
+		CustomID: "post-results:123",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Send(ctx, message); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	if err := <-server.done; err != nil {
+		t.Fatalf("serving: %v", err)
+	}
+
+	if server.from != "FROM:<noreply@quorumvote.com>" {
+		t.Errorf("got MAIL %q", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "TO:<voter@example.com>" {
+		t.Errorf("got RCPT %q", server.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	headers := map[string]string{
+		"From":                      `"Quorum" <noreply@quorumvote.com>`,
+		"To":                        "voter@example.com",
+		"X-Quorum-Custom-Id":        "post-results:123",
+		"Content-Type":              "text/html; charset=utf-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, want := range headers {
+		if got := msg.Header.Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decoding subject: %v", err)
+	}
+	if subject != message.Subject {
+		t.Errorf("got subject %q, want %q", subject, message.Subject)
+	}
+	if msg.Header.Get("Message-Id") == "" || msg.Header.Get("Date") == "" {
+		t.Error("missing Message-ID or Date")
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got := strings.TrimSpace(string(body)); got != message.HTML {
+		t.Errorf("got body %q, want %q", got, message.HTML)
+	}
+}
+
+func TestSMTPProviderSendRespectsCtx(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer listener.Close()
+	// Accepts but never greets, like a server that's hung
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	provider := NewSMTPProvider(SMTPConfig{Host: host, Port: port})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = provider.Send(ctx, Message{From: defaultFrom, To: "voter@example.com"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v to give up", elapsed)
+	}
+}
@@ -0,0 +1,37 @@
+package communications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+)
+
+type Template string
+
+const (
+	TemplateLoginLink           Template = "login_link"
+	TemplatePostResults         Template = "post_results"
+	TemplateEmailChange         Template = "email_change"
+	TemplateWorkspaceInvitation Template = "workspace_invitation"
+	TemplatePostInvite          Template = "post_invite"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// renderTemplate executes the template with the email's variables, each
+// template is defined in templates/<name>.html.
+func renderTemplate(name Template, variables map[string]interface{}) (string, error) {
+	t := templates.Lookup(fmt.Sprintf("%s.html", name))
+	if t == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, variables); err != nil {
+		return "", fmt.Errorf("executing template %q: %w", name, err)
+	}
+	return b.String(), nil
+}
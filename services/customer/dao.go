@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"quorum-api/database"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -23,11 +24,16 @@ type getCustomersByFilterParams struct {
 }
 
 type customer struct {
-	ID         uuid.UUID      `db:"id"`
-	Email      string         `db:"email"`
-	FirstName  sql.NullString `db:"first_name"`
-	LastName   sql.NullString `db:"last_name"`
-	Profession sql.NullString `db:"profession"`
+	ID            uuid.UUID      `db:"id"`
+	Email         string         `db:"email"`
+	FirstName     sql.NullString `db:"first_name"`
+	LastName      sql.NullString `db:"last_name"`
+	Profession    sql.NullString `db:"profession"`
+	DisplayName   sql.NullString `db:"display_name"`
+	Bio           sql.NullString `db:"bio"`
+	PortfolioURL  sql.NullString `db:"portfolio_url"`
+	AvatarFileKey sql.NullString `db:"avatar_file_key"`
+	DeletedAt     *time.Time     `db:"deleted_at"`
 }
 
 func getCustomersByFilter(
@@ -38,7 +44,17 @@ func getCustomersByFilter(
 ) ([]customer, error) {
 	customers := []customer{}
 	query := `
-		select id, email, first_name, last_name, profession
+		select
+			id,
+			email,
+			first_name,
+			last_name,
+			profession,
+			display_name,
+			bio,
+			portfolio_url,
+			avatar_file_key,
+			deleted_at
 		from customer
 		where true
 	`
@@ -143,3 +159,569 @@ func getUnverifiedCustomer(
 
 	return &customer, nil
 }
+
+func getUnverifiedCustomerByEmail(
+	ctx context.Context, q database.Q, email string,
+) (*customer, error) {
+	customer := customer{}
+	if err := q.GetContext(ctx, &customer, `
+		select id, email, first_name, last_name, profession from unverified_customer where email = $1
+	`, email); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoUnverifiedCustomer
+		}
+		return nil, fmt.Errorf("selecting unverified_customer: %w", err)
+	}
+
+	return &customer, nil
+}
+
+type loginToken struct {
+	ID         uuid.UUID  `db:"id"`
+	CustomerID uuid.UUID  `db:"customer_id"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	UsedAt     *time.Time `db:"used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+type insertLoginTokenParams struct {
+	ID         uuid.UUID `db:"id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+	TokenHash  []byte    `db:"token_hash"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+func insertLoginToken(
+	ctx context.Context, q database.Q, params insertLoginTokenParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		insert into login_token (
+			id, customer_id, token_hash, expires_at
+		) values (
+			:id, :customer_id, :token_hash, :expires_at
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting login_token: %w", err)
+	}
+	return nil
+}
+
+// revokeLoginTokens revokes every unused token for the customer.
+func revokeLoginTokens(
+	ctx context.Context, q database.Q, customerID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update login_token set revoked_at = now()
+		where customer_id = $1 and used_at is null and revoked_at is null
+	`, customerID); err != nil {
+		return fmt.Errorf("updating login_token: %w", err)
+	}
+	return nil
+}
+
+var errNoLoginToken = errors.New("no login token found")
+
+func getLoginTokenByHash(
+	ctx context.Context, q database.Q, tokenHash []byte, dbLock DBLock,
+) (*loginToken, error) {
+	token := loginToken{}
+	if err := q.GetContext(ctx, &token, fmt.Sprintf(`
+		select id, customer_id, expires_at, used_at, revoked_at
+		from login_token
+		where token_hash = $1
+		%s
+	`, dbLock), tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoLoginToken
+		}
+		return nil, fmt.Errorf("selecting login_token: %w", err)
+	}
+	return &token, nil
+}
+
+func markLoginTokenUsed(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update login_token set used_at = now() where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating login_token: %w", err)
+	}
+	return nil
+}
+
+type getSessionsByFilterParams struct {
+	IDs         []uuid.UUID
+	CustomerIDs []uuid.UUID
+	ActiveOnly  bool
+}
+
+type session struct {
+	ID         uuid.UUID      `db:"id"`
+	CustomerID uuid.UUID      `db:"customer_id"`
+	IPAddress  sql.NullString `db:"ip_address"`
+	UserAgent  sql.NullString `db:"user_agent"`
+	CreatedAt  time.Time      `db:"created_at"`
+	LastSeenAt time.Time      `db:"last_seen_at"`
+	ExpiresAt  time.Time      `db:"expires_at"`
+	RevokedAt  *time.Time     `db:"revoked_at"`
+}
+
+func getSessionsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getSessionsByFilterParams,
+	dbLock DBLock,
+) ([]session, error) {
+	sessions := []session{}
+	query := `
+		select
+			id,
+			customer_id,
+			ip_address,
+			user_agent,
+			created_at,
+			last_seen_at,
+			expires_at,
+			revoked_at
+		from session
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
+	if params.ActiveOnly {
+		query = fmt.Sprintf("%s and revoked_at is null and expires_at > now()", query)
+	}
+
+	query = fmt.Sprintf("%s order by last_seen_at desc %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &sessions, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+type upsertSessionParams struct {
+	ID         uuid.UUID      `db:"id"`
+	CustomerID uuid.UUID      `db:"customer_id"`
+	IPAddress  sql.NullString `db:"ip_address"`
+	UserAgent  sql.NullString `db:"user_agent"`
+	ExpiresAt  time.Time      `db:"expires_at"`
+}
+
+func upsertSession(
+	ctx context.Context, q database.Q, params upsertSessionParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		insert into session (
+			id, customer_id, ip_address, user_agent, expires_at
+		) values (
+			:id, :customer_id, :ip_address, :user_agent, :expires_at
+		)
+		on conflict (id) do update set
+			ip_address = :ip_address,
+			user_agent = :user_agent,
+			expires_at = :expires_at,
+			last_seen_at = now()
+	`, params); err != nil {
+		return fmt.Errorf("upserting session: %w", err)
+	}
+	return nil
+}
+
+type touchSessionParams struct {
+	ID        uuid.UUID      `db:"id"`
+	IPAddress sql.NullString `db:"ip_address"`
+	UserAgent sql.NullString `db:"user_agent"`
+	// Sessions seen since then are left as they are
+	LastSeenBefore time.Time `db:"last_seen_before"`
+}
+
+// touchSession records that the session was just used, unless it already
+// has been recently.
+func touchSession(
+	ctx context.Context, q database.Q, params touchSessionParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		update session set
+			ip_address = :ip_address,
+			user_agent = :user_agent,
+			last_seen_at = now()
+		where id = :id and last_seen_at < :last_seen_before
+	`, params); err != nil {
+		return fmt.Errorf("updating session: %w", err)
+	}
+	return nil
+}
+
+type revokeSessionsParams struct {
+	IDs         []uuid.UUID
+	CustomerIDs []uuid.UUID
+}
+
+func revokeSessions(
+	ctx context.Context, q database.Q, params revokeSessionsParams,
+) error {
+	query := `update session set revoked_at = now() where revoked_at is null`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
+	if len(args) == 0 {
+		return errors.New("refusing to revoke sessions without a filter")
+	}
+
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("updating session: %w", err)
+	}
+	return nil
+}
+
+type sessionRefreshToken struct {
+	SessionID uuid.UUID  `db:"session_id"`
+	RotatedAt *time.Time `db:"rotated_at"`
+}
+
+var errNoSessionRefreshToken = errors.New("no session refresh token found")
+
+func getSessionRefreshTokenByHash(
+	ctx context.Context, q database.Q, tokenHash []byte, dbLock DBLock,
+) (*sessionRefreshToken, error) {
+	token := sessionRefreshToken{}
+	if err := q.GetContext(ctx, &token, fmt.Sprintf(`
+		select session_id, rotated_at
+		from session_refresh_token
+		where token_hash = $1
+		%s
+	`, dbLock), tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoSessionRefreshToken
+		}
+		return nil, fmt.Errorf("selecting session_refresh_token: %w", err)
+	}
+	return &token, nil
+}
+
+func insertSessionRefreshToken(
+	ctx context.Context, q database.Q, sessionID uuid.UUID, tokenHash []byte,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		insert into session_refresh_token (token_hash, session_id) values ($1, $2)
+	`, tokenHash, sessionID); err != nil {
+		return fmt.Errorf("inserting session_refresh_token: %w", err)
+	}
+	return nil
+}
+
+func rotateSessionRefreshToken(
+	ctx context.Context, q database.Q, tokenHash []byte,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update session_refresh_token set rotated_at = now() where token_hash = $1
+	`, tokenHash); err != nil {
+		return fmt.Errorf("updating session_refresh_token: %w", err)
+	}
+	return nil
+}
+
+type updateCustomerProfileParams struct {
+	ID            uuid.UUID      `db:"id"`
+	FirstName     sql.NullString `db:"first_name"`
+	LastName      sql.NullString `db:"last_name"`
+	Profession    sql.NullString `db:"profession"`
+	DisplayName   sql.NullString `db:"display_name"`
+	Bio           sql.NullString `db:"bio"`
+	PortfolioURL  sql.NullString `db:"portfolio_url"`
+	AvatarFileKey sql.NullString `db:"avatar_file_key"`
+}
+
+func updateCustomerProfile(
+	ctx context.Context, q database.Q, params updateCustomerProfileParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		update customer set
+			first_name = :first_name,
+			last_name = :last_name,
+			profession = :profession,
+			display_name = :display_name,
+			bio = :bio,
+			portfolio_url = :portfolio_url,
+			avatar_file_key = :avatar_file_key,
+			updated_at = now()
+		where id = :id
+	`, params); err != nil {
+		return fmt.Errorf("updating customer: %w", err)
+	}
+	return nil
+}
+
+func updateCustomerEmail(
+	ctx context.Context, q database.Q, id uuid.UUID, email string,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update customer set email = $2, updated_at = now() where id = $1
+	`, id, email); err != nil {
+		return fmt.Errorf("updating customer: %w", err)
+	}
+	return nil
+}
+
+// eraseCustomer removes the customer's personal details and marks them
+// deleted. The email is replaced as it must be unique, .invalid is reserved so
+// it can never be delivered.
+func eraseCustomer(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update customer set
+			email = id::text || '@deleted.invalid',
+			first_name = null,
+			last_name = null,
+			profession = null,
+			display_name = null,
+			bio = null,
+			portfolio_url = null,
+			avatar_file_key = null,
+			deleted_at = now(),
+			updated_at = now()
+		where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating customer: %w", err)
+	}
+	// Otherwise signing up again with the same email would reuse the id
+	if _, err := q.ExecContext(ctx, `
+		delete from unverified_customer where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("deleting unverified_customer: %w", err)
+	}
+	// So signing in with the same provider account creates a new customer
+	if _, err := q.ExecContext(ctx, `
+		delete from customer_identity where customer_id = $1
+	`, id); err != nil {
+		return fmt.Errorf("deleting customer_identity: %w", err)
+	}
+	return nil
+}
+
+type emailChangeRequest struct {
+	ID         uuid.UUID  `db:"id"`
+	CustomerID uuid.UUID  `db:"customer_id"`
+	NewEmail   string     `db:"new_email"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	UsedAt     *time.Time `db:"used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+type insertEmailChangeRequestParams struct {
+	ID         uuid.UUID `db:"id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+	NewEmail   string    `db:"new_email"`
+	TokenHash  []byte    `db:"token_hash"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+func insertEmailChangeRequest(
+	ctx context.Context, q database.Q, params insertEmailChangeRequestParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		insert into email_change_request (
+			id, customer_id, new_email, token_hash, expires_at
+		) values (
+			:id, :customer_id, :new_email, :token_hash, :expires_at
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting email_change_request: %w", err)
+	}
+	return nil
+}
+
+// revokeEmailChangeRequests revokes every unused request for the customer.
+func revokeEmailChangeRequests(
+	ctx context.Context, q database.Q, customerID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update email_change_request set revoked_at = now()
+		where customer_id = $1 and used_at is null and revoked_at is null
+	`, customerID); err != nil {
+		return fmt.Errorf("updating email_change_request: %w", err)
+	}
+	return nil
+}
+
+var errNoEmailChangeRequest = errors.New("no email change request found")
+
+func getEmailChangeRequestByHash(
+	ctx context.Context, q database.Q, tokenHash []byte, dbLock DBLock,
+) (*emailChangeRequest, error) {
+	request := emailChangeRequest{}
+	if err := q.GetContext(ctx, &request, fmt.Sprintf(`
+		select id, customer_id, new_email, expires_at, used_at, revoked_at
+		from email_change_request
+		where token_hash = $1
+		%s
+	`, dbLock), tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoEmailChangeRequest
+		}
+		return nil, fmt.Errorf("selecting email_change_request: %w", err)
+	}
+	return &request, nil
+}
+
+func markEmailChangeRequestUsed(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update email_change_request set used_at = now() where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating email_change_request: %w", err)
+	}
+	return nil
+}
+
+type customerIdentity struct {
+	ID         uuid.UUID `db:"id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+}
+
+var errNoCustomerIdentity = errors.New("no customer identity found")
+
+func getCustomerIdentity(
+	ctx context.Context, q database.Q, provider string, subject string,
+) (*customerIdentity, error) {
+	identity := customerIdentity{}
+	if err := q.GetContext(ctx, &identity, `
+		select id, customer_id from customer_identity
+		where provider = $1 and subject = $2
+	`, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoCustomerIdentity
+		}
+		return nil, fmt.Errorf("selecting customer_identity: %w", err)
+	}
+	return &identity, nil
+}
+
+type insertCustomerIdentityParams struct {
+	ID         uuid.UUID `db:"id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+	Provider   string    `db:"provider"`
+	Subject    string    `db:"subject"`
+}
+
+var errCustomerIdentityExists = errors.New("customer identity already exists")
+
+func insertCustomerIdentity(
+	ctx context.Context, q database.Q, params insertCustomerIdentityParams,
+) error {
+	res, err := q.NamedExecContext(ctx, `
+		insert into customer_identity (
+			id, customer_id, provider, subject
+		) values (
+			:id, :customer_id, :provider, :subject
+		) on conflict (provider, subject) do nothing
+	`, params)
+	if err != nil {
+		return fmt.Errorf("inserting customer_identity: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errCustomerIdentityExists
+	}
+	return nil
+}
+
+func touchCustomerIdentity(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update customer_identity set last_used_at = now() where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating customer_identity: %w", err)
+	}
+	return nil
+}
+
+type oidcLoginState struct {
+	ID           uuid.UUID  `db:"id"`
+	Provider     string     `db:"provider"`
+	CodeVerifier string     `db:"code_verifier"`
+	Nonce        string     `db:"nonce"`
+	ReturnTo     string     `db:"return_to"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	UsedAt       *time.Time `db:"used_at"`
+}
+
+type insertOIDCLoginStateParams struct {
+	ID           uuid.UUID `db:"id"`
+	Provider     string    `db:"provider"`
+	StateHash    []byte    `db:"state_hash"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	ReturnTo     string    `db:"return_to"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+func insertOIDCLoginState(
+	ctx context.Context, q database.Q, params insertOIDCLoginStateParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		insert into oidc_login_state (
+			id, provider, state_hash, code_verifier, nonce, return_to, expires_at
+		) values (
+			:id, :provider, :state_hash, :code_verifier, :nonce, :return_to, :expires_at
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting oidc_login_state: %w", err)
+	}
+	return nil
+}
+
+var errNoOIDCLoginState = errors.New("no oidc login state found")
+
+func getOIDCLoginStateByHash(
+	ctx context.Context, q database.Q, stateHash []byte, dbLock DBLock,
+) (*oidcLoginState, error) {
+	state := oidcLoginState{}
+	if err := q.GetContext(ctx, &state, fmt.Sprintf(`
+		select id, provider, code_verifier, nonce, return_to, expires_at, used_at
+		from oidc_login_state
+		where state_hash = $1
+		%s
+	`, dbLock), stateHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoOIDCLoginState
+		}
+		return nil, fmt.Errorf("selecting oidc_login_state: %w", err)
+	}
+	return &state, nil
+}
+
+func markOIDCLoginStateUsed(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update oidc_login_state set used_at = now() where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating oidc_login_state: %w", err)
+	}
+	return nil
+}
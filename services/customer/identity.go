@@ -0,0 +1,203 @@
+package srvcustomer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateOIDCLoginStateRequest struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ReturnTo     string
+}
+
+type ConsumeOIDCLoginStateRequest struct {
+	Provider string
+	State    string
+}
+
+type OIDCLoginState struct {
+	CodeVerifier string
+	Nonce        string
+	ReturnTo     string
+}
+
+type SignInWithIdentityRequest struct {
+	Provider string
+	// The provider's sub claim
+	Subject string
+	Email   string
+	// Only verified emails are linked to existing customers or used to
+	// create new ones
+	EmailVerified bool
+	FirstName     *string
+	LastName      *string
+}
+
+// How long the customer has to sign in with the provider
+const oidcLoginStateTTL = 10 * time.Minute
+
+var ErrOIDCLoginStateInvalid = errors.New("sign in state does not exist, has expired or was already used")
+var ErrIdentityEmailNotVerified = errors.New("the provider has not verified the account's email")
+
+func (s *srv) CreateOIDCLoginState(
+	ctx context.Context, request CreateOIDCLoginStateRequest,
+) (string, error) {
+	state, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("generating state: %w", err)
+	}
+	if err = insertOIDCLoginState(ctx, s.db, insertOIDCLoginStateParams{
+		ID:           uuid.New(),
+		Provider:     request.Provider,
+		StateHash:    hashToken(state),
+		CodeVerifier: request.CodeVerifier,
+		Nonce:        request.Nonce,
+		ReturnTo:     request.ReturnTo,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	}); err != nil {
+		return "", fmt.Errorf("inserting oidc login state: %w", err)
+	}
+	return state, nil
+}
+
+func (s *srv) ConsumeOIDCLoginState(
+	ctx context.Context, request ConsumeOIDCLoginStateRequest,
+) (*OIDCLoginState, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	state, err := getOIDCLoginStateByHash(ctx, tx, hashToken(request.State), DBLockForUpdate)
+	if err != nil {
+		if err == errNoOIDCLoginState {
+			return nil, ErrOIDCLoginStateInvalid
+		}
+		return nil, fmt.Errorf("getting oidc login state: %w", err)
+	}
+	if state.UsedAt != nil ||
+		state.Provider != request.Provider ||
+		!time.Now().Before(state.ExpiresAt) {
+		return nil, ErrOIDCLoginStateInvalid
+	}
+
+	if err = markOIDCLoginStateUsed(ctx, tx, state.ID); err != nil {
+		return nil, fmt.Errorf("marking oidc login state used: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return &OIDCLoginState{
+		CodeVerifier: state.CodeVerifier,
+		Nonce:        state.Nonce,
+		ReturnTo:     state.ReturnTo,
+	}, nil
+}
+
+// SignInWithIdentity finds the customer linked to the provider account. The
+// first time an account is used it's linked to the customer with the same
+// email, verifying them if they haven't used a login link yet, or a new
+// customer is created.
+func (s *srv) SignInWithIdentity(
+	ctx context.Context, request SignInWithIdentityRequest,
+) (uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	identity, err := getCustomerIdentity(ctx, tx, request.Provider, request.Subject)
+	switch {
+	case err == nil:
+		if err = touchCustomerIdentity(ctx, tx, identity.ID); err != nil {
+			return uuid.Nil, fmt.Errorf("touching customer identity: %w", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+		}
+		return identity.CustomerID, nil
+	case err != errNoCustomerIdentity:
+		return uuid.Nil, fmt.Errorf("getting customer identity: %w", err)
+	}
+
+	if !request.EmailVerified {
+		return uuid.Nil, ErrIdentityEmailNotVerified
+	}
+	mailAddress, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		return uuid.Nil, ErrInvalidEmail
+	}
+
+	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
+		Emails: []string{mailAddress.Address},
+	}, DBLockForUpdate)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting customers: %w", err)
+	}
+
+	var customerID uuid.UUID
+	if len(customers) > 0 {
+		customerID = customers[0].ID
+	} else {
+		unverified, err := getUnverifiedCustomerByEmail(ctx, tx, mailAddress.Address)
+		switch {
+		case err == nil:
+			// Keep the details they signed up with
+			customerID = unverified.ID
+		case err == errNoUnverifiedCustomer:
+			upsertParams := upsertUnverifiedCustomerParams{
+				Email: mailAddress.Address,
+			}
+			if request.FirstName != nil {
+				upsertParams.FirstName = nullString(*request.FirstName)
+			}
+			if request.LastName != nil {
+				upsertParams.LastName = nullString(*request.LastName)
+			}
+			customerID, err = upsertUnverifiedCustomer(ctx, tx, upsertParams)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("upserting customer: %w", err)
+			}
+		default:
+			return uuid.Nil, fmt.Errorf("getting unverified customer: %w", err)
+		}
+		if err = verifyCustomer(ctx, tx, customerID); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	err = insertCustomerIdentity(ctx, tx, insertCustomerIdentityParams{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Provider:   request.Provider,
+		Subject:    request.Subject,
+	})
+	if err == errCustomerIdentityExists {
+		// Another first sign in with the identity won the race, so sign in
+		// to the customer it was linked to
+		tx.Rollback()
+		identity, err = getCustomerIdentity(ctx, s.db, request.Provider, request.Subject)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("getting customer identity: %w", err)
+		}
+		return identity.CustomerID, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("inserting customer identity: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return customerID, nil
+}
@@ -0,0 +1,259 @@
+package srvcustomer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GetSessionsByFilterRequest struct {
+	IDs         []uuid.UUID
+	CustomerIDs []uuid.UUID
+	// Exclude revoked and expired sessions
+	ActiveOnly bool
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CustomerID uuid.UUID
+	IPAddress  *string
+	UserAgent  *string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+type CreateSessionRequest struct {
+	CustomerID uuid.UUID
+	IPAddress  string
+	UserAgent  string
+}
+
+type CreateSessionResponse struct {
+	SessionID    uuid.UUID
+	RefreshToken string
+}
+
+type RefreshSessionRequest struct {
+	RefreshToken string
+	IPAddress    string
+	UserAgent    string
+}
+
+type RefreshSessionResponse struct {
+	SessionID    uuid.UUID
+	CustomerID   uuid.UUID
+	RefreshToken string
+}
+
+type TouchSessionRequest struct {
+	ID         uuid.UUID
+	CustomerID uuid.UUID
+	IPAddress  string
+	UserAgent  string
+}
+
+type RevokeSessionsRequest struct {
+	IDs         []uuid.UUID
+	CustomerIDs []uuid.UUID
+}
+
+// How long a session lasts without being refreshed
+const sessionTTL = 30 * 24 * time.Hour
+
+// How stale last_seen_at can get before TouchSession writes it again, so
+// every request doesn't update the session
+const sessionTouchInterval = time.Minute
+
+var ErrRefreshTokenInvalid = errors.New("refresh token does not exist or its session has ended")
+var ErrRefreshTokenReused = errors.New("refresh token was already used, the session has been revoked")
+var ErrSessionRevoked = errors.New("session does not exist, has expired or was revoked")
+
+func (s *srv) GetSessionsByFilter(
+	ctx context.Context, request GetSessionsByFilterRequest,
+) ([]Session, error) {
+	rows, err := getSessionsByFilter(
+		ctx, s.db, getSessionsByFilterParams(request), DBLockUnspecified,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting sessions: %w", err)
+	}
+
+	res := []Session{}
+	for _, row := range rows {
+		session := Session{
+			ID:         row.ID,
+			CustomerID: row.CustomerID,
+			CreatedAt:  row.CreatedAt,
+			LastSeenAt: row.LastSeenAt,
+			ExpiresAt:  row.ExpiresAt,
+			RevokedAt:  row.RevokedAt,
+		}
+		if row.IPAddress.Valid {
+			ipAddress := row.IPAddress.String
+			session.IPAddress = &ipAddress
+		}
+		if row.UserAgent.Valid {
+			userAgent := row.UserAgent.String
+			session.UserAgent = &userAgent
+		}
+		res = append(res, session)
+	}
+	return res, nil
+}
+
+func (s *srv) CreateSession(
+	ctx context.Context, request CreateSessionRequest,
+) (*CreateSessionResponse, error) {
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("generating refresh token: %w", err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	sessionID := uuid.New()
+	if err = upsertSession(ctx, tx, upsertSessionParams{
+		ID:         sessionID,
+		CustomerID: request.CustomerID,
+		IPAddress:  nullString(request.IPAddress),
+		UserAgent:  nullString(request.UserAgent),
+		ExpiresAt:  time.Now().Add(sessionTTL),
+	}); err != nil {
+		return nil, fmt.Errorf("upserting session: %w", err)
+	}
+
+	if err = insertSessionRefreshToken(ctx, tx, sessionID, hashToken(refreshToken)); err != nil {
+		return nil, fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tx: %w", err)
+	}
+
+	return &CreateSessionResponse{
+		SessionID:    sessionID,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *srv) RefreshSession(
+	ctx context.Context, request RefreshSessionRequest,
+) (*RefreshSessionResponse, error) {
+	newRefreshToken, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("generating refresh token: %w", err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	tokenHash := hashToken(request.RefreshToken)
+	refreshToken, err := getSessionRefreshTokenByHash(ctx, tx, tokenHash, DBLockForUpdate)
+	if err != nil {
+		if err == errNoSessionRefreshToken {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("getting refresh token: %w", err)
+	}
+
+	sessions, err := getSessionsByFilter(ctx, tx, getSessionsByFilterParams{
+		IDs:        []uuid.UUID{refreshToken.SessionID},
+		ActiveOnly: true,
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting session: %w", err)
+	}
+	if len(sessions) != 1 {
+		return nil, ErrRefreshTokenInvalid
+	}
+	session := sessions[0]
+
+	if refreshToken.RotatedAt != nil {
+		if err = revokeSessions(ctx, tx, revokeSessionsParams{
+			IDs: []uuid.UUID{session.ID},
+		}); err != nil {
+			return nil, fmt.Errorf("revoking session: %w", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, fmt.Errorf("committing tx: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if err = rotateSessionRefreshToken(ctx, tx, tokenHash); err != nil {
+		return nil, fmt.Errorf("rotating refresh token: %w", err)
+	}
+	if err = insertSessionRefreshToken(ctx, tx, session.ID, hashToken(newRefreshToken)); err != nil {
+		return nil, fmt.Errorf("inserting refresh token: %w", err)
+	}
+	if err = upsertSession(ctx, tx, upsertSessionParams{
+		ID:         session.ID,
+		CustomerID: session.CustomerID,
+		IPAddress:  nullString(request.IPAddress),
+		UserAgent:  nullString(request.UserAgent),
+		ExpiresAt:  time.Now().Add(sessionTTL),
+	}); err != nil {
+		return nil, fmt.Errorf("upserting session: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tx: %w", err)
+	}
+
+	return &RefreshSessionResponse{
+		SessionID:    session.ID,
+		CustomerID:   session.CustomerID,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+func (s *srv) TouchSession(ctx context.Context, request TouchSessionRequest) error {
+	sessions, err := getSessionsByFilter(ctx, s.db, getSessionsByFilterParams{
+		IDs:         []uuid.UUID{request.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+		ActiveOnly:  true,
+	}, DBLockUnspecified)
+	if err != nil {
+		return fmt.Errorf("getting session: %w", err)
+	}
+	if len(sessions) != 1 {
+		return ErrSessionRevoked
+	}
+
+	if err = touchSession(ctx, s.db, touchSessionParams{
+		ID:             request.ID,
+		IPAddress:      nullString(request.IPAddress),
+		UserAgent:      nullString(request.UserAgent),
+		LastSeenBefore: time.Now().Add(-sessionTouchInterval),
+	}); err != nil {
+		return fmt.Errorf("touching session: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) RevokeSessions(ctx context.Context, request RevokeSessionsRequest) error {
+	if err := revokeSessions(ctx, s.db, revokeSessionsParams(request)); err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{
+		Valid:  s != "",
+		String: s,
+	}
+}
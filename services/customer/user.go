@@ -2,10 +2,20 @@ package srvcustomer
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/mail"
+	"net/url"
+	"path/filepath"
+	"quorum-api/blobstore"
+	"quorum-api/database"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -15,6 +25,42 @@ type SRVCustomer interface {
 	GetCustomersByFilter(ctx context.Context, request GetCustomersByFilterRequest) ([]Customer, error)
 	CreateUnverifiedCustomer(ctx context.Context, request CreateUnverifiedCustomerRequest) (uuid.UUID, error)
 	VerifyCustomer(ctx context.Context, id uuid.UUID) error
+	// CreateLoginToken creates a single use token for a login link, revoking
+	// any earlier unused tokens for the customer. Pass a tx to create it in
+	// the same tx as another change, or nil.
+	CreateLoginToken(ctx context.Context, tx database.Q, request CreateLoginTokenRequest) (string, error)
+	// VerifyLoginToken uses up the token and verifies the customer it was
+	// created for.
+	VerifyLoginToken(ctx context.Context, token string) (uuid.UUID, error)
+	RevokeLoginTokens(ctx context.Context, customerID uuid.UUID) error
+	GetSessionsByFilter(ctx context.Context, request GetSessionsByFilterRequest) ([]Session, error)
+	CreateSession(ctx context.Context, request CreateSessionRequest) (*CreateSessionResponse, error)
+	// RefreshSession swaps a refresh token for a new one. Reusing a refresh
+	// token that was already swapped revokes the session.
+	RefreshSession(ctx context.Context, request RefreshSessionRequest) (*RefreshSessionResponse, error)
+	// TouchSession checks the session is still active and records that it
+	// was used, at most once a minute.
+	TouchSession(ctx context.Context, request TouchSessionRequest) error
+	RevokeSessions(ctx context.Context, request RevokeSessionsRequest) error
+	UpdateCustomer(ctx context.Context, request UpdateCustomerRequest) error
+	// RequestEmailChange creates a single use token that confirms the new
+	// email, revoking any earlier requests for the customer.
+	RequestEmailChange(ctx context.Context, request RequestEmailChangeRequest) error
+	ConfirmEmailChange(ctx context.Context, token string) (uuid.UUID, error)
+	// DeleteCustomer erases the customer's details and ends their sessions.
+	// The customer row is kept so anything that references it still
+	// resolves.
+	DeleteCustomer(ctx context.Context, request DeleteCustomerRequest) error
+	// GenerateSignedAvatarURL returns a url to upload an avatar to, pass the
+	// file key to UpdateCustomer once uploaded.
+	GenerateSignedAvatarURL(ctx context.Context, request GenerateSignedAvatarURLRequest) (*GenerateSignedAvatarURLResponse, error)
+	// CreateOIDCLoginState stores the PKCE verifier and nonce for a sign in
+	// with an OpenID Connect provider, returning the state to send to it.
+	CreateOIDCLoginState(ctx context.Context, request CreateOIDCLoginStateRequest) (string, error)
+	// ConsumeOIDCLoginState returns the sign in's verifier and nonce, and
+	// stops the state being used again.
+	ConsumeOIDCLoginState(ctx context.Context, request ConsumeOIDCLoginStateRequest) (*OIDCLoginState, error)
+	SignInWithIdentity(ctx context.Context, request SignInWithIdentityRequest) (uuid.UUID, error)
 }
 
 type GetCustomersByFilterRequest struct {
@@ -28,6 +74,13 @@ type Customer struct {
 	FirstName  *string
 	LastName   *string
 	Profession *string
+	// Public profile, shown to other customers
+	DisplayName  *string
+	Bio          *string
+	PortfolioURL *string
+	// Signed url that expires after avatarURLTTL
+	AvatarURL *string
+	DeletedAt *time.Time
 }
 
 type CreateUnverifiedCustomerRequest struct {
@@ -35,20 +88,108 @@ type CreateUnverifiedCustomerRequest struct {
 	FirstName  *string
 	LastName   *string
 	Profession *string
+	// Optional, called in the same tx the customer is created in, e.g. to
+	// enqueue their verification email
+	OnCreate func(ctx context.Context, tx database.Q, customerID uuid.UUID) error
 }
 
+// Nil fields are left unchanged, empty strings clear the field
+type UpdateCustomerRequest struct {
+	ID           uuid.UUID
+	FirstName    *string
+	LastName     *string
+	Profession   *string
+	DisplayName  *string
+	Bio          *string
+	PortfolioURL *string
+	// Key from GenerateSignedAvatarURL
+	AvatarFileKey *string
+}
+
+type GenerateSignedAvatarURLRequest struct {
+	CustomerID  uuid.UUID
+	FileName    string
+	ContentType string
+}
+
+type GenerateSignedAvatarURLResponse struct {
+	FileKey string
+	URL     string
+}
+
+const (
+	maxDisplayNameLength  = 50
+	maxBioLength          = 500
+	maxPortfolioURLLength = 2048
+	maxAvatarSize         = 5 << 20
+	avatarURLTTL          = time.Hour
+)
+
+// The content type avatars must be uploaded with, by extension
+var avatarContentTypes = map[string]string{
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// avatarKeyPrefix is where the customer's avatars are uploaded to, so they
+// can only use their own.
+func avatarKeyPrefix(customerID uuid.UUID) string {
+	return fmt.Sprintf("avatars/%s/", customerID)
+}
+
+type RequestEmailChangeRequest struct {
+	CustomerID uuid.UUID
+	NewEmail   string
+	// Called in the same tx the request is created in, e.g. to enqueue the
+	// email containing the token
+	OnCreate func(ctx context.Context, tx database.Q, token string) error
+}
+
+type DeleteCustomerRequest struct {
+	ID uuid.UUID
+	// Optional, called in the same tx the customer is deleted in so other
+	// services can remove the customer's data
+	OnDelete func(ctx context.Context, tx database.Q, customerID uuid.UUID) error
+}
+
+const emailChangeTTL = 24 * time.Hour
+
+type CreateLoginTokenRequest struct {
+	CustomerID uuid.UUID
+}
+
+const loginTokenTTL = 1 * time.Hour
+
 var ErrEmailTaken = errors.New("another verified customer exists with that email")
 var ErrInvalidEmail = errors.New("email string format is invalid")
 var ErrCustomerNotFound = errors.New("no customer exists")
+var ErrLoginTokenInvalid = errors.New("login token does not exist")
+var ErrLoginTokenExpired = errors.New("login token has expired")
+var ErrLoginTokenUsed = errors.New("login token has already been used")
+var ErrLoginTokenRevoked = errors.New("login token was revoked, usually because a newer link was sent")
+var ErrInvalidDisplayName = fmt.Errorf("display name must be at most %v characters", maxDisplayNameLength)
+var ErrInvalidBio = fmt.Errorf("bio must be at most %v characters", maxBioLength)
+var ErrInvalidPortfolioURL = errors.New("portfolio link must be an http or https url")
+var ErrUnsupportedAvatarType = errors.New("only .jpeg, .jpg, .png and .webp avatars are supported")
+var ErrAvatarFileNotFound = errors.New("avatar file not found in bucket")
+var ErrAvatarTooLarge = fmt.Errorf("avatar must be at most %vMB", maxAvatarSize>>20)
+var ErrEmailChangeTokenInvalid = errors.New("email change token does not exist")
+var ErrEmailChangeTokenExpired = errors.New("email change token has expired")
+var ErrEmailChangeTokenUsed = errors.New("email change token has already been used")
+var ErrEmailChangeTokenRevoked = errors.New("email change token was revoked, usually because a newer change was requested")
 
-func New(db *sqlx.DB) SRVCustomer {
+func New(db *sqlx.DB, blobs blobstore.BlobStore) SRVCustomer {
 	return &srv{
-		db: db,
+		db:    db,
+		blobs: blobs,
 	}
 }
 
 type srv struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	blobs blobstore.BlobStore
 }
 
 func (s *srv) GetCustomersByFilter(ctx context.Context, request GetCustomersByFilterRequest) ([]Customer, error) {
@@ -74,9 +215,31 @@ func (s *srv) GetCustomersByFilter(ctx context.Context, request GetCustomersByFi
 			customer.LastName = &lastName
 		}
 		if row.Profession.Valid {
-			profession := row.LastName.String
+			profession := row.Profession.String
 			customer.Profession = &profession
 		}
+		if row.DisplayName.Valid {
+			displayName := row.DisplayName.String
+			customer.DisplayName = &displayName
+		}
+		if row.Bio.Valid {
+			bio := row.Bio.String
+			customer.Bio = &bio
+		}
+		if row.PortfolioURL.Valid {
+			portfolioURL := row.PortfolioURL.String
+			customer.PortfolioURL = &portfolioURL
+		}
+		if row.AvatarFileKey.Valid {
+			avatarURL, err := s.blobs.SignedGetURL(
+				ctx, row.AvatarFileKey.String, time.Now().Add(avatarURLTTL),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("signing avatar url: %w", err)
+			}
+			customer.AvatarURL = &avatarURL
+		}
+		customer.DeletedAt = row.DeletedAt
 		res = append(res, customer)
 	}
 	return res, nil
@@ -130,6 +293,12 @@ func (s *srv) CreateUnverifiedCustomer(ctx context.Context, request CreateUnveri
 		return uuid.Nil, fmt.Errorf("upserting customer: %w", err)
 	}
 
+	if request.OnCreate != nil {
+		if err = request.OnCreate(ctx, tx, customerID); err != nil {
+			return uuid.Nil, fmt.Errorf("handling created customer: %w", err)
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
 	}
@@ -143,6 +312,20 @@ func (s *srv) VerifyCustomer(ctx context.Context, id uuid.UUID) error {
 	}
 	defer tx.Rollback()
 
+	if err = verifyCustomer(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+
+	return nil
+}
+
+// verifyCustomer moves an unverified customer into customer, doing nothing if
+// they're already verified.
+func verifyCustomer(ctx context.Context, tx database.Q, id uuid.UUID) error {
 	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
 		IDs: []uuid.UUID{id},
 	}, DBLockForUpdate)
@@ -170,10 +353,360 @@ func (s *srv) VerifyCustomer(ctx context.Context, id uuid.UUID) error {
 	}); err != nil {
 		return fmt.Errorf("upserting customer: %w", err)
 	}
+	return nil
+}
+
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is what's stored instead of a token, so a leaked table can't be
+// used to log in.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+func (s *srv) CreateLoginToken(
+	ctx context.Context, tx database.Q, request CreateLoginTokenRequest,
+) (string, error) {
+	if tx == nil {
+		tx = s.db
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	if err = revokeLoginTokens(ctx, tx, request.CustomerID); err != nil {
+		return "", fmt.Errorf("revoking login tokens: %w", err)
+	}
+
+	if err = insertLoginToken(ctx, tx, insertLoginTokenParams{
+		ID:         uuid.New(),
+		CustomerID: request.CustomerID,
+		TokenHash:  hashToken(token),
+		ExpiresAt:  time.Now().Add(loginTokenTTL),
+	}); err != nil {
+		return "", fmt.Errorf("inserting login token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *srv) VerifyLoginToken(ctx context.Context, token string) (uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	loginToken, err := getLoginTokenByHash(ctx, tx, hashToken(token), DBLockForUpdate)
+	if err != nil {
+		if err == errNoLoginToken {
+			return uuid.Nil, ErrLoginTokenInvalid
+		}
+		return uuid.Nil, fmt.Errorf("getting login token: %w", err)
+	}
+	switch {
+	case loginToken.UsedAt != nil:
+		return uuid.Nil, ErrLoginTokenUsed
+	case loginToken.RevokedAt != nil:
+		return uuid.Nil, ErrLoginTokenRevoked
+	case !time.Now().Before(loginToken.ExpiresAt):
+		return uuid.Nil, ErrLoginTokenExpired
+	}
+
+	if err = markLoginTokenUsed(ctx, tx, loginToken.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("marking login token used: %w", err)
+	}
+
+	if err = verifyCustomer(ctx, tx, loginToken.CustomerID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return loginToken.CustomerID, nil
+}
+
+func (s *srv) RevokeLoginTokens(ctx context.Context, customerID uuid.UUID) error {
+	if err := revokeLoginTokens(ctx, s.db, customerID); err != nil {
+		return fmt.Errorf("revoking login tokens: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) UpdateCustomer(ctx context.Context, request UpdateCustomerRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
+		IDs: []uuid.UUID{request.ID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return fmt.Errorf("getting customers: %w", err)
+	}
+	if len(customers) != 1 || customers[0].DeletedAt != nil {
+		return ErrCustomerNotFound
+	}
+
+	params := updateCustomerProfileParams{
+		ID:            request.ID,
+		FirstName:     customers[0].FirstName,
+		LastName:      customers[0].LastName,
+		Profession:    customers[0].Profession,
+		DisplayName:   customers[0].DisplayName,
+		Bio:           customers[0].Bio,
+		PortfolioURL:  customers[0].PortfolioURL,
+		AvatarFileKey: customers[0].AvatarFileKey,
+	}
+	if request.FirstName != nil {
+		params.FirstName = nullString(*request.FirstName)
+	}
+	if request.LastName != nil {
+		params.LastName = nullString(*request.LastName)
+	}
+	if request.Profession != nil {
+		params.Profession = nullString(*request.Profession)
+	}
+	if request.DisplayName != nil {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+			return ErrInvalidDisplayName
+		}
+		params.DisplayName = nullString(displayName)
+	}
+	if request.Bio != nil {
+		bio := strings.TrimSpace(*request.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return ErrInvalidBio
+		}
+		params.Bio = nullString(bio)
+	}
+	if request.PortfolioURL != nil {
+		portfolioURL := strings.TrimSpace(*request.PortfolioURL)
+		if portfolioURL != "" {
+			parsed, err := url.Parse(portfolioURL)
+			if err != nil ||
+				(parsed.Scheme != "http" && parsed.Scheme != "https") ||
+				parsed.Host == "" ||
+				len(portfolioURL) > maxPortfolioURLLength {
+				return ErrInvalidPortfolioURL
+			}
+		}
+		params.PortfolioURL = nullString(portfolioURL)
+	}
+	// Keeping the current avatar doesn't need checking again
+	if request.AvatarFileKey != nil && *request.AvatarFileKey != "" &&
+		*request.AvatarFileKey != customers[0].AvatarFileKey.String {
+		if !strings.HasPrefix(*request.AvatarFileKey, avatarKeyPrefix(request.ID)) {
+			return ErrAvatarFileNotFound
+		}
+		attrs, err := s.blobs.Stat(ctx, *request.AvatarFileKey)
+		if err != nil {
+			if errors.Is(err, blobstore.ErrNotExist) {
+				return ErrAvatarFileNotFound
+			}
+			return fmt.Errorf("getting avatar metadata: %w", err)
+		}
+		if attrs.Size > maxAvatarSize {
+			return ErrAvatarTooLarge
+		}
+		contentType := avatarContentTypes[filepath.Ext(*request.AvatarFileKey)]
+		if contentType == "" || attrs.ContentType != contentType {
+			return ErrUnsupportedAvatarType
+		}
+	}
+	if request.AvatarFileKey != nil {
+		params.AvatarFileKey = nullString(*request.AvatarFileKey)
+	}
+	if err = updateCustomerProfile(ctx, tx, params); err != nil {
+		return fmt.Errorf("updating customer: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) RequestEmailChange(ctx context.Context, request RequestEmailChangeRequest) error {
+	mailAddress, err := mail.ParseAddress(request.NewEmail)
+	if err != nil {
+		return ErrInvalidEmail
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
+		Emails: []string{mailAddress.Address},
+	}, DBLockUnspecified)
+	if err != nil {
+		return fmt.Errorf("getting customers: %w", err)
+	}
+	if len(customers) > 0 {
+		return ErrEmailTaken
+	}
+
+	if err = revokeEmailChangeRequests(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("revoking email change requests: %w", err)
+	}
+	if err = insertEmailChangeRequest(ctx, tx, insertEmailChangeRequestParams{
+		ID:         uuid.New(),
+		CustomerID: request.CustomerID,
+		NewEmail:   mailAddress.Address,
+		TokenHash:  hashToken(token),
+		ExpiresAt:  time.Now().Add(emailChangeTTL),
+	}); err != nil {
+		return fmt.Errorf("inserting email change request: %w", err)
+	}
+
+	if err = request.OnCreate(ctx, tx, token); err != nil {
+		return fmt.Errorf("handling email change request: %w", err)
+	}
 
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("committing tx: %w", err)
 	}
+	return nil
+}
+
+func (s *srv) ConfirmEmailChange(ctx context.Context, token string) (uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	changeRequest, err := getEmailChangeRequestByHash(ctx, tx, hashToken(token), DBLockForUpdate)
+	if err != nil {
+		if err == errNoEmailChangeRequest {
+			return uuid.Nil, ErrEmailChangeTokenInvalid
+		}
+		return uuid.Nil, fmt.Errorf("getting email change request: %w", err)
+	}
+	switch {
+	case changeRequest.UsedAt != nil:
+		return uuid.Nil, ErrEmailChangeTokenUsed
+	case changeRequest.RevokedAt != nil:
+		return uuid.Nil, ErrEmailChangeTokenRevoked
+	case !time.Now().Before(changeRequest.ExpiresAt):
+		return uuid.Nil, ErrEmailChangeTokenExpired
+	}
 
+	// Someone may have signed up with the email since the change was requested
+	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
+		Emails: []string{changeRequest.NewEmail},
+	}, DBLockForUpdate)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting customers: %w", err)
+	}
+	if len(customers) > 0 {
+		return uuid.Nil, ErrEmailTaken
+	}
+
+	if err = markEmailChangeRequestUsed(ctx, tx, changeRequest.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("marking email change request used: %w", err)
+	}
+	if err = updateCustomerEmail(ctx, tx, changeRequest.CustomerID, changeRequest.NewEmail); err != nil {
+		return uuid.Nil, fmt.Errorf("updating email: %w", err)
+	}
+	// Links sent to the old address shouldn't log in to the account anymore
+	if err = revokeLoginTokens(ctx, tx, changeRequest.CustomerID); err != nil {
+		return uuid.Nil, fmt.Errorf("revoking login tokens: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return changeRequest.CustomerID, nil
+}
+
+func (s *srv) DeleteCustomer(ctx context.Context, request DeleteCustomerRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	customers, err := getCustomersByFilter(ctx, tx, getCustomersByFilterParams{
+		IDs: []uuid.UUID{request.ID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return fmt.Errorf("getting customers: %w", err)
+	}
+	if len(customers) != 1 || customers[0].DeletedAt != nil {
+		return ErrCustomerNotFound
+	}
+
+	if request.OnDelete != nil {
+		if err = request.OnDelete(ctx, tx, request.ID); err != nil {
+			return fmt.Errorf("handling deleted customer: %w", err)
+		}
+	}
+
+	if err = eraseCustomer(ctx, tx, request.ID); err != nil {
+		return fmt.Errorf("erasing customer: %w", err)
+	}
+	if err = revokeSessions(ctx, tx, revokeSessionsParams{
+		CustomerIDs: []uuid.UUID{request.ID},
+	}); err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+	if err = revokeLoginTokens(ctx, tx, request.ID); err != nil {
+		return fmt.Errorf("revoking login tokens: %w", err)
+	}
+	if err = revokeEmailChangeRequests(ctx, tx, request.ID); err != nil {
+		return fmt.Errorf("revoking email change requests: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
 	return nil
 }
+
+func (s *srv) GenerateSignedAvatarURL(
+	ctx context.Context, request GenerateSignedAvatarURLRequest,
+) (*GenerateSignedAvatarURLResponse, error) {
+	ext := filepath.Ext(strings.ToLower(request.FileName))
+	contentType, ok := avatarContentTypes[ext]
+	if !ok {
+		return nil, ErrUnsupportedAvatarType
+	}
+	if request.ContentType == "" {
+		return nil, fmt.Errorf("expected content type to be non-empty")
+	}
+	if request.ContentType != contentType {
+		return nil, ErrUnsupportedAvatarType
+	}
+	res := GenerateSignedAvatarURLResponse{
+		FileKey: fmt.Sprintf("%s%s%s", avatarKeyPrefix(request.CustomerID), uuid.NewString(), ext),
+	}
+	url, err := s.blobs.SignedPutURL(
+		ctx, res.FileKey, request.ContentType, time.Now().Add(time.Minute*15),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating SignedURL: %w", err)
+	}
+	res.URL = url
+	return &res, nil
+}
@@ -0,0 +1,269 @@
+package srvpost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// RankedRound is one round of an instant-runoff tally.
+type RankedRound struct {
+	// Starting from 1
+	Round int
+	// Options still in the running, ordered by position. Percentages are of
+	// the ballots that count this round.
+	Options []OptionResult
+	// Ballots that don't rank any option still in the running
+	ExhaustedBallots int
+	// Options knocked out at the end of the round, empty for the last round
+	EliminatedOptionIDs []uuid.UUID
+}
+
+var ErrInvalidBallot = errors.New("ballot must pick at least one option, and each option at most once")
+
+var ErrTooManySelections = errors.New("ballot picks more options than the post allows")
+
+func validMaxSelections(mode VotingMode, maxSelections *int) bool {
+	if maxSelections == nil {
+		return true
+	}
+	return mode == VotingModeMultiSelect && *maxSelections >= 1 && *maxSelections <= 6
+}
+
+// validateBallot checks the options picked are allowed by the post's voting
+// mode.
+func validateBallot(post Post, optionIDs []uuid.UUID) error {
+	if len(optionIDs) == 0 {
+		return ErrInvalidBallot
+	}
+	picked := map[uuid.UUID]bool{}
+	for _, optionID := range optionIDs {
+		if picked[optionID] {
+			return ErrInvalidBallot
+		}
+		picked[optionID] = true
+
+		found := false
+		for _, postOptionID := range post.OptionIDs {
+			if postOptionID == optionID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrOptionNotFound
+		}
+	}
+	switch post.VotingMode {
+	case VotingModeSingle:
+		if len(optionIDs) > 1 {
+			return ErrTooManySelections
+		}
+	case VotingModeMultiSelect:
+		if post.MaxSelections != nil && len(optionIDs) > *post.MaxSelections {
+			return ErrTooManySelections
+		}
+	}
+	return nil
+}
+
+func (s *srv) SubmitBallot(
+	ctx context.Context, request SubmitBallotRequest,
+) (*SubmitBallotResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	post, err := getLivePost(ctx, tx, request.PostID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = validateBallot(*post, request.OptionIDs); err != nil {
+		return nil, err
+	}
+
+	votes, err := getPostVotesByFilter(ctx, tx, getPostVotesByFilterParams{
+		PostIDs:     []uuid.UUID{post.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting votes: %w", err)
+	}
+
+	firstChoiceID := request.OptionIDs[0]
+	voteID := uuid.New()
+	action := postVoteActionCast
+	if len(votes) == 0 {
+		if post.Restricted && post.AuthorID != request.CustomerID {
+			if err = redeemInvite(ctx, tx, post.ID, request.CustomerID, request.InviteToken); err != nil {
+				return nil, err
+			}
+		}
+		if err = insertPostVote(ctx, tx, insertPostVoteParams{
+			ID:           voteID,
+			PostOptionID: firstChoiceID,
+			PostID:       post.ID,
+			Reason:       request.Reason,
+			CustomerID:   request.CustomerID,
+		}); err != nil {
+			if errors.Is(err, errPostVoteExists) {
+				return nil, ErrVoteAlreadyCast
+			}
+			return nil, fmt.Errorf("inserting vote: %w", err)
+		}
+	} else {
+		if !post.AllowVoteChanges {
+			return nil, ErrVoteAlreadyCast
+		}
+		voteID = votes[0].ID
+		action = postVoteActionChanged
+		if err = updatePostVote(ctx, tx, updatePostVoteParams{
+			ID:           voteID,
+			PostOptionID: firstChoiceID,
+			Reason:       request.Reason,
+		}); err != nil {
+			return nil, fmt.Errorf("updating vote: %w", err)
+		}
+	}
+
+	selections := []postVoteSelection{}
+	for i, optionID := range request.OptionIDs {
+		selections = append(selections, postVoteSelection{
+			PostVoteID:   voteID,
+			PostID:       post.ID,
+			PostOptionID: optionID,
+			Rank:         i + 1,
+		})
+	}
+	if err = replacePostVoteSelections(ctx, tx, voteID, selections); err != nil {
+		return nil, fmt.Errorf("replacing vote selections: %w", err)
+	}
+	if err = replaceCriterionResponses(
+		ctx, tx, post.ID, voteID, firstChoiceID, request.Criteria,
+	); err != nil {
+		return nil, err
+	}
+
+	if err = insertPostVoteEvent(ctx, tx, insertPostVoteEventParams{
+		ID:           uuid.New(),
+		PostID:       post.ID,
+		CustomerID:   request.CustomerID,
+		PostOptionID: &firstChoiceID,
+		Action:       action,
+		Reason:       request.Reason,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting vote event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+
+	eventType := PostEventTypeVoteAdded
+	if action == postVoteActionChanged {
+		eventType = PostEventTypeVoteChanged
+	}
+	s.publishPostEvent(ctx, PostEvent{
+		Type:   eventType,
+		PostID: post.ID,
+		VoteID: &voteID,
+	})
+
+	return &SubmitBallotResponse{
+		PostID: post.ID,
+		VoteID: voteID,
+	}, nil
+}
+
+// instantRunoff tallies ranked ballots round by round. Each round a ballot
+// counts for its highest ranked option still in the running. An option with
+// more than half of those votes wins, otherwise the options with the fewest
+// are knocked out and their ballots move on to their next choice. Options tied
+// for fewest go out together, unless that's every option left, in which case
+// there's no winner.
+func instantRunoff(
+	optionIDs []uuid.UUID, ballots [][]uuid.UUID,
+) ([]RankedRound, *uuid.UUID) {
+	rounds := []RankedRound{}
+	if len(ballots) == 0 {
+		return rounds, nil
+	}
+
+	running := map[uuid.UUID]bool{}
+	for _, optionID := range optionIDs {
+		running[optionID] = true
+	}
+
+	for len(running) > 0 {
+		round := RankedRound{
+			Round:               len(rounds) + 1,
+			Options:             []OptionResult{},
+			EliminatedOptionIDs: []uuid.UUID{},
+		}
+		voteCounts := map[uuid.UUID]int{}
+		for _, ballot := range ballots {
+			counted := false
+			for _, optionID := range ballot {
+				if running[optionID] {
+					voteCounts[optionID]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				round.ExhaustedBallots++
+			}
+		}
+		activeBallots := len(ballots) - round.ExhaustedBallots
+
+		var winningOptionID *uuid.UUID
+		fewestVotes := -1
+		for _, optionID := range optionIDs {
+			if !running[optionID] {
+				continue
+			}
+			result := OptionResult{
+				OptionID:  optionID,
+				VoteCount: voteCounts[optionID],
+			}
+			if activeBallots > 0 {
+				result.VotePercentage = float64(result.VoteCount) / float64(activeBallots) * 100
+			}
+			round.Options = append(round.Options, result)
+			if result.VoteCount*2 > activeBallots {
+				id := optionID
+				winningOptionID = &id
+			}
+			if fewestVotes == -1 || result.VoteCount < fewestVotes {
+				fewestVotes = result.VoteCount
+			}
+		}
+		if winningOptionID != nil {
+			rounds = append(rounds, round)
+			return rounds, winningOptionID
+		}
+
+		for _, o := range round.Options {
+			if o.VoteCount == fewestVotes {
+				round.EliminatedOptionIDs = append(round.EliminatedOptionIDs, o.OptionID)
+			}
+		}
+		if len(round.EliminatedOptionIDs) == len(round.Options) {
+			round.EliminatedOptionIDs = []uuid.UUID{}
+			rounds = append(rounds, round)
+			return rounds, nil
+		}
+		for _, optionID := range round.EliminatedOptionIDs {
+			delete(running, optionID)
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, nil
+}
@@ -0,0 +1,176 @@
+package srvpost
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	names := map[uuid.UUID]string{a: "a", b: "b", c: "c", d: "d"}
+	repeat := func(n int, ballot ...uuid.UUID) [][]uuid.UUID {
+		res := [][]uuid.UUID{}
+		for i := 0; i < n; i++ {
+			res = append(res, ballot)
+		}
+		return res
+	}
+	ballots := func(groups ...[][]uuid.UUID) [][]uuid.UUID {
+		return slices.Concat(groups...)
+	}
+
+	type round struct {
+		// Option names and their vote counts, in position order
+		counts     string
+		exhausted  int
+		eliminated []uuid.UUID
+	}
+	tests := []struct {
+		name    string
+		options []uuid.UUID
+		ballots [][]uuid.UUID
+		rounds  []round
+		winner  *uuid.UUID
+	}{
+		{
+			name:    "no ballots",
+			options: []uuid.UUID{a, b},
+			rounds:  []round{},
+		},
+		{
+			name:    "majority in the first round",
+			options: []uuid.UUID{a, b, c},
+			ballots: ballots(repeat(3, a, b), repeat(1, b), repeat(1, c, a)),
+			rounds: []round{
+				{counts: "a:3 b:1 c:1"},
+			},
+			winner: &a,
+		},
+		{
+			name:    "half isn't a majority",
+			options: []uuid.UUID{a, b},
+			ballots: ballots(repeat(2, a), repeat(1, b), repeat(1, b, a)),
+			rounds: []round{
+				{counts: "a:2 b:2"},
+			},
+		},
+		{
+			name:    "elimination transfers to the next choice",
+			options: []uuid.UUID{a, b, c},
+			ballots: ballots(repeat(3, a), repeat(3, b), repeat(1, c, b)),
+			rounds: []round{
+				{counts: "a:3 b:3 c:1", eliminated: []uuid.UUID{c}},
+				{counts: "a:3 b:4"},
+			},
+			winner: &b,
+		},
+		{
+			name:    "transfers skip eliminated choices",
+			options: []uuid.UUID{a, b, c, d},
+			ballots: ballots(
+				repeat(4, a), repeat(3, b), repeat(2, c, b), repeat(1, d, c, a),
+			),
+			rounds: []round{
+				{counts: "a:4 b:3 c:2 d:1", eliminated: []uuid.UUID{d}},
+				{counts: "a:4 b:3 c:3", eliminated: []uuid.UUID{b, c}},
+				// b's and c's ballots don't rank a, and d's ballot
+				// counts for a once c is out too
+				{counts: "a:5", exhausted: 5},
+			},
+			winner: &a,
+		},
+		{
+			name:    "options tied for fewest go out together",
+			options: []uuid.UUID{a, b, c, d},
+			ballots: ballots(repeat(3, a), repeat(3, d), repeat(1, b, a), repeat(1, c, a)),
+			rounds: []round{
+				{counts: "a:3 b:1 c:1 d:3", eliminated: []uuid.UUID{b, c}},
+				{counts: "a:5 d:3"},
+			},
+			winner: &a,
+		},
+		{
+			name:    "options without votes are eliminated",
+			options: []uuid.UUID{a, b, c},
+			ballots: ballots(repeat(1, a), repeat(1, b)),
+			rounds: []round{
+				{counts: "a:1 b:1 c:0", eliminated: []uuid.UUID{c}},
+				{counts: "a:1 b:1"},
+			},
+		},
+		{
+			name:    "tie between every option left",
+			options: []uuid.UUID{a, b, c, d},
+			ballots: ballots(repeat(3, a), repeat(3, d), repeat(1, b, a), repeat(1, c, d)),
+			rounds: []round{
+				{counts: "a:3 b:1 c:1 d:3", eliminated: []uuid.UUID{b, c}},
+				{counts: "a:4 d:4"},
+			},
+		},
+		{
+			name:    "exhausted ballots don't count towards the majority",
+			options: []uuid.UUID{a, b, c},
+			ballots: ballots(repeat(3, a), repeat(2, b), repeat(1, c)),
+			rounds: []round{
+				{counts: "a:3 b:2 c:1", eliminated: []uuid.UUID{c}},
+				{counts: "a:3 b:2", exhausted: 1},
+			},
+			winner: &a,
+		},
+		{
+			name:    "ballots only ranking removed options are exhausted",
+			options: []uuid.UUID{a, b},
+			ballots: ballots(repeat(2, a), repeat(1, b), repeat(3, c)),
+			rounds: []round{
+				{counts: "a:2 b:1", exhausted: 3},
+			},
+			winner: &a,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounds, winner := instantRunoff(tt.options, tt.ballots)
+
+			if (winner == nil) != (tt.winner == nil) || (winner != nil && *winner != *tt.winner) {
+				t.Errorf("got winner %v, want %v", winner, tt.winner)
+			}
+			if len(rounds) != len(tt.rounds) {
+				t.Fatalf("got %v rounds, want %v", len(rounds), len(tt.rounds))
+			}
+			for i, got := range rounds {
+				want := tt.rounds[i]
+				if got.Round != i+1 {
+					t.Errorf("got round number %v, want %v", got.Round, i+1)
+				}
+				counts := ""
+				totalPercentage := 0.0
+				for j, o := range got.Options {
+					if j > 0 {
+						counts += " "
+					}
+					counts += fmt.Sprintf("%s:%v", names[o.OptionID], o.VoteCount)
+					totalPercentage += o.VotePercentage
+				}
+				if counts != want.counts {
+					t.Errorf("round %v: got counts %q, want %q", i+1, counts, want.counts)
+				}
+				if got.ExhaustedBallots != want.exhausted {
+					t.Errorf("round %v: got %v exhausted, want %v", i+1, got.ExhaustedBallots, want.exhausted)
+				}
+				if math.Abs(totalPercentage-100) > 1e-9 {
+					t.Errorf("round %v: percentages add up to %v", i+1, totalPercentage)
+				}
+				if want.eliminated == nil {
+					want.eliminated = []uuid.UUID{}
+				}
+				if !slices.Equal(got.EliminatedOptionIDs, want.eliminated) {
+					t.Errorf("round %v: got eliminated %v, want %v", i+1, got.EliminatedOptionIDs, want.eliminated)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package srvpost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quorum-api/database"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type Criterion struct {
+	ID       uuid.UUID
+	PostID   uuid.UUID
+	Name     string
+	Kind     CriterionKind
+	Position int
+}
+
+type UpsertPostCriterionRequest struct {
+	ID       uuid.UUID
+	Name     string
+	Kind     CriterionKind
+	Position int
+}
+
+// CriterionResponse is a voter's answer to one of the post's criteria, about
+// the option they voted for.
+type CriterionResponse struct {
+	CriterionID uuid.UUID
+	// From 1 to 5 for RATING criteria, nil for TAG criteria
+	Score *int
+}
+
+type GetCriteriaByFilterRequest struct {
+	PostIDs []uuid.UUID
+}
+
+type GetCriterionResultsByFilterRequest struct {
+	PostIDs []uuid.UUID
+}
+
+// CriterionResult aggregates the responses to a criterion about one option.
+type CriterionResult struct {
+	PostID        uuid.UUID
+	CriterionID   uuid.UUID
+	OptionID      uuid.UUID
+	ResponseCount int
+	// Nil for TAG criteria
+	MeanScore *float64
+	// Between 0 and 100, the share of the option's votes that tagged it. Nil
+	// for RATING criteria.
+	TagPercentage *float64
+}
+
+const maxCriteria = 5
+
+const maxCriterionNameLength = 40
+
+var ErrTooManyCriteria = fmt.Errorf("posts can have at most %v criteria", maxCriteria)
+
+var ErrInvalidCriterion = fmt.Errorf(
+	"criteria need a unique name of up to %v characters, a kind, and unique positions from 1",
+	maxCriterionNameLength,
+)
+
+var ErrCriterionNotFound = errors.New("criterion not found")
+
+var ErrInvalidCriterionResponse = errors.New(
+	"RATING criteria need a score from 1 to 5, TAG criteria can't have one, and each criterion can only be answered once",
+)
+
+func (s *srv) GetCriteriaByFilter(
+	ctx context.Context, request GetCriteriaByFilterRequest,
+) ([]Criterion, error) {
+	criteria, err := getPostCriteriaByFilter(ctx, s.db, getPostCriteriaByFilterParams{
+		PostIDs: request.PostIDs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting criteria: %w", err)
+	}
+
+	res := []Criterion{}
+	for _, c := range criteria {
+		res = append(res, Criterion{
+			ID:       c.ID,
+			PostID:   c.PostID,
+			Name:     c.Name,
+			Kind:     c.Kind,
+			Position: c.Position,
+		})
+	}
+	return res, nil
+}
+
+func (s *srv) GetCriterionResultsByFilter(
+	ctx context.Context, request GetCriterionResultsByFilterRequest,
+) ([]CriterionResult, error) {
+	if len(request.PostIDs) == 0 {
+		return []CriterionResult{}, nil
+	}
+	criteria, err := getPostCriteriaByFilter(ctx, s.db, getPostCriteriaByFilterParams{
+		PostIDs: request.PostIDs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting criteria: %w", err)
+	}
+	kinds := map[uuid.UUID]CriterionKind{}
+	for _, c := range criteria {
+		kinds[c.ID] = c.Kind
+	}
+
+	optionResults, err := getPostCriterionOptionResults(ctx, s.db, request.PostIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting criterion results: %w", err)
+	}
+
+	res := []CriterionResult{}
+	for _, r := range optionResults {
+		result := CriterionResult{
+			PostID:        r.PostID,
+			CriterionID:   r.PostCriterionID,
+			OptionID:      r.PostOptionID,
+			ResponseCount: r.ResponseCount,
+		}
+		switch kinds[r.PostCriterionID] {
+		case CriterionKindRating:
+			result.MeanScore = r.MeanScore
+		case CriterionKindTag:
+			tagPercentage := 0.0
+			if r.OptionVoteCount > 0 {
+				tagPercentage = float64(r.ResponseCount) / float64(r.OptionVoteCount) * 100
+			}
+			result.TagPercentage = &tagPercentage
+		}
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+// criteriaToReplace validates the criteria requested for the post, returning
+// the rows to replace its criteria with.
+func criteriaToReplace(
+	postID uuid.UUID, request []*UpsertPostCriterionRequest,
+) ([]postCriterion, error) {
+	if len(request) > maxCriteria {
+		return nil, ErrTooManyCriteria
+	}
+	names := map[string]bool{}
+	positions := map[int]bool{}
+	criteria := []postCriterion{}
+	for _, c := range request {
+		name := strings.TrimSpace(c.Name)
+		key := strings.ToLower(name)
+		if name == "" || utf8.RuneCountInString(name) > maxCriterionNameLength || names[key] {
+			return nil, ErrInvalidCriterion
+		}
+		if c.Kind != CriterionKindRating && c.Kind != CriterionKindTag {
+			return nil, ErrInvalidCriterion
+		}
+		if c.Position < 1 || c.Position > len(request) || positions[c.Position] {
+			return nil, ErrInvalidCriterion
+		}
+		names[key] = true
+		positions[c.Position] = true
+		criteria = append(criteria, postCriterion{
+			ID:       c.ID,
+			PostID:   postID,
+			Name:     name,
+			Kind:     c.Kind,
+			Position: c.Position,
+		})
+	}
+	return criteria, nil
+}
+
+// replaceCriterionResponses checks the voter's responses are to the post's
+// criteria, and swaps them in for the vote's earlier responses.
+func replaceCriterionResponses(
+	ctx context.Context,
+	q database.Q,
+	postID uuid.UUID,
+	voteID uuid.UUID,
+	optionID uuid.UUID,
+	responses []CriterionResponse,
+) error {
+	criteria, err := getPostCriteriaByFilter(ctx, q, getPostCriteriaByFilterParams{
+		PostIDs: []uuid.UUID{postID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return fmt.Errorf("getting criteria: %w", err)
+	}
+	kinds := map[uuid.UUID]CriterionKind{}
+	for _, c := range criteria {
+		kinds[c.ID] = c.Kind
+	}
+
+	answered := map[uuid.UUID]bool{}
+	responsesToInsert := []postVoteCriterionResponse{}
+	for _, r := range responses {
+		kind, ok := kinds[r.CriterionID]
+		if !ok {
+			return ErrCriterionNotFound
+		}
+		if answered[r.CriterionID] {
+			return ErrInvalidCriterionResponse
+		}
+		answered[r.CriterionID] = true
+		switch kind {
+		case CriterionKindRating:
+			if r.Score == nil || *r.Score < 1 || *r.Score > 5 {
+				return ErrInvalidCriterionResponse
+			}
+		case CriterionKindTag:
+			if r.Score != nil {
+				return ErrInvalidCriterionResponse
+			}
+		}
+		responsesToInsert = append(responsesToInsert, postVoteCriterionResponse{
+			PostVoteID:      voteID,
+			PostID:          postID,
+			PostCriterionID: r.CriterionID,
+			PostOptionID:    optionID,
+			Score:           r.Score,
+		})
+	}
+
+	if err = replacePostVoteCriterionResponses(ctx, q, voteID, responsesToInsert); err != nil {
+		return fmt.Errorf("replacing criterion responses: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package srvpost
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"quorum-api/database"
 	"time"
@@ -12,8 +13,9 @@ import (
 type DBLock string
 
 const (
-	DBLockUnspecified DBLock = ""
-	DBLockForUpdate   DBLock = "for update"
+	DBLockUnspecified         DBLock = ""
+	DBLockForUpdate           DBLock = "for update"
+	DBLockForUpdateSkipLocked DBLock = "for update skip locked"
 )
 
 type PostCategory string
@@ -36,22 +38,177 @@ const (
 	DesignPhaseHiFi      DesignPhase = "HI_FI"
 )
 
+type ResultsVisibility string
+
+const (
+	// Voters are never revealed, only tallies
+	ResultsVisibilityAnonymous ResultsVisibility = "ANONYMOUS"
+	// Voters are revealed to the author, and to everyone once the post closes
+	ResultsVisibilityAttributed ResultsVisibility = "ATTRIBUTED"
+)
+
+type PostVisibility string
+
+const (
+	// Shown in the feed to everyone
+	PostVisibilityPublic PostVisibility = "PUBLIC"
+	// Only members of the post's workspace can see or vote on it
+	PostVisibilityWorkspace PostVisibility = "WORKSPACE"
+	// Left out of the feed, anyone with a link can see it
+	PostVisibilityUnlisted PostVisibility = "UNLISTED"
+)
+
+type VotingMode string
+
+const (
+	// Voters pick one option
+	VotingModeSingle VotingMode = "SINGLE"
+	// Voters pick as many options as they like, up to the post's max
+	// selections
+	VotingModeMultiSelect VotingMode = "MULTI_SELECT"
+	// Voters rank options, tallied by instant-runoff
+	VotingModeRanked VotingMode = "RANKED"
+)
+
+type FeedbackMode string
+
+const (
+	// Voters only vote
+	FeedbackModeNone FeedbackMode = "NONE"
+	// Voters score each option from 1 to 5
+	FeedbackModeRating FeedbackMode = "RATING"
+	// Voters pick the better of random pairs of options
+	FeedbackModePairwise FeedbackMode = "PAIRWISE"
+)
+
+type ProcessingStatus string
+
+const (
+	// Waiting for renditions to be made from the upload
+	ProcessingStatusPending   ProcessingStatus = "PENDING"
+	ProcessingStatusProcessed ProcessingStatus = "PROCESSED"
+	// Gave up, either the upload isn't a supported image or processing failed
+	// too many times
+	ProcessingStatusFailed ProcessingStatus = "FAILED"
+)
+
+type MediaType string
+
+const (
+	// JPEG, PNG, GIF or WebP
+	MediaTypeImage MediaType = "IMAGE"
+	// SVG, served sanitised
+	MediaTypeVector MediaType = "VECTOR"
+	// PDF, with a preview of the first page
+	MediaTypeDocument MediaType = "DOCUMENT"
+	// MP4, WebM or QuickTime, with a poster frame
+	MediaTypeVideo MediaType = "VIDEO"
+)
+
+type ImageSize string
+
+const (
+	ImageSizeThumb  ImageSize = "THUMB"
+	ImageSizeMedium ImageSize = "MEDIUM"
+	ImageSizeFull   ImageSize = "FULL"
+)
+
+type CriterionKind string
+
+const (
+	// Voters score the option on the criterion from 1 to 5
+	CriterionKindRating CriterionKind = "RATING"
+	// Voters tag the option when the criterion applies to it
+	CriterionKindTag CriterionKind = "TAG"
+)
+
+type PostStatus string
+
+const (
+	PostStatusDraft  PostStatus = "DRAFT"
+	PostStatusLive   PostStatus = "LIVE"
+	PostStatusClosed PostStatus = "CLOSED"
+)
+
+type PostOrder string
+
+const (
+	PostOrderOpensAtDesc   PostOrder = "OPENS_AT_DESC"
+	PostOrderCreatedAtDesc PostOrder = "CREATED_AT_DESC"
+	PostOrderClosesAtAsc   PostOrder = "CLOSES_AT_ASC"
+)
+
+// postStatusExpr mirrors the status worked out by the graph so the feed can
+// filter on it.
+const postStatusExpr = `case
+	when post.opens_at is null or post.closes_at is null or post.opens_at > now() then 'DRAFT'
+	when post.closes_at > now() then 'LIVE'
+	else 'CLOSED'
+end`
+
+type postOrderClause struct {
+	sortExpr  string
+	direction string
+	operator  string
+}
+
+var postOrderClauses = map[PostOrder]postOrderClause{
+	PostOrderOpensAtDesc: {
+		sortExpr:  "coalesce(post.opens_at, '-infinity'::timestamptz)",
+		direction: "desc",
+		operator:  "<",
+	},
+	PostOrderCreatedAtDesc: {
+		sortExpr:  "post.created_at",
+		direction: "desc",
+		operator:  "<",
+	},
+	PostOrderClosesAtAsc: {
+		sortExpr:  "coalesce(post.closes_at, 'infinity'::timestamptz)",
+		direction: "asc",
+		operator:  ">",
+	},
+}
+
 type getPostsByFilterParams struct {
-	IDs database.UUIDSlice
+	IDs            database.UUIDSlice
+	AuthorIDs      database.UUIDSlice
+	Categories     []string
+	DesignPhases   []string
+	Statuses       []string
+	DraftsAuthorID uuid.NullUUID
+	WorkspaceIDs   database.UUIDSlice
+	Viewer         *postViewerParams
+	OrderBy        PostOrder
+	After          *PostCursor
+	Limit          int
+}
+
+type postViewerParams struct {
+	CustomerID   uuid.NullUUID
+	WorkspaceIDs database.UUIDSlice
 }
 
 type post struct {
-	ID          uuid.UUID          `db:"id"`
-	DesignPhase *DesignPhase       `db:"design_phase"`
-	Context     *string            `db:"context"`
-	Category    *PostCategory      `db:"category"`
-	OpensAt     *time.Time         `db:"opens_at"`
-	ClosesAt    *time.Time         `db:"closes_at"`
-	AuthorID    uuid.UUID          `db:"author_id"`
-	OptionIDs   database.UUIDSlice `db:"option_ids"`
-	VoteIDs     database.UUIDSlice `db:"vote_ids"`
-	CreatedAt   time.Time          `db:"created_at"`
-	UpdatedAt   time.Time          `db:"updated_at"`
+	ID                uuid.UUID          `db:"id"`
+	DesignPhase       *DesignPhase       `db:"design_phase"`
+	Context           *string            `db:"context"`
+	Category          *PostCategory      `db:"category"`
+	OpensAt           *time.Time         `db:"opens_at"`
+	ClosesAt          *time.Time         `db:"closes_at"`
+	AuthorID          uuid.UUID          `db:"author_id"`
+	OptionIDs         database.UUIDSlice `db:"option_ids"`
+	ResultsVisibility ResultsVisibility  `db:"results_visibility"`
+	AllowVoteChanges  bool               `db:"allow_vote_changes"`
+	Visibility        PostVisibility     `db:"visibility"`
+	WorkspaceID       *uuid.UUID         `db:"workspace_id"`
+	Restricted        bool               `db:"restricted"`
+	VotingMode        VotingMode         `db:"voting_mode"`
+	MaxSelections     *int               `db:"max_selections"`
+	FeedbackMode      FeedbackMode       `db:"feedback_mode"`
+	VoteIDs           database.UUIDSlice `db:"vote_ids"`
+	CreatedAt         time.Time          `db:"created_at"`
+	UpdatedAt         time.Time          `db:"updated_at"`
 }
 
 func getPostsByFilter(
@@ -65,17 +222,32 @@ func getPostsByFilter(
 		select
 			post.id,
 			post.author_id,
+			post.design_phase,
 			post.context,
 			post.category,
 			post.created_at,
 			post.updated_at,
 			post.opens_at,
 			post.closes_at,
-			array_agg(po.id) filter (where po.id is not null) option_ids,
-			array_agg(pv.id) filter (where pv.id is not null) vote_ids
+			post.results_visibility,
+			post.allow_vote_changes,
+			post.visibility,
+			post.workspace_id,
+			post.restricted,
+			post.voting_mode,
+			post.max_selections,
+			post.feedback_mode,
+			array(
+				select po.id from post_option po
+				where po.post_id = post.id
+				order by po.position
+			) option_ids,
+			array(
+				select pv.id from post_vote pv
+				where pv.post_id = post.id
+				order by pv.created_at
+			) vote_ids
 		from post
-		left join post_option po on po.post_id = post.id
-		left join post_vote pv on pv.post_id = post.id
 		where true
 	`
 
@@ -84,19 +256,73 @@ func getPostsByFilter(
 		args = append(args, params.IDs)
 		query = fmt.Sprintf("%s and post.id = any($%v)", query, len(args))
 	}
+	if len(params.AuthorIDs) > 0 {
+		args = append(args, params.AuthorIDs)
+		query = fmt.Sprintf("%s and post.author_id = any($%v)", query, len(args))
+	}
+	if len(params.Categories) > 0 {
+		args = append(args, params.Categories)
+		query = fmt.Sprintf("%s and post.category = any($%v::post_category[])", query, len(args))
+	}
+	if len(params.DesignPhases) > 0 {
+		args = append(args, params.DesignPhases)
+		query = fmt.Sprintf("%s and post.design_phase = any($%v::design_phase[])", query, len(args))
+	}
+	if len(params.Statuses) > 0 {
+		args = append(args, params.Statuses)
+		query = fmt.Sprintf("%s and (%s) = any($%v)", query, postStatusExpr, len(args))
+	}
+	if len(params.WorkspaceIDs) > 0 {
+		args = append(args, params.WorkspaceIDs)
+		query = fmt.Sprintf("%s and post.workspace_id = any($%v)", query, len(args))
+	}
+	if params.Viewer != nil {
+		// Unlisted posts can be seen with a link, but aren't listed
+		unlisted := "false"
+		if len(params.IDs) > 0 {
+			unlisted = "post.visibility = 'UNLISTED'"
+		}
+		args = append(args, params.Viewer.CustomerID, params.Viewer.WorkspaceIDs)
+		query = fmt.Sprintf(`%s and (
+			post.author_id = $%v::uuid
+			or post.visibility = 'PUBLIC'
+			or (post.visibility = 'WORKSPACE' and post.workspace_id = any($%v::uuid[]))
+			or %s
+		)`, query, len(args)-1, len(args), unlisted)
+	}
+	if params.DraftsAuthorID.Valid {
+		args = append(args, params.DraftsAuthorID.UUID)
+		query = fmt.Sprintf(
+			"%s and ((%s) <> 'DRAFT' or post.author_id = $%v)",
+			query, postStatusExpr, len(args),
+		)
+	}
 
-	query = fmt.Sprintf(`%s
-		group by
-			post.id,
-			post.author_id,
-			post.context,
-			post.category,
-			post.created_at,
-			post.updated_at,
-			post.opens_at,
-			post.closes_at
-		order by post.opens_at desc`,
-		query)
+	orderBy := params.OrderBy
+	if orderBy == "" {
+		orderBy = PostOrderOpensAtDesc
+	}
+	orderClause, ok := postOrderClauses[orderBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown post order %q", orderBy)
+	}
+	if params.After != nil {
+		args = append(args, params.After.SortValue, params.After.ID)
+		query = fmt.Sprintf(
+			"%s and (%s, post.id) %s ($%v::timestamptz, $%v)",
+			query, orderClause.sortExpr, orderClause.operator, len(args)-1, len(args),
+		)
+	}
+
+	query = fmt.Sprintf(
+		"%s order by %s %s, post.id %s",
+		query, orderClause.sortExpr, orderClause.direction, orderClause.direction,
+	)
+
+	if params.Limit > 0 {
+		args = append(args, params.Limit)
+		query = fmt.Sprintf("%s limit $%v", query, len(args))
+	}
 
 	if dbLock != DBLockUnspecified {
 		query = fmt.Sprintf(`
@@ -113,15 +339,24 @@ func getPostsByFilter(
 }
 
 type getPostOptionsByFilterParams struct {
-	IDs     database.UUIDSlice
-	PostIDs database.UUIDSlice
+	IDs      database.UUIDSlice
+	PostIDs  database.UUIDSlice
+	FileRefs []string
 }
 
 type postOption struct {
-	ID       uuid.UUID `db:"id"`
-	PostID   uuid.UUID `db:"post_id"`
-	Position int       `db:"position"`
-	FileRef  string    `db:"file_ref"`
+	ID                 uuid.UUID        `db:"id"`
+	PostID             uuid.UUID        `db:"post_id"`
+	Position           int              `db:"position"`
+	FileRef            string           `db:"file_ref"`
+	MediaType          MediaType        `db:"media_type"`
+	ProcessingStatus   ProcessingStatus `db:"processing_status"`
+	ProcessingAttempts int              `db:"processing_attempts"`
+	NextProcessingAt   time.Time        `db:"next_processing_at"`
+	ProcessingError    *string          `db:"processing_error"`
+	Width              *int             `db:"width"`
+	Height             *int             `db:"height"`
+	Blurhash           *string          `db:"blurhash"`
 }
 
 func getPostOptionsByFilter(
@@ -136,7 +371,15 @@ func getPostOptionsByFilter(
 			id,
 			post_id,
 			position,
-			file_ref
+			file_ref,
+			media_type,
+			processing_status,
+			processing_attempts,
+			next_processing_at,
+			processing_error,
+			width,
+			height,
+			blurhash
 		from post_option
 		where true
 	`
@@ -147,9 +390,13 @@ func getPostOptionsByFilter(
 		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
 	}
 	if len(params.PostIDs) > 0 {
-		args = append(args, params.IDs)
+		args = append(args, params.PostIDs)
 		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
 	}
+	if len(params.FileRefs) > 0 {
+		args = append(args, params.FileRefs)
+		query = fmt.Sprintf("%s and file_ref = any($%v)", query, len(args))
+	}
 
 	query = fmt.Sprintf(`%s
 		order by post_id, position
@@ -163,15 +410,19 @@ func getPostOptionsByFilter(
 }
 
 type getPostVotesByFilterParams struct {
-	IDs database.UUIDSlice
+	IDs         database.UUIDSlice
+	PostIDs     database.UUIDSlice
+	CustomerIDs database.UUIDSlice
 }
 
 type postVote struct {
-	ID           uuid.UUID `db:"id"`
-	PostID       uuid.UUID `db:"post_id"`
-	CustomerID   uuid.UUID `db:"voter_id"`
-	PostOptionID uuid.UUID `db:"post_option_id"`
-	Reason       *string   `db:"reason"`
+	ID           uuid.UUID     `db:"id"`
+	PostID       uuid.UUID     `db:"post_id"`
+	CustomerID   uuid.NullUUID `db:"customer_id"`
+	PostOptionID uuid.UUID     `db:"post_option_id"`
+	// Every option picked, in rank order
+	PostOptionIDs database.UUIDSlice `db:"post_option_ids"`
+	Reason        *string            `db:"reason"`
 }
 
 func getPostVotesByFilter(
@@ -185,8 +436,13 @@ func getPostVotesByFilter(
 		select
 			id,
 			post_id,
-			voter_id,
+			customer_id,
 			post_option_id,
+			array(
+				select pvs.post_option_id from post_vote_selection pvs
+				where pvs.post_vote_id = post_vote.id
+				order by pvs.rank
+			) post_option_ids,
 			reason
 		from post_vote
 		where true
@@ -197,6 +453,14 @@ func getPostVotesByFilter(
 		args = append(args, params.IDs)
 		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
 	}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
 
 	query = fmt.Sprintf("%s %s", query, dbLock)
 
@@ -207,14 +471,66 @@ func getPostVotesByFilter(
 	return postVote, nil
 }
 
+type getPostOptionVoteCountsParams struct {
+	PostIDs database.UUIDSlice
+}
+
+type postOptionVoteCount struct {
+	PostID       uuid.UUID  `db:"post_id"`
+	VotingMode   VotingMode `db:"voting_mode"`
+	PostOptionID uuid.UUID  `db:"post_option_id"`
+	VoteCount    int        `db:"vote_count"`
+	// Ballots cast on the post, the same for each of its options
+	BallotCount int `db:"ballot_count"`
+}
+
+func getPostOptionVoteCounts(
+	ctx context.Context,
+	db database.Q,
+	params getPostOptionVoteCountsParams,
+) ([]postOptionVoteCount, error) {
+	voteCounts := []postOptionVoteCount{}
+	if err := db.SelectContext(ctx, &voteCounts, `
+		select
+			po.post_id,
+			post.voting_mode,
+			po.id post_option_id,
+			count(pvs.post_vote_id) vote_count,
+			(
+				select count(*) from post_vote pv
+				where pv.post_id = po.post_id
+			) ballot_count
+		from post_option po
+		join post on post.id = po.post_id
+		-- Every pick counts on MULTI_SELECT posts, otherwise only first
+		-- choices do
+		left join post_vote_selection pvs on pvs.post_option_id = po.id
+			and (post.voting_mode = 'MULTI_SELECT' or pvs.rank = 1)
+		where po.post_id = any($1)
+		group by po.post_id, post.voting_mode, po.id, po.position
+		order by po.post_id, po.position
+	`, params.PostIDs); err != nil {
+		return nil, fmt.Errorf("selecting post_option vote counts: %w", err)
+	}
+	return voteCounts, nil
+}
+
 type upsertPostParams struct {
-	ID          uuid.UUID     `db:"id"`
-	AuthorID    uuid.UUID     `db:"author_id"`
-	DesignPhase *DesignPhase  `db:"design_phase"`
-	Context     *string       `db:"context"`
-	Category    *PostCategory `db:"category"`
-	OpensAt     *time.Time    `db:"opens_at"`
-	ClosesAt    *time.Time    `db:"closes_at"`
+	ID                uuid.UUID         `db:"id"`
+	AuthorID          uuid.UUID         `db:"author_id"`
+	DesignPhase       *DesignPhase      `db:"design_phase"`
+	Context           *string           `db:"context"`
+	Category          *PostCategory     `db:"category"`
+	OpensAt           *time.Time        `db:"opens_at"`
+	ClosesAt          *time.Time        `db:"closes_at"`
+	ResultsVisibility ResultsVisibility `db:"results_visibility"`
+	AllowVoteChanges  bool              `db:"allow_vote_changes"`
+	Visibility        PostVisibility    `db:"visibility"`
+	WorkspaceID       *uuid.UUID        `db:"workspace_id"`
+	Restricted        bool              `db:"restricted"`
+	VotingMode        VotingMode        `db:"voting_mode"`
+	MaxSelections     *int              `db:"max_selections"`
+	FeedbackMode      FeedbackMode      `db:"feedback_mode"`
 }
 
 func upsertPost(
@@ -230,7 +546,15 @@ func upsertPost(
 			context,
 			category,
 			opens_at,
-			closes_at
+			closes_at,
+			results_visibility,
+			allow_vote_changes,
+			visibility,
+			workspace_id,
+			restricted,
+			voting_mode,
+			max_selections,
+			feedback_mode
 		) values (
 			:id,
 			:author_id,
@@ -238,14 +562,30 @@ func upsertPost(
 			:context,
 			:category,
 			:opens_at,
-			:closes_at
+			:closes_at,
+			:results_visibility,
+			:allow_vote_changes,
+			:visibility,
+			:workspace_id,
+			:restricted,
+			:voting_mode,
+			:max_selections,
+			:feedback_mode
 		) on conflict (id) do update set
 			updated_at = now(),
 			design_phase = excluded.design_phase,
 			context = excluded.context,
 			category = excluded.category,
 			opens_at = excluded.opens_at,
-			closes_at = excluded.closes_at
+			closes_at = excluded.closes_at,
+			results_visibility = excluded.results_visibility,
+			allow_vote_changes = excluded.allow_vote_changes,
+			visibility = excluded.visibility,
+			workspace_id = excluded.workspace_id,
+			restricted = excluded.restricted,
+			voting_mode = excluded.voting_mode,
+			max_selections = excluded.max_selections,
+			feedback_mode = excluded.feedback_mode
 	`, params); err != nil {
 		return fmt.Errorf("inserting post: %w", err)
 	}
@@ -257,6 +597,11 @@ func deletePostOptions(
 	db database.Q,
 	postOptionIDs database.UUIDSlice,
 ) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_option_rendition where post_option_id = any($1)
+		`, postOptionIDs); err != nil {
+		return fmt.Errorf("deleting from post_option_rendition: %w", err)
+	}
 	if _, err := db.ExecContext(ctx, `
 		delete from post_option where id = any($1)
 		`, postOptionIDs); err != nil {
@@ -275,12 +620,14 @@ func insertPostOptions(
 			id,
 			post_id,
 			position,
-			file_ref
+			file_ref,
+			media_type
 		) values (
 			:id,
 			:post_id,
 			:position,
-			:file_ref
+			:file_ref,
+			:media_type
 		)
 	`, postOptions); err != nil {
 		return fmt.Errorf("inserting post: %w", err)
@@ -321,19 +668,21 @@ func updatePostOptionPositions(
 }
 
 type insertPostVoteParams struct {
-	id           uuid.UUID `db:"id"`
-	postOptionID uuid.UUID `db:"post_option_id"`
-	postID       uuid.UUID `db:"post_id"`
-	customerID   uuid.UUID `db:"customer_id"`
-	reason       *string   `db:"reason"`
+	ID           uuid.UUID `db:"id"`
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	PostID       uuid.UUID `db:"post_id"`
+	CustomerID   uuid.UUID `db:"customer_id"`
+	Reason       *string   `db:"reason"`
 }
 
+var errPostVoteExists = errors.New("customer has already voted on post")
+
 func insertPostVote(
 	ctx context.Context,
 	db database.Q,
 	params insertPostVoteParams,
 ) error {
-	if _, err := db.NamedExecContext(ctx, `
+	res, err := db.NamedExecContext(ctx, `
 		insert into post_vote (
 			id,
 			post_option_id,
@@ -346,9 +695,1125 @@ func insertPostVote(
 			:post_id,
 			:customer_id,
 			:reason
-		) on conflict do nothing
+		) on conflict (post_id, customer_id) do nothing
+	`, params)
+	if err != nil {
+		return fmt.Errorf("inserting post_vote: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errPostVoteExists
+	}
+	return nil
+}
+
+type updatePostVoteParams struct {
+	ID           uuid.UUID `db:"id"`
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	Reason       *string   `db:"reason"`
+}
+
+func updatePostVote(
+	ctx context.Context,
+	db database.Q,
+	params updatePostVoteParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		update post_vote set
+			post_option_id = :post_option_id,
+			reason = :reason,
+			updated_at = now()
+		where id = :id
+	`, params); err != nil {
+		return fmt.Errorf("updating post_vote: %w", err)
+	}
+	return nil
+}
+
+func deletePostVote(
+	ctx context.Context,
+	db database.Q,
+	id uuid.UUID,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_vote_selection where post_vote_id = $1
+	`, id); err != nil {
+		return fmt.Errorf("deleting from post_vote_selection: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		delete from post_vote_criterion_response where post_vote_id = $1
+	`, id); err != nil {
+		return fmt.Errorf("deleting from post_vote_criterion_response: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		delete from post_vote where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("deleting from post_vote: %w", err)
+	}
+	return nil
+}
+
+type postVoteSelection struct {
+	PostVoteID   uuid.UUID `db:"post_vote_id"`
+	PostID       uuid.UUID `db:"post_id"`
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	Rank         int       `db:"rank"`
+}
+
+// replacePostVoteSelections swaps the options picked on the ballot for
+// selections, which must all be for the vote.
+func replacePostVoteSelections(
+	ctx context.Context,
+	db database.Q,
+	voteID uuid.UUID,
+	selections []postVoteSelection,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_vote_selection where post_vote_id = $1
+	`, voteID); err != nil {
+		return fmt.Errorf("deleting from post_vote_selection: %w", err)
+	}
+	if len(selections) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_vote_selection (
+			post_vote_id,
+			post_id,
+			post_option_id,
+			rank
+		) values (
+			:post_vote_id,
+			:post_id,
+			:post_option_id,
+			:rank
+		)
+	`, selections); err != nil {
+		return fmt.Errorf("inserting post_vote_selection: %w", err)
+	}
+	return nil
+}
+
+// getPostVoteSelections returns every pick on the posts' ballots, grouped by
+// ballot in rank order.
+func getPostVoteSelections(
+	ctx context.Context,
+	db database.Q,
+	postIDs database.UUIDSlice,
+) ([]postVoteSelection, error) {
+	selections := []postVoteSelection{}
+	if err := db.SelectContext(ctx, &selections, `
+		select
+			post_vote_id,
+			post_id,
+			post_option_id,
+			rank
+		from post_vote_selection
+		where post_id = any($1)
+		order by post_id, post_vote_id, rank
+	`, postIDs); err != nil {
+		return nil, fmt.Errorf("selecting post_vote_selection: %w", err)
+	}
+	return selections, nil
+}
+
+type postVoteAction string
+
+const (
+	postVoteActionCast      postVoteAction = "CAST"
+	postVoteActionChanged   postVoteAction = "CHANGED"
+	postVoteActionRetracted postVoteAction = "RETRACTED"
+)
+
+type insertPostVoteEventParams struct {
+	ID           uuid.UUID      `db:"id"`
+	PostID       uuid.UUID      `db:"post_id"`
+	CustomerID   uuid.UUID      `db:"customer_id"`
+	PostOptionID *uuid.UUID     `db:"post_option_id"`
+	Action       postVoteAction `db:"action"`
+	Reason       *string        `db:"reason"`
+}
+
+func insertPostVoteEvent(
+	ctx context.Context,
+	db database.Q,
+	params insertPostVoteEventParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_vote_event (
+			id,
+			post_id,
+			customer_id,
+			post_option_id,
+			action,
+			reason
+		) values (
+			:id,
+			:post_id,
+			:customer_id,
+			:post_option_id,
+			:action,
+			:reason
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting post_vote_event: %w", err)
+	}
+	return nil
+}
+
+type postStatusChange struct {
+	ID             uuid.UUID  `db:"id"`
+	AuthorID       uuid.UUID  `db:"author_id"`
+	RecordedStatus PostStatus `db:"recorded_status"`
+	Status         PostStatus `db:"status"`
+}
+
+type getPostStatusChangesParams struct {
+	IDs   database.UUIDSlice
+	Limit int
+}
+
+// getPostStatusChanges returns posts whose status has changed since it was
+// last recorded. Posts that have failed to transition before come last, so
+// they can't hold up the rest.
+func getPostStatusChanges(
+	ctx context.Context,
+	db database.Q,
+	params getPostStatusChangesParams,
+	dbLock DBLock,
+) ([]postStatusChange, error) {
+	changes := []postStatusChange{}
+	query := fmt.Sprintf(`
+		select
+			post.id,
+			post.author_id,
+			post.recorded_status,
+			(%s) status
+		from post
+		where post.recorded_status <> 'CLOSED'
+		and post.recorded_status::text <> (%s)
+	`, postStatusExpr, postStatusExpr)
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and post.id = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf(
+		"%s order by post.status_transition_attempts, post.opens_at nulls last, post.id",
+		query,
+	)
+
+	if params.Limit > 0 {
+		args = append(args, params.Limit)
+		query = fmt.Sprintf("%s limit $%v", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &changes, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post status changes: %w", err)
+	}
+	return changes, nil
+}
+
+type insertPostStatusTransitionParams struct {
+	ID         uuid.UUID  `db:"id"`
+	PostID     uuid.UUID  `db:"post_id"`
+	FromStatus PostStatus `db:"from_status"`
+	ToStatus   PostStatus `db:"to_status"`
+}
+
+func insertPostStatusTransition(
+	ctx context.Context,
+	db database.Q,
+	params insertPostStatusTransitionParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_status_transition (
+			id,
+			post_id,
+			from_status,
+			to_status
+		) values (
+			:id,
+			:post_id,
+			:from_status,
+			:to_status
+		)
 	`, params); err != nil {
-		return err
+		return fmt.Errorf("inserting post_status_transition: %w", err)
+	}
+	return nil
+}
+
+func updatePostRecordedStatus(
+	ctx context.Context,
+	db database.Q,
+	postID uuid.UUID,
+	status PostStatus,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update post set recorded_status = $2, status_transition_attempts = 0 where id = $1
+	`, postID, status); err != nil {
+		return fmt.Errorf("updating post: %w", err)
+	}
+	return nil
+}
+
+func incrementPostStatusTransitionAttempts(
+	ctx context.Context,
+	db database.Q,
+	postID uuid.UUID,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update post set status_transition_attempts = status_transition_attempts + 1 where id = $1
+	`, postID); err != nil {
+		return fmt.Errorf("updating post: %w", err)
+	}
+	return nil
+}
+
+// anonymisePostVotes unlinks the customer from their votes and vote history,
+// leaving the votes to count towards results.
+func anonymisePostVotes(
+	ctx context.Context, q database.Q, customerID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update post_vote set customer_id = null, updated_at = now()
+		where customer_id = $1
+	`, customerID); err != nil {
+		return fmt.Errorf("updating post_vote: %w", err)
+	}
+	if _, err := q.ExecContext(ctx, `
+		update post_vote_event set customer_id = null where customer_id = $1
+	`, customerID); err != nil {
+		return fmt.Errorf("updating post_vote_event: %w", err)
+	}
+	return nil
+}
+
+// deleteDraftPosts deletes every post by the author that hasn't opened yet,
+// along with anything that references them.
+func deleteDraftPosts(
+	ctx context.Context, q database.Q, authorID uuid.UUID,
+) error {
+	postIDs := database.UUIDSlice{}
+	if err := q.SelectContext(ctx, &postIDs, fmt.Sprintf(`
+		select id from post
+		where author_id = $1 and (%s) = 'DRAFT'
+		for update
+	`, postStatusExpr), authorID); err != nil {
+		return fmt.Errorf("selecting draft posts: %w", err)
+	}
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	for _, table := range []string{
+		"post_invite_redemption",
+		"post_invite",
+		"post_option_rating",
+		"post_matchup",
+		"post_vote_event",
+		"post_vote_criterion_response",
+		"post_criterion",
+		"post_vote_selection",
+		"post_vote",
+		"post_status_transition",
+		"comment",
+		"post_option_rendition",
+		"post_option",
+	} {
+		if _, err := q.ExecContext(ctx, fmt.Sprintf(
+			"delete from %s where post_id = any($1)", table,
+		), postIDs); err != nil {
+			return fmt.Errorf("deleting from %s: %w", table, err)
+		}
+	}
+	if _, err := q.ExecContext(ctx, `
+		delete from post where id = any($1)
+	`, postIDs); err != nil {
+		return fmt.Errorf("deleting from post: %w", err)
+	}
+	return nil
+}
+
+// closeLivePosts closes every post by the author that's open for voting.
+func closeLivePosts(
+	ctx context.Context, q database.Q, authorID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, fmt.Sprintf(`
+		update post set closes_at = now(), updated_at = now()
+		where author_id = $1 and (%s) = 'LIVE'
+	`, postStatusExpr), authorID); err != nil {
+		return fmt.Errorf("updating post: %w", err)
+	}
+	return nil
+}
+
+type getPostInvitesByFilterParams struct {
+	IDs        database.UUIDSlice
+	PostIDs    database.UUIDSlice
+	TokenHash  []byte
+	ActiveOnly bool
+}
+
+type postInvite struct {
+	ID        uuid.UUID  `db:"id"`
+	PostID    uuid.UUID  `db:"post_id"`
+	Email     *string    `db:"email"`
+	MaxUses   *int       `db:"max_uses"`
+	UseCount  int        `db:"use_count"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func getPostInvitesByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getPostInvitesByFilterParams,
+	dbLock DBLock,
+) ([]postInvite, error) {
+	invites := []postInvite{}
+	query := `
+		select
+			id,
+			post_id,
+			email,
+			max_uses,
+			use_count,
+			expires_at,
+			revoked_at,
+			created_at
+		from post_invite
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+	if params.TokenHash != nil {
+		args = append(args, params.TokenHash)
+		query = fmt.Sprintf("%s and token_hash = $%v", query, len(args))
+	}
+	if params.ActiveOnly {
+		query = fmt.Sprintf(`%s
+			and revoked_at is null
+			and expires_at > now()
+			and (max_uses is null or use_count < max_uses)
+		`, query)
+	}
+
+	query = fmt.Sprintf("%s order by created_at desc, id %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &invites, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post invites: %w", err)
+	}
+	return invites, nil
+}
+
+type insertPostInviteParams struct {
+	ID        uuid.UUID `db:"id"`
+	PostID    uuid.UUID `db:"post_id"`
+	Email     *string   `db:"email"`
+	MaxUses   *int      `db:"max_uses"`
+	TokenHash []byte    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+func insertPostInvites(
+	ctx context.Context,
+	db database.Q,
+	params []insertPostInviteParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_invite (
+			id,
+			post_id,
+			email,
+			max_uses,
+			token_hash,
+			expires_at
+		) values (
+			:id,
+			:post_id,
+			:email,
+			:max_uses,
+			:token_hash,
+			:expires_at
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting post_invite: %w", err)
+	}
+	return nil
+}
+
+func revokePostInvite(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update post_invite set revoked_at = now()
+		where id = $1 and revoked_at is null
+	`, id); err != nil {
+		return fmt.Errorf("updating post_invite: %w", err)
+	}
+	return nil
+}
+
+type hasRedeemedPostInviteParams struct {
+	PostID     uuid.UUID
+	CustomerID uuid.UUID
+	// Any of the post's invites when not set
+	InviteID uuid.NullUUID
+}
+
+// hasRedeemedPostInvite reports whether the customer has used an invite to the
+// post that hasn't since been revoked.
+func hasRedeemedPostInvite(
+	ctx context.Context, q database.Q, params hasRedeemedPostInviteParams,
+) (bool, error) {
+	redeemed := false
+	if err := q.GetContext(ctx, &redeemed, `
+		select exists (
+			select 1
+			from post_invite_redemption r
+			join post_invite i on i.id = r.post_invite_id
+			where r.post_id = $1
+				and r.customer_id = $2
+				and ($3::uuid is null or r.post_invite_id = $3)
+				and i.revoked_at is null
+		)
+	`, params.PostID, params.CustomerID, params.InviteID); err != nil {
+		return false, fmt.Errorf("selecting post_invite_redemption: %w", err)
+	}
+	return redeemed, nil
+}
+
+// insertPostInviteRedemption records the customer using the invite, counting
+// towards its max uses.
+func insertPostInviteRedemption(
+	ctx context.Context, q database.Q, invite postInvite, customerID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		insert into post_invite_redemption (post_invite_id, post_id, customer_id)
+		values ($1, $2, $3)
+	`, invite.ID, invite.PostID, customerID); err != nil {
+		return fmt.Errorf("inserting post_invite_redemption: %w", err)
+	}
+	if _, err := q.ExecContext(ctx, `
+		update post_invite set use_count = use_count + 1 where id = $1
+	`, invite.ID); err != nil {
+		return fmt.Errorf("updating post_invite: %w", err)
+	}
+	return nil
+}
+
+func deletePostInviteRedemptions(
+	ctx context.Context, q database.Q, customerID uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		delete from post_invite_redemption where customer_id = $1
+	`, customerID); err != nil {
+		return fmt.Errorf("deleting from post_invite_redemption: %w", err)
+	}
+	return nil
+}
+
+type getPostOptionRatingsByFilterParams struct {
+	PostIDs     database.UUIDSlice
+	CustomerIDs database.UUIDSlice
+}
+
+type postOptionRating struct {
+	ID           uuid.UUID     `db:"id"`
+	PostID       uuid.UUID     `db:"post_id"`
+	PostOptionID uuid.UUID     `db:"post_option_id"`
+	CustomerID   uuid.NullUUID `db:"customer_id"`
+	Score        int           `db:"score"`
+}
+
+func getPostOptionRatingsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getPostOptionRatingsByFilterParams,
+	dbLock DBLock,
+) ([]postOptionRating, error) {
+	ratings := []postOptionRating{}
+	query := `
+		select
+			id,
+			post_id,
+			post_option_id,
+			customer_id,
+			score
+		from post_option_rating
+		where true
+	`
+
+	args := []any{}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &ratings, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post_option_rating: %w", err)
+	}
+	return ratings, nil
+}
+
+type upsertPostOptionRatingParams struct {
+	ID           uuid.UUID `db:"id"`
+	PostID       uuid.UUID `db:"post_id"`
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	CustomerID   uuid.UUID `db:"customer_id"`
+	Score        int       `db:"score"`
+}
+
+func upsertPostOptionRatings(
+	ctx context.Context,
+	db database.Q,
+	ratings []upsertPostOptionRatingParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_option_rating (
+			id,
+			post_id,
+			post_option_id,
+			customer_id,
+			score
+		) values (
+			:id,
+			:post_id,
+			:post_option_id,
+			:customer_id,
+			:score
+		) on conflict (post_option_id, customer_id) do update set
+			score = excluded.score,
+			updated_at = now()
+	`, ratings); err != nil {
+		return fmt.Errorf("upserting post_option_rating: %w", err)
+	}
+	return nil
+}
+
+type postOptionScoreCount struct {
+	PostID       uuid.UUID `db:"post_id"`
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	Score        int       `db:"score"`
+	RatingCount  int       `db:"rating_count"`
+}
+
+func getPostOptionScoreCounts(
+	ctx context.Context,
+	db database.Q,
+	postIDs database.UUIDSlice,
+) ([]postOptionScoreCount, error) {
+	scoreCounts := []postOptionScoreCount{}
+	if err := db.SelectContext(ctx, &scoreCounts, `
+		select
+			post_id,
+			post_option_id,
+			score,
+			count(*) rating_count
+		from post_option_rating
+		where post_id = any($1)
+		group by post_id, post_option_id, score
+	`, postIDs); err != nil {
+		return nil, fmt.Errorf("selecting post_option_rating counts: %w", err)
+	}
+	return scoreCounts, nil
+}
+
+type getPostMatchupsByFilterParams struct {
+	IDs         database.UUIDSlice
+	PostIDs     database.UUIDSlice
+	CustomerIDs database.UUIDSlice
+}
+
+type postMatchup struct {
+	ID             uuid.UUID     `db:"id"`
+	PostID         uuid.UUID     `db:"post_id"`
+	CustomerID     uuid.NullUUID `db:"customer_id"`
+	OptionAID      uuid.UUID     `db:"option_a_id"`
+	OptionBID      uuid.UUID     `db:"option_b_id"`
+	WinnerOptionID *uuid.UUID    `db:"winner_option_id"`
+	CreatedAt      time.Time     `db:"created_at"`
+	AnsweredAt     *time.Time    `db:"answered_at"`
+}
+
+func getPostMatchupsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getPostMatchupsByFilterParams,
+	dbLock DBLock,
+) ([]postMatchup, error) {
+	matchups := []postMatchup{}
+	query := `
+		select
+			id,
+			post_id,
+			customer_id,
+			option_a_id,
+			option_b_id,
+			winner_option_id,
+			created_at,
+			answered_at
+		from post_matchup
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s order by created_at %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &matchups, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post_matchup: %w", err)
+	}
+	return matchups, nil
+}
+
+type insertPostMatchupParams struct {
+	ID         uuid.UUID `db:"id"`
+	PostID     uuid.UUID `db:"post_id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+	OptionAID  uuid.UUID `db:"option_a_id"`
+	OptionBID  uuid.UUID `db:"option_b_id"`
+}
+
+func insertPostMatchup(
+	ctx context.Context,
+	db database.Q,
+	params insertPostMatchupParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_matchup (
+			id,
+			post_id,
+			customer_id,
+			option_a_id,
+			option_b_id
+		) values (
+			:id,
+			:post_id,
+			:customer_id,
+			:option_a_id,
+			:option_b_id
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting post_matchup: %w", err)
+	}
+	return nil
+}
+
+func answerPostMatchup(
+	ctx context.Context,
+	db database.Q,
+	id uuid.UUID,
+	winnerOptionID uuid.UUID,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		update post_matchup set winner_option_id = $2, answered_at = now()
+		where id = $1
+	`, id, winnerOptionID); err != nil {
+		return fmt.Errorf("updating post_matchup: %w", err)
+	}
+	return nil
+}
+
+type postMatchupOutcome struct {
+	PostID         uuid.UUID `db:"post_id"`
+	WinnerOptionID uuid.UUID `db:"winner_option_id"`
+	LoserOptionID  uuid.UUID `db:"loser_option_id"`
+	Wins           int       `db:"wins"`
+}
+
+// getPostMatchupOutcomes counts how often each option beat each other option
+// in answered matchups.
+func getPostMatchupOutcomes(
+	ctx context.Context,
+	db database.Q,
+	postIDs database.UUIDSlice,
+) ([]postMatchupOutcome, error) {
+	outcomes := []postMatchupOutcome{}
+	if err := db.SelectContext(ctx, &outcomes, `
+		select
+			post_id,
+			winner_option_id,
+			case when winner_option_id = option_a_id
+				then option_b_id
+				else option_a_id
+			end loser_option_id,
+			count(*) wins
+		from post_matchup
+		where post_id = any($1) and winner_option_id is not null
+		group by 1, 2, 3
+	`, postIDs); err != nil {
+		return nil, fmt.Errorf("selecting post_matchup outcomes: %w", err)
+	}
+	return outcomes, nil
+}
+
+// anonymisePostFeedback unlinks the customer from their ratings and
+// matchups, leaving them to count towards results.
+func anonymisePostFeedback(
+	ctx context.Context, q database.Q, customerID uuid.UUID,
+) error {
+	for _, table := range []string{"post_option_rating", "post_matchup"} {
+		if _, err := q.ExecContext(ctx, fmt.Sprintf(
+			"update %s set customer_id = null where customer_id = $1", table,
+		), customerID); err != nil {
+			return fmt.Errorf("updating %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+type getPostCriteriaByFilterParams struct {
+	PostIDs database.UUIDSlice
+}
+
+type postCriterion struct {
+	ID       uuid.UUID     `db:"id"`
+	PostID   uuid.UUID     `db:"post_id"`
+	Name     string        `db:"name"`
+	Kind     CriterionKind `db:"kind"`
+	Position int           `db:"position"`
+}
+
+func getPostCriteriaByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getPostCriteriaByFilterParams,
+	dbLock DBLock,
+) ([]postCriterion, error) {
+	criteria := []postCriterion{}
+	query := `
+		select
+			id,
+			post_id,
+			name,
+			kind,
+			position
+		from post_criterion
+		where true
+	`
+
+	args := []any{}
+	if len(params.PostIDs) > 0 {
+		args = append(args, params.PostIDs)
+		query = fmt.Sprintf("%s and post_id = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf(`%s
+		order by post_id, position
+	%s`, query, dbLock)
+
+	if err := db.SelectContext(ctx, &criteria, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post_criterion: %w", err)
+	}
+	return criteria, nil
+}
+
+// replacePostCriteria swaps the post's criteria for criteria. Only safe
+// before the post opens, while there are no responses to them.
+func replacePostCriteria(
+	ctx context.Context,
+	db database.Q,
+	postID uuid.UUID,
+	criteria []postCriterion,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_criterion where post_id = $1
+	`, postID); err != nil {
+		return fmt.Errorf("deleting from post_criterion: %w", err)
+	}
+	if len(criteria) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_criterion (
+			id,
+			post_id,
+			name,
+			kind,
+			position
+		) values (
+			:id,
+			:post_id,
+			:name,
+			:kind,
+			:position
+		)
+	`, criteria); err != nil {
+		return fmt.Errorf("inserting post_criterion: %w", err)
+	}
+	return nil
+}
+
+type postVoteCriterionResponse struct {
+	PostVoteID      uuid.UUID `db:"post_vote_id"`
+	PostID          uuid.UUID `db:"post_id"`
+	PostCriterionID uuid.UUID `db:"post_criterion_id"`
+	PostOptionID    uuid.UUID `db:"post_option_id"`
+	Score           *int      `db:"score"`
+}
+
+// replacePostVoteCriterionResponses swaps the voter's answers to the post's
+// criteria for responses, which must all be for the vote.
+func replacePostVoteCriterionResponses(
+	ctx context.Context,
+	db database.Q,
+	voteID uuid.UUID,
+	responses []postVoteCriterionResponse,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_vote_criterion_response where post_vote_id = $1
+	`, voteID); err != nil {
+		return fmt.Errorf("deleting from post_vote_criterion_response: %w", err)
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_vote_criterion_response (
+			post_vote_id,
+			post_id,
+			post_criterion_id,
+			post_option_id,
+			score
+		) values (
+			:post_vote_id,
+			:post_id,
+			:post_criterion_id,
+			:post_option_id,
+			:score
+		)
+	`, responses); err != nil {
+		return fmt.Errorf("inserting post_vote_criterion_response: %w", err)
+	}
+	return nil
+}
+
+type postCriterionOptionResult struct {
+	PostID          uuid.UUID `db:"post_id"`
+	PostCriterionID uuid.UUID `db:"post_criterion_id"`
+	PostOptionID    uuid.UUID `db:"post_option_id"`
+	ResponseCount   int       `db:"response_count"`
+	MeanScore       *float64  `db:"mean_score"`
+	// Votes for the option, for working out how often it was tagged
+	OptionVoteCount int `db:"option_vote_count"`
+}
+
+// getPostCriterionOptionResults aggregates the responses to each criterion
+// for each option, leaving out options with no responses.
+func getPostCriterionOptionResults(
+	ctx context.Context,
+	db database.Q,
+	postIDs database.UUIDSlice,
+) ([]postCriterionOptionResult, error) {
+	results := []postCriterionOptionResult{}
+	if err := db.SelectContext(ctx, &results, `
+		select
+			r.post_id,
+			r.post_criterion_id,
+			r.post_option_id,
+			count(*) response_count,
+			avg(r.score)::float8 mean_score,
+			(
+				select count(*) from post_vote pv
+				where pv.post_option_id = r.post_option_id
+			) option_vote_count
+		from post_vote_criterion_response r
+		where r.post_id = any($1)
+		group by r.post_id, r.post_criterion_id, r.post_option_id
+	`, postIDs); err != nil {
+		return nil, fmt.Errorf("selecting post_vote_criterion_response results: %w", err)
+	}
+	return results, nil
+}
+
+// getPendingPostOptions returns options due to be processed, oldest first.
+func getPendingPostOptions(
+	ctx context.Context,
+	db database.Q,
+	limit int,
+	dbLock DBLock,
+) ([]postOption, error) {
+	postOptions := []postOption{}
+	if err := db.SelectContext(ctx, &postOptions, fmt.Sprintf(`
+		select
+			id,
+			post_id,
+			position,
+			file_ref,
+			media_type,
+			processing_status,
+			processing_attempts,
+			next_processing_at,
+			processing_error,
+			width,
+			height,
+			blurhash
+		from post_option
+		where processing_status = 'PENDING' and next_processing_at <= now()
+		order by next_processing_at, id
+		limit $1
+		%s
+	`, dbLock), limit); err != nil {
+		return nil, fmt.Errorf("selecting pending post_options: %w", err)
+	}
+	return postOptions, nil
+}
+
+type updatePostOptionProcessingParams struct {
+	ID                 uuid.UUID        `db:"id"`
+	ProcessingStatus   ProcessingStatus `db:"processing_status"`
+	ProcessingAttempts int              `db:"processing_attempts"`
+	NextProcessingAt   time.Time        `db:"next_processing_at"`
+	ProcessingError    *string          `db:"processing_error"`
+	Width              *int             `db:"width"`
+	Height             *int             `db:"height"`
+	Blurhash           *string          `db:"blurhash"`
+}
+
+func updatePostOptionProcessing(
+	ctx context.Context,
+	db database.Q,
+	params updatePostOptionProcessingParams,
+) error {
+	if _, err := db.NamedExecContext(ctx, `
+		update post_option set
+			processing_status = :processing_status,
+			processing_attempts = :processing_attempts,
+			next_processing_at = :next_processing_at,
+			processing_error = :processing_error,
+			width = :width,
+			height = :height,
+			blurhash = :blurhash
+		where id = :id
+	`, params); err != nil {
+		return fmt.Errorf("updating post_option: %w", err)
+	}
+	return nil
+}
+
+type postOptionRendition struct {
+	PostOptionID uuid.UUID `db:"post_option_id"`
+	PostID       uuid.UUID `db:"post_id"`
+	Size         ImageSize `db:"size"`
+	FileKey      string    `db:"file_key"`
+	ContentType  string    `db:"content_type"`
+	Width        int       `db:"width"`
+	Height       int       `db:"height"`
+}
+
+type getPostOptionRenditionsByFilterParams struct {
+	PostOptionIDs database.UUIDSlice
+	FileKeys      []string
+}
+
+func getPostOptionRenditionsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getPostOptionRenditionsByFilterParams,
+) ([]postOptionRendition, error) {
+	renditions := []postOptionRendition{}
+	query := `
+		select
+			post_option_id,
+			post_id,
+			size,
+			file_key,
+			content_type,
+			width,
+			height
+		from post_option_rendition
+		where true
+	`
+
+	args := []any{}
+	if len(params.PostOptionIDs) > 0 {
+		args = append(args, params.PostOptionIDs)
+		query = fmt.Sprintf("%s and post_option_id = any($%v)", query, len(args))
+	}
+	if len(params.FileKeys) > 0 {
+		args = append(args, params.FileKeys)
+		query = fmt.Sprintf("%s and file_key = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s order by post_option_id, size", query)
+
+	if err := db.SelectContext(ctx, &renditions, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting post_option_rendition: %w", err)
+	}
+	return renditions, nil
+}
+
+func replacePostOptionRenditions(
+	ctx context.Context,
+	db database.Q,
+	postOptionID uuid.UUID,
+	renditions []postOptionRendition,
+) error {
+	if _, err := db.ExecContext(ctx, `
+		delete from post_option_rendition where post_option_id = $1
+	`, postOptionID); err != nil {
+		return fmt.Errorf("deleting from post_option_rendition: %w", err)
+	}
+	if len(renditions) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+		insert into post_option_rendition (
+			post_option_id,
+			post_id,
+			size,
+			file_key,
+			content_type,
+			width,
+			height
+		) values (
+			:post_option_id,
+			:post_id,
+			:size,
+			:file_key,
+			:content_type,
+			:width,
+			:height
+		)
+	`, renditions); err != nil {
+		return fmt.Errorf("inserting post_option_rendition: %w", err)
 	}
 	return nil
 }
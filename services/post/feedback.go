@@ -0,0 +1,607 @@
+package srvpost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"quorum-api/database"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Feedback is the ratings or matchup results for a single post.
+type Feedback struct {
+	PostID uuid.UUID
+	Mode   FeedbackMode
+	// Ordered by option position, empty when the mode is NONE
+	Options []OptionFeedback
+}
+
+type OptionFeedback struct {
+	OptionID uuid.UUID
+	// 1 for the best option, tied options share a rank. Nil until the option
+	// has been rated, or compared.
+	Rank *int
+
+	// For RATING posts
+	RatingCount int
+	// Nil until the option has been rated
+	MeanScore *float64
+	// Number of ratings of each score, index 0 is a score of 1
+	ScoreDistribution []int
+	// 95% confidence interval of the mean score, nil with fewer than 2
+	// ratings
+	ScoreInterval *Interval
+
+	// For PAIRWISE posts
+	Comparisons int
+	Wins        int
+	// Bradley-Terry strength on the Elo scale, where 1500 is an average
+	// option and a 400 point lead means 10 to 1 odds of being preferred. Nil
+	// until the option has been compared.
+	Rating *float64
+	// Approximate 95% confidence interval of the rating
+	RatingInterval *Interval
+}
+
+type Interval struct {
+	Low  float64
+	High float64
+}
+
+// Option returns the feedback for the given option, or nil if the option is
+// not part of the post.
+func (f Feedback) Option(optionID uuid.UUID) *OptionFeedback {
+	for i := range f.Options {
+		if f.Options[i].OptionID == optionID {
+			return &f.Options[i]
+		}
+	}
+	return nil
+}
+
+type Matchup struct {
+	ID        uuid.UUID
+	PostID    uuid.UUID
+	OptionAID uuid.UUID
+	OptionBID uuid.UUID
+}
+
+type GetFeedbackByFilterRequest struct {
+	PostIDs []uuid.UUID
+}
+
+type RateOptionsRequest struct {
+	PostID     uuid.UUID
+	CustomerID uuid.UUID
+	Ratings    []OptionRating
+	// Workspaces the voter is a member of, only members can rate options on
+	// WORKSPACE posts
+	WorkspaceIDs []uuid.UUID
+	// Needed for restricted posts, the same as SubmitVoteRequest.InviteToken
+	InviteToken *string
+}
+
+type OptionRating struct {
+	OptionID uuid.UUID
+	// From 1 to 5
+	Score int
+}
+
+type RateOptionsResponse struct {
+	PostID uuid.UUID
+}
+
+type GetNextMatchupRequest struct {
+	PostID     uuid.UUID
+	CustomerID uuid.UUID
+	// Workspaces the voter is a member of
+	WorkspaceIDs []uuid.UUID
+}
+
+type AnswerMatchupRequest struct {
+	MatchupID      uuid.UUID
+	CustomerID     uuid.UUID
+	WinnerOptionID uuid.UUID
+	// Workspaces the voter is a member of
+	WorkspaceIDs []uuid.UUID
+	// Needed for restricted posts, the same as SubmitVoteRequest.InviteToken
+	InviteToken *string
+}
+
+type AnswerMatchupResponse struct {
+	PostID uuid.UUID
+}
+
+// z score for 95% confidence intervals
+const confidenceZ = 1.96
+
+// Elo points per unit of Bradley-Terry log strength
+var eloScale = 400 / math.Ln10
+
+var ErrFeedbackNotAccepted = errors.New("post does not take this kind of feedback")
+
+var ErrInvalidScore = errors.New("scores must be from 1 to 5, with one score per option")
+
+var ErrMatchupNotFound = errors.New("matchup not found")
+
+var ErrMatchupAnswered = errors.New("matchup has already been answered")
+
+func (s *srv) GetFeedbackByFilter(
+	ctx context.Context, request GetFeedbackByFilterRequest,
+) ([]Feedback, error) {
+	if len(request.PostIDs) == 0 {
+		return []Feedback{}, nil
+	}
+	posts, err := getPostsByFilter(ctx, s.db, getPostsByFilterParams{
+		IDs: request.PostIDs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting posts: %w", err)
+	}
+
+	feedbackByPostID := map[uuid.UUID]*Feedback{}
+	ratingPostIDs := database.UUIDSlice{}
+	pairwisePostIDs := database.UUIDSlice{}
+	for _, p := range posts {
+		feedback := &Feedback{
+			PostID:  p.ID,
+			Mode:    p.FeedbackMode,
+			Options: []OptionFeedback{},
+		}
+		if p.FeedbackMode != FeedbackModeNone {
+			for _, optionID := range p.OptionIDs {
+				feedback.Options = append(feedback.Options, OptionFeedback{
+					OptionID:          optionID,
+					ScoreDistribution: make([]int, 5),
+				})
+			}
+		}
+		switch p.FeedbackMode {
+		case FeedbackModeRating:
+			ratingPostIDs = append(ratingPostIDs, p.ID)
+		case FeedbackModePairwise:
+			pairwisePostIDs = append(pairwisePostIDs, p.ID)
+		}
+		feedbackByPostID[p.ID] = feedback
+	}
+
+	if len(ratingPostIDs) > 0 {
+		scoreCounts, err := getPostOptionScoreCounts(ctx, s.db, ratingPostIDs)
+		if err != nil {
+			return nil, fmt.Errorf("getting score counts: %w", err)
+		}
+		for _, sc := range scoreCounts {
+			option := feedbackByPostID[sc.PostID].Option(sc.PostOptionID)
+			if option == nil {
+				continue
+			}
+			option.ScoreDistribution[sc.Score-1] = sc.RatingCount
+		}
+		for _, postID := range ratingPostIDs {
+			feedback := feedbackByPostID[postID]
+			for i := range feedback.Options {
+				o := &feedback.Options[i]
+				o.RatingCount, o.MeanScore, o.ScoreInterval = scoreStats(o.ScoreDistribution)
+			}
+			rankOptions(feedback.Options, func(o OptionFeedback) *float64 {
+				return o.MeanScore
+			})
+		}
+	}
+
+	if len(pairwisePostIDs) > 0 {
+		outcomes, err := getPostMatchupOutcomes(ctx, s.db, pairwisePostIDs)
+		if err != nil {
+			return nil, fmt.Errorf("getting matchup outcomes: %w", err)
+		}
+		outcomesByPostID := map[uuid.UUID][]postMatchupOutcome{}
+		for _, o := range outcomes {
+			outcomesByPostID[o.PostID] = append(outcomesByPostID[o.PostID], o)
+		}
+		for _, postID := range pairwisePostIDs {
+			feedback := feedbackByPostID[postID]
+			bradleyTerry(feedback.Options, outcomesByPostID[postID])
+			rankOptions(feedback.Options, func(o OptionFeedback) *float64 {
+				return o.Rating
+			})
+		}
+	}
+
+	res := []Feedback{}
+	for _, postID := range request.PostIDs {
+		if feedback, ok := feedbackByPostID[postID]; ok {
+			res = append(res, *feedback)
+		}
+	}
+	return res, nil
+}
+
+// scoreStats works out the number of ratings, their mean and the mean's
+// confidence interval from the number of ratings of each score.
+func scoreStats(distribution []int) (int, *float64, *Interval) {
+	count, sum := 0, 0
+	for i, n := range distribution {
+		count += n
+		sum += n * (i + 1)
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+	mean := float64(sum) / float64(count)
+	if count < 2 {
+		return count, &mean, nil
+	}
+	squaredDeviations := 0.0
+	for i, n := range distribution {
+		squaredDeviations += float64(n) * math.Pow(float64(i+1)-mean, 2)
+	}
+	standardError := math.Sqrt(squaredDeviations/float64(count-1)) / math.Sqrt(float64(count))
+	return count, &mean, &Interval{
+		Low:  math.Max(1, mean-confidenceZ*standardError),
+		High: math.Min(5, mean+confidenceZ*standardError),
+	}
+}
+
+// bradleyTerry fits a Bradley-Terry model to the matchup outcomes and sets
+// each compared option's rating. Every option is also given half a win and
+// half a loss against an average option, which keeps options that never won,
+// or never lost, from running off to infinity.
+func bradleyTerry(options []OptionFeedback, outcomes []postMatchupOutcome) {
+	index := map[uuid.UUID]int{}
+	for i, o := range options {
+		index[o.OptionID] = i
+	}
+	// games[i][j] is how many times options i and j were compared
+	games := make([][]float64, len(options))
+	for i := range games {
+		games[i] = make([]float64, len(options))
+	}
+	for _, o := range outcomes {
+		winner, ok := index[o.WinnerOptionID]
+		if !ok {
+			continue
+		}
+		loser, ok := index[o.LoserOptionID]
+		if !ok {
+			continue
+		}
+		options[winner].Wins += o.Wins
+		options[winner].Comparisons += o.Wins
+		options[loser].Comparisons += o.Wins
+		games[winner][loser] += float64(o.Wins)
+		games[loser][winner] += float64(o.Wins)
+	}
+
+	// Newton's method on the log strengths, which are relative to the
+	// average option's log strength of 0. Minorization-maximization takes
+	// thousands of iterations when an option wins nearly every matchup.
+	logStrengths := make([]float64, len(options))
+	strengths := make([]float64, len(options))
+	for iteration := 0; iteration < 100; iteration++ {
+		for i := range options {
+			strengths[i] = math.Exp(logStrengths[i])
+		}
+		// The gradient of the log likelihood, and its Hessian negated
+		gradient := make([]float64, len(options))
+		hessian := make([][]float64, len(options))
+		for i := range options {
+			hessian[i] = make([]float64, len(options))
+			gradient[i] = float64(options[i].Wins) + 0.5 - strengths[i]/(strengths[i]+1)
+			hessian[i][i] = strengths[i] / math.Pow(strengths[i]+1, 2)
+			for j := range options {
+				if games[i][j] == 0 {
+					continue
+				}
+				gradient[i] -= games[i][j] * strengths[i] / (strengths[i] + strengths[j])
+				curvature := games[i][j] * strengths[i] * strengths[j] /
+					math.Pow(strengths[i]+strengths[j], 2)
+				hessian[i][i] += curvature
+				hessian[i][j] -= curvature
+			}
+		}
+		step := solveLinear(hessian, gradient)
+		largestStep := 0.0
+		for i := range options {
+			// Capped so a step from far away can't overshoot
+			step[i] = math.Max(-1, math.Min(1, step[i]))
+			logStrengths[i] += step[i]
+			largestStep = math.Max(largestStep, math.Abs(step[i]))
+		}
+		if largestStep < 1e-10 {
+			break
+		}
+	}
+	for i := range options {
+		strengths[i] = math.Exp(logStrengths[i])
+	}
+
+	for i := range options {
+		if options[i].Comparisons == 0 {
+			continue
+		}
+		// Fisher information of the log strength, ignoring covariance with
+		// the other options
+		information := strengths[i] / math.Pow(strengths[i]+1, 2)
+		for j := range options {
+			if games[i][j] > 0 {
+				information += games[i][j] * strengths[i] * strengths[j] /
+					math.Pow(strengths[i]+strengths[j], 2)
+			}
+		}
+		rating := 1500 + eloScale*math.Log(strengths[i])
+		margin := confidenceZ * eloScale / math.Sqrt(information)
+		options[i].Rating = &rating
+		options[i].RatingInterval = &Interval{
+			Low:  rating - margin,
+			High: rating + margin,
+		}
+	}
+}
+
+// solveLinear solves a x = b by Gaussian elimination. a has to be diagonally
+// dominant, like the negated Hessian in bradleyTerry, so no pivoting is
+// needed. a and b are overwritten.
+func solveLinear(a [][]float64, b []float64) []float64 {
+	n := len(b)
+	for col := 0; col < n; col++ {
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x
+}
+
+// rankOptions ranks options by score, highest first. Options without a score
+// aren't ranked.
+func rankOptions(options []OptionFeedback, score func(OptionFeedback) *float64) {
+	scored := []int{}
+	for i, o := range options {
+		if score(o) != nil {
+			scored = append(scored, i)
+		}
+	}
+	sort.SliceStable(scored, func(a, b int) bool {
+		return *score(options[scored[a]]) > *score(options[scored[b]])
+	})
+	for position, i := range scored {
+		rank := position + 1
+		if position > 0 {
+			previous := options[scored[position-1]]
+			if *score(previous) == *score(options[i]) {
+				rank = *previous.Rank
+			}
+		}
+		options[i].Rank = &rank
+	}
+}
+
+func (s *srv) RateOptions(
+	ctx context.Context, request RateOptionsRequest,
+) (*RateOptionsResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	post, err := getLivePost(ctx, tx, request.PostID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if post.FeedbackMode != FeedbackModeRating {
+		return nil, ErrFeedbackNotAccepted
+	}
+
+	if len(request.Ratings) == 0 {
+		return nil, ErrInvalidScore
+	}
+	rated := map[uuid.UUID]bool{}
+	ratingsToUpsert := []upsertPostOptionRatingParams{}
+	for _, r := range request.Ratings {
+		if r.Score < 1 || r.Score > 5 || rated[r.OptionID] {
+			return nil, ErrInvalidScore
+		}
+		rated[r.OptionID] = true
+		found := false
+		for _, optionID := range post.OptionIDs {
+			if optionID == r.OptionID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrOptionNotFound
+		}
+		ratingsToUpsert = append(ratingsToUpsert, upsertPostOptionRatingParams{
+			ID:           uuid.New(),
+			PostID:       post.ID,
+			PostOptionID: r.OptionID,
+			CustomerID:   request.CustomerID,
+			Score:        r.Score,
+		})
+	}
+
+	existingRatings, err := getPostOptionRatingsByFilter(ctx, tx, getPostOptionRatingsByFilterParams{
+		PostIDs:     []uuid.UUID{post.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting ratings: %w", err)
+	}
+	for _, r := range existingRatings {
+		if rated[r.PostOptionID] && !post.AllowVoteChanges {
+			return nil, ErrVoteAlreadyCast
+		}
+	}
+
+	if post.Restricted && post.AuthorID != request.CustomerID {
+		if err = redeemInvite(ctx, tx, post.ID, request.CustomerID, request.InviteToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = upsertPostOptionRatings(ctx, tx, ratingsToUpsert); err != nil {
+		return nil, fmt.Errorf("upserting ratings: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+	return &RateOptionsResponse{
+		PostID: post.ID,
+	}, nil
+}
+
+func (s *srv) GetNextMatchup(
+	ctx context.Context, request GetNextMatchupRequest,
+) (*Matchup, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	post, err := getLivePost(ctx, tx, request.PostID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if post.FeedbackMode != FeedbackModePairwise {
+		return nil, ErrFeedbackNotAccepted
+	}
+
+	matchups, err := getPostMatchupsByFilter(ctx, tx, getPostMatchupsByFilterParams{
+		PostIDs:     []uuid.UUID{post.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting matchups: %w", err)
+	}
+	type pair struct{ a, b uuid.UUID }
+	compared := map[pair]bool{}
+	for _, m := range matchups {
+		if m.WinnerOptionID == nil {
+			return &Matchup{
+				ID:        m.ID,
+				PostID:    m.PostID,
+				OptionAID: m.OptionAID,
+				OptionBID: m.OptionBID,
+			}, nil
+		}
+		compared[pair{m.OptionAID, m.OptionBID}] = true
+		compared[pair{m.OptionBID, m.OptionAID}] = true
+	}
+
+	candidates := []pair{}
+	for i, a := range post.OptionIDs {
+		for _, b := range post.OptionIDs[i+1:] {
+			if !compared[pair{a, b}] {
+				candidates = append(candidates, pair{a, b})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	picked := candidates[rand.IntN(len(candidates))]
+	if rand.IntN(2) == 1 {
+		picked = pair{picked.b, picked.a}
+	}
+
+	matchup := Matchup{
+		ID:        uuid.New(),
+		PostID:    post.ID,
+		OptionAID: picked.a,
+		OptionBID: picked.b,
+	}
+	if err = insertPostMatchup(ctx, tx, insertPostMatchupParams{
+		ID:         matchup.ID,
+		PostID:     matchup.PostID,
+		CustomerID: request.CustomerID,
+		OptionAID:  matchup.OptionAID,
+		OptionBID:  matchup.OptionBID,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting matchup: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+	return &matchup, nil
+}
+
+func (s *srv) AnswerMatchup(
+	ctx context.Context, request AnswerMatchupRequest,
+) (*AnswerMatchupResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	matchups, err := getPostMatchupsByFilter(ctx, tx, getPostMatchupsByFilterParams{
+		IDs: []uuid.UUID{request.MatchupID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting matchups: %w", err)
+	}
+	if len(matchups) != 1 || matchups[0].CustomerID != (uuid.NullUUID{UUID: request.CustomerID, Valid: true}) {
+		return nil, ErrMatchupNotFound
+	}
+	matchup := matchups[0]
+
+	post, err := getLivePost(ctx, tx, matchup.PostID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if post.FeedbackMode != FeedbackModePairwise {
+		return nil, ErrFeedbackNotAccepted
+	}
+	if matchup.WinnerOptionID != nil {
+		return nil, ErrMatchupAnswered
+	}
+	if request.WinnerOptionID != matchup.OptionAID && request.WinnerOptionID != matchup.OptionBID {
+		return nil, ErrOptionNotFound
+	}
+
+	if post.Restricted && post.AuthorID != request.CustomerID {
+		if err = redeemInvite(ctx, tx, post.ID, request.CustomerID, request.InviteToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = answerPostMatchup(ctx, tx, matchup.ID, request.WinnerOptionID); err != nil {
+		return nil, fmt.Errorf("answering matchup: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+	return &AnswerMatchupResponse{
+		PostID: post.ID,
+	}, nil
+}
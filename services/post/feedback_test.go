@@ -0,0 +1,180 @@
+package srvpost
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func approxEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestScoreStats(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution []int
+		count        int
+		mean         *float64
+		interval     *Interval
+	}{
+		{
+			name:         "no ratings",
+			distribution: []int{0, 0, 0, 0, 0},
+		},
+		{
+			name:         "empty distribution",
+			distribution: []int{},
+		},
+		{
+			name:         "one rating has no interval",
+			distribution: []int{0, 0, 0, 1, 0},
+			count:        1,
+			mean:         ptr(4.0),
+		},
+		{
+			// Sample standard deviation of 3 and 4 is √0.5, so the standard
+			// error is 0.5
+			name:         "known mean and interval",
+			distribution: []int{0, 0, 1, 1, 0},
+			count:        2,
+			mean:         ptr(3.5),
+			interval:     &Interval{Low: 3.5 - 0.98, High: 3.5 + 0.98},
+		},
+		{
+			name:         "same score every time",
+			distribution: []int{0, 0, 0, 0, 4},
+			count:        4,
+			mean:         ptr(5.0),
+			interval:     &Interval{Low: 5, High: 5},
+		},
+		{
+			name:         "interval is clamped to the scores",
+			distribution: []int{1, 0, 0, 0, 1},
+			count:        2,
+			mean:         ptr(3.0),
+			interval:     &Interval{Low: 1, High: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, mean, interval := scoreStats(tt.distribution)
+			if count != tt.count {
+				t.Errorf("got count %v, want %v", count, tt.count)
+			}
+			if (mean == nil) != (tt.mean == nil) || (mean != nil && !approxEqual(*mean, *tt.mean)) {
+				t.Errorf("got mean %v, want %v", mean, tt.mean)
+			}
+			if (interval == nil) != (tt.interval == nil) ||
+				(interval != nil && (!approxEqual(interval.Low, tt.interval.Low) ||
+					!approxEqual(interval.High, tt.interval.High))) {
+				t.Errorf("got interval %+v, want %+v", interval, tt.interval)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestBradleyTerryWithoutMatchups(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	tests := []struct {
+		name     string
+		options  []OptionFeedback
+		outcomes []postMatchupOutcome
+	}{
+		{
+			name:    "no matchups",
+			options: []OptionFeedback{{OptionID: a}, {OptionID: b}},
+		},
+		{
+			name:    "single option",
+			options: []OptionFeedback{{OptionID: a}},
+		},
+		{
+			name:    "matchups against removed options",
+			options: []OptionFeedback{{OptionID: a}},
+			outcomes: []postMatchupOutcome{
+				{WinnerOptionID: a, LoserOptionID: b, Wins: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bradleyTerry(tt.options, tt.outcomes)
+			for _, o := range tt.options {
+				if o.Rating != nil || o.RatingInterval != nil || o.Comparisons != 0 || o.Wins != 0 {
+					t.Errorf("got %+v, want an unrated option", o)
+				}
+			}
+		})
+	}
+}
+
+func TestBradleyTerryEvenRecord(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	options := []OptionFeedback{{OptionID: a}, {OptionID: b}}
+	bradleyTerry(options, []postMatchupOutcome{
+		{WinnerOptionID: a, LoserOptionID: b, Wins: 5},
+		{WinnerOptionID: b, LoserOptionID: a, Wins: 5},
+	})
+	for _, o := range options {
+		if o.Rating == nil || !approxEqual(*o.Rating, 1500) {
+			t.Errorf("got rating %v, want 1500", o.Rating)
+		}
+		if o.Comparisons != 10 || o.Wins != 5 {
+			t.Errorf("got %v wins of %v comparisons", o.Wins, o.Comparisons)
+		}
+	}
+}
+
+func TestBradleyTerryAlwaysWins(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	options := []OptionFeedback{{OptionID: a}, {OptionID: b}, {OptionID: c}}
+	outcomes := []postMatchupOutcome{
+		{WinnerOptionID: a, LoserOptionID: b, Wins: 20},
+		{WinnerOptionID: a, LoserOptionID: c, Wins: 20},
+		{WinnerOptionID: b, LoserOptionID: c, Wins: 6},
+		{WinnerOptionID: c, LoserOptionID: b, Wins: 4},
+	}
+	bradleyTerry(options, outcomes)
+
+	strengths := []float64{}
+	for _, o := range options {
+		if o.Rating == nil || o.RatingInterval == nil {
+			t.Fatalf("got %+v, want a rated option", o)
+		}
+		for _, v := range []float64{*o.Rating, o.RatingInterval.Low, o.RatingInterval.High} {
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				t.Fatalf("got rating %v with interval %+v, want finite", *o.Rating, *o.RatingInterval)
+			}
+		}
+		if o.RatingInterval.Low >= *o.Rating || o.RatingInterval.High <= *o.Rating {
+			t.Errorf("rating %v isn't inside its interval %+v", *o.Rating, *o.RatingInterval)
+		}
+		strengths = append(strengths, math.Exp((*o.Rating-1500)/eloScale))
+	}
+	if !(*options[0].Rating > *options[1].Rating && *options[1].Rating > *options[2].Rating) {
+		t.Errorf("got ratings %v, %v, %v, want a > b > c",
+			*options[0].Rating, *options[1].Rating, *options[2].Rating)
+	}
+
+	// Converged to where each option's expected wins, including the half win
+	// against an average option, match its actual wins
+	games := map[[2]int]float64{{0, 1}: 20, {0, 2}: 20, {1, 2}: 10}
+	for i, o := range options {
+		expected := strengths[i] / (strengths[i] + 1)
+		for j := range options {
+			n := games[[2]int{min(i, j), max(i, j)}]
+			if i != j && n > 0 {
+				expected += n * strengths[i] / (strengths[i] + strengths[j])
+			}
+		}
+		if math.Abs(expected-(float64(o.Wins)+0.5)) > 1e-6 {
+			t.Errorf("option %v: expected %v wins, got %v", i, expected, float64(o.Wins)+0.5)
+		}
+	}
+}
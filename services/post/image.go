@@ -0,0 +1,247 @@
+package srvpost
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Registers the decoders for uploads
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/buckket/go-blurhash"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Longest edge of each rendition in pixels. Images are never scaled up.
+var renditionSizes = []struct {
+	Size    ImageSize
+	MaxEdge int
+}{
+	{Size: ImageSizeThumb, MaxEdge: 320},
+	{Size: ImageSizeMedium, MaxEdge: 1080},
+	{Size: ImageSizeFull, MaxEdge: 2560},
+}
+
+const (
+	// Stops small files that decode to huge images from using up memory
+	maxImagePixels = 40_000_000
+	jpegQuality    = 85
+)
+
+type encodedRendition struct {
+	Size        ImageSize
+	ContentType string
+	Ext         string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+type processedImage struct {
+	// Of the upload, after its EXIF orientation is applied
+	Width      int
+	Height     int
+	Blurhash   string
+	Renditions []encodedRendition
+}
+
+// processImage checks the upload is an image by its magic bytes rather than
+// its extension, and re-encodes it at each rendition size. Re-encoding drops
+// EXIF and any other metadata, so the orientation is applied first. Opaque
+// images are encoded as JPEG, and images with transparency as lossless WebP.
+// Only the first frame of animated GIFs is kept.
+func processImage(data []byte) (*processedImage, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" &&
+		contentType != "image/gif" && contentType != "image/webp" {
+		return nil, fmt.Errorf("%w: content is %s", errUnsupportedMedia, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnsupportedMedia, err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("%w: larger than %v pixels", errUnsupportedMedia, maxImagePixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnsupportedMedia, err)
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, exifOrientation(data))
+	}
+
+	opaque := true
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		opaque = o.Opaque()
+	}
+
+	res := processedImage{
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+		Renditions: []encodedRendition{},
+	}
+	for _, rs := range renditionSizes {
+		resized := resizeImage(img, rs.MaxEdge)
+		rendition := encodedRendition{
+			Size:   rs.Size,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+		}
+		buf := bytes.Buffer{}
+		if opaque {
+			rendition.ContentType = "image/jpeg"
+			rendition.Ext = ".jpg"
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			rendition.ContentType = "image/webp"
+			rendition.Ext = ".webp"
+			err = nativewebp.Encode(&buf, resized, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s rendition: %w", rs.Size, err)
+		}
+		rendition.Data = buf.Bytes()
+		res.Renditions = append(res.Renditions, rendition)
+
+		if rs.Size == ImageSizeThumb {
+			// Hashing is slow for big images, and only keeps the rough colours
+			res.Blurhash, err = blurhash.Encode(4, 3, resized)
+			if err != nil {
+				return nil, fmt.Errorf("encoding blurhash: %w", err)
+			}
+		}
+	}
+	return &res, nil
+}
+
+// resizeImage scales the image down so its longest edge fits maxEdge.
+func resizeImage(img image.Image, maxEdge int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxEdge && height <= maxEdge {
+		return img
+	}
+	if width >= height {
+		height = max(1, height*maxEdge/width)
+		width = maxEdge
+	} else {
+		width = max(1, width*maxEdge/height)
+		height = maxEdge
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// exifOrientation reads the orientation tag from a JPEG's EXIF data, 1 (as
+// stored) when there isn't one.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// Start of scan, the image data follows so there's no more metadata
+		if marker == 0xDA {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation flips and rotates the image so it displays upright once
+// its EXIF orientation is stripped.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := w, h
+	if orientation >= 5 {
+		dstWidth, dstHeight = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			from, to := src.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[to:to+4], src.Pix[from:from+4])
+		}
+	}
+	return dst
+}
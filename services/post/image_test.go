@@ -0,0 +1,151 @@
+package srvpost
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"image"
+	"testing"
+)
+
+// Stored 16x8 with the top half red and the bottom half blue, with EXIF
+// holding the orientation and a GPS position in its description
+var (
+	//go:embed testdata/photo-orientation-6.jpg
+	photoOrientation6 []byte
+	//go:embed testdata/photo-orientation-8.jpg
+	photoOrientation8 []byte
+)
+
+// A 12x10 PNG with transparency, uploaded with the wrong extension
+//
+//go:embed testdata/transparent-png.jpg
+var transparentPNG []byte
+
+// Just the header of a 10000x5000 PNG
+//
+//go:embed testdata/huge.png
+var hugePNG []byte
+
+//go:embed testdata/not-an-image.jpg
+var notAnImage []byte
+
+func TestProcessImageDetectsContentByMagicBytes(t *testing.T) {
+	processed, err := processImage(transparentPNG)
+	if err != nil {
+		t.Fatalf("processing: %v", err)
+	}
+	if processed.Width != 12 || processed.Height != 10 {
+		t.Errorf("got %vx%v, want 12x10", processed.Width, processed.Height)
+	}
+	if processed.Blurhash == "" {
+		t.Error("got no blurhash")
+	}
+	if len(processed.Renditions) != len(renditionSizes) {
+		t.Fatalf("got %v renditions, want %v", len(processed.Renditions), len(renditionSizes))
+	}
+	for _, r := range processed.Renditions {
+		// Decoded as the PNG it is, so the transparency is kept
+		if r.ContentType != "image/webp" || r.Ext != ".webp" {
+			t.Errorf("got %s rendition as %s", r.Size, r.ContentType)
+		}
+		// Never scaled up
+		if r.Width != 12 || r.Height != 10 {
+			t.Errorf("got %s rendition of %vx%v", r.Size, r.Width, r.Height)
+		}
+	}
+}
+
+func TestProcessImageRejectsUnsupportedMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not an image", notAnImage},
+		{"over the pixel limit", hugePNG},
+		{"truncated", photoOrientation6[:len(photoOrientation6)/2]},
+		{"empty", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := processImage(tt.data); !errors.Is(err, errUnsupportedMedia) {
+				t.Errorf("got %v, want errUnsupportedMedia", err)
+			}
+		})
+	}
+}
+
+func TestProcessImageAppliesOrientation(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		orientation int
+		// Which side the stored top half ends up on
+		redOnRight bool
+	}{
+		{"rotated 90° clockwise", photoOrientation6, 6, true},
+		{"rotated 90° anticlockwise", photoOrientation8, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != tt.orientation {
+				t.Fatalf("got orientation %v, want %v", got, tt.orientation)
+			}
+			processed, err := processImage(tt.data)
+			if err != nil {
+				t.Fatalf("processing: %v", err)
+			}
+			if processed.Width != 8 || processed.Height != 16 {
+				t.Errorf("got %vx%v, want 8x16", processed.Width, processed.Height)
+			}
+
+			for _, r := range processed.Renditions {
+				if r.ContentType != "image/jpeg" {
+					t.Errorf("got %s rendition as %s", r.Size, r.ContentType)
+				}
+				if bytes.Contains(r.Data, []byte("Exif")) || bytes.Contains(r.Data, []byte("GPS")) {
+					t.Errorf("%s rendition kept the EXIF data", r.Size)
+				}
+				if got := exifOrientation(r.Data); got != 1 {
+					t.Errorf("%s rendition has orientation %v", r.Size, got)
+				}
+
+				img, _, err := image.Decode(bytes.NewReader(r.Data))
+				if err != nil {
+					t.Fatalf("decoding %s rendition: %v", r.Size, err)
+				}
+				if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 16 {
+					t.Errorf("got %s rendition of %v", r.Size, img.Bounds())
+				}
+				left, right := isRed(img, 1, 8), isRed(img, 6, 8)
+				if left == right || right != tt.redOnRight {
+					t.Errorf("got red on the left %v and on the right %v", left, right)
+				}
+			}
+		})
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}},
+		{"not a jpeg", transparentPNG},
+		{"segment longer than the file", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E'}},
+		{"empty", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != 1 {
+				t.Errorf("got orientation %v, want 1", got)
+			}
+		})
+	}
+}
+
+func isRed(img image.Image, x int, y int) bool {
+	r, _, b, _ := img.At(x, y).RGBA()
+	return r > b
+}
@@ -0,0 +1,331 @@
+package srvpost
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"quorum-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Invite struct {
+	ID     uuid.UUID
+	PostID uuid.UUID
+	// Set for invites emailed to someone
+	Email *string
+	// Nil when the invite can be used any number of times
+	MaxUses   *int
+	UseCount  int
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	// For the invite link, only set on invites returned by CreateInvites as
+	// just its hash is stored
+	Token string
+}
+
+type GetInvitesByFilterRequest struct {
+	IDs     []uuid.UUID
+	PostIDs []uuid.UUID
+	// Exclude revoked, expired and used up invites
+	ActiveOnly bool
+}
+
+type CreateInvitesRequest struct {
+	PostID   uuid.UUID
+	AuthorID uuid.UUID
+	// A single use invite is created for each email. When empty, one
+	// shareable link is created instead.
+	Emails []string
+	// Only for shareable links, nil for no limit
+	MaxUses *int
+	// Defaults to inviteTTL from now
+	ExpiresAt *time.Time
+	// Called in the same tx the invites are created in, e.g. to enqueue
+	// emails with their links
+	OnCreate func(ctx context.Context, tx database.Q, invites []Invite) error
+}
+
+type RevokeInviteRequest struct {
+	ID       uuid.UUID
+	AuthorID uuid.UUID
+}
+
+type RevokeInviteResponse struct {
+	PostID uuid.UUID
+}
+
+// How long invites last when the author doesn't say
+const inviteTTL = 7 * 24 * time.Hour
+
+const maxInviteTTL = 90 * 24 * time.Hour
+
+// Max number of emails that can be invited at once
+const maxInviteEmails = 50
+
+var ErrInviteRequired = errors.New("only invited voters can vote on this post")
+
+var ErrInviteNotFound = errors.New("invite does not exist or was revoked")
+
+var ErrInviteExpired = errors.New("invite has expired")
+
+var ErrInviteUsedUp = errors.New("invite has already been used")
+
+var ErrInvalidInviteExpiry = errors.New("invite must expire in the future, and within 90 days")
+
+var ErrInvalidInviteMaxUses = errors.New("invite max uses must be at least 1")
+
+var ErrTooManyInviteEmails = fmt.Errorf("at most %v emails can be invited at once", maxInviteEmails)
+
+var ErrInvalidInviteEmail = errors.New("invalid format for email")
+
+func inviteFromRow(i postInvite) Invite {
+	return Invite{
+		ID:        i.ID,
+		PostID:    i.PostID,
+		Email:     i.Email,
+		MaxUses:   i.MaxUses,
+		UseCount:  i.UseCount,
+		ExpiresAt: i.ExpiresAt,
+		RevokedAt: i.RevokedAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+func (s *srv) GetInvitesByFilter(
+	ctx context.Context, request GetInvitesByFilterRequest,
+) ([]Invite, error) {
+	invites, err := getPostInvitesByFilter(ctx, s.db, getPostInvitesByFilterParams{
+		IDs:        request.IDs,
+		PostIDs:    request.PostIDs,
+		ActiveOnly: request.ActiveOnly,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting invites: %w", err)
+	}
+
+	res := []Invite{}
+	for _, i := range invites {
+		res = append(res, inviteFromRow(i))
+	}
+	return res, nil
+}
+
+func (s *srv) CreateInvites(
+	ctx context.Context, request CreateInvitesRequest,
+) ([]Invite, error) {
+	expiresAt := time.Now().Add(inviteTTL)
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+	}
+	if !expiresAt.After(time.Now()) || expiresAt.After(time.Now().Add(maxInviteTTL)) {
+		return nil, ErrInvalidInviteExpiry
+	}
+	if request.MaxUses != nil && *request.MaxUses < 1 {
+		return nil, ErrInvalidInviteMaxUses
+	}
+	if len(request.Emails) > maxInviteEmails {
+		return nil, ErrTooManyInviteEmails
+	}
+
+	invitesToInsert := []insertPostInviteParams{}
+	for _, email := range request.Emails {
+		mailAddress, err := mail.ParseAddress(email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidInviteEmail, email)
+		}
+		singleUse := 1
+		invitesToInsert = append(invitesToInsert, insertPostInviteParams{
+			ID:        uuid.New(),
+			PostID:    request.PostID,
+			Email:     &mailAddress.Address,
+			MaxUses:   &singleUse,
+			ExpiresAt: expiresAt,
+		})
+	}
+	if len(request.Emails) == 0 {
+		invitesToInsert = append(invitesToInsert, insertPostInviteParams{
+			ID:        uuid.New(),
+			PostID:    request.PostID,
+			MaxUses:   request.MaxUses,
+			ExpiresAt: expiresAt,
+		})
+	}
+	tokens := map[uuid.UUID]string{}
+	for i := range invitesToInsert {
+		token, err := newToken()
+		if err != nil {
+			return nil, fmt.Errorf("generating token: %w", err)
+		}
+		tokens[invitesToInsert[i].ID] = token
+		invitesToInsert[i].TokenHash = hashToken(token)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	posts, err := getPostsByFilter(ctx, tx, getPostsByFilterParams{
+		IDs: []uuid.UUID{request.PostID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting posts: %w", err)
+	}
+	if len(posts) != 1 {
+		return nil, ErrPostNotFound
+	}
+	if posts[0].AuthorID != request.AuthorID {
+		return nil, ErrPostNotOwned
+	}
+
+	if err = insertPostInvites(ctx, tx, invitesToInsert); err != nil {
+		return nil, fmt.Errorf("inserting invites: %w", err)
+	}
+	ids := []uuid.UUID{}
+	for _, i := range invitesToInsert {
+		ids = append(ids, i.ID)
+	}
+	inserted, err := getPostInvitesByFilter(ctx, tx, getPostInvitesByFilterParams{
+		IDs: ids,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting invites: %w", err)
+	}
+	invites := []Invite{}
+	for _, i := range inserted {
+		invite := inviteFromRow(i)
+		invite.Token = tokens[i.ID]
+		invites = append(invites, invite)
+	}
+
+	if request.OnCreate != nil {
+		if err = request.OnCreate(ctx, tx, invites); err != nil {
+			return nil, fmt.Errorf("handling created invites: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+	return invites, nil
+}
+
+func (s *srv) RevokeInvite(
+	ctx context.Context, request RevokeInviteRequest,
+) (*RevokeInviteResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	invites, err := getPostInvitesByFilter(ctx, tx, getPostInvitesByFilterParams{
+		IDs: []uuid.UUID{request.ID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting invites: %w", err)
+	}
+	if len(invites) != 1 {
+		return nil, ErrInviteNotFound
+	}
+	posts, err := getPostsByFilter(ctx, tx, getPostsByFilterParams{
+		IDs: []uuid.UUID{invites[0].PostID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting posts: %w", err)
+	}
+	// Other authors' invites aren't revealed to exist
+	if len(posts) != 1 || posts[0].AuthorID != request.AuthorID {
+		return nil, ErrInviteNotFound
+	}
+
+	if err = revokePostInvite(ctx, tx, request.ID); err != nil {
+		return nil, fmt.Errorf("revoking invite: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+	return &RevokeInviteResponse{
+		PostID: invites[0].PostID,
+	}, nil
+}
+
+// redeemInvite checks the voter holds an invite to the post, using up the
+// invite the first time they vote with it. Voters who've used an invite that
+// hasn't been revoked since don't need to send it again.
+func redeemInvite(
+	ctx context.Context,
+	q database.Q,
+	postID uuid.UUID,
+	customerID uuid.UUID,
+	inviteToken *string,
+) error {
+	params := hasRedeemedPostInviteParams{
+		PostID:     postID,
+		CustomerID: customerID,
+	}
+	if inviteToken == nil {
+		redeemed, err := hasRedeemedPostInvite(ctx, q, params)
+		if err != nil {
+			return fmt.Errorf("checking invite redemptions: %w", err)
+		}
+		if !redeemed {
+			return ErrInviteRequired
+		}
+		return nil
+	}
+
+	invites, err := getPostInvitesByFilter(ctx, q, getPostInvitesByFilterParams{
+		TokenHash: hashToken(*inviteToken),
+	}, DBLockForUpdate)
+	if err != nil {
+		return fmt.Errorf("getting invites: %w", err)
+	}
+	if len(invites) != 1 || invites[0].PostID != postID || invites[0].RevokedAt != nil {
+		return ErrInviteNotFound
+	}
+	invite := invites[0]
+
+	params.InviteID = uuid.NullUUID{UUID: invite.ID, Valid: true}
+	redeemed, err := hasRedeemedPostInvite(ctx, q, params)
+	if err != nil {
+		return fmt.Errorf("checking invite redemptions: %w", err)
+	}
+	if redeemed {
+		return nil
+	}
+	if !time.Now().Before(invite.ExpiresAt) {
+		return ErrInviteExpired
+	}
+	if invite.MaxUses != nil && invite.UseCount >= *invite.MaxUses {
+		return ErrInviteUsedUp
+	}
+	if err = insertPostInviteRedemption(ctx, q, invite, customerID); err != nil {
+		return fmt.Errorf("redeeming invite: %w", err)
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is what's stored instead of an invite's token, so a leaked table
+// can't be used to vote on restricted posts.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
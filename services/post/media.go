@@ -0,0 +1,407 @@
+package srvpost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"quorum-api/blobstore"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Option urls are signed for optionURLTTL, and the same url is handed out
+// until less than optionURLMinTTL is left on it. Clients can cache images for
+// as long as the url stays the same.
+const (
+	optionURLTTL    = time.Hour
+	optionURLMinTTL = time.Minute * 15
+)
+
+// Where option uploads and their renditions are kept in the blob store
+const (
+	optionUploadPrefix    = "post-options/"
+	optionRenditionPrefix = "post-option-renditions/"
+)
+
+// How long clients have to upload an option after asking for a signed url
+const optionUploadURLTTL = time.Minute * 15
+
+type ProcessPendingOptionsRequest struct {
+	Limit int
+}
+
+type ProcessPendingOptionsResponse struct {
+	Processed int
+	Failed    int
+}
+
+type signedURL struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// optionURLCache holds the signed GET url for each of the options' files,
+// keyed by file key, so options loaded again get the same urls while they're
+// still fresh.
+type optionURLCache struct {
+	mu        sync.Mutex
+	urls      map[string]signedURL
+	lastSwept time.Time
+}
+
+func newOptionURLCache() *optionURLCache {
+	return &optionURLCache{
+		urls: map[string]signedURL{},
+	}
+}
+
+func (c *optionURLCache) get(fileKey string, now time.Time) (signedURL, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.urls[fileKey]
+	if !ok || cached.ExpiresAt.Sub(now) < optionURLMinTTL {
+		return signedURL{}, false
+	}
+	return cached, true
+}
+
+func (c *optionURLCache) set(fileKey string, url signedURL, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Stale urls are never handed out again, clear them out every so often
+	if now.Sub(c.lastSwept) > optionURLMinTTL {
+		for key, cached := range c.urls {
+			if cached.ExpiresAt.Sub(now) < optionURLMinTTL {
+				delete(c.urls, key)
+			}
+		}
+		c.lastSwept = now
+	}
+	c.urls[fileKey] = url
+}
+
+// mediaFormat is a file type options can be uploaded as, by extension.
+type mediaFormat struct {
+	MediaType MediaType
+	// Allowed for the upload's Content-Type
+	ContentTypes []string
+}
+
+var mediaFormats = map[string]mediaFormat{
+	".jpeg": {MediaType: MediaTypeImage, ContentTypes: []string{"image/jpeg"}},
+	".jpg":  {MediaType: MediaTypeImage, ContentTypes: []string{"image/jpeg"}},
+	".png":  {MediaType: MediaTypeImage, ContentTypes: []string{"image/png"}},
+	".gif":  {MediaType: MediaTypeImage, ContentTypes: []string{"image/gif"}},
+	".webp": {MediaType: MediaTypeImage, ContentTypes: []string{"image/webp"}},
+	".svg":  {MediaType: MediaTypeVector, ContentTypes: []string{"image/svg+xml"}},
+	".pdf":  {MediaType: MediaTypeDocument, ContentTypes: []string{"application/pdf"}},
+	".mp4":  {MediaType: MediaTypeVideo, ContentTypes: []string{"video/mp4"}},
+	".webm": {MediaType: MediaTypeVideo, ContentTypes: []string{"video/webm"}},
+	".mov":  {MediaType: MediaTypeVideo, ContentTypes: []string{"video/quicktime"}},
+}
+
+var maxFileSizes = map[MediaType]int64{
+	MediaTypeImage:    20 << 20,
+	MediaTypeVector:   2 << 20,
+	MediaTypeDocument: 50 << 20,
+	MediaTypeVideo:    200 << 20,
+}
+
+// errUnsupportedMedia is returned for uploads that will never process, so
+// aren't retried.
+var errUnsupportedMedia = errors.New("upload is not a supported file")
+
+func mediaTypeOf(fileKey string) (MediaType, bool) {
+	format, ok := mediaFormats[strings.ToLower(path.Ext(fileKey))]
+	return format.MediaType, ok
+}
+
+// verifyOptionFile checks the file has been uploaded and is within the size
+// limit for its media type.
+func (s *srv) verifyOptionFile(
+	ctx context.Context, fileKey string, mediaType MediaType,
+) error {
+	attrs, err := s.blobs.Stat(ctx, fileKey)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotExist) {
+			return ErrOptionFileNotFound
+		}
+		return fmt.Errorf("getting file metadata: %w", err)
+	}
+	if attrs.Size > maxFileSizes[mediaType] {
+		return ErrOptionFileTooLarge
+	}
+	return nil
+}
+
+// fileKey strips the bucket name from the start of an option's file_ref.
+func (s *srv) fileKey(fileRef string) string {
+	return strings.TrimPrefix(fileRef, s.blobs.Bucket()+"/")
+}
+
+// optionURL returns a signed url to GET one of an option's files, reusing the
+// last one signed while it has long enough left.
+func (s *srv) optionURL(ctx context.Context, fileKey string) (signedURL, error) {
+	now := time.Now()
+	if cached, ok := s.optionURLs.get(fileKey, now); ok {
+		return cached, nil
+	}
+	expiresAt := now.Add(optionURLTTL)
+	url, err := s.blobs.SignedGetURL(ctx, fileKey, expiresAt)
+	if err != nil {
+		return signedURL{}, fmt.Errorf("signing url: %w", err)
+	}
+	signed := signedURL{
+		URL:       url,
+		ExpiresAt: expiresAt,
+	}
+	s.optionURLs.set(fileKey, signed, now)
+	return signed, nil
+}
+
+const (
+	maxProcessingAttempts = 5
+	processingBackoff     = time.Minute
+	processingTimeout     = 5 * time.Minute
+)
+
+func (s *srv) ProcessPendingOptions(
+	ctx context.Context, request ProcessPendingOptionsRequest,
+) (ProcessPendingOptionsResponse, error) {
+	res := ProcessPendingOptionsResponse{}
+
+	// Long enough to process the whole batch, after which options are
+	// assumed lost with their instance and claimed again
+	lease := processingTimeout * time.Duration(request.Limit+1)
+	options, err := s.claimPendingOptions(ctx, request.Limit, lease)
+	if err != nil {
+		return res, fmt.Errorf("claiming pending options: %w", err)
+	}
+
+	for _, option := range options {
+		params := updatePostOptionProcessingParams{
+			ID:                 option.ID,
+			ProcessingStatus:   ProcessingStatusProcessed,
+			ProcessingAttempts: option.ProcessingAttempts,
+			NextProcessingAt:   option.NextProcessingAt,
+		}
+
+		processCtx, cancel := context.WithTimeout(ctx, processingTimeout)
+		processed, renditions, err := s.processOption(processCtx, option)
+		cancel()
+
+		if err != nil {
+			res.Failed++
+			processingError := err.Error()
+			params.ProcessingError = &processingError
+			params.ProcessingStatus = ProcessingStatusPending
+			params.NextProcessingAt = time.Now().Add(
+				processingBackoff * time.Duration(params.ProcessingAttempts),
+			)
+			if errors.Is(err, errUnsupportedMedia) ||
+				params.ProcessingAttempts >= maxProcessingAttempts {
+				params.ProcessingStatus = ProcessingStatusFailed
+			}
+		} else {
+			if processed.Width > 0 && processed.Height > 0 {
+				params.Width = &processed.Width
+				params.Height = &processed.Height
+			}
+			if processed.Blurhash != "" {
+				params.Blurhash = &processed.Blurhash
+			}
+		}
+
+		// Recorded on its own so one option failing to save doesn't undo
+		// the rest of the batch. It's retried once the lease is up.
+		if err := s.recordOptionProcessing(ctx, params, renditions); err != nil {
+			log.Printf("recording processing of option %s: %v", option.ID, err)
+			res.Failed++
+			continue
+		}
+		if params.ProcessingStatus == ProcessingStatusProcessed {
+			res.Processed++
+		}
+	}
+
+	return res, nil
+}
+
+// claimPendingOptions counts an attempt for each due option and pushes it
+// back until the lease is up, so other instances skip them while they're
+// processed outside of any tx.
+func (s *srv) claimPendingOptions(
+	ctx context.Context, limit int, lease time.Duration,
+) ([]postOption, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	options, err := getPendingPostOptions(ctx, tx, limit, DBLockForUpdateSkipLocked)
+	if err != nil {
+		return nil, fmt.Errorf("getting pending options: %w", err)
+	}
+
+	for i := range options {
+		options[i].ProcessingAttempts++
+		options[i].NextProcessingAt = time.Now().Add(lease)
+		if err = updatePostOptionProcessing(ctx, tx, updatePostOptionProcessingParams{
+			ID:                 options[i].ID,
+			ProcessingStatus:   options[i].ProcessingStatus,
+			ProcessingAttempts: options[i].ProcessingAttempts,
+			NextProcessingAt:   options[i].NextProcessingAt,
+			ProcessingError:    options[i].ProcessingError,
+			Width:              options[i].Width,
+			Height:             options[i].Height,
+			Blurhash:           options[i].Blurhash,
+		}); err != nil {
+			return nil, fmt.Errorf("updating option: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return options, nil
+}
+
+// recordOptionProcessing saves the outcome of processing an option, along
+// with its renditions if it was processed.
+func (s *srv) recordOptionProcessing(
+	ctx context.Context,
+	params updatePostOptionProcessingParams,
+	renditions []postOptionRendition,
+) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if params.ProcessingStatus == ProcessingStatusProcessed {
+		if err = replacePostOptionRenditions(ctx, tx, params.ID, renditions); err != nil {
+			return fmt.Errorf("replacing renditions: %w", err)
+		}
+	}
+	if err = updatePostOptionProcessing(ctx, tx, params); err != nil {
+		return fmt.Errorf("updating option: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+// processOption makes the renditions of the option's upload and saves them
+// to the blob store. Images are resized, SVGs are sanitised and used for every
+// size, and documents and videos get renditions of a preview.
+func (s *srv) processOption(
+	ctx context.Context, option postOption,
+) (*processedImage, []postOptionRendition, error) {
+	r, err := s.blobs.Open(ctx, s.fileKey(option.FileRef))
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w: file not found", errUnsupportedMedia)
+		}
+		return nil, nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer r.Close()
+
+	var processed *processedImage
+	switch option.MediaType {
+	case MediaTypeVector:
+		data, err := readUpload(r, option.MediaType)
+		if err != nil {
+			return nil, nil, err
+		}
+		svg, err := sanitiseSVG(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileKey := renditionKey(option.ID, "full", ".svg")
+		if err = s.blobs.Put(
+			ctx, fileKey, "image/svg+xml", bytes.NewReader(svg.Data),
+		); err != nil {
+			return nil, nil, fmt.Errorf("saving sanitised svg: %w", err)
+		}
+		renditions := []postOptionRendition{}
+		for _, rs := range renditionSizes {
+			renditions = append(renditions, postOptionRendition{
+				PostOptionID: option.ID,
+				PostID:       option.PostID,
+				Size:         rs.Size,
+				FileKey:      fileKey,
+				ContentType:  "image/svg+xml",
+				Width:        svg.Width,
+				Height:       svg.Height,
+			})
+		}
+		return &processedImage{Width: svg.Width, Height: svg.Height}, renditions, nil
+	case MediaTypeDocument, MediaTypeVideo:
+		preview, err := renderPreview(ctx, option.MediaType, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if processed, err = processImage(preview); err != nil {
+			return nil, nil, fmt.Errorf("processing preview: %w", err)
+		}
+	default:
+		data, err := readUpload(r, option.MediaType)
+		if err != nil {
+			return nil, nil, err
+		}
+		if processed, err = processImage(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	renditions := []postOptionRendition{}
+	for _, encoded := range processed.Renditions {
+		fileKey := renditionKey(
+			option.ID, strings.ToLower(string(encoded.Size)), encoded.Ext,
+		)
+		if err = s.blobs.Put(
+			ctx, fileKey, encoded.ContentType, bytes.NewReader(encoded.Data),
+		); err != nil {
+			return nil, nil, fmt.Errorf("saving %s rendition: %w", encoded.Size, err)
+		}
+		renditions = append(renditions, postOptionRendition{
+			PostOptionID: option.ID,
+			PostID:       option.PostID,
+			Size:         encoded.Size,
+			FileKey:      fileKey,
+			ContentType:  encoded.ContentType,
+			Width:        encoded.Width,
+			Height:       encoded.Height,
+		})
+	}
+	return processed, renditions, nil
+}
+
+func renditionKey(optionID uuid.UUID, name string, ext string) string {
+	return fmt.Sprintf("%s%s/%s%s", optionRenditionPrefix, optionID, name, ext)
+}
+
+// readUpload reads the whole upload, failing if it's over the size limit for
+// its media type.
+func readUpload(r io.Reader, mediaType MediaType) ([]byte, error) {
+	maxSize := maxFileSizes[mediaType]
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: larger than %vMB", errUnsupportedMedia, maxSize>>20)
+	}
+	return data, nil
+}
@@ -0,0 +1,168 @@
+package srvpost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"quorum-api/blobstore"
+	"time"
+)
+
+type SweepOrphanedFilesRequest struct {
+	// Files uploaded more recently than this are left alone, as they may be
+	// about to be attached to an option. Raised to minOrphanGracePeriod if
+	// it's shorter.
+	GracePeriod time.Duration
+	// Report the orphaned files without deleting them
+	DryRun bool
+}
+
+type SweepOrphanedFilesResponse struct {
+	DryRun bool
+	// Files that were deleted, or would have been on a dry run
+	Deleted []OrphanedFile
+	// Files that couldn't be deleted, they're retried on the next sweep
+	Failed int
+}
+
+type OrphanedFile struct {
+	Key        string
+	Size       int64
+	UploadedAt time.Time
+}
+
+// Clients can still be uploading until their signed url expires, so anything
+// newer than that is never an orphan.
+const minOrphanGracePeriod = optionUploadURLTTL + time.Hour
+
+// Keys are looked up in the db this many at a time
+const orphanBatchSize = 500
+
+// referencedFunc returns which of the keys are still used by an option.
+type referencedFunc func(ctx context.Context, keys []string) (map[string]bool, error)
+
+func (s *srv) SweepOrphanedFiles(
+	ctx context.Context, request SweepOrphanedFilesRequest,
+) (*SweepOrphanedFilesResponse, error) {
+	return s.sweepOrphanedFiles(ctx, request, s.referencedUploads, s.referencedRenditions)
+}
+
+func (s *srv) sweepOrphanedFiles(
+	ctx context.Context,
+	request SweepOrphanedFilesRequest,
+	referencedUploads referencedFunc,
+	referencedRenditions referencedFunc,
+) (*SweepOrphanedFilesResponse, error) {
+	gracePeriod := max(request.GracePeriod, minOrphanGracePeriod)
+	uploadedBefore := time.Now().Add(-gracePeriod)
+
+	uploads, err := s.orphanedFiles(ctx, optionUploadPrefix, uploadedBefore, referencedUploads)
+	if err != nil {
+		return nil, fmt.Errorf("finding orphaned uploads: %w", err)
+	}
+	renditions, err := s.orphanedFiles(ctx, optionRenditionPrefix, uploadedBefore, referencedRenditions)
+	if err != nil {
+		return nil, fmt.Errorf("finding orphaned renditions: %w", err)
+	}
+
+	res := &SweepOrphanedFilesResponse{
+		DryRun:  request.DryRun,
+		Deleted: []OrphanedFile{},
+	}
+	for _, file := range append(uploads, renditions...) {
+		if !request.DryRun {
+			// Another instance may have swept it first
+			if err = s.blobs.Delete(ctx, file.Key); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+				log.Printf("deleting orphaned file %q: %v", file.Key, err)
+				res.Failed++
+				continue
+			}
+		}
+		res.Deleted = append(res.Deleted, file)
+	}
+	return res, nil
+}
+
+// orphanedFiles lists the files under prefix uploaded before uploadedBefore
+// that referenced doesn't know about.
+func (s *srv) orphanedFiles(
+	ctx context.Context,
+	prefix string,
+	uploadedBefore time.Time,
+	referenced referencedFunc,
+) ([]OrphanedFile, error) {
+	objects, err := s.blobs.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+	candidates := []blobstore.Attrs{}
+	for _, o := range objects {
+		if o.CreatedAt.Before(uploadedBefore) {
+			candidates = append(candidates, o)
+		}
+	}
+
+	orphans := []OrphanedFile{}
+	for start := 0; start < len(candidates); start += orphanBatchSize {
+		batch := candidates[start:min(start+orphanBatchSize, len(candidates))]
+		keys := []string{}
+		for _, o := range batch {
+			keys = append(keys, o.Key)
+		}
+		inUse, err := referenced(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range batch {
+			if inUse[o.Key] {
+				continue
+			}
+			orphans = append(orphans, OrphanedFile{
+				Key:        o.Key,
+				Size:       o.Size,
+				UploadedAt: o.CreatedAt,
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// referencedUploads returns which of the keys are the file_ref of an option.
+func (s *srv) referencedUploads(
+	ctx context.Context, keys []string,
+) (map[string]bool, error) {
+	fileRefs := []string{}
+	for _, key := range keys {
+		fileRefs = append(fileRefs, fmt.Sprintf("%s/%s", s.blobs.Bucket(), key))
+	}
+	options, err := getPostOptionsByFilter(ctx, s.db, getPostOptionsByFilterParams{
+		FileRefs: fileRefs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting options: %w", err)
+	}
+	res := map[string]bool{}
+	for _, o := range options {
+		res[s.fileKey(o.FileRef)] = true
+	}
+	return res, nil
+}
+
+// referencedRenditions returns which of the keys belong to an option's
+// renditions.
+func (s *srv) referencedRenditions(
+	ctx context.Context, keys []string,
+) (map[string]bool, error) {
+	renditions, err := getPostOptionRenditionsByFilter(ctx, s.db, getPostOptionRenditionsByFilterParams{
+		FileKeys: keys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting renditions: %w", err)
+	}
+	res := map[string]bool{}
+	for _, r := range renditions {
+		res[r.FileKey] = true
+	}
+	return res, nil
+}
@@ -0,0 +1,180 @@
+package srvpost
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"quorum-api/blobstore"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orphansTest keeps files in a local blob store, with the options that use
+// them standing in for the db.
+type orphansTest struct {
+	t     *testing.T
+	dir   string
+	blobs *blobstore.Local
+	srv   *srv
+	// Option file_refs, and rendition keys
+	fileRefs   map[string]bool
+	renditions map[string]bool
+}
+
+func newOrphansTest(t *testing.T) *orphansTest {
+	t.Helper()
+	dir := t.TempDir()
+	blobs, err := blobstore.NewLocal(dir, "test-bucket", "http://blobs.test")
+	if err != nil {
+		t.Fatalf("creating blob store: %v", err)
+	}
+	return &orphansTest{
+		t:          t,
+		dir:        dir,
+		blobs:      blobs,
+		srv:        &srv{blobs: blobs},
+		fileRefs:   map[string]bool{},
+		renditions: map[string]bool{},
+	}
+}
+
+// put uploads the file as if it was uploaded age ago.
+func (ot *orphansTest) put(key string, age time.Duration) {
+	ot.t.Helper()
+	if err := ot.blobs.Put(context.Background(), key, "", strings.NewReader("data")); err != nil {
+		ot.t.Fatalf("putting %s: %v", key, err)
+	}
+	uploadedAt := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(ot.dir, filepath.FromSlash(key)), uploadedAt, uploadedAt); err != nil {
+		ot.t.Fatalf("setting upload time of %s: %v", key, err)
+	}
+}
+
+func (ot *orphansTest) sweep(request SweepOrphanedFilesRequest) *SweepOrphanedFilesResponse {
+	ot.t.Helper()
+	res, err := ot.srv.sweepOrphanedFiles(
+		context.Background(),
+		request,
+		func(ctx context.Context, keys []string) (map[string]bool, error) {
+			res := map[string]bool{}
+			for _, key := range keys {
+				res[key] = ot.fileRefs[ot.blobs.Bucket()+"/"+key]
+			}
+			return res, nil
+		},
+		func(ctx context.Context, keys []string) (map[string]bool, error) {
+			res := map[string]bool{}
+			for _, key := range keys {
+				res[key] = ot.renditions[key]
+			}
+			return res, nil
+		},
+	)
+	if err != nil {
+		ot.t.Fatalf("sweeping: %v", err)
+	}
+	return res
+}
+
+func (ot *orphansTest) exists(key string) bool {
+	ot.t.Helper()
+	_, err := ot.blobs.Stat(context.Background(), key)
+	if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+		ot.t.Fatalf("stating %s: %v", key, err)
+	}
+	return err == nil
+}
+
+func deletedKeys(res *SweepOrphanedFilesResponse) []string {
+	keys := []string{}
+	for _, file := range res.Deleted {
+		keys = append(keys, file.Key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestSweepOrphanedFiles(t *testing.T) {
+	ot := newOrphansTest(t)
+	const day = 24 * time.Hour
+
+	// An option's upload and renditions
+	ot.put("post-options/used.png", 2*day)
+	ot.put("post-option-renditions/used/thumb.jpg", 2*day)
+	ot.fileRefs["test-bucket/post-options/used.png"] = true
+	ot.renditions["post-option-renditions/used/thumb.jpg"] = true
+	// Never attached to an option
+	ot.put("post-options/abandoned.png", 2*day)
+	// Of an option that has been removed
+	ot.put("post-option-renditions/removed/thumb.jpg", 2*day)
+	ot.put("post-option-renditions/removed/full.jpg", 2*day)
+	// Not attached yet, but may be about to be
+	ot.put("post-options/recent.png", time.Hour)
+	ot.put("post-options/just-uploaded.png", 0)
+	// Not an option's file
+	ot.put("avatars/old.png", 2*day)
+
+	wantDeleted := []string{
+		"post-option-renditions/removed/full.jpg",
+		"post-option-renditions/removed/thumb.jpg",
+		"post-options/abandoned.png",
+	}
+	wantKept := []string{
+		"post-options/used.png",
+		"post-option-renditions/used/thumb.jpg",
+		"post-options/recent.png",
+		"post-options/just-uploaded.png",
+		"avatars/old.png",
+	}
+
+	res := ot.sweep(SweepOrphanedFilesRequest{GracePeriod: day, DryRun: true})
+	if !res.DryRun {
+		t.Error("got a dry run response that isn't marked as one")
+	}
+	if got := deletedKeys(res); !slices.Equal(got, wantDeleted) {
+		t.Errorf("dry run: got %v, want %v", got, wantDeleted)
+	}
+	for _, key := range append(wantDeleted, wantKept...) {
+		if !ot.exists(key) {
+			t.Errorf("dry run deleted %s", key)
+		}
+	}
+
+	res = ot.sweep(SweepOrphanedFilesRequest{GracePeriod: day})
+	if got := deletedKeys(res); !slices.Equal(got, wantDeleted) || res.Failed != 0 {
+		t.Errorf("got %v with %v failed, want %v", got, res.Failed, wantDeleted)
+	}
+	for _, key := range wantDeleted {
+		if ot.exists(key) {
+			t.Errorf("%s wasn't deleted", key)
+		}
+	}
+	for _, key := range wantKept {
+		if !ot.exists(key) {
+			t.Errorf("%s was deleted", key)
+		}
+	}
+
+	// Nothing's left to sweep
+	if res = ot.sweep(SweepOrphanedFilesRequest{GracePeriod: day}); len(res.Deleted) != 0 {
+		t.Errorf("got %v deleted on the second sweep", deletedKeys(res))
+	}
+}
+
+func TestSweepOrphanedFilesKeepsUploadsWithSignedURLs(t *testing.T) {
+	ot := newOrphansTest(t)
+	// Its signed url may still be in use
+	ot.put("post-options/uploading.png", optionUploadURLTTL)
+	ot.put("post-options/abandoned.png", minOrphanGracePeriod+time.Minute)
+
+	res := ot.sweep(SweepOrphanedFilesRequest{GracePeriod: 0})
+	if got := deletedKeys(res); !slices.Equal(got, []string{"post-options/abandoned.png"}) {
+		t.Errorf("got %v, want only the upload older than the minimum grace period", got)
+	}
+	if !ot.exists("post-options/uploading.png") {
+		t.Error("upload inside the minimum grace period was deleted")
+	}
+}
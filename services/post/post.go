@@ -2,13 +2,19 @@ package srvpost
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"path/filepath"
+	"quorum-api/blobstore"
+	"quorum-api/database"
+	"quorum-api/pubsub"
+	"slices"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/sync/errgroup"
@@ -19,51 +25,165 @@ type SRVPost interface {
 	UpsertPost(ctx context.Context, request UpsertPostRequest) error
 	GetOptionsByFilter(ctx context.Context, request GetOptionsByFilterRequest) ([]Option, error)
 	GetVotesByFilter(ctx context.Context, request GetVotesByFilterRequest) ([]Vote, error)
+	GetResultsByFilter(ctx context.Context, request GetResultsByFilterRequest) ([]Results, error)
 	GenerateSignedPostOptionURL(ctx context.Context, request GenerateSignedPostOptionURLRequest) (*GenerateSignedPostOptionURLResponse, error)
 	SubmitVote(ctx context.Context, request SubmitVoteRequest) (*SubmitVoteResponse, error)
+	ChangeVote(ctx context.Context, request ChangeVoteRequest) (*ChangeVoteResponse, error)
+	RetractVote(ctx context.Context, request RetractVoteRequest) (*RetractVoteResponse, error)
+	SubscribeToPostEvents(ctx context.Context, request SubscribeToPostEventsRequest) (<-chan PostEvent, error)
+	RecordStatusTransitions(ctx context.Context, request RecordStatusTransitionsRequest) ([]StatusTransition, error)
+	// RemoveCustomer applies the account deletion policy to the customer's
+	// posts and votes, in the tx the customer is deleted in:
+	//   - votes are kept so results don't change, but no longer link to them
+	//   - posts that haven't opened are deleted
+	//   - live posts are closed, so voters see the results
+	//   - closed posts are kept
+	RemoveCustomer(ctx context.Context, tx database.Q, request RemoveCustomerRequest) error
+	// SubmitBallot casts the customer's ballot, or replaces it if they've
+	// already voted and the post allows vote changes.
+	SubmitBallot(ctx context.Context, request SubmitBallotRequest) (*SubmitBallotResponse, error)
+	GetFeedbackByFilter(ctx context.Context, request GetFeedbackByFilterRequest) ([]Feedback, error)
+	// RateOptions scores options on a RATING post, replacing the customer's
+	// earlier scores for them.
+	RateOptions(ctx context.Context, request RateOptionsRequest) (*RateOptionsResponse, error)
+	// GetNextMatchup returns the customer's unanswered matchup on a PAIRWISE
+	// post, or picks a random pair they haven't compared yet. Nil once
+	// they've compared every pair.
+	GetNextMatchup(ctx context.Context, request GetNextMatchupRequest) (*Matchup, error)
+	AnswerMatchup(ctx context.Context, request AnswerMatchupRequest) (*AnswerMatchupResponse, error)
+	GetCriteriaByFilter(ctx context.Context, request GetCriteriaByFilterRequest) ([]Criterion, error)
+	GetCriterionResultsByFilter(ctx context.Context, request GetCriterionResultsByFilterRequest) ([]CriterionResult, error)
+	// ProcessPendingOptions makes resized renditions of option uploads that
+	// haven't been processed yet, retrying failures with backoff.
+	ProcessPendingOptions(ctx context.Context, request ProcessPendingOptionsRequest) (ProcessPendingOptionsResponse, error)
+	// SweepOrphanedFiles deletes option uploads and renditions that no option
+	// uses any more, e.g. uploads that were never attached to a post or
+	// belonged to removed options.
+	SweepOrphanedFiles(ctx context.Context, request SweepOrphanedFilesRequest) (*SweepOrphanedFilesResponse, error)
+	GetInvitesByFilter(ctx context.Context, request GetInvitesByFilterRequest) ([]Invite, error)
+	// CreateInvites creates invites to vote on the author's post, either a
+	// shareable link or a single use invite per email.
+	CreateInvites(ctx context.Context, request CreateInvitesRequest) ([]Invite, error)
+	// RevokeInvite stops the invite being used to vote, votes already cast
+	// with it are kept.
+	RevokeInvite(ctx context.Context, request RevokeInviteRequest) (*RevokeInviteResponse, error)
 }
 
 type GetPostsByFilterRequest struct {
-	IDs []uuid.UUID
+	IDs          []uuid.UUID
+	AuthorIDs    []uuid.UUID
+	WorkspaceIDs []uuid.UUID
+	Categories   []PostCategory
+	DesignPhases []DesignPhase
+	Statuses     []PostStatus
+	// When set, DRAFT posts are only returned if they were authored by this
+	// customer.
+	DraftsAuthorID uuid.NullUUID
+	// When set, only posts the viewer can see are returned. UNLISTED posts
+	// are left out unless they're requested by ID or authored by the viewer.
+	Viewer  *PostViewer
+	OrderBy PostOrder
+	// Only return posts that sort after this cursor. Must have been created
+	// with the same OrderBy.
+	After *PostCursor
+	// Max number of posts to return, 0 for no limit.
+	Limit int
+}
+
+// PostViewer is who is looking at a post, for checking its visibility.
+type PostViewer struct {
+	CustomerID uuid.NullUUID
+	// Workspaces the customer is a member of
+	WorkspaceIDs []uuid.UUID
+}
+
+// PostCursor marks a position in a list of posts, for keyset pagination.
+type PostCursor struct {
+	OrderBy   PostOrder `json:"o"`
+	SortValue string    `json:"v"`
+	ID        uuid.UUID `json:"i"`
 }
 
 type Post struct {
-	ID          uuid.UUID
-	DesignPhase *DesignPhase
-	Context     *string
-	Category    *PostCategory
-	OpensAt     *time.Time
-	ClosesAt    *time.Time
-	AuthorID    uuid.UUID
-	OptionIDs   []uuid.UUID
-	VoteIDs     []uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID                uuid.UUID
+	DesignPhase       *DesignPhase
+	Context           *string
+	Category          *PostCategory
+	OpensAt           *time.Time
+	ClosesAt          *time.Time
+	AuthorID          uuid.UUID
+	OptionIDs         []uuid.UUID
+	VoteIDs           []uuid.UUID
+	ResultsVisibility ResultsVisibility
+	// Whether voters can change or retract their vote while the post is live
+	AllowVoteChanges bool
+	Visibility       PostVisibility
+	// Set when Visibility is WORKSPACE
+	WorkspaceID *uuid.UUID
+	// Only voters holding an invite can vote
+	Restricted bool
+	VotingMode VotingMode
+	// Most options a MULTI_SELECT ballot can pick, nil for any number
+	MaxSelections *int
+	FeedbackMode  FeedbackMode
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 type Option struct {
-	ID       uuid.UUID
-	URL      *string
+	ID        uuid.UUID
+	PostID    uuid.UUID
+	MediaType MediaType
+	// Signed urls to GET the option's image at each size, a preview for
+	// documents and videos. Missing until the option has been processed, so
+	// uploads are never served before their metadata has been stripped.
+	URLs map[ImageSize]string
+	// Signed url to GET the document or video itself, otherwise the FULL
+	// image
+	FileURL *string
+	// When the first of the urls stops working
+	URLExpiresAt     *time.Time
+	ProcessingStatus ProcessingStatus
+	// Of the upload in pixels, nil until it has been processed
+	Width    *int
+	Height   *int
+	Blurhash *string
 	Position int
 }
 
 type Vote struct {
-	ID         uuid.UUID
-	PostID     uuid.UUID
-	CustomerID uuid.UUID
-	OptionID   uuid.UUID
-	Reason     *string
+	ID     uuid.UUID
+	PostID uuid.UUID
+	// Null once the voter has deleted their account
+	CustomerID uuid.NullUUID
+	// The first choice
+	OptionID uuid.UUID
+	// Every option picked, in rank order for RANKED posts
+	OptionIDs []uuid.UUID
+	Reason    *string
 }
 
 type UpsertPostRequest struct {
-	ID          uuid.UUID
-	AuthorID    uuid.UUID
-	DesignPhase *DesignPhase
-	Context     *string
-	Category    *PostCategory
-	OpensAt     *time.Time
-	ClosesAt    *time.Time
-	Options     []*UpsertPostOptionRequest
+	ID                uuid.UUID
+	AuthorID          uuid.UUID
+	DesignPhase       *DesignPhase
+	Context           *string
+	Category          *PostCategory
+	OpensAt           *time.Time
+	ClosesAt          *time.Time
+	ResultsVisibility *ResultsVisibility
+	AllowVoteChanges  *bool
+	Visibility        *PostVisibility
+	// Required when Visibility is WORKSPACE, the author must be a member
+	WorkspaceID *uuid.UUID
+	Restricted  *bool
+	VotingMode  *VotingMode
+	// Only for MULTI_SELECT posts, cleared when the mode changes
+	MaxSelections *int
+	FeedbackMode  *FeedbackMode
+	Options       []*UpsertPostOptionRequest
+	// Replaces the post's criteria, nil to leave them as they are
+	Criteria []*UpsertPostCriterionRequest
 }
 
 type UpsertPostOptionRequest struct {
@@ -74,13 +194,52 @@ type UpsertPostOptionRequest struct {
 }
 
 type GetOptionsByFilterRequest struct {
-	IDs []uuid.UUID
+	IDs     []uuid.UUID
+	PostIDs []uuid.UUID
 }
 
 type GetVotesByFilterRequest struct {
 	IDs []uuid.UUID
 }
 
+type GetResultsByFilterRequest struct {
+	PostIDs []uuid.UUID
+}
+
+// Results are the vote tallies for a single post.
+type Results struct {
+	PostID uuid.UUID
+	// Number of ballots cast
+	TotalVotes int
+	// Ordered by option position. Counts every pick on MULTI_SELECT posts, so
+	// percentages are of voters and can add up to more than 100. Only first
+	// choices are counted on RANKED posts.
+	Options []OptionResult
+	// Nil when there are no votes, or the top options are tied. Decided by
+	// the last round on RANKED posts.
+	WinningOptionID *uuid.UUID
+	// Instant-runoff rounds, only for RANKED posts
+	Rounds []RankedRound
+}
+
+type OptionResult struct {
+	OptionID  uuid.UUID
+	VoteCount int
+	// Between 0 and 100
+	VotePercentage float64
+}
+
+// Option returns the result for the given option, or nil if the option is not
+// part of the post.
+func (r Results) Option(optionID uuid.UUID) *OptionResult {
+	for i := range r.Options {
+		if r.Options[i].OptionID == optionID {
+			return &r.Options[i]
+		}
+	}
+	return nil
+}
+
 type GenerateSignedPostOptionURLRequest struct {
 	FileName    string
 	ContentType string
@@ -96,6 +255,14 @@ type SubmitVoteRequest struct {
 	OptionID   uuid.UUID
 	CustomerID uuid.UUID
 	Reason     *string
+	// Workspaces the voter is a member of, only members can vote on
+	// WORKSPACE posts
+	WorkspaceIDs []uuid.UUID
+	// The token from the invite link the voter was sent. Needed for
+	// restricted posts unless they've used an invite before.
+	InviteToken *string
+	// Answers to the post's criteria about the option, all optional
+	Criteria []CriterionResponse
 }
 
 type SubmitVoteResponse struct {
@@ -103,6 +270,100 @@ type SubmitVoteResponse struct {
 	VoteID uuid.UUID
 }
 
+type ChangeVoteRequest struct {
+	OptionID   uuid.UUID
+	CustomerID uuid.UUID
+	Reason     *string
+	// Workspaces the voter is a member of, only members can vote on
+	// WORKSPACE posts
+	WorkspaceIDs []uuid.UUID
+	// Replaces the answers to the post's criteria, which are about the new
+	// option
+	Criteria []CriterionResponse
+}
+
+type ChangeVoteResponse struct {
+	PostID uuid.UUID
+	VoteID uuid.UUID
+}
+
+type RetractVoteRequest struct {
+	PostID     uuid.UUID
+	CustomerID uuid.UUID
+}
+
+type RetractVoteResponse struct {
+	PostID uuid.UUID
+}
+
+type SubmitBallotRequest struct {
+	PostID uuid.UUID
+	// In rank order for RANKED posts, SINGLE posts take exactly one
+	OptionIDs  []uuid.UUID
+	CustomerID uuid.UUID
+	Reason     *string
+	// Workspaces the voter is a member of, only members can vote on
+	// WORKSPACE posts
+	WorkspaceIDs []uuid.UUID
+	// The token from the invite link the voter was sent. Needed for
+	// restricted posts unless they've used an invite before.
+	InviteToken *string
+	// Answers to the post's criteria about the first choice, replacing any
+	// earlier answers
+	Criteria []CriterionResponse
+}
+
+type SubmitBallotResponse struct {
+	PostID uuid.UUID
+	VoteID uuid.UUID
+}
+
+type SubscribeToPostEventsRequest struct {
+	PostID uuid.UUID
+}
+
+type RecordStatusTransitionsRequest struct {
+	// Max number of posts to transition
+	Limit int
+	// Optional, called for each transition before the post is locked, so it
+	// can do slow work. The returned TransitionEffect, if any, runs in the tx
+	// the transition is recorded in. Returning an error skips the post until
+	// the next call.
+	PrepareTransition func(ctx context.Context, t StatusTransition) (TransitionEffect, error)
+}
+
+// TransitionEffect is run in the same tx as a status transition, so it's
+// only recorded if the effect succeeds.
+type TransitionEffect func(ctx context.Context, tx database.Q) error
+
+type RemoveCustomerRequest struct {
+	CustomerID uuid.UUID
+}
+
+type StatusTransition struct {
+	PostID     uuid.UUID
+	AuthorID   uuid.UUID
+	FromStatus PostStatus
+	ToStatus   PostStatus
+}
+
+type PostEventType string
+
+const (
+	PostEventTypeVoteAdded     PostEventType = "VOTE_ADDED"
+	PostEventTypeVoteChanged   PostEventType = "VOTE_CHANGED"
+	PostEventTypeVoteRetracted PostEventType = "VOTE_RETRACTED"
+	// The post itself was changed, e.g. its open or close time
+	PostEventTypeUpdated PostEventType = "UPDATED"
+)
+
+type PostEvent struct {
+	Type   PostEventType `json:"type"`
+	PostID uuid.UUID     `json:"postId"`
+	// Set for vote events
+	VoteID *uuid.UUID `json:"voteId,omitempty"`
+}
+
 var ErrTooManyOptions = errors.New("exceeded the maximum amount of options")
 
 var ErrTooFewOptions = errors.New("at least 2 options are required to create a post")
@@ -119,29 +380,82 @@ var ErrClosesAtNotSet = errors.New("must set close time when publishing a post")
 
 var ErrOptionPositionsInvalid = errors.New("must provide unique position from 1 to 6")
 
-var ErrUnsupportedFileType = errors.New("only .jpeg, .png and .gif files are supported")
+var ErrUnsupportedFileType = errors.New(
+	"only .jpeg, .jpg, .png, .gif, .webp, .svg, .pdf, .mp4, .webm and .mov files are supported, with a matching content type",
+)
+
+var ErrOptionFileTooLarge = errors.New(
+	"option files must be at most 20MB for images, 2MB for SVGs, 50MB for PDFs and 200MB for videos",
+)
 
 var ErrOptionNotFound = errors.New("option not found")
 
-func New(db *sqlx.DB, bucket *storage.BucketHandle, bucketName string) SRVPost {
+var ErrPostNotFound = errors.New("post not found")
+
+var ErrPostNotLive = errors.New("post is not open for voting")
+
+var ErrVoteAlreadyCast = errors.New("a vote has already been cast on this post")
+
+var ErrVoteNotFound = errors.New("no vote has been cast on this post")
+
+var ErrWorkspaceRequired = errors.New("workspace posts must be in a workspace, and only workspace posts can be")
+
+var ErrInvalidCursor = errors.New("cursor is invalid or was created with a different order")
+
+var ErrInvalidMaxSelections = errors.New("max selections can only be set on MULTI_SELECT posts, from 1 to 6")
+
+func New(
+	db *sqlx.DB,
+	blobs blobstore.BlobStore,
+	ps pubsub.PubSub,
+) SRVPost {
 	return &srv{
 		db:         db,
-		bucket:     bucket,
-		bucketName: bucketName,
+		blobs:      blobs,
+		pubsub:     ps,
+		optionURLs: newOptionURLCache(),
 	}
 }
 
 type srv struct {
 	db         *sqlx.DB
-	bucket     *storage.BucketHandle
-	bucketName string
+	blobs      blobstore.BlobStore
+	pubsub     pubsub.PubSub
+	optionURLs *optionURLCache
 }
 
 func (s *srv) GetPostsByFilter(
 	ctx context.Context, request GetPostsByFilterRequest,
 ) ([]Post, error) {
+	if request.OrderBy == "" {
+		request.OrderBy = PostOrderOpensAtDesc
+	}
+	if request.After != nil && request.After.OrderBy != request.OrderBy {
+		return nil, ErrInvalidCursor
+	}
 	params := getPostsByFilterParams{
-		IDs: request.IDs,
+		IDs:            request.IDs,
+		AuthorIDs:      request.AuthorIDs,
+		DraftsAuthorID: request.DraftsAuthorID,
+		WorkspaceIDs:   request.WorkspaceIDs,
+		OrderBy:        request.OrderBy,
+		After:          request.After,
+		Limit:          request.Limit,
+	}
+	if request.Viewer != nil {
+		params.Viewer = &postViewerParams{
+			CustomerID:   request.Viewer.CustomerID,
+			WorkspaceIDs: request.Viewer.WorkspaceIDs,
+		}
+	}
+	for _, c := range request.Categories {
+		params.Categories = append(params.Categories, string(c))
+	}
+	for _, dp := range request.DesignPhases {
+		params.DesignPhases = append(params.DesignPhases, string(dp))
+	}
+	for _, st := range request.Statuses {
+		params.Statuses = append(params.Statuses, string(st))
 	}
 	posts, err := getPostsByFilter(
 		ctx, s.db, params, DBLockUnspecified,
@@ -152,24 +466,153 @@ func (s *srv) GetPostsByFilter(
 
 	res := []Post{}
 	for _, p := range posts {
-		res = append(res, Post{
-			ID:          p.ID,
-			DesignPhase: p.DesignPhase,
-			Context:     p.Context,
-			Category:    p.Category,
-			OpensAt:     p.OpensAt,
-			ClosesAt:    p.ClosesAt,
-			AuthorID:    p.AuthorID,
-			OptionIDs:   p.OptionIDs,
-			VoteIDs:     p.VoteIDs,
-			CreatedAt:   p.CreatedAt,
-			UpdatedAt:   p.UpdatedAt,
-		})
+		res = append(res, postFromRow(p))
 	}
 
 	return res, nil
 }
 
+func postFromRow(p post) Post {
+	return Post{
+		ID:                p.ID,
+		DesignPhase:       p.DesignPhase,
+		Context:           p.Context,
+		Category:          p.Category,
+		OpensAt:           p.OpensAt,
+		ClosesAt:          p.ClosesAt,
+		AuthorID:          p.AuthorID,
+		OptionIDs:         p.OptionIDs,
+		VoteIDs:           p.VoteIDs,
+		ResultsVisibility: p.ResultsVisibility,
+		AllowVoteChanges:  p.AllowVoteChanges,
+		Visibility:        p.Visibility,
+		WorkspaceID:       p.WorkspaceID,
+		Restricted:        p.Restricted,
+		VotingMode:        p.VotingMode,
+		MaxSelections:     p.MaxSelections,
+		FeedbackMode:      p.FeedbackMode,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+}
+
+// Cursor returns the position of the post when sorted by orderBy.
+func (p Post) Cursor(orderBy PostOrder) PostCursor {
+	cursor := PostCursor{
+		OrderBy: orderBy,
+		ID:      p.ID,
+	}
+	switch orderBy {
+	case PostOrderCreatedAtDesc:
+		cursor.SortValue = p.CreatedAt.Format(time.RFC3339Nano)
+	case PostOrderClosesAtAsc:
+		cursor.SortValue = "infinity"
+		if p.ClosesAt != nil {
+			cursor.SortValue = p.ClosesAt.Format(time.RFC3339Nano)
+		}
+	default:
+		cursor.SortValue = "-infinity"
+		if p.OpensAt != nil {
+			cursor.SortValue = p.OpensAt.Format(time.RFC3339Nano)
+		}
+	}
+	return cursor
+}
+
+// Status works out where the post is in its lifecycle at the given time.
+func (p Post) Status(now time.Time) PostStatus {
+	if p.OpensAt == nil || p.ClosesAt == nil || p.OpensAt.After(now) {
+		return PostStatusDraft
+	}
+	if p.ClosesAt.After(now) {
+		return PostStatusLive
+	}
+	return PostStatusClosed
+}
+
+// NextStatusChangeAt returns when the post will next change status by itself,
+// or nil if it won't without being updated.
+func (p Post) NextStatusChangeAt(now time.Time) *time.Time {
+	switch p.Status(now) {
+	case PostStatusDraft:
+		if p.OpensAt != nil && p.ClosesAt != nil {
+			return p.OpensAt
+		}
+	case PostStatusLive:
+		return p.ClosesAt
+	}
+	return nil
+}
+
+// ResultsVisibleTo reports whether the viewer can see the tallies and votes of
+// the post. The author can always see them, everyone else has to wait for the
+// post to close so live votes aren't biased.
+func (p Post) ResultsVisibleTo(viewerID uuid.NullUUID, now time.Time) bool {
+	if viewerID.Valid && viewerID.UUID == p.AuthorID {
+		return true
+	}
+	return p.Status(now) == PostStatusClosed
+}
+
+// VisibleTo reports whether the viewer can see the post. Drafts can only be
+// seen by their author. UNLISTED posts can be seen by anyone with a link,
+// WORKSPACE posts only by the workspace's members.
+func (p Post) VisibleTo(viewer PostViewer, now time.Time) bool {
+	if viewer.CustomerID.Valid && viewer.CustomerID.UUID == p.AuthorID {
+		return true
+	}
+	if p.Status(now) == PostStatusDraft {
+		return false
+	}
+	if p.Visibility != PostVisibilityWorkspace {
+		return true
+	}
+	for _, workspaceID := range viewer.WorkspaceIDs {
+		if p.WorkspaceID != nil && *p.WorkspaceID == workspaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// VoterVisibleTo reports whether the viewer can see who cast the vote.
+// Customers can always see their own votes.
+func (p Post) VoterVisibleTo(vote Vote, viewerID uuid.NullUUID, now time.Time) bool {
+	if viewerID.Valid && vote.CustomerID.Valid && viewerID.UUID == vote.CustomerID.UUID {
+		return true
+	}
+	if p.ResultsVisibility != ResultsVisibilityAttributed {
+		return false
+	}
+	return p.ResultsVisibleTo(viewerID, now)
+}
+
+// Encode returns an opaque string representation of the cursor.
+func (c PostCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodePostCursor(encoded string) (*PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor := PostCursor{}
+	if err = json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, ok := postOrderClauses[cursor.OrderBy]; !ok {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortValue != "infinity" && cursor.SortValue != "-infinity" {
+		if _, err = time.Parse(time.RFC3339Nano, cursor.SortValue); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
 func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -192,13 +635,44 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 
 	if existingPost == nil {
 		postToUpsert := upsertPostParams{
-			ID:          request.ID,
-			AuthorID:    request.AuthorID,
-			DesignPhase: request.DesignPhase,
-			Context:     request.Context,
-			Category:    request.Category,
-			OpensAt:     request.OpensAt,
-			ClosesAt:    request.ClosesAt,
+			ID:                request.ID,
+			AuthorID:          request.AuthorID,
+			DesignPhase:       request.DesignPhase,
+			Context:           request.Context,
+			Category:          request.Category,
+			OpensAt:           request.OpensAt,
+			ClosesAt:          request.ClosesAt,
+			ResultsVisibility: ResultsVisibilityAnonymous,
+			AllowVoteChanges:  true,
+			Visibility:        PostVisibilityPublic,
+			WorkspaceID:       request.WorkspaceID,
+			VotingMode:        VotingModeSingle,
+			MaxSelections:     request.MaxSelections,
+			FeedbackMode:      FeedbackModeNone,
+		}
+		if request.ResultsVisibility != nil {
+			postToUpsert.ResultsVisibility = *request.ResultsVisibility
+		}
+		if request.AllowVoteChanges != nil {
+			postToUpsert.AllowVoteChanges = *request.AllowVoteChanges
+		}
+		if request.Visibility != nil {
+			postToUpsert.Visibility = *request.Visibility
+		}
+		if request.Restricted != nil {
+			postToUpsert.Restricted = *request.Restricted
+		}
+		if request.VotingMode != nil {
+			postToUpsert.VotingMode = *request.VotingMode
+		}
+		if request.FeedbackMode != nil {
+			postToUpsert.FeedbackMode = *request.FeedbackMode
+		}
+		if !validMaxSelections(postToUpsert.VotingMode, postToUpsert.MaxSelections) {
+			return ErrInvalidMaxSelections
+		}
+		if (postToUpsert.Visibility == PostVisibilityWorkspace) != (postToUpsert.WorkspaceID != nil) {
+			return ErrWorkspaceRequired
 		}
 		if postToUpsert.OpensAt != nil &&
 			postToUpsert.OpensAt.Before(time.Now().Add(-time.Minute*10)) {
@@ -215,6 +689,11 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 			return ErrTooManyOptions
 		}
 
+		criteriaToInsert, err := criteriaToReplace(postToUpsert.ID, request.Criteria)
+		if err != nil {
+			return err
+		}
+
 		postWillBeLive := postToUpsert.OpensAt != nil && postToUpsert.OpensAt.Before(time.Now())
 
 		if postWillBeLive && len(request.Options) < 2 {
@@ -238,31 +717,30 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 			}
 		}
 
-		g, ctx := errgroup.WithContext(ctx)
+		g, gCtx := errgroup.WithContext(ctx)
 		optionsToInsert := []postOption{}
 		for _, o := range request.Options {
-			if s.bucketName != o.BucketName {
+			if s.blobs.Bucket() != o.BucketName {
 				return fmt.Errorf("invalid bucket name")
 			}
 			fileRef := fmt.Sprintf(
 				"%s/%s",
-				s.bucketName, o.FileKey,
+				s.blobs.Bucket(), o.FileKey,
 			)
+			mediaType, ok := mediaTypeOf(o.FileKey)
+			if !ok {
+				return ErrUnsupportedFileType
+			}
 			optionsToInsert = append(optionsToInsert, postOption{
-				ID:       o.ID,
-				PostID:   postToUpsert.ID,
-				Position: o.Position,
-				FileRef:  fileRef,
+				ID:        o.ID,
+				PostID:    postToUpsert.ID,
+				Position:  o.Position,
+				FileRef:   fileRef,
+				MediaType: mediaType,
 			})
 			fileKey := o.FileKey
 			g.Go(func() error {
-				if _, err := s.bucket.Object(fileKey).Attrs(ctx); err != nil {
-					if err == storage.ErrObjectNotExist {
-						return ErrOptionFileNotFound
-					}
-					return fmt.Errorf("getting file metadata: %w", err)
-				}
-				return nil
+				return s.verifyOptionFile(gCtx, fileKey, mediaType)
 			})
 		}
 
@@ -276,6 +754,10 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 			}
 		}
 
+		if err = replacePostCriteria(ctx, tx, postToUpsert.ID, criteriaToInsert); err != nil {
+			return fmt.Errorf("inserting criteria: %w", err)
+		}
+
 		if err = g.Wait(); err != nil {
 			return fmt.Errorf("verifying option file: %w", err)
 		}
@@ -283,17 +765,29 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 		if err = tx.Commit(); err != nil {
 			return fmt.Errorf("comitting tx: %w", err)
 		}
+		s.publishPostEvent(ctx, PostEvent{
+			Type:   PostEventTypeUpdated,
+			PostID: request.ID,
+		})
 		return nil
 	}
 
 	postToUpsert := upsertPostParams{
-		ID:          existingPost.ID,
-		AuthorID:    existingPost.AuthorID,
-		DesignPhase: existingPost.DesignPhase,
-		Context:     existingPost.Context,
-		Category:    existingPost.Category,
-		OpensAt:     existingPost.OpensAt,
-		ClosesAt:    existingPost.ClosesAt,
+		ID:                existingPost.ID,
+		AuthorID:          existingPost.AuthorID,
+		DesignPhase:       existingPost.DesignPhase,
+		Context:           existingPost.Context,
+		Category:          existingPost.Category,
+		OpensAt:           existingPost.OpensAt,
+		ClosesAt:          existingPost.ClosesAt,
+		ResultsVisibility: existingPost.ResultsVisibility,
+		AllowVoteChanges:  existingPost.AllowVoteChanges,
+		Visibility:        existingPost.Visibility,
+		WorkspaceID:       existingPost.WorkspaceID,
+		Restricted:        existingPost.Restricted,
+		VotingMode:        existingPost.VotingMode,
+		MaxSelections:     existingPost.MaxSelections,
+		FeedbackMode:      existingPost.FeedbackMode,
 	}
 
 	if request.AuthorID != existingPost.AuthorID {
@@ -336,6 +830,35 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 	if request.OpensAt != nil {
 		postToUpsert.OpensAt = request.OpensAt
 	}
+	if request.ResultsVisibility != nil {
+		postToUpsert.ResultsVisibility = *request.ResultsVisibility
+	}
+	if request.AllowVoteChanges != nil {
+		postToUpsert.AllowVoteChanges = *request.AllowVoteChanges
+	}
+	if request.Visibility != nil {
+		postToUpsert.Visibility = *request.Visibility
+		postToUpsert.WorkspaceID = request.WorkspaceID
+	}
+	if request.Restricted != nil {
+		postToUpsert.Restricted = *request.Restricted
+	}
+	if request.VotingMode != nil && *request.VotingMode != postToUpsert.VotingMode {
+		postToUpsert.VotingMode = *request.VotingMode
+		postToUpsert.MaxSelections = nil
+	}
+	if request.MaxSelections != nil {
+		postToUpsert.MaxSelections = request.MaxSelections
+	}
+	if request.FeedbackMode != nil {
+		postToUpsert.FeedbackMode = *request.FeedbackMode
+	}
+	if !validMaxSelections(postToUpsert.VotingMode, postToUpsert.MaxSelections) {
+		return ErrInvalidMaxSelections
+	}
+	if (postToUpsert.Visibility == PostVisibilityWorkspace) != (postToUpsert.WorkspaceID != nil) {
+		return ErrWorkspaceRequired
+	}
 
 	if postWillBeLive && len(request.Options) < 2 {
 		return ErrTooFewOptions
@@ -345,6 +868,14 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 		return ErrTooManyOptions
 	}
 
+	var criteriaToUpsert []postCriterion
+	if request.Criteria != nil {
+		criteriaToUpsert, err = criteriaToReplace(postToUpsert.ID, request.Criteria)
+		if err != nil {
+			return err
+		}
+	}
+
 	for i := 1; i <= len(request.Options); i++ {
 		optionFound := false
 		for _, o := range request.Options {
@@ -387,31 +918,30 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 		}
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
+	g, gCtx := errgroup.WithContext(ctx)
 	optionsToInsert := []postOption{}
 	for _, o := range request.Options {
-		if s.bucketName != o.BucketName {
+		if s.blobs.Bucket() != o.BucketName {
 			return fmt.Errorf("invalid bucket name")
 		}
 		fileRef := fmt.Sprintf(
 			"%s/%s",
-			s.bucketName, o.FileKey,
+			s.blobs.Bucket(), o.FileKey,
 		)
+		mediaType, ok := mediaTypeOf(o.FileKey)
+		if !ok {
+			return ErrUnsupportedFileType
+		}
 		optionsToInsert = append(optionsToInsert, postOption{
-			ID:       o.ID,
-			PostID:   postToUpsert.ID,
-			Position: o.Position,
-			FileRef:  fileRef,
+			ID:        o.ID,
+			PostID:    postToUpsert.ID,
+			Position:  o.Position,
+			FileRef:   fileRef,
+			MediaType: mediaType,
 		})
 		fileKey := o.FileKey
 		g.Go(func() error {
-			if _, err := s.bucket.Object(fileKey).Attrs(ctx); err != nil {
-				if err == storage.ErrObjectNotExist {
-					return ErrOptionFileNotFound
-				}
-				return fmt.Errorf("getting file metadata: %w", err)
-			}
-			return nil
+			return s.verifyOptionFile(gCtx, fileKey, mediaType)
 		})
 	}
 
@@ -420,7 +950,6 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 	}
 
 	if len(optionIDsToDelete) > 0 {
-		// todo: probs want a cron to delete dangly files in bucket
 		if err = deletePostOptions(ctx, tx, optionIDsToDelete); err != nil {
 			return fmt.Errorf("deleting post options: %w", err)
 		}
@@ -438,6 +967,12 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 		}
 	}
 
+	if request.Criteria != nil {
+		if err = replacePostCriteria(ctx, tx, postToUpsert.ID, criteriaToUpsert); err != nil {
+			return fmt.Errorf("replacing criteria: %w", err)
+		}
+	}
+
 	if err = g.Wait(); err != nil {
 		return fmt.Errorf("verifying option file: %w", err)
 	}
@@ -445,6 +980,10 @@ func (s *srv) UpsertPost(ctx context.Context, request UpsertPostRequest) error {
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("comitting tx: %w", err)
 	}
+	s.publishPostEvent(ctx, PostEvent{
+		Type:   PostEventTypeUpdated,
+		PostID: request.ID,
+	})
 	return nil
 }
 
@@ -452,7 +991,8 @@ func (s *srv) GetOptionsByFilter(
 	ctx context.Context, request GetOptionsByFilterRequest,
 ) ([]Option, error) {
 	params := getPostOptionsByFilterParams{
-		IDs: request.IDs,
+		IDs:     request.IDs,
+		PostIDs: request.PostIDs,
 	}
 	postOptions, err := getPostOptionsByFilter(
 		ctx, s.db, params, DBLockUnspecified,
@@ -461,14 +1001,76 @@ func (s *srv) GetOptionsByFilter(
 		return nil, fmt.Errorf("getting posts: %w", err)
 	}
 
+	optionIDs := []uuid.UUID{}
+	for _, po := range postOptions {
+		optionIDs = append(optionIDs, po.ID)
+	}
+	renditionKeys := map[uuid.UUID]map[ImageSize]string{}
+	if len(optionIDs) > 0 {
+		renditions, err := getPostOptionRenditionsByFilter(
+			ctx, s.db, getPostOptionRenditionsByFilterParams{
+				PostOptionIDs: optionIDs,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getting renditions: %w", err)
+		}
+		for _, r := range renditions {
+			if renditionKeys[r.PostOptionID] == nil {
+				renditionKeys[r.PostOptionID] = map[ImageSize]string{}
+			}
+			renditionKeys[r.PostOptionID][r.Size] = r.FileKey
+		}
+	}
+
 	res := []Option{}
 	for _, po := range postOptions {
-		url := fmt.Sprintf("https://storage.cloud.google.com/%s/%s", s.bucketName, po.FileRef)
-		res = append(res, Option{
-			ID:       po.ID,
-			Position: po.Position,
-			URL:      &url,
-		})
+		option := Option{
+			ID:               po.ID,
+			PostID:           po.PostID,
+			MediaType:        po.MediaType,
+			Position:         po.Position,
+			URLs:             map[ImageSize]string{},
+			ProcessingStatus: po.ProcessingStatus,
+			Width:            po.Width,
+			Height:           po.Height,
+			Blurhash:         po.Blurhash,
+		}
+		sign := func(fileKey string) (string, error) {
+			url, err := s.optionURL(ctx, fileKey)
+			if err != nil {
+				return "", fmt.Errorf("getting option url: %w", err)
+			}
+			if option.URLExpiresAt == nil || url.ExpiresAt.Before(*option.URLExpiresAt) {
+				expiresAt := url.ExpiresAt
+				option.URLExpiresAt = &expiresAt
+			}
+			return url.URL, nil
+		}
+		for _, rs := range renditionSizes {
+			// Uploads haven't been checked or had their metadata stripped,
+			// so nothing is served until there's a rendition
+			fileKey, ok := renditionKeys[po.ID][rs.Size]
+			if !ok {
+				continue
+			}
+			if option.URLs[rs.Size], err = sign(fileKey); err != nil {
+				return nil, err
+			}
+		}
+		switch po.MediaType {
+		case MediaTypeDocument, MediaTypeVideo:
+			fileURL, err := sign(s.fileKey(po.FileRef))
+			if err != nil {
+				return nil, err
+			}
+			option.FileURL = &fileURL
+		default:
+			if fileURL, ok := option.URLs[ImageSizeFull]; ok {
+				option.FileURL = &fileURL
+			}
+		}
+		res = append(res, option)
 	}
 
 	return res, nil
@@ -489,19 +1091,109 @@ func (s *srv) GetVotesByFilter(
 
 	res := []Vote{}
 	for _, pv := range postVotes {
-		reason := *pv.Reason
 		res = append(res, Vote{
 			ID:         pv.ID,
 			CustomerID: pv.CustomerID,
 			OptionID:   pv.PostOptionID,
+			OptionIDs:  pv.PostOptionIDs,
 			PostID:     pv.PostID,
-			Reason:     &reason,
+			Reason:     pv.Reason,
 		})
 	}
 
 	return res, nil
 }
 
+func (s *srv) GetResultsByFilter(
+	ctx context.Context, request GetResultsByFilterRequest,
+) ([]Results, error) {
+	if len(request.PostIDs) == 0 {
+		return []Results{}, nil
+	}
+	voteCounts, err := getPostOptionVoteCounts(
+		ctx, s.db, getPostOptionVoteCountsParams{
+			PostIDs: request.PostIDs,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting vote counts: %w", err)
+	}
+
+	resultsByPostID := map[uuid.UUID]*Results{}
+	for _, postID := range request.PostIDs {
+		resultsByPostID[postID] = &Results{
+			PostID:  postID,
+			Options: []OptionResult{},
+		}
+	}
+	rankedPostIDs := database.UUIDSlice{}
+	optionIDsByPostID := map[uuid.UUID][]uuid.UUID{}
+	for _, vc := range voteCounts {
+		results, ok := resultsByPostID[vc.PostID]
+		if !ok {
+			continue
+		}
+		results.TotalVotes = vc.BallotCount
+		results.Options = append(results.Options, OptionResult{
+			OptionID:  vc.PostOptionID,
+			VoteCount: vc.VoteCount,
+		})
+		optionIDsByPostID[vc.PostID] = append(optionIDsByPostID[vc.PostID], vc.PostOptionID)
+		if vc.VotingMode == VotingModeRanked && len(optionIDsByPostID[vc.PostID]) == 1 {
+			rankedPostIDs = append(rankedPostIDs, vc.PostID)
+		}
+	}
+
+	for _, postID := range request.PostIDs {
+		results := resultsByPostID[postID]
+		topVoteCount := 0
+		for i, o := range results.Options {
+			if results.TotalVotes > 0 {
+				results.Options[i].VotePercentage = float64(o.VoteCount) / float64(results.TotalVotes) * 100
+			}
+			switch {
+			case o.VoteCount > topVoteCount:
+				topVoteCount = o.VoteCount
+				optionID := o.OptionID
+				results.WinningOptionID = &optionID
+			case o.VoteCount == topVoteCount:
+				results.WinningOptionID = nil
+			}
+		}
+	}
+
+	if len(rankedPostIDs) > 0 {
+		selections, err := getPostVoteSelections(ctx, s.db, rankedPostIDs)
+		if err != nil {
+			return nil, fmt.Errorf("getting vote selections: %w", err)
+		}
+		ballotsByPostID := map[uuid.UUID][][]uuid.UUID{}
+		var lastVoteID uuid.UUID
+		for _, vs := range selections {
+			ballots := ballotsByPostID[vs.PostID]
+			if vs.PostVoteID != lastVoteID {
+				ballots = append(ballots, []uuid.UUID{})
+				lastVoteID = vs.PostVoteID
+			}
+			ballots[len(ballots)-1] = append(ballots[len(ballots)-1], vs.PostOptionID)
+			ballotsByPostID[vs.PostID] = ballots
+		}
+		for _, postID := range rankedPostIDs {
+			results := resultsByPostID[postID]
+			results.Rounds, results.WinningOptionID = instantRunoff(
+				optionIDsByPostID[postID], ballotsByPostID[postID],
+			)
+		}
+	}
+
+	res := []Results{}
+	for _, postID := range request.PostIDs {
+		res = append(res, *resultsByPostID[postID])
+	}
+
+	return res, nil
+}
+
 func (s *srv) GenerateSignedPostOptionURL(
 	ctx context.Context,
 	request GenerateSignedPostOptionURLRequest,
@@ -510,21 +1202,23 @@ func (s *srv) GenerateSignedPostOptionURL(
 	if ext == "" {
 		return nil, fmt.Errorf("expected file extension to be non-empty")
 	}
-	if ext != ".jpeg" && ext != ".png" && ext != ".gif" {
+	format, ok := mediaFormats[ext]
+	if !ok {
 		return nil, ErrUnsupportedFileType
 	}
 	if request.ContentType == "" {
 		return nil, fmt.Errorf("expected content type to be non-empty")
 	}
+	if !slices.Contains(format.ContentTypes, request.ContentType) {
+		return nil, ErrUnsupportedFileType
+	}
 	res := GenerateSignedPostOptionURLResponse{
-		FileKey:    fmt.Sprintf("post-options/%s%s", uuid.NewString(), ext),
-		BucketName: s.bucketName,
+		FileKey:    fmt.Sprintf("%s%s%s", optionUploadPrefix, uuid.NewString(), ext),
+		BucketName: s.blobs.Bucket(),
 	}
-	url, err := s.bucket.SignedURL(res.FileKey, &storage.SignedURLOptions{
-		Method:      "PUT",
-		Expires:     time.Now().Add(time.Minute * 15),
-		ContentType: request.ContentType,
-	})
+	url, err := s.blobs.SignedPutURL(
+		ctx, res.FileKey, request.ContentType, time.Now().Add(optionUploadURLTTL),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("creating SignedURL: %w", err)
 	}
@@ -532,53 +1226,454 @@ func (s *srv) GenerateSignedPostOptionURL(
 	return &res, nil
 }
 
-func (s *srv) SubmitVote(ctx context.Context, request SubmitVoteRequest) (*SubmitVoteResponse, error) {
+// getLiveOption returns the option and its post, as long as the post is open
+// for voting and visible to the viewer.
+func getLiveOption(
+	ctx context.Context, q database.Q, optionID uuid.UUID, viewer PostViewer,
+) (*postOption, *Post, error) {
 	postOptions, err := getPostOptionsByFilter(
-		ctx, s.db,
+		ctx, q,
 		getPostOptionsByFilterParams{
-			IDs: []uuid.UUID{request.OptionID},
+			IDs: []uuid.UUID{optionID},
 		},
 		DBLockUnspecified,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("selecting post options: %w", err)
+		return nil, nil, fmt.Errorf("selecting post options: %w", err)
 	}
 
 	if len(postOptions) != 1 {
-		return nil, ErrOptionNotFound
+		return nil, nil, ErrOptionNotFound
 	}
 
 	postOption := postOptions[0]
 
-	posts, err := getPostsByFilter(ctx, s.db, getPostsByFilterParams{
+	posts, err := getPostsByFilter(ctx, q, getPostsByFilterParams{
 		IDs: []uuid.UUID{postOption.PostID},
 	}, DBLockUnspecified)
 	if err != nil {
-		return nil, fmt.Errorf("selecting post: %w", err)
+		return nil, nil, fmt.Errorf("selecting post: %w", err)
 	}
 
 	if len(posts) != 1 {
-		return nil, ErrOptionNotFound
+		return nil, nil, ErrOptionNotFound
+	}
+
+	post := postFromRow(posts[0])
+	if !post.VisibleTo(viewer, time.Now()) {
+		return nil, nil, ErrOptionNotFound
+	}
+	if post.Status(time.Now()) != PostStatusLive {
+		return nil, nil, ErrPostNotLive
+	}
+
+	return &postOption, &post, nil
+}
+
+// getLivePost returns the post, as long as it's open for voting and visible to
+// the viewer.
+func getLivePost(
+	ctx context.Context, q database.Q, postID uuid.UUID, viewer PostViewer,
+) (*Post, error) {
+	posts, err := getPostsByFilter(ctx, q, getPostsByFilterParams{
+		IDs: []uuid.UUID{postID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("selecting post: %w", err)
+	}
+	if len(posts) != 1 {
+		return nil, ErrPostNotFound
+	}
+	post := postFromRow(posts[0])
+	if !post.VisibleTo(viewer, time.Now()) {
+		return nil, ErrPostNotFound
+	}
+	if post.Status(time.Now()) != PostStatusLive {
+		return nil, ErrPostNotLive
+	}
+	return &post, nil
+}
+
+func (s *srv) SubmitVote(ctx context.Context, request SubmitVoteRequest) (*SubmitVoteResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	post := posts[0]
-	if post.OpensAt.Before(time.Now()) || !time.Now().Before(*post.ClosesAt) {
-		return nil, ErrOptionNotFound
+	postOption, post, err := getLiveOption(ctx, tx, request.OptionID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if post.Restricted && post.AuthorID != request.CustomerID {
+		if err = redeemInvite(ctx, tx, post.ID, request.CustomerID, request.InviteToken); err != nil {
+			return nil, err
+		}
 	}
 
 	voteID := uuid.New()
-	if err = insertPostVote(ctx, s.db, insertPostVoteParams{
-		id:           voteID,
-		postOptionID: postOption.ID,
-		postID:       postOption.PostID,
-		reason:       request.Reason,
-		customerID:   request.CustomerID,
+	if err = insertPostVote(ctx, tx, insertPostVoteParams{
+		ID:           voteID,
+		PostOptionID: postOption.ID,
+		PostID:       postOption.PostID,
+		Reason:       request.Reason,
+		CustomerID:   request.CustomerID,
+	}); err != nil {
+		if errors.Is(err, errPostVoteExists) {
+			return nil, ErrVoteAlreadyCast
+		}
+		return nil, fmt.Errorf("inserting vote: %w", err)
+	}
+	if err = replacePostVoteSelections(ctx, tx, voteID, []postVoteSelection{{
+		PostVoteID:   voteID,
+		PostID:       postOption.PostID,
+		PostOptionID: postOption.ID,
+		Rank:         1,
+	}}); err != nil {
+		return nil, fmt.Errorf("inserting vote selections: %w", err)
+	}
+	if err = replaceCriterionResponses(
+		ctx, tx, postOption.PostID, voteID, postOption.ID, request.Criteria,
+	); err != nil {
+		return nil, err
+	}
+
+	if err = insertPostVoteEvent(ctx, tx, insertPostVoteEventParams{
+		ID:           uuid.New(),
+		PostID:       postOption.PostID,
+		CustomerID:   request.CustomerID,
+		PostOptionID: &postOption.ID,
+		Action:       postVoteActionCast,
+		Reason:       request.Reason,
 	}); err != nil {
-		return nil, fmt.Errorf("inserting post: %w", err)
+		return nil, fmt.Errorf("inserting vote event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
 	}
 
+	s.publishPostEvent(ctx, PostEvent{
+		Type:   PostEventTypeVoteAdded,
+		PostID: postOption.PostID,
+		VoteID: &voteID,
+	})
+
 	return &SubmitVoteResponse{
 		PostID: postOption.PostID,
 		VoteID: voteID,
 	}, nil
 }
+
+func (s *srv) ChangeVote(ctx context.Context, request ChangeVoteRequest) (*ChangeVoteResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	postOption, post, err := getLiveOption(ctx, tx, request.OptionID, PostViewer{
+		CustomerID:   uuid.NullUUID{UUID: request.CustomerID, Valid: true},
+		WorkspaceIDs: request.WorkspaceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := getPostVotesByFilter(ctx, tx, getPostVotesByFilterParams{
+		PostIDs:     []uuid.UUID{post.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting votes: %w", err)
+	}
+	if len(votes) != 1 {
+		return nil, ErrVoteNotFound
+	}
+	vote := votes[0]
+
+	if !post.AllowVoteChanges {
+		return nil, ErrVoteAlreadyCast
+	}
+
+	if err = updatePostVote(ctx, tx, updatePostVoteParams{
+		ID:           vote.ID,
+		PostOptionID: postOption.ID,
+		Reason:       request.Reason,
+	}); err != nil {
+		return nil, fmt.Errorf("updating vote: %w", err)
+	}
+	if err = replacePostVoteSelections(ctx, tx, vote.ID, []postVoteSelection{{
+		PostVoteID:   vote.ID,
+		PostID:       post.ID,
+		PostOptionID: postOption.ID,
+		Rank:         1,
+	}}); err != nil {
+		return nil, fmt.Errorf("replacing vote selections: %w", err)
+	}
+	if err = replaceCriterionResponses(
+		ctx, tx, post.ID, vote.ID, postOption.ID, request.Criteria,
+	); err != nil {
+		return nil, err
+	}
+
+	if err = insertPostVoteEvent(ctx, tx, insertPostVoteEventParams{
+		ID:           uuid.New(),
+		PostID:       post.ID,
+		CustomerID:   request.CustomerID,
+		PostOptionID: &postOption.ID,
+		Action:       postVoteActionChanged,
+		Reason:       request.Reason,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting vote event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+
+	s.publishPostEvent(ctx, PostEvent{
+		Type:   PostEventTypeVoteChanged,
+		PostID: post.ID,
+		VoteID: &vote.ID,
+	})
+
+	return &ChangeVoteResponse{
+		PostID: post.ID,
+		VoteID: vote.ID,
+	}, nil
+}
+
+func (s *srv) RetractVote(ctx context.Context, request RetractVoteRequest) (*RetractVoteResponse, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	posts, err := getPostsByFilter(ctx, tx, getPostsByFilterParams{
+		IDs: []uuid.UUID{request.PostID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("selecting post: %w", err)
+	}
+	if len(posts) != 1 {
+		return nil, ErrPostNotFound
+	}
+	post := postFromRow(posts[0])
+	if post.Status(time.Now()) != PostStatusLive {
+		return nil, ErrPostNotLive
+	}
+
+	votes, err := getPostVotesByFilter(ctx, tx, getPostVotesByFilterParams{
+		PostIDs:     []uuid.UUID{post.ID},
+		CustomerIDs: []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting votes: %w", err)
+	}
+	if len(votes) != 1 {
+		return nil, ErrVoteNotFound
+	}
+	vote := votes[0]
+
+	if !post.AllowVoteChanges {
+		return nil, ErrVoteAlreadyCast
+	}
+
+	if err = deletePostVote(ctx, tx, vote.ID); err != nil {
+		return nil, fmt.Errorf("deleting vote: %w", err)
+	}
+
+	if err = insertPostVoteEvent(ctx, tx, insertPostVoteEventParams{
+		ID:         uuid.New(),
+		PostID:     post.ID,
+		CustomerID: request.CustomerID,
+		Action:     postVoteActionRetracted,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting vote event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("comitting tx: %w", err)
+	}
+
+	s.publishPostEvent(ctx, PostEvent{
+		Type:   PostEventTypeVoteRetracted,
+		PostID: post.ID,
+		VoteID: &vote.ID,
+	})
+
+	return &RetractVoteResponse{
+		PostID: post.ID,
+	}, nil
+}
+
+func postEventTopic(postID uuid.UUID) string {
+	return fmt.Sprintf("post:%s", postID)
+}
+
+// publishPostEvent is best effort, the change the event describes has already
+// been committed so failures are only logged.
+func (s *srv) publishPostEvent(ctx context.Context, event PostEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("marshalling post event: %v", err)
+		return
+	}
+	if err = s.pubsub.Publish(ctx, postEventTopic(event.PostID), payload); err != nil {
+		log.Printf("publishing post event: %v", err)
+	}
+}
+
+func (s *srv) SubscribeToPostEvents(
+	ctx context.Context, request SubscribeToPostEventsRequest,
+) (<-chan PostEvent, error) {
+	messages, err := s.pubsub.Subscribe(ctx, postEventTopic(request.PostID))
+	if err != nil {
+		return nil, fmt.Errorf("subscribing: %w", err)
+	}
+	events := make(chan PostEvent)
+	go func() {
+		defer close(events)
+		for message := range messages {
+			event := PostEvent{}
+			if err := json.Unmarshal(message, &event); err != nil {
+				log.Printf("unmarshalling post event: %v", err)
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// RecordStatusTransitions finds posts that have opened or closed since they
+// were last checked, and records the change. Safe to call from multiple
+// instances at once, each post is only transitioned once. Each post is
+// recorded in its own tx, posts that fail are logged and skipped.
+func (s *srv) RecordStatusTransitions(
+	ctx context.Context, request RecordStatusTransitionsRequest,
+) ([]StatusTransition, error) {
+	changes, err := getPostStatusChanges(ctx, s.db, getPostStatusChangesParams{
+		Limit: request.Limit,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting status changes: %w", err)
+	}
+
+	transitions := []StatusTransition{}
+	for _, c := range changes {
+		transition := StatusTransition{
+			PostID:     c.ID,
+			AuthorID:   c.AuthorID,
+			FromStatus: c.RecordedStatus,
+			ToStatus:   c.Status,
+		}
+		recorded, err := s.recordStatusTransition(ctx, request, transition)
+		if err != nil {
+			if ctx.Err() != nil {
+				return transitions, ctx.Err()
+			}
+			log.Printf("recording status transition for post %s: %v", c.ID, err)
+			if err = incrementPostStatusTransitionAttempts(ctx, s.db, c.ID); err != nil {
+				log.Printf("incrementing status transition attempts for post %s: %v", c.ID, err)
+			}
+			continue
+		}
+		if !recorded {
+			continue
+		}
+		transitions = append(transitions, transition)
+		s.publishPostEvent(ctx, PostEvent{
+			Type:   PostEventTypeUpdated,
+			PostID: transition.PostID,
+		})
+	}
+
+	return transitions, nil
+}
+
+// recordStatusTransition records a single transition, returning false if the
+// post has since been transitioned by another instance or changed again.
+func (s *srv) recordStatusTransition(
+	ctx context.Context, request RecordStatusTransitionsRequest, transition StatusTransition,
+) (bool, error) {
+	var effect TransitionEffect
+	if request.PrepareTransition != nil {
+		var err error
+		effect, err = request.PrepareTransition(ctx, transition)
+		if err != nil {
+			return false, fmt.Errorf("preparing transition: %w", err)
+		}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	changes, err := getPostStatusChanges(ctx, tx, getPostStatusChangesParams{
+		IDs: []uuid.UUID{transition.PostID},
+	}, DBLockForUpdateSkipLocked)
+	if err != nil {
+		return false, fmt.Errorf("getting status change: %w", err)
+	}
+	if len(changes) != 1 ||
+		changes[0].RecordedStatus != transition.FromStatus ||
+		changes[0].Status != transition.ToStatus {
+		return false, nil
+	}
+
+	if err = insertPostStatusTransition(ctx, tx, insertPostStatusTransitionParams{
+		ID:         uuid.New(),
+		PostID:     transition.PostID,
+		FromStatus: transition.FromStatus,
+		ToStatus:   transition.ToStatus,
+	}); err != nil {
+		return false, fmt.Errorf("inserting transition: %w", err)
+	}
+	if err = updatePostRecordedStatus(ctx, tx, transition.PostID, transition.ToStatus); err != nil {
+		return false, fmt.Errorf("updating recorded status: %w", err)
+	}
+	if effect != nil {
+		if err = effect(ctx, tx); err != nil {
+			return false, fmt.Errorf("handling transition: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("comitting tx: %w", err)
+	}
+	return true, nil
+}
+
+func (s *srv) RemoveCustomer(
+	ctx context.Context, tx database.Q, request RemoveCustomerRequest,
+) error {
+	if err := anonymisePostVotes(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("anonymising votes: %w", err)
+	}
+	if err := anonymisePostFeedback(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("anonymising feedback: %w", err)
+	}
+	if err := deletePostInviteRedemptions(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("deleting invite redemptions: %w", err)
+	}
+	if err := deleteDraftPosts(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("deleting draft posts: %w", err)
+	}
+	// The lifecycle worker records the close and notifies subscribers
+	if err := closeLivePosts(ctx, tx, request.CustomerID); err != nil {
+		return fmt.Errorf("closing live posts: %w", err)
+	}
+	return nil
+}
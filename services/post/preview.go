@@ -0,0 +1,95 @@
+package srvpost
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Previews are rendered with pdftoppm from poppler and ffmpeg, which have to
+// be installed wherever the media worker runs.
+const previewTimeout = time.Minute
+
+// renderPreview saves the upload to a temp file and renders a PNG of the
+// first page of a DOCUMENT, or a representative frame of a VIDEO.
+func renderPreview(ctx context.Context, mediaType MediaType, r io.Reader) ([]byte, error) {
+	br := bufio.NewReaderSize(r, 512)
+	// Shorter files are fine, the checks below see what's there
+	header, _ := br.Peek(512)
+	switch mediaType {
+	case MediaTypeDocument:
+		if !bytes.HasPrefix(header, []byte("%PDF-")) {
+			return nil, fmt.Errorf("%w: content isn't a PDF", errUnsupportedMedia)
+		}
+	case MediaTypeVideo:
+		if !isVideo(header) {
+			return nil, fmt.Errorf("%w: content isn't an MP4, WebM or QuickTime video", errUnsupportedMedia)
+		}
+	default:
+		return nil, fmt.Errorf("no preview for %s", mediaType)
+	}
+
+	dir, err := os.MkdirTemp("", "option-preview-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "upload")
+	file, err := os.Create(input)
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	maxSize := maxFileSizes[mediaType]
+	n, err := io.Copy(file, io.LimitReader(br, maxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("saving upload: %w", err)
+	}
+	if n > maxSize {
+		return nil, fmt.Errorf("%w: larger than %vMB", errUnsupportedMedia, maxSize>>20)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+	output := filepath.Join(dir, "preview.png")
+	var cmd *exec.Cmd
+	if mediaType == MediaTypeDocument {
+		cmd = exec.CommandContext(
+			ctx, "pdftoppm", "-png", "-f", "1", "-l", "1", "-singlefile",
+			"-scale-to", fmt.Sprint(renditionSizes[len(renditionSizes)-1].MaxEdge),
+			input, filepath.Join(dir, "preview"),
+		)
+	} else {
+		cmd = exec.CommandContext(
+			ctx, "ffmpeg", "-v", "error", "-i", input,
+			"-vf", "thumbnail", "-frames:v", "1", output,
+		)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("running %s: %w: %s", filepath.Base(cmd.Path), err, out)
+	}
+
+	preview, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("reading preview: %w", err)
+	}
+	return preview, nil
+}
+
+// isVideo checks for the ftyp box that starts MP4 and QuickTime files, or
+// the EBML header of WebM.
+func isVideo(header []byte) bool {
+	if len(header) >= 8 && string(header[4:8]) == "ftyp" {
+		return true
+	}
+	return bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
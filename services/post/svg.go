@@ -0,0 +1,309 @@
+package srvpost
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Elements kept in sanitised SVGs, everything else is dropped along with its
+// children. Leaves out script, foreignObject and animation elements, which
+// can run script or swap in links.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true,
+	"title": true, "desc": true, "style": true, "switch": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true,
+	"polyline": true, "polygon": true, "text": true, "tspan": true,
+	"textPath": true, "image": true, "marker": true, "pattern": true,
+	"clipPath": true, "mask": true, "linearGradient": true,
+	"radialGradient": true, "stop": true, "filter": true, "feBlend": true,
+	"feColorMatrix": true, "feComponentTransfer": true, "feComposite": true,
+	"feConvolveMatrix": true, "feDiffuseLighting": true,
+	"feDisplacementMap": true, "feDistantLight": true, "feDropShadow": true,
+	"feFlood": true, "feFuncA": true, "feFuncB": true, "feFuncG": true,
+	"feFuncR": true, "feGaussianBlur": true, "feMerge": true,
+	"feMergeNode": true, "feMorphology": true, "feOffset": true,
+	"fePointLight": true, "feSpecularLighting": true, "feSpotLight": true,
+	"feTile": true, "feTurbulence": true,
+}
+
+// Namespaces prefixes can be declared for. Names are only checked by their
+// prefix, so the prefixes can't be bound to anything else.
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+var svgPrefixes = map[string]string{
+	"svg":   svgNamespace,
+	"xlink": xlinkNamespace,
+}
+
+// CSS functions allowed in styles besides url(#fragment). None of them can
+// load anything.
+var cssFunctions = map[string]bool{
+	"rgb": true, "rgba": true, "hsl": true, "hsla": true, "hwb": true,
+	"lab": true, "lch": true, "oklab": true, "oklch": true, "color": true,
+	"color-mix": true, "calc": true, "min": true, "max": true, "clamp": true,
+	"var": true, "matrix": true, "translate": true, "translatex": true,
+	"translatey": true, "scale": true, "scalex": true, "scaley": true,
+	"rotate": true, "skew": true, "skewx": true, "skewy": true, "blur": true,
+	"brightness": true, "contrast": true, "drop-shadow": true,
+	"grayscale": true, "hue-rotate": true, "invert": true, "opacity": true,
+	"saturate": true, "sepia": true, "cubic-bezier": true, "steps": true,
+}
+
+type sanitisedSVG struct {
+	Data []byte
+	// From the width and height or viewBox of the root element, 0 when
+	// they're relative or missing
+	Width  int
+	Height int
+}
+
+// sanitiseSVG rewrites the SVG keeping only drawing elements and attributes.
+// Event handlers, links to anything but fragments or embedded images, and
+// styles that load external resources are dropped, as are comments, doctypes
+// and processing instructions.
+func sanitiseSVG(data []byte) (*sanitisedSVG, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+	buf := bytes.Buffer{}
+	encoder := xml.NewEncoder(&buf)
+	res := sanitisedSVG{}
+
+	// Raw tokens aren't checked for matching start and end elements
+	open := []xml.Name{}
+	// Depth of the element being dropped, 0 when nothing is being dropped
+	droppedAt := 0
+	// Text of the style element being read, checked as a whole once it ends
+	// so it can't be split up by comments or CDATA sections
+	var style *strings.Builder
+	sawRoot := false
+	for {
+		// Raw tokens keep namespace prefixes as written, which the encoder
+		// would otherwise mangle
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errUnsupportedMedia, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+			depth := len(open)
+			if droppedAt > 0 {
+				continue
+			}
+			if !sawRoot {
+				if t.Name.Local != "svg" || (t.Name.Space != "" && t.Name.Space != "svg") {
+					return nil, fmt.Errorf("%w: root element isn't svg", errUnsupportedMedia)
+				}
+				sawRoot = true
+				res.Width, res.Height = svgSize(t.Attr)
+			}
+			if style != nil ||
+				(t.Name.Space != "" && t.Name.Space != "svg") || !svgElements[t.Name.Local] {
+				droppedAt = depth
+				continue
+			}
+			if t.Name.Local == "style" {
+				style = &strings.Builder{}
+			}
+			attrs := []xml.Attr{}
+			for _, a := range t.Attr {
+				if safeSVGAttr(a) {
+					attrs = append(attrs, xml.Attr{Name: rawName(a.Name), Value: a.Value})
+				}
+			}
+			if err = encoder.EncodeToken(xml.StartElement{
+				Name: rawName(t.Name), Attr: attrs,
+			}); err != nil {
+				return nil, fmt.Errorf("encoding svg: %w", err)
+			}
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, fmt.Errorf("%w: mismatched end element", errUnsupportedMedia)
+			}
+			open = open[:len(open)-1]
+			depth := len(open)
+			if droppedAt > 0 {
+				if depth < droppedAt {
+					droppedAt = 0
+				}
+				continue
+			}
+			if style != nil {
+				if css := style.String(); safeCSS(css) {
+					if err = encoder.EncodeToken(xml.CharData(css)); err != nil {
+						return nil, fmt.Errorf("encoding svg: %w", err)
+					}
+				}
+				style = nil
+			}
+			if err = encoder.EncodeToken(xml.EndElement{Name: rawName(t.Name)}); err != nil {
+				return nil, fmt.Errorf("encoding svg: %w", err)
+			}
+		case xml.CharData:
+			if droppedAt > 0 || len(open) == 0 {
+				continue
+			}
+			if style != nil {
+				style.Write(t)
+				continue
+			}
+			if err = encoder.EncodeToken(t.Copy()); err != nil {
+				return nil, fmt.Errorf("encoding svg: %w", err)
+			}
+		}
+	}
+	if !sawRoot {
+		return nil, fmt.Errorf("%w: no svg element", errUnsupportedMedia)
+	}
+	if len(open) != 0 {
+		return nil, fmt.Errorf("%w: unclosed elements", errUnsupportedMedia)
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, fmt.Errorf("encoding svg: %w", err)
+	}
+	res.Data = buf.Bytes()
+	return &res, nil
+}
+
+// rawName flattens a prefixed name from RawToken so the encoder writes it
+// back as it was.
+func rawName(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+func safeSVGAttr(a xml.Attr) bool {
+	switch a.Name.Space {
+	case "":
+		if a.Name.Local == "xmlns" {
+			return a.Value == svgNamespace
+		}
+	case "xmlns":
+		return svgPrefixes[a.Name.Local] == a.Value
+	case "xml":
+		// Not xml:base, which would change where fragment links point
+		return a.Name.Local == "space" || a.Name.Local == "lang"
+	case "xlink":
+		if a.Name.Local != "href" {
+			return false
+		}
+	default:
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(a.Name.Local), "on") {
+		return false
+	}
+	if a.Name.Local == "href" {
+		return safeSVGLink(a.Value)
+	}
+	return safeCSS(a.Value)
+}
+
+// safeSVGLink allows links to other elements in the same SVG, and embedded
+// raster images.
+func safeSVGLink(link string) bool {
+	link = strings.TrimSpace(strings.ToLower(link))
+	if strings.HasPrefix(link, "#") {
+		return true
+	}
+	for _, prefix := range []string{
+		"data:image/png;", "data:image/jpeg;", "data:image/gif;", "data:image/webp;",
+	} {
+		if strings.HasPrefix(link, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeCSS rejects styles that could load something from outside the SVG.
+// Only url(#fragment) references and functions in cssFunctions are allowed.
+func safeCSS(css string) bool {
+	css = strings.ToLower(css)
+	// Escapes could spell out any of the names checked below
+	if strings.Contains(css, `\`) ||
+		strings.Contains(css, "@import") ||
+		strings.Contains(css, "javascript:") {
+		return false
+	}
+	for i := 0; i < len(css); i++ {
+		if css[i] != '(' {
+			continue
+		}
+		start := i
+		for start > 0 && isCSSNameByte(css[start-1]) {
+			start--
+		}
+		switch name := css[start:i]; {
+		case name == "":
+			// Grouping, like in calc()
+		case name == "url":
+			target := strings.TrimLeft(css[i+1:], " \t\n\r\f'\"")
+			if !strings.HasPrefix(target, "#") {
+				return false
+			}
+		case !cssFunctions[name]:
+			return false
+		}
+	}
+	return true
+}
+
+// isCSSNameByte is whether the byte can be part of a CSS identifier. Bytes of
+// non-ASCII characters count, so they can't hide a function's name.
+func isCSSNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b >= 0x80
+}
+
+func svgSize(attrs []xml.Attr) (int, int) {
+	width, height := 0, 0
+	for _, a := range attrs {
+		if a.Name.Space != "" {
+			continue
+		}
+		switch a.Name.Local {
+		case "width":
+			width = svgLength(a.Value)
+		case "height":
+			height = svgLength(a.Value)
+		}
+	}
+	if width > 0 && height > 0 {
+		return width, height
+	}
+	for _, a := range attrs {
+		if a.Name.Space == "" && a.Name.Local == "viewBox" {
+			fields := strings.FieldsFunc(a.Value, func(r rune) bool {
+				return r == ' ' || r == ','
+			})
+			if len(fields) == 4 {
+				return svgLength(fields[2]), svgLength(fields[3])
+			}
+		}
+	}
+	return 0, 0
+}
+
+// svgLength parses lengths in user units or pixels, 0 for anything else
+func svgLength(value string) int {
+	length, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || length <= 0 {
+		return 0
+	}
+	return int(length + 0.5)
+}
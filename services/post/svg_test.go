@@ -0,0 +1,206 @@
+package srvpost
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitiseSVG(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		// Expected in the output
+		keeps []string
+		// Not expected anywhere in the output
+		drops []string
+		// Whether the SVG is rejected outright
+		rejected bool
+	}{
+		{
+			name:  "script",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><rect width="1"/></svg>`,
+			keeps: []string{`<rect width="1">`},
+			drops: []string{"script", "alert"},
+		},
+		{
+			name:  "event handlers",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><rect ONCLICK="alert(2)" fill="red"/></svg>`,
+			keeps: []string{`fill="red"`},
+			drops: []string{"onload", "ONCLICK", "alert"},
+		},
+		{
+			name: "javascript xlink:href",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">` +
+				`<use xlink:href="javascript:alert(1)"/><use xlink:href="#shape"/></svg>`,
+			keeps: []string{`xmlns:xlink="http://www.w3.org/1999/xlink"`, `xlink:href="#shape"`},
+			drops: []string{"javascript"},
+		},
+		{
+			name: "prefix rebound to the xlink namespace",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:a="http://www.w3.org/1999/xlink">` +
+				`<use a:href="javascript:alert(1)"/></svg>`,
+			keeps: []string{"<use>"},
+			drops: []string{"xmlns:a", "a:href", "javascript"},
+		},
+		{
+			name:  "xlink prefix bound elsewhere",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://example.com"><use href="#a"/></svg>`,
+			keeps: []string{`href="#a"`},
+			drops: []string{"example.com"},
+		},
+		{
+			name:  "default namespace bound elsewhere",
+			svg:   `<svg xmlns="http://www.w3.org/1999/xhtml"><rect/></svg>`,
+			drops: []string{"xhtml"},
+		},
+		{
+			name:  "xml:base",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg" xml:base="https://example.com/"><use href="#a" xml:space="preserve"/></svg>`,
+			keeps: []string{`xml:space="preserve"`},
+			drops: []string{"xml:base", "example.com"},
+		},
+		{
+			name: "foreignObject",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg"><foreignObject><div xmlns="http://www.w3.org/1999/xhtml">` +
+				`<iframe src="https://example.com"></iframe></div></foreignObject><circle r="1"/></svg>`,
+			keeps: []string{`<circle r="1">`},
+			drops: []string{"foreignObject", "iframe", "example.com"},
+		},
+		{
+			name:  "doctype",
+			svg:   `<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd"><svg xmlns="http://www.w3.org/2000/svg"/>`,
+			drops: []string{"DOCTYPE", "w3.org/Graphics"},
+		},
+		{
+			name: "entities",
+			svg: `<!DOCTYPE svg [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>` +
+				`<svg xmlns="http://www.w3.org/2000/svg"><text>&xxe;</text></svg>`,
+			rejected: true,
+		},
+		{
+			name:  "style @import",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>@import "https://example.com/a.css";</style></svg>`,
+			keeps: []string{"<style></style>"},
+			drops: []string{"import", "example.com"},
+		},
+		{
+			name:  "style @import split by a comment",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>@im<!-- -->port "https://example.com/a.css";</style></svg>`,
+			drops: []string{"port", "example.com"},
+		},
+		{
+			name:  "style @import split by CDATA",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>@im<![CDATA[port "https://example.com/a.css";]]></style></svg>`,
+			drops: []string{"port", "example.com"},
+		},
+		{
+			name:  "escaped @import",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>@\69mport "https://example.com/a.css";</style></svg>`,
+			drops: []string{"mport", "example.com"},
+		},
+		{
+			name:  "escaped url",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><rect style="fill: \75 rl(https://example.com/a.png)"/></svg>`,
+			keeps: []string{"<rect>"},
+			drops: []string{"example.com"},
+		},
+		{
+			name:  "external url",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><rect style="fill: URL( 'https://example.com/a.png')"/></svg>`,
+			drops: []string{"example.com"},
+		},
+		{
+			name:  "image-set",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><rect style="fill: image-set(&quot;https://example.com/a.png&quot; 1x)"/></svg>`,
+			drops: []string{"image-set", "example.com"},
+		},
+		{
+			name:  "prefixed image-set",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>rect { fill: -webkit-image-set("https://example.com/a.png" 1x) }</style></svg>`,
+			drops: []string{"image-set", "example.com"},
+		},
+		{
+			name:  "src",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><style>@font-face { src: src("https://example.com/a.woff") }</style></svg>`,
+			drops: []string{"src(", "example.com"},
+		},
+		{
+			name: "allowed styles",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg"><style>rect { fill: url(#grad); stroke: rgb(0, 0, 0) }</style>` +
+				`<rect transform="translate(1, 2) rotate(45)" style="width: calc((100% - 2px) / 2)"/></svg>`,
+			keeps: []string{
+				"rect { fill: url(#grad); stroke: rgb(0, 0, 0) }",
+				`transform="translate(1, 2) rotate(45)"`,
+				`style="width: calc((100% - 2px) / 2)"`,
+			},
+		},
+		{
+			name:  "svg data href",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><image href="data:image/svg+xml;base64,PHN2Zy8+"/></svg>`,
+			keeps: []string{"<image>"},
+			drops: []string{"data:"},
+		},
+		{
+			name:  "raster data href",
+			svg:   `<svg xmlns="http://www.w3.org/2000/svg"><image href="data:image/png;base64,iVBORw0KGgo="/></svg>`,
+			keeps: []string{`href="data:image/png;base64,iVBORw0KGgo="`},
+		},
+		{
+			name:     "not an svg",
+			svg:      `<html><body/></html>`,
+			rejected: true,
+		},
+		{
+			name:     "not xml",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg"><rect></svg>`,
+			rejected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := sanitiseSVG([]byte(tt.svg))
+			if tt.rejected {
+				if !errors.Is(err, errUnsupportedMedia) {
+					t.Fatalf("got %v, want errUnsupportedMedia", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sanitising: %v", err)
+			}
+			got := string(res.Data)
+			for _, keep := range tt.keeps {
+				if !strings.Contains(got, keep) {
+					t.Errorf("%q was dropped from %s", keep, got)
+				}
+			}
+			for _, drop := range tt.drops {
+				if strings.Contains(got, drop) {
+					t.Errorf("%q was kept in %s", drop, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitiseSVGSize(t *testing.T) {
+	tests := []struct {
+		svg           string
+		width, height int
+	}{
+		{`<svg xmlns="http://www.w3.org/2000/svg" width="100px" height="50"/>`, 100, 50},
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20,10.4"/>`, 20, 10},
+		{`<svg xmlns="http://www.w3.org/2000/svg" width="100%" height="100%"/>`, 0, 0},
+	}
+	for _, tt := range tests {
+		res, err := sanitiseSVG([]byte(tt.svg))
+		if err != nil {
+			t.Fatalf("sanitising %s: %v", tt.svg, err)
+		}
+		if res.Width != tt.width || res.Height != tt.height {
+			t.Errorf("got %vx%v for %s, want %vx%v",
+				res.Width, res.Height, tt.svg, tt.width, tt.height)
+		}
+	}
+}
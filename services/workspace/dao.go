@@ -0,0 +1,337 @@
+package srvworkspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"quorum-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DBLock string
+
+const (
+	DBLockUnspecified DBLock = ""
+	DBLockForUpdate   DBLock = "for update"
+)
+
+type getWorkspacesByFilterParams struct {
+	IDs       database.UUIDSlice
+	MemberIDs database.UUIDSlice
+}
+
+type workspace struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func getWorkspacesByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getWorkspacesByFilterParams,
+	dbLock DBLock,
+) ([]workspace, error) {
+	workspaces := []workspace{}
+	query := `
+		select id, name, created_at, updated_at
+		from workspace
+		where true
+	`
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.MemberIDs) > 0 {
+		args = append(args, params.MemberIDs)
+		query = fmt.Sprintf(`%s and id in (
+			select workspace_id from workspace_member where customer_id = any($%v)
+		)`, query, len(args))
+	}
+
+	query = fmt.Sprintf("%s order by name, id %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &workspaces, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+func insertWorkspace(
+	ctx context.Context, q database.Q, id uuid.UUID, name string,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		insert into workspace (id, name) values ($1, $2)
+	`, id, name); err != nil {
+		return fmt.Errorf("inserting workspace: %w", err)
+	}
+	return nil
+}
+
+func updateWorkspaceName(
+	ctx context.Context, q database.Q, id uuid.UUID, name string,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update workspace set name = $2, updated_at = now() where id = $1
+	`, id, name); err != nil {
+		return fmt.Errorf("updating workspace: %w", err)
+	}
+	return nil
+}
+
+type getMembersByFilterParams struct {
+	WorkspaceIDs database.UUIDSlice
+	CustomerIDs  database.UUIDSlice
+}
+
+type member struct {
+	WorkspaceID uuid.UUID `db:"workspace_id"`
+	CustomerID  uuid.UUID `db:"customer_id"`
+	Role        Role      `db:"role"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func getMembersByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getMembersByFilterParams,
+	dbLock DBLock,
+) ([]member, error) {
+	members := []member{}
+	query := `
+		select workspace_id, customer_id, role, created_at
+		from workspace_member
+		where true
+	`
+
+	args := []any{}
+	if len(params.WorkspaceIDs) > 0 {
+		args = append(args, params.WorkspaceIDs)
+		query = fmt.Sprintf("%s and workspace_id = any($%v)", query, len(args))
+	}
+	if len(params.CustomerIDs) > 0 {
+		args = append(args, params.CustomerIDs)
+		query = fmt.Sprintf("%s and customer_id = any($%v)", query, len(args))
+	}
+
+	query = fmt.Sprintf("%s order by created_at, customer_id %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &members, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting workspace members: %w", err)
+	}
+	return members, nil
+}
+
+func upsertMember(
+	ctx context.Context, q database.Q, workspaceID uuid.UUID, customerID uuid.UUID, role Role,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		insert into workspace_member (workspace_id, customer_id, role)
+		values ($1, $2, $3)
+		on conflict (workspace_id, customer_id) do update set
+			role = excluded.role,
+			updated_at = now()
+	`, workspaceID, customerID, role); err != nil {
+		return fmt.Errorf("upserting workspace_member: %w", err)
+	}
+	return nil
+}
+
+type deleteMembersParams struct {
+	// Every workspace when not set
+	WorkspaceID uuid.NullUUID
+	CustomerID  uuid.UUID
+}
+
+func deleteMembers(
+	ctx context.Context, q database.Q, params deleteMembersParams,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		delete from workspace_member
+		where customer_id = $1 and ($2::uuid is null or workspace_id = $2)
+	`, params.CustomerID, params.WorkspaceID); err != nil {
+		return fmt.Errorf("deleting workspace_member: %w", err)
+	}
+	return nil
+}
+
+// promoteOwnerlessWorkspaces makes the highest ranked, longest standing member
+// of each workspace without an owner its owner.
+func promoteOwnerlessWorkspaces(ctx context.Context, q database.Q) error {
+	if _, err := q.ExecContext(ctx, `
+		update workspace_member set role = 'OWNER', updated_at = now()
+		where (workspace_id, customer_id) in (
+			select distinct on (workspace_id) workspace_id, customer_id
+			from workspace_member wm
+			where not exists (
+				select 1 from workspace_member o
+				where o.workspace_id = wm.workspace_id and o.role = 'OWNER'
+			)
+			order by workspace_id, role, created_at
+		)
+	`); err != nil {
+		return fmt.Errorf("updating workspace_member: %w", err)
+	}
+	return nil
+}
+
+type getInvitationsByFilterParams struct {
+	IDs          database.UUIDSlice
+	WorkspaceIDs database.UUIDSlice
+	PendingOnly  bool
+}
+
+type invitation struct {
+	ID          uuid.UUID  `db:"id"`
+	WorkspaceID uuid.UUID  `db:"workspace_id"`
+	Email       string     `db:"email"`
+	Role        Role       `db:"role"`
+	InvitedBy   uuid.UUID  `db:"invited_by"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	AcceptedAt  *time.Time `db:"accepted_at"`
+	RevokedAt   *time.Time `db:"revoked_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
+
+const invitationColumns = `
+	id,
+	workspace_id,
+	email,
+	role,
+	invited_by,
+	expires_at,
+	accepted_at,
+	revoked_at,
+	created_at
+`
+
+func getInvitationsByFilter(
+	ctx context.Context,
+	db database.Q,
+	params getInvitationsByFilterParams,
+	dbLock DBLock,
+) ([]invitation, error) {
+	invitations := []invitation{}
+	query := fmt.Sprintf(`
+		select %s
+		from workspace_invitation
+		where true
+	`, invitationColumns)
+
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	}
+	if len(params.WorkspaceIDs) > 0 {
+		args = append(args, params.WorkspaceIDs)
+		query = fmt.Sprintf("%s and workspace_id = any($%v)", query, len(args))
+	}
+	if params.PendingOnly {
+		query = fmt.Sprintf(
+			"%s and accepted_at is null and revoked_at is null and expires_at > now()",
+			query,
+		)
+	}
+
+	query = fmt.Sprintf("%s order by created_at, id %s", query, dbLock)
+
+	if err := db.SelectContext(ctx, &invitations, query, args...); err != nil {
+		return nil, fmt.Errorf("selecting workspace invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+type insertInvitationParams struct {
+	ID          uuid.UUID `db:"id"`
+	WorkspaceID uuid.UUID `db:"workspace_id"`
+	Email       string    `db:"email"`
+	Role        Role      `db:"role"`
+	InvitedBy   uuid.UUID `db:"invited_by"`
+	TokenHash   []byte    `db:"token_hash"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+func insertInvitation(
+	ctx context.Context, q database.Q, params insertInvitationParams,
+) error {
+	if _, err := q.NamedExecContext(ctx, `
+		insert into workspace_invitation (
+			id, workspace_id, email, role, invited_by, token_hash, expires_at
+		) values (
+			:id, :workspace_id, :email, :role, :invited_by, :token_hash, :expires_at
+		)
+	`, params); err != nil {
+		return fmt.Errorf("inserting workspace_invitation: %w", err)
+	}
+	return nil
+}
+
+type revokeInvitationsParams struct {
+	IDs database.UUIDSlice
+	// Or every invitation to the email in the workspace
+	WorkspaceID uuid.UUID
+	Email       string
+}
+
+// revokeInvitations revokes matching invitations that haven't been accepted.
+func revokeInvitations(
+	ctx context.Context, q database.Q, params revokeInvitationsParams,
+) error {
+	query := `
+		update workspace_invitation set revoked_at = now()
+		where accepted_at is null and revoked_at is null
+	`
+	args := []any{}
+	if len(params.IDs) > 0 {
+		args = append(args, params.IDs)
+		query = fmt.Sprintf("%s and id = any($%v)", query, len(args))
+	} else {
+		args = append(args, params.WorkspaceID, params.Email)
+		query = fmt.Sprintf(
+			"%s and workspace_id = $%v and lower(email) = lower($%v)",
+			query, len(args)-1, len(args),
+		)
+	}
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("updating workspace_invitation: %w", err)
+	}
+	return nil
+}
+
+var errNoInvitation = errors.New("no invitation found")
+
+func getInvitationByHash(
+	ctx context.Context, q database.Q, tokenHash []byte, dbLock DBLock,
+) (*invitation, error) {
+	invitation := invitation{}
+	if err := q.GetContext(ctx, &invitation, fmt.Sprintf(`
+		select %s
+		from workspace_invitation
+		where token_hash = $1
+		%s
+	`, invitationColumns, dbLock), tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNoInvitation
+		}
+		return nil, fmt.Errorf("selecting workspace_invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+func markInvitationAccepted(
+	ctx context.Context, q database.Q, id uuid.UUID,
+) error {
+	if _, err := q.ExecContext(ctx, `
+		update workspace_invitation set accepted_at = now() where id = $1
+	`, id); err != nil {
+		return fmt.Errorf("updating workspace_invitation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,652 @@
+package srvworkspace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"quorum-api/database"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SRVWorkspace interface {
+	GetWorkspacesByFilter(ctx context.Context, request GetWorkspacesByFilterRequest) ([]Workspace, error)
+	GetMembersByFilter(ctx context.Context, request GetMembersByFilterRequest) ([]Member, error)
+	GetInvitationsByFilter(ctx context.Context, request GetInvitationsByFilterRequest) ([]Invitation, error)
+	// CreateWorkspace creates a workspace with the customer as its owner
+	CreateWorkspace(ctx context.Context, request CreateWorkspaceRequest) (uuid.UUID, error)
+	// UpdateWorkspace needs the actor to be an admin or owner
+	UpdateWorkspace(ctx context.Context, request UpdateWorkspaceRequest) error
+	// InviteMember needs the actor to be an admin or owner. Any earlier
+	// pending invitation for the email is revoked.
+	InviteMember(ctx context.Context, request InviteMemberRequest) error
+	RevokeInvitation(ctx context.Context, request RevokeInvitationRequest) error
+	// AcceptInvitation adds the customer to the workspace, they must have
+	// the email the invitation was sent to.
+	AcceptInvitation(ctx context.Context, request AcceptInvitationRequest) (uuid.UUID, error)
+	// ChangeMemberRole needs the actor to be an admin to change members and
+	// admins, or an owner to make or change owners.
+	ChangeMemberRole(ctx context.Context, request ChangeMemberRoleRequest) error
+	// RemoveMember lets admins remove members and admins, owners remove
+	// anyone and any member leave by removing themselves. The last owner
+	// can't be removed.
+	RemoveMember(ctx context.Context, request RemoveMemberRequest) error
+	// RemoveCustomer removes the customer from every workspace, in the tx
+	// they're deleted in. Workspaces left without an owner are handed to
+	// their longest standing member.
+	RemoveCustomer(ctx context.Context, tx database.Q, request RemoveCustomerRequest) error
+}
+
+type Role string
+
+const (
+	RoleOwner  Role = "OWNER"
+	RoleAdmin  Role = "ADMIN"
+	RoleMember Role = "MEMBER"
+)
+
+// rank orders roles so permissions can be compared, higher can do more.
+func (r Role) rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether the role has the permissions of other.
+func (r Role) AtLeast(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+type GetWorkspacesByFilterRequest struct {
+	IDs []uuid.UUID
+	// Workspaces any of the customers are members of
+	MemberIDs []uuid.UUID
+}
+
+type Workspace struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type GetMembersByFilterRequest struct {
+	WorkspaceIDs []uuid.UUID
+	CustomerIDs  []uuid.UUID
+}
+
+type Member struct {
+	WorkspaceID uuid.UUID
+	CustomerID  uuid.UUID
+	Role        Role
+	CreatedAt   time.Time
+}
+
+type GetInvitationsByFilterRequest struct {
+	IDs          []uuid.UUID
+	WorkspaceIDs []uuid.UUID
+	// Exclude accepted, revoked and expired invitations
+	PendingOnly bool
+}
+
+type Invitation struct {
+	ID          uuid.UUID
+	WorkspaceID uuid.UUID
+	Email       string
+	Role        Role
+	InvitedBy   uuid.UUID
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+type CreateWorkspaceRequest struct {
+	Name    string
+	OwnerID uuid.UUID
+}
+
+// Nil fields are left unchanged
+type UpdateWorkspaceRequest struct {
+	ID      uuid.UUID
+	ActorID uuid.UUID
+	Name    *string
+}
+
+type InviteMemberRequest struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	Email       string
+	Role        Role
+	// Called in the same tx the invitation is created in, e.g. to enqueue
+	// the email with the token
+	OnCreate func(ctx context.Context, tx database.Q, token string) error
+}
+
+type RevokeInvitationRequest struct {
+	ID      uuid.UUID
+	ActorID uuid.UUID
+}
+
+type AcceptInvitationRequest struct {
+	Token      string
+	CustomerID uuid.UUID
+	// The customer's current email, must match the invitation
+	Email string
+}
+
+type ChangeMemberRoleRequest struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	CustomerID  uuid.UUID
+	Role        Role
+}
+
+type RemoveMemberRequest struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	CustomerID  uuid.UUID
+}
+
+type RemoveCustomerRequest struct {
+	CustomerID uuid.UUID
+}
+
+const maxNameLength = 80
+
+// How long an invitation can be accepted for
+const invitationTTL = 7 * 24 * time.Hour
+
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+var ErrInvalidName = fmt.Errorf("workspace name must be between 1 and %v characters", maxNameLength)
+var ErrInvalidRole = errors.New("unknown workspace role")
+var ErrInvalidEmail = errors.New("invalid format for email")
+var ErrInsufficientRole = errors.New("your role in the workspace doesn't allow this")
+var ErrMemberNotFound = errors.New("customer is not a member of the workspace")
+var ErrAlreadyMember = errors.New("customer is already a member of the workspace")
+var ErrLastOwner = errors.New("a workspace must keep at least one owner")
+var ErrInvitationInvalid = errors.New("invitation does not exist or was revoked")
+var ErrInvitationExpired = errors.New("invitation has expired")
+var ErrInvitationUsed = errors.New("invitation has already been accepted")
+var ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email")
+
+func New(db *sqlx.DB) SRVWorkspace {
+	return &srv{
+		db: db,
+	}
+}
+
+type srv struct {
+	db *sqlx.DB
+}
+
+func (s *srv) GetWorkspacesByFilter(
+	ctx context.Context, request GetWorkspacesByFilterRequest,
+) ([]Workspace, error) {
+	workspaces, err := getWorkspacesByFilter(ctx, s.db, getWorkspacesByFilterParams{
+		IDs:       request.IDs,
+		MemberIDs: request.MemberIDs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting workspaces: %w", err)
+	}
+	res := []Workspace{}
+	for _, w := range workspaces {
+		res = append(res, Workspace{
+			ID:        w.ID,
+			Name:      w.Name,
+			CreatedAt: w.CreatedAt,
+			UpdatedAt: w.UpdatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *srv) GetMembersByFilter(
+	ctx context.Context, request GetMembersByFilterRequest,
+) ([]Member, error) {
+	members, err := getMembersByFilter(ctx, s.db, getMembersByFilterParams{
+		WorkspaceIDs: request.WorkspaceIDs,
+		CustomerIDs:  request.CustomerIDs,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting members: %w", err)
+	}
+	res := []Member{}
+	for _, m := range members {
+		res = append(res, Member{
+			WorkspaceID: m.WorkspaceID,
+			CustomerID:  m.CustomerID,
+			Role:        m.Role,
+			CreatedAt:   m.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *srv) GetInvitationsByFilter(
+	ctx context.Context, request GetInvitationsByFilterRequest,
+) ([]Invitation, error) {
+	invitations, err := getInvitationsByFilter(ctx, s.db, getInvitationsByFilterParams{
+		IDs:          request.IDs,
+		WorkspaceIDs: request.WorkspaceIDs,
+		PendingOnly:  request.PendingOnly,
+	}, DBLockUnspecified)
+	if err != nil {
+		return nil, fmt.Errorf("getting invitations: %w", err)
+	}
+	res := []Invitation{}
+	for _, i := range invitations {
+		res = append(res, Invitation{
+			ID:          i.ID,
+			WorkspaceID: i.WorkspaceID,
+			Email:       i.Email,
+			Role:        i.Role,
+			InvitedBy:   i.InvitedBy,
+			ExpiresAt:   i.ExpiresAt,
+			AcceptedAt:  i.AcceptedAt,
+			RevokedAt:   i.RevokedAt,
+			CreatedAt:   i.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+func (s *srv) CreateWorkspace(
+	ctx context.Context, request CreateWorkspaceRequest,
+) (uuid.UUID, error) {
+	name, err := validateName(request.Name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	workspaceID := uuid.New()
+	if err = insertWorkspace(ctx, tx, workspaceID, name); err != nil {
+		return uuid.Nil, fmt.Errorf("inserting workspace: %w", err)
+	}
+	if err = upsertMember(ctx, tx, workspaceID, request.OwnerID, RoleOwner); err != nil {
+		return uuid.Nil, fmt.Errorf("inserting owner: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return workspaceID, nil
+}
+
+// getActorRole locks the workspace so concurrent role changes can't remove
+// every owner, and returns the actor's role in it.
+func getActorRole(
+	ctx context.Context, tx database.Q, workspaceID uuid.UUID, actorID uuid.UUID,
+) (Role, error) {
+	workspaces, err := getWorkspacesByFilter(ctx, tx, getWorkspacesByFilterParams{
+		IDs: []uuid.UUID{workspaceID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return "", fmt.Errorf("getting workspace: %w", err)
+	}
+	if len(workspaces) != 1 {
+		return "", ErrWorkspaceNotFound
+	}
+	members, err := getMembersByFilter(ctx, tx, getMembersByFilterParams{
+		WorkspaceIDs: []uuid.UUID{workspaceID},
+		CustomerIDs:  []uuid.UUID{actorID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return "", fmt.Errorf("getting members: %w", err)
+	}
+	if len(members) != 1 {
+		// Don't reveal the workspace exists to non members
+		return "", ErrWorkspaceNotFound
+	}
+	return members[0].Role, nil
+}
+
+func (s *srv) UpdateWorkspace(ctx context.Context, request UpdateWorkspaceRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	actorRole, err := getActorRole(ctx, tx, request.ID, request.ActorID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.AtLeast(RoleAdmin) {
+		return ErrInsufficientRole
+	}
+
+	if request.Name != nil {
+		name, err := validateName(*request.Name)
+		if err != nil {
+			return err
+		}
+		if err = updateWorkspaceName(ctx, tx, request.ID, name); err != nil {
+			return fmt.Errorf("updating workspace: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) InviteMember(ctx context.Context, request InviteMemberRequest) error {
+	mailAddress, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		return ErrInvalidEmail
+	}
+	if request.Role != RoleAdmin && request.Role != RoleMember && request.Role != RoleOwner {
+		return ErrInvalidRole
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	actorRole, err := getActorRole(ctx, tx, request.WorkspaceID, request.ActorID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.AtLeast(RoleAdmin) || !actorRole.AtLeast(request.Role) {
+		return ErrInsufficientRole
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+	if err = revokeInvitations(ctx, tx, revokeInvitationsParams{
+		WorkspaceID: request.WorkspaceID,
+		Email:       mailAddress.Address,
+	}); err != nil {
+		return fmt.Errorf("revoking invitations: %w", err)
+	}
+	if err = insertInvitation(ctx, tx, insertInvitationParams{
+		ID:          uuid.New(),
+		WorkspaceID: request.WorkspaceID,
+		Email:       mailAddress.Address,
+		Role:        request.Role,
+		InvitedBy:   request.ActorID,
+		TokenHash:   hashToken(token),
+		ExpiresAt:   time.Now().Add(invitationTTL),
+	}); err != nil {
+		return fmt.Errorf("inserting invitation: %w", err)
+	}
+
+	if request.OnCreate != nil {
+		if err = request.OnCreate(ctx, tx, token); err != nil {
+			return fmt.Errorf("handling created invitation: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) RevokeInvitation(ctx context.Context, request RevokeInvitationRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitations, err := getInvitationsByFilter(ctx, tx, getInvitationsByFilterParams{
+		IDs: []uuid.UUID{request.ID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return fmt.Errorf("getting invitations: %w", err)
+	}
+	if len(invitations) != 1 {
+		return ErrInvitationInvalid
+	}
+	actorRole, err := getActorRole(ctx, tx, invitations[0].WorkspaceID, request.ActorID)
+	if err != nil {
+		if errors.Is(err, ErrWorkspaceNotFound) {
+			return ErrInvitationInvalid
+		}
+		return err
+	}
+	if !actorRole.AtLeast(RoleAdmin) {
+		return ErrInsufficientRole
+	}
+
+	if err = revokeInvitations(ctx, tx, revokeInvitationsParams{
+		IDs: []uuid.UUID{request.ID},
+	}); err != nil {
+		return fmt.Errorf("revoking invitation: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) AcceptInvitation(
+	ctx context.Context, request AcceptInvitationRequest,
+) (uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := getInvitationByHash(ctx, tx, hashToken(request.Token), DBLockForUpdate)
+	if err != nil {
+		if err == errNoInvitation {
+			return uuid.Nil, ErrInvitationInvalid
+		}
+		return uuid.Nil, fmt.Errorf("getting invitation: %w", err)
+	}
+	switch {
+	case invitation.AcceptedAt != nil:
+		return uuid.Nil, ErrInvitationUsed
+	case invitation.RevokedAt != nil:
+		return uuid.Nil, ErrInvitationInvalid
+	case !time.Now().Before(invitation.ExpiresAt):
+		return uuid.Nil, ErrInvitationExpired
+	case !strings.EqualFold(invitation.Email, request.Email):
+		return uuid.Nil, ErrInvitationEmailMismatch
+	}
+
+	members, err := getMembersByFilter(ctx, tx, getMembersByFilterParams{
+		WorkspaceIDs: []uuid.UUID{invitation.WorkspaceID},
+		CustomerIDs:  []uuid.UUID{request.CustomerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting members: %w", err)
+	}
+	// Accepting never lowers the role of an existing member
+	if len(members) == 0 || !members[0].Role.AtLeast(invitation.Role) {
+		if err = upsertMember(ctx, tx, invitation.WorkspaceID, request.CustomerID, invitation.Role); err != nil {
+			return uuid.Nil, fmt.Errorf("upserting member: %w", err)
+		}
+	}
+	if err = markInvitationAccepted(ctx, tx, invitation.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("marking invitation accepted: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("committing tx: %w", err)
+	}
+	return invitation.WorkspaceID, nil
+}
+
+func (s *srv) ChangeMemberRole(ctx context.Context, request ChangeMemberRoleRequest) error {
+	if request.Role != RoleAdmin && request.Role != RoleMember && request.Role != RoleOwner {
+		return ErrInvalidRole
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	actorRole, err := getActorRole(ctx, tx, request.WorkspaceID, request.ActorID)
+	if err != nil {
+		return err
+	}
+	member, err := getMember(ctx, tx, request.WorkspaceID, request.CustomerID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.AtLeast(RoleAdmin) ||
+		!actorRole.AtLeast(member.Role) ||
+		!actorRole.AtLeast(request.Role) {
+		return ErrInsufficientRole
+	}
+	if member.Role == RoleOwner && request.Role != RoleOwner {
+		if err = ensureAnotherOwner(ctx, tx, request.WorkspaceID, request.CustomerID); err != nil {
+			return err
+		}
+	}
+
+	if err = upsertMember(ctx, tx, request.WorkspaceID, request.CustomerID, request.Role); err != nil {
+		return fmt.Errorf("updating member: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) RemoveMember(ctx context.Context, request RemoveMemberRequest) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	actorRole, err := getActorRole(ctx, tx, request.WorkspaceID, request.ActorID)
+	if err != nil {
+		return err
+	}
+	member, err := getMember(ctx, tx, request.WorkspaceID, request.CustomerID)
+	if err != nil {
+		return err
+	}
+	if request.ActorID != request.CustomerID &&
+		(!actorRole.AtLeast(RoleAdmin) || !actorRole.AtLeast(member.Role)) {
+		return ErrInsufficientRole
+	}
+	if member.Role == RoleOwner {
+		if err = ensureAnotherOwner(ctx, tx, request.WorkspaceID, request.CustomerID); err != nil {
+			return err
+		}
+	}
+
+	if err = deleteMembers(ctx, tx, deleteMembersParams{
+		WorkspaceID: uuid.NullUUID{UUID: request.WorkspaceID, Valid: true},
+		CustomerID:  request.CustomerID,
+	}); err != nil {
+		return fmt.Errorf("deleting member: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+	return nil
+}
+
+func (s *srv) RemoveCustomer(
+	ctx context.Context, tx database.Q, request RemoveCustomerRequest,
+) error {
+	if tx == nil {
+		tx = s.db
+	}
+	if err := deleteMembers(ctx, tx, deleteMembersParams{
+		CustomerID: request.CustomerID,
+	}); err != nil {
+		return fmt.Errorf("deleting memberships: %w", err)
+	}
+	if err := promoteOwnerlessWorkspaces(ctx, tx); err != nil {
+		return fmt.Errorf("promoting new owners: %w", err)
+	}
+	return nil
+}
+
+func getMember(
+	ctx context.Context, tx database.Q, workspaceID uuid.UUID, customerID uuid.UUID,
+) (*member, error) {
+	members, err := getMembersByFilter(ctx, tx, getMembersByFilterParams{
+		WorkspaceIDs: []uuid.UUID{workspaceID},
+		CustomerIDs:  []uuid.UUID{customerID},
+	}, DBLockForUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("getting members: %w", err)
+	}
+	if len(members) != 1 {
+		return nil, ErrMemberNotFound
+	}
+	return &members[0], nil
+}
+
+// ensureAnotherOwner returns ErrLastOwner unless the workspace has an owner
+// other than customerID.
+func ensureAnotherOwner(
+	ctx context.Context, tx database.Q, workspaceID uuid.UUID, customerID uuid.UUID,
+) error {
+	members, err := getMembersByFilter(ctx, tx, getMembersByFilterParams{
+		WorkspaceIDs: []uuid.UUID{workspaceID},
+	}, DBLockUnspecified)
+	if err != nil {
+		return fmt.Errorf("getting members: %w", err)
+	}
+	for _, m := range members {
+		if m.Role == RoleOwner && m.CustomerID != customerID {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is what's stored instead of a token, so a leaked table can't be
+// used to join a workspace.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"quorum-api/database"
+	srvcommunications "quorum-api/services/communications"
+	srvcustomer "quorum-api/services/customer"
+	srvpost "quorum-api/services/post"
+
+	"github.com/google/uuid"
+)
+
+const lifecycleBatchSize = 100
+
+// Lifecycle records posts opening and closing, and emails authors their
+// results once a post closes.
+type Lifecycle struct {
+	Post           srvpost.SRVPost
+	Customer       srvcustomer.SRVCustomer
+	Communications srvcommunications.SRVCommunications
+	FrontendURL    string
+}
+
+func (l *Lifecycle) Run(ctx context.Context) error {
+	for {
+		transitions, err := l.Post.RecordStatusTransitions(
+			ctx, srvpost.RecordStatusTransitionsRequest{
+				Limit:             lifecycleBatchSize,
+				PrepareTransition: l.prepareResultsEmail,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("recording status transitions: %w", err)
+		}
+		if len(transitions) < lifecycleBatchSize {
+			return nil
+		}
+	}
+}
+
+// prepareResultsEmail builds the results email before the post is locked,
+// and writes it to the outbox in the same tx as the transition, so it's only
+// recorded once the email is queued.
+func (l *Lifecycle) prepareResultsEmail(
+	ctx context.Context, t srvpost.StatusTransition,
+) (srvpost.TransitionEffect, error) {
+	if t.ToStatus != srvpost.PostStatusClosed {
+		return nil, nil
+	}
+
+	customers, err := l.Customer.GetCustomersByFilter(
+		ctx, srvcustomer.GetCustomersByFilterRequest{
+			IDs: []uuid.UUID{t.AuthorID},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting author: %w", err)
+	}
+	if len(customers) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 author, got %v", len(customers))
+	}
+	author := customers[0]
+	if author.DeletedAt != nil {
+		return nil, nil
+	}
+
+	results, err := l.Post.GetResultsByFilter(
+		ctx, srvpost.GetResultsByFilterRequest{
+			PostIDs: []uuid.UUID{t.PostID},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting results: %w", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 result, got %v", len(results))
+	}
+
+	options, err := l.Post.GetOptionsByFilter(
+		ctx, srvpost.GetOptionsByFilterRequest{
+			PostIDs: []uuid.UUID{t.PostID},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting options: %w", err)
+	}
+
+	optionVariables := []map[string]interface{}{}
+	winningPosition := 0
+	for _, o := range options {
+		optionResult := results[0].Option(o.ID)
+		if optionResult == nil {
+			continue
+		}
+		if results[0].WinningOptionID != nil && *results[0].WinningOptionID == o.ID {
+			winningPosition = o.Position
+		}
+		optionVariables = append(optionVariables, map[string]interface{}{
+			"position":        o.Position,
+			"url":             o.URLs[srvpost.ImageSizeMedium],
+			"vote_count":      optionResult.VoteCount,
+			"vote_percentage": fmt.Sprintf("%.0f", optionResult.VotePercentage),
+		})
+	}
+
+	firstName := ""
+	if author.FirstName != nil {
+		firstName = *author.FirstName
+	}
+
+	email := srvcommunications.EnqueueEmailRequest{
+		IdempotencyKey: fmt.Sprintf("post-results:%s", t.PostID),
+		ToEmail:        author.Email,
+		Template:       srvcommunications.TemplatePostResults,
+		Subject:        "Voting has closed on your Quorum post",
+		Variables: map[string]interface{}{
+			"first_name":       firstName,
+			"post_link":        fmt.Sprintf("%s/post/%s", l.FrontendURL, t.PostID),
+			"total_votes":      results[0].TotalVotes,
+			"winning_position": winningPosition,
+			"options":          optionVariables,
+		},
+	}
+	return func(ctx context.Context, tx database.Q) error {
+		return l.Communications.EnqueueEmail(ctx, tx, email)
+	}, nil
+}
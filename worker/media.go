@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	srvpost "quorum-api/services/post"
+)
+
+// Images are held in memory while they're processed, so batches are small
+const mediaBatchSize = 10
+
+// Media makes resized renditions of option uploads, retrying failures with
+// backoff until they're given up on.
+type Media struct {
+	Post srvpost.SRVPost
+}
+
+func (m *Media) Run(ctx context.Context) error {
+	for {
+		res, err := m.Post.ProcessPendingOptions(
+			ctx, srvpost.ProcessPendingOptionsRequest{
+				Limit: mediaBatchSize,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("processing pending options: %w", err)
+		}
+		if res.Failed > 0 {
+			log.Printf("media: %v options processed, %v failed", res.Processed, res.Failed)
+		}
+		if res.Processed+res.Failed < mediaBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	srvpost "quorum-api/services/post"
+	"time"
+)
+
+// Uploads are only swept once they've been orphaned for a day, so clients
+// have plenty of time to attach them to a post.
+const orphanGracePeriod = 24 * time.Hour
+
+// Orphans deletes option files in the blob store that no option uses, logging
+// each one. With DryRun set it only logs what it would delete.
+type Orphans struct {
+	Post   srvpost.SRVPost
+	DryRun bool
+}
+
+func (o *Orphans) Run(ctx context.Context) error {
+	res, err := o.Post.SweepOrphanedFiles(ctx, srvpost.SweepOrphanedFilesRequest{
+		GracePeriod: orphanGracePeriod,
+		DryRun:      o.DryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("sweeping orphaned files: %w", err)
+	}
+	action := "deleted"
+	if res.DryRun {
+		action = "would delete"
+	}
+	for _, file := range res.Deleted {
+		log.Printf("orphans: %s %s (%v bytes, uploaded %s)",
+			action, file.Key, file.Size, file.UploadedAt.Format(time.RFC3339),
+		)
+	}
+	if len(res.Deleted) > 0 || res.Failed > 0 {
+		log.Printf("orphans: %s %v files, %v failed", action, len(res.Deleted), res.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	srvcommunications "quorum-api/services/communications"
+)
+
+const outboxBatchSize = 50
+
+// Outbox delivers emails written to the outbox, retrying failed sends with
+// backoff until they're dead lettered.
+type Outbox struct {
+	Communications srvcommunications.SRVCommunications
+}
+
+func (o *Outbox) Run(ctx context.Context) error {
+	for {
+		res, err := o.Communications.DeliverPendingEmails(
+			ctx, srvcommunications.DeliverPendingEmailsRequest{
+				Limit: outboxBatchSize,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("delivering pending emails: %w", err)
+		}
+		if res.Failed > 0 {
+			log.Printf("outbox: %v emails sent, %v failed", res.Sent, res.Failed)
+		}
+		if res.Sent+res.Failed < outboxBatchSize {
+			return nil
+		}
+	}
+}
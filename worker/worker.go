@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs job straight away and then every interval until ctx is done.
+// Failed runs are logged and retried on the next tick.
+func Every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("worker %s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}